@@ -1,6 +1,8 @@
 package cli
 
 import (
+	"fmt"
+	"github.com/42milez/ProtocolStack/src/ctl"
 	psErr "github.com/42milez/ProtocolStack/src/error"
 	psLog "github.com/42milez/ProtocolStack/src/log"
 	"github.com/42milez/ProtocolStack/src/monitor"
@@ -23,8 +25,18 @@ import (
 const serviceTimeout = 3 * time.Second
 
 var sigCh chan os.Signal
+var arpCacheSize int
+var captureFile string
+var ctlSocket string
+var tapQueues int
+var tapVnetHdr bool
+var tapIoUring bool
+var tapPromisc bool
+var tapQdisc string
+var tapRate int
 
 var arpWg sync.WaitGroup
+var ctlWg sync.WaitGroup
 var ethWg sync.WaitGroup
 var icmpWg sync.WaitGroup
 var ipWg sync.WaitGroup
@@ -32,12 +44,24 @@ var monitorWg sync.WaitGroup
 var repoWg sync.WaitGroup
 var tcpWg sync.WaitGroup
 
-func setup() error {
+// setup creates devices and starts services. The TAP device is created only when tap is true so that the stack can
+// communicate over the loopback device without it.
+func setup(tap bool) error {
 	psLog.D(
 		"-------------------------------------------------------",
 		"          I N I T I A L I Z E   D E V I C E S          ",
 		"-------------------------------------------------------")
 
+	if captureFile != "" {
+		if err := mw.StartCapture(captureFile); err != psErr.OK {
+			return psErr.Error
+		}
+	}
+
+	if err := arp.SetCacheSize(arpCacheSize); err != psErr.OK {
+		return psErr.Error
+	}
+
 	// Create a loopback device and its iface, then link them.
 	loopbackDev := eth.GenLoopbackDevice("net" + strconv.Itoa(repo.DeviceRepo.NextNumber()))
 	if err := repo.DeviceRepo.Register(loopbackDev); err != psErr.OK {
@@ -51,23 +75,38 @@ func setup() error {
 
 	repo.RouteRepo.Register(mw.ParseIP(mw.LoopbackNetwork), mw.V4Any, iface1)
 
-	// Create a TAP device and its interface, then link them.
-	tapDev := eth.GenTapDevice(
-		"net"+strconv.Itoa(repo.DeviceRepo.NextNumber()),
-		"tap0",
-		eth.HwAddr)
-	if err := repo.DeviceRepo.Register(tapDev); err != psErr.OK {
-		return psErr.Error
-	}
+	if tap {
+		// Create a TAP device and its interface, then link them.
+		tapDev := eth.GenTapDevice(
+			"net"+strconv.Itoa(repo.DeviceRepo.NextNumber()),
+			"tap0",
+			eth.HwAddr)
+		tapDev.Queues = tapQueues
+		tapDev.VnetHdr = tapVnetHdr
+		if tapIoUring {
+			tapDev.Backend = eth.IoUringBackend
+		}
+		if err := repo.DeviceRepo.Register(tapDev); err != psErr.OK {
+			return psErr.Error
+		}
+		if tapPromisc {
+			if err := tapDev.SetPromisc(true); err != psErr.OK {
+				return psErr.Error
+			}
+		}
+		if err := setQdisc(tapDev); err != psErr.OK {
+			return psErr.Error
+		}
 
-	iface2 := net.GenIface("192.0.2.2", "255.255.255.0", "192.0.2.255")
-	if err := repo.IfaceRepo.Register(iface2, tapDev); err != psErr.OK {
-		return psErr.Error
-	}
+		iface2 := net.GenIface("192.0.2.2", "255.255.255.0", "192.0.2.255")
+		if err := repo.IfaceRepo.Register(iface2, tapDev); err != psErr.OK {
+			return psErr.Error
+		}
 
-	repo.RouteRepo.Register(mw.ParseIP("192.0.0.0"), mw.V4Any, iface2)
+		repo.RouteRepo.Register(mw.ParseIP("192.0.0.0"), mw.V4Any, iface2)
 
-	repo.RouteRepo.RegisterDefaultGateway(iface2, mw.ParseIP("192.0.2.1"))
+		repo.RouteRepo.RegisterDefaultGateway(iface2, mw.ParseIP("192.0.2.1"))
+	}
 
 	psLog.D(
 		"-------------------------------------------------------",
@@ -78,6 +117,11 @@ func setup() error {
 		return psErr.Error
 	}
 
+	// the stack works without the control socket, but it can't be operated by the other commands
+	if err := ctl.Start(&ctlWg, ctlSocket); err != psErr.OK {
+		psLog.W("control socket isn't available")
+	}
+
 	psLog.D(
 		"///////////////////////////////////////////////////////",
 		"         A P P L I C A T I O N   S T A R T E D         ",
@@ -86,6 +130,40 @@ func setup() error {
 	return psErr.OK
 }
 
+// setQdisc attaches the queueing discipline chosen with the flags to the device. Frames are sent synchronously when no
+// discipline is chosen.
+func setQdisc(dev mw.IDevice) error {
+	switch tapQdisc {
+	case "":
+	case "fifo":
+		mw.SetQdisc(dev, eth.GenFifoQdisc())
+	case "prio":
+		mw.SetQdisc(dev, eth.GenPrioQdisc())
+	case "tbf":
+		if tapRate <= 0 {
+			psLog.E(fmt.Sprintf("invalid rate: %d", tapRate))
+			return psErr.Error
+		}
+		// the bucket holds the bytes of 100ms, and the largest frame at least
+		burst := tapRate / 10
+		if frameLenMax := mw.EthFrameLenMax + mw.VlanTagLen; burst < frameLenMax {
+			burst = frameLenMax
+		}
+		if frameLenMax := mw.EthHdrLen + mw.VlanTagLen + ip.TotalLenMax; tapVnetHdr && burst < frameLenMax {
+			burst = frameLenMax
+		}
+		qdisc := eth.GenTbfQdisc(tapRate, burst)
+		if qdisc == nil {
+			return psErr.Error
+		}
+		mw.SetQdisc(dev, qdisc)
+	default:
+		psLog.E(fmt.Sprintf("unknown queueing discipline: %s", tapQdisc))
+		return psErr.Error
+	}
+	return psErr.OK
+}
+
 func startServices() error {
 	if err := arp.Start(&arpWg); err != psErr.OK {
 		return psErr.Error
@@ -126,6 +204,9 @@ func startServices() error {
 }
 
 func stopServices() {
+	ctl.Stop()
+	ctlWg.Wait()
+
 	arp.Stop()
 	eth.Stop()
 	icmp.Stop()
@@ -141,6 +222,8 @@ func stopServices() {
 	monitorWg.Wait()
 	repoWg.Wait()
 	tcpWg.Wait()
+
+	_ = mw.StopCapture()
 }
 
 func init() {
@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/42milez/ProtocolStack/src/ctl"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/42milez/ProtocolStack/src/net/arp"
+	"github.com/spf13/cobra"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+var arpDev string
+
+var arpCmd = &cobra.Command{
+	Use:   "arp",
+	Short: "show the ARP cache of a running stack",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		request("arp", "list")
+	},
+}
+
+var arpListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "show the ARP cache of a running stack",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		request("arp", "list")
+	},
+}
+
+var arpAddCmd = &cobra.Command{
+	Use:   "add <address> <hwaddress>",
+	Short: "add a static entry which is never expired (to the device of the route to the address by default)",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 2 {
+			return errors.New("requires an address and a hardware address")
+		}
+		if mw.ParseIP(args[0]) == nil {
+			return fmt.Errorf("invalid address: %s", args[0])
+		}
+		if _, err := mw.ParseEthAddr(args[1]); err != psErr.OK {
+			return fmt.Errorf("invalid hardware address: %s", args[1])
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		request("arp", "add", args[0], args[1], arpDev)
+	},
+}
+
+var arpDeleteCmd = &cobra.Command{
+	Use:   "delete <address>",
+	Short: "delete the entry of the address (of all the devices by default)",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("requires an address")
+		}
+		if mw.ParseIP(args[0]) == nil {
+			return fmt.Errorf("invalid address: %s", args[0])
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		request("arp", "delete", args[0], arpDev)
+	},
+}
+
+var arpFlushCmd = &cobra.Command{
+	Use:   "flush",
+	Short: "delete all the entries except the static ones",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		request("arp", "flush")
+	},
+}
+
+// request sends the arguments to the running stack and prints the output. It exits when the request fails.
+func request(args ...string) {
+	out, err := ctl.Request(ctlSocket, args)
+	if out != "" {
+		fmt.Print(out)
+	}
+	if err == psErr.CantOpenIOResource {
+		_, _ = fmt.Fprintf(os.Stderr, "no stack is listening to %s\n", ctlSocket)
+		os.Exit(1)
+	}
+	if err != psErr.OK {
+		_, _ = fmt.Fprintf(os.Stderr, "request failed: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// handleArp processes the requests of the arp command in the running stack.
+func handleArp(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", psErr.InvalidFormat
+	}
+
+	switch args[0] {
+	case "list":
+		return formatArpEntries(arp.Entries()), psErr.OK
+	case "add":
+		if len(args) != 4 {
+			return "", psErr.InvalidFormat
+		}
+		ha, err := mw.ParseEthAddr(args[2])
+		if err != psErr.OK {
+			return "", err
+		}
+		return "", arp.AddStatic(args[3], mw.ParseIP(args[1]), ha)
+	case "delete":
+		if len(args) != 3 {
+			return "", psErr.InvalidFormat
+		}
+		if err := arp.Delete(args[2], mw.ParseIP(args[1])); err != psErr.OK {
+			return fmt.Sprintf("no entry for %s\n", args[1]), err
+		}
+		return "", psErr.OK
+	case "flush":
+		return fmt.Sprintf("%d entries were flushed\n", arp.Flush()), psErr.OK
+	default:
+		return fmt.Sprintf("unknown subcommand: %s\n", args[0]), psErr.NotFound
+	}
+}
+
+func formatArpEntries(entries []arp.Entry) string {
+	buf := new(bytes.Buffer)
+	w := tabwriter.NewWriter(buf, 0, 8, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "Address\tHWaddress\tStatus\tAge\tDevice")
+	for _, v := range entries {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			mw.IP(v.PA[:]), v.HA, v.Status, v.Age.Truncate(time.Second), v.Dev)
+	}
+	_ = w.Flush()
+	return buf.String()
+}
+
+func init() {
+	rootCmd.AddCommand(arpCmd)
+	arpCmd.AddCommand(arpListCmd, arpAddCmd, arpDeleteCmd, arpFlushCmd)
+	arpCmd.PersistentFlags().StringVar(&ctlSocket, "socket", ctl.SocketPath, "send requests to the stack listening to <socket>")
+	arpAddCmd.Flags().StringVar(&arpDev, "dev", "", "add the entry to <dev>")
+	arpDeleteCmd.Flags().StringVar(&arpDev, "dev", "", "delete the entry of <dev> only")
+	ctl.Handle("arp", handleArp)
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"github.com/42milez/ProtocolStack/src/ctl"
 	psErr "github.com/42milez/ProtocolStack/src/error"
 	psLog "github.com/42milez/ProtocolStack/src/log"
 	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/42milez/ProtocolStack/src/net/arp"
 	"github.com/42milez/ProtocolStack/src/net/icmp"
 	"github.com/spf13/cobra"
 	"syscall"
@@ -29,7 +31,7 @@ var pingCmd = &cobra.Command{
 		return nil
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := setup(); err != psErr.OK {
+		if err := setup(!isLoopback(mw.ParseIP(dst))); err != psErr.OK {
 			psLog.F("initialization failed")
 		}
 		var nReplied int
@@ -52,6 +54,9 @@ var pingCmd = &cobra.Command{
 				handleReply(reply)
 				nReplied += 1
 				skipNextRequest = false
+			case u := <-icmp.UnreachableQueue:
+				handleUnreachable(u)
+				skipNextRequest = false
 			case letter := <-mw.IcmpDeadLetterQueue:
 				time.Sleep(100 * time.Millisecond)
 				handleDeadLetter(letter)
@@ -100,7 +105,7 @@ func send(id uint16, seq uint16, payload []byte) {
 		Code:    0,
 		Content: uint32(id)<<16 | uint32(seq),
 		Data:    payload,
-		Src:     mw.IP{192, 0, 2, 2},
+		Src:     mw.V4Any,
 		Dst:     mw.ParseIP(dst),
 	}
 	mw.IcmpTxCh <- msg
@@ -113,12 +118,24 @@ func handleDeadLetter(letter *mw.IcmpQueueEntry) {
 	psLog.W(fmt.Sprintf("icmp packet sent (dead letter): id=%d, seq=%d", id, seq))
 }
 
+// isLoopback reports whether the address belongs to the loopback network.
+func isLoopback(ip mw.IP) bool {
+	return ip.Mask(mw.ParseIP(mw.LoopbackNetmask)).Equal(mw.ParseIP(mw.LoopbackNetwork))
+}
+
 func handleReply(reply *icmp.Reply) {
 	psLog.I(fmt.Sprintf("icmp packet received: seq=%d, id=%d", reply.Seq, reply.ID))
 }
 
+func handleUnreachable(u *icmp.Unreachable) {
+	psLog.W(fmt.Sprintf("destination host unreachable: %s, seq=%d, id=%d", u.Dst, u.Seq, u.ID))
+}
+
 func init() {
 	provider = provider_{}
 	rootCmd.AddCommand(pingCmd)
 	pingCmd.PersistentFlags().IntVarP(&count, "count", "c", 0, "stop after <count> replies")
+	pingCmd.PersistentFlags().IntVar(&arpCacheSize, "arp-cache-size", arp.DefaultCacheSize, "hold up to <arp-cache-size> neighbors")
+	pingCmd.PersistentFlags().StringVar(&captureFile, "capture", "", "write frames into <file> in pcapng format")
+	pingCmd.PersistentFlags().StringVar(&ctlSocket, "socket", ctl.SocketPath, "accept requests of the other commands on <socket>")
 }
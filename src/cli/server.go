@@ -2,9 +2,11 @@ package cli
 
 import (
 	"fmt"
+	"github.com/42milez/ProtocolStack/src/ctl"
 	psErr "github.com/42milez/ProtocolStack/src/error"
 	psLog "github.com/42milez/ProtocolStack/src/log"
 	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/42milez/ProtocolStack/src/net/arp"
 	"github.com/42milez/ProtocolStack/src/net/tcp"
 	"github.com/spf13/cobra"
 	"os"
@@ -18,7 +20,7 @@ var serverCmd = &cobra.Command{
 	Use:   "server",
 	Short: "a simple echo server",
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := setup(); err != psErr.OK {
+		if err := setup(true); err != psErr.OK {
 			psLog.F("initialization failed")
 		}
 
@@ -66,4 +68,13 @@ var serverCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(serverCmd)
+	serverCmd.PersistentFlags().IntVar(&arpCacheSize, "arp-cache-size", arp.DefaultCacheSize, "hold up to <arp-cache-size> neighbors")
+	serverCmd.PersistentFlags().StringVar(&captureFile, "capture", "", "write frames into <file> in pcapng format")
+	serverCmd.PersistentFlags().StringVar(&ctlSocket, "socket", ctl.SocketPath, "accept requests of the other commands on <socket>")
+	serverCmd.PersistentFlags().IntVar(&tapQueues, "queues", 1, "read the TAP device with <queues> receive workers")
+	serverCmd.PersistentFlags().BoolVar(&tapVnetHdr, "offload", false, "offload checksums and segmentation of the TAP device to the kernel")
+	serverCmd.PersistentFlags().BoolVar(&tapIoUring, "io-uring", false, "read and write the TAP device through io_uring")
+	serverCmd.PersistentFlags().BoolVar(&tapPromisc, "promisc", false, "receive all the frames which arrive at the TAP device")
+	serverCmd.PersistentFlags().StringVar(&tapQdisc, "qdisc", "", "queue frames of the TAP device with <qdisc> (fifo, prio or tbf)")
+	serverCmd.PersistentFlags().IntVar(&tapRate, "rate", 0, "limit the TAP device to <rate> bytes per second (tbf)")
 }
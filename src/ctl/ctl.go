@@ -0,0 +1,182 @@
+package ctl
+
+import (
+	"encoding/json"
+	"fmt"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// SocketPath is the default path of the control socket.
+const SocketPath = "/run/pstack/pstack.sock"
+
+const requestTimeout = 3 * time.Second
+
+var handlers map[string]Handler
+var handlerMtx sync.RWMutex
+
+var listener net.Listener
+var listenerPath string
+
+// Handler processes a request sent to a running stack. The output is written to the terminal of the requester.
+type Handler func(args []string) (string, error)
+
+type request struct {
+	Args []string
+}
+
+type response struct {
+	Output string
+	Err    psErr.Err
+}
+
+// Handle registers the handler of the requests of which first argument is the name.
+func Handle(name string, h Handler) {
+	handlerMtx.Lock()
+	defer handlerMtx.Unlock()
+	handlers[name] = h
+}
+
+// Start listens to the control socket so that other processes can operate the stack. It fails when another stack is
+// listening to the socket. The directory of the socket is created with 0700 if it doesn't exist, and it must be owned
+// by the user of the stack and inaccessible to the others, since the stack runs with privileges.
+func Start(wg *sync.WaitGroup, path string) error {
+	if err := makeSocketDir(filepath.Dir(path)); err != psErr.OK {
+		return err
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		_ = conn.Close()
+		psLog.E(fmt.Sprintf("another stack is listening to %s", path))
+		return psErr.Exist
+	}
+	// the socket is left when the stack which listened to it didn't stop normally
+	_ = os.Remove(path)
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		psLog.E(fmt.Sprintf("can't listen to %s: %s", path, err))
+		return psErr.CantCreateEndpoint
+	}
+	listener = l
+	listenerPath = path
+
+	wg.Add(1)
+	go serve(wg, l)
+
+	psLog.D("control service started", "socket: "+path)
+
+	return psErr.OK
+}
+
+// Stop closes the control socket.
+func Stop() {
+	if listener == nil {
+		return
+	}
+	_ = listener.Close()
+	_ = os.Remove(listenerPath)
+	listener = nil
+}
+
+// makeSocketDir creates the directory of the socket. The socket is protected by the directory rather than its own mode,
+// so that nobody else can connect to it even before its mode is changed.
+func makeSocketDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		psLog.E(fmt.Sprintf("can't create %s: %s", dir, err))
+		return psErr.CantCreateEndpoint
+	}
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		psLog.E(fmt.Sprintf("can't stat %s: %s", dir, err))
+		return psErr.CantCreateEndpoint
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !fi.IsDir() || !ok || int(st.Uid) != os.Geteuid() || fi.Mode().Perm()&0077 != 0 {
+		psLog.E(fmt.Sprintf("%s must be a directory which only the owner can access: %s", dir, fi.Mode()))
+		return psErr.CantCreateEndpoint
+	}
+	return psErr.OK
+}
+
+// Request sends the arguments to the stack listening to the socket and returns the output.
+func Request(path string, args []string) (string, error) {
+	conn, err := net.DialTimeout("unix", path, requestTimeout)
+	if err != nil {
+		return "", psErr.CantOpenIOResource
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	if err := json.NewEncoder(conn).Encode(&request{Args: args}); err != nil {
+		return "", psErr.WriteToBufError
+	}
+	var res response
+	if err := json.NewDecoder(conn).Decode(&res); err != nil {
+		return "", psErr.ReadFromBufError
+	}
+
+	return res.Output, res.Err
+}
+
+func serve(wg *sync.WaitGroup, l net.Listener) {
+	defer func() {
+		psLog.D("control service stopped")
+		wg.Done()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			// the listener has been closed
+			return
+		}
+		handle(conn)
+	}
+}
+
+// handle processes a request. Requests are processed one at a time.
+func handle(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	var req request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		psLog.W(fmt.Sprintf("invalid control request: %s", err))
+		return
+	}
+
+	res := response{Err: psErr.OK}
+	if len(req.Args) == 0 {
+		res.Err = psErr.InvalidFormat
+	} else {
+		handlerMtx.RLock()
+		h := handlers[req.Args[0]]
+		handlerMtx.RUnlock()
+		if h == nil {
+			res.Output = fmt.Sprintf("unknown command: %s", req.Args[0])
+			res.Err = psErr.NotFound
+		} else {
+			var err error
+			res.Output, err = h(req.Args[1:])
+			if v, ok := err.(psErr.Err); ok {
+				res.Err = v
+			} else {
+				res.Err = psErr.Error
+			}
+		}
+	}
+
+	if err := json.NewEncoder(conn).Encode(&res); err != nil {
+		psLog.W(fmt.Sprintf("can't send control response: %s", err))
+	}
+}
+
+func init() {
+	handlers = make(map[string]Handler)
+}
@@ -0,0 +1,130 @@
+package ctl
+
+import (
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func startCtl(t *testing.T) (path string, teardown func()) {
+	psLog.DisableOutput()
+	dir, err := ioutil.TempDir("", "ctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path = filepath.Join(dir, "pstack.sock")
+
+	var wg sync.WaitGroup
+	if err := Start(&wg, path); err != psErr.OK {
+		t.Fatalf("Start() = %s; want %s", err, psErr.OK)
+	}
+	teardown = func() {
+		Stop()
+		wg.Wait()
+		_ = os.RemoveAll(dir)
+		psLog.EnableOutput()
+	}
+	return
+}
+
+// Success when the request is passed to the handler and its output is returned.
+func TestRequest_1(t *testing.T) {
+	path, teardown := startCtl(t)
+	defer teardown()
+
+	Handle("echo", func(args []string) (string, error) {
+		return strings.Join(args, " "), psErr.OK
+	})
+
+	got, err := Request(path, []string{"echo", "a", "b"})
+	if err != psErr.OK {
+		t.Fatalf("Request() = %s; want %s", err, psErr.OK)
+	}
+	if want := "a b"; got != want {
+		t.Errorf("Request() = %q; want %q", got, want)
+	}
+}
+
+// Fail when no handler is registered for the request.
+func TestRequest_2(t *testing.T) {
+	path, teardown := startCtl(t)
+	defer teardown()
+
+	if _, err := Request(path, []string{"unknown"}); err != psErr.NotFound {
+		t.Errorf("Request() = %s; want %s", err, psErr.NotFound)
+	}
+}
+
+// Fail when no stack is listening to the socket.
+func TestRequest_3(t *testing.T) {
+	path, teardown := startCtl(t)
+	teardown()
+
+	if _, err := Request(path, []string{"echo"}); err != psErr.CantOpenIOResource {
+		t.Errorf("Request() = %s; want %s", err, psErr.CantOpenIOResource)
+	}
+}
+
+// Success when the directory of the socket is created so that only the owner can access it.
+func TestStart_1(t *testing.T) {
+	psLog.DisableOutput()
+	defer psLog.EnableOutput()
+
+	dir, err := ioutil.TempDir("", "ctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sockDir := filepath.Join(dir, "pstack")
+
+	var wg sync.WaitGroup
+	if err := Start(&wg, filepath.Join(sockDir, "pstack.sock")); err != psErr.OK {
+		t.Fatalf("Start() = %s; want %s", err, psErr.OK)
+	}
+	Stop()
+	wg.Wait()
+
+	fi, err := os.Stat(sockDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fi.Mode().Perm(); got != 0700 {
+		t.Errorf("mode of %s = %s; want %s", sockDir, got, os.FileMode(0700))
+	}
+}
+
+// Fail when another stack is listening to the socket.
+func TestStart_2(t *testing.T) {
+	path, teardown := startCtl(t)
+	defer teardown()
+
+	var wg sync.WaitGroup
+	if err := Start(&wg, path); err != psErr.Exist {
+		t.Errorf("Start() = %s; want %s", err, psErr.Exist)
+	}
+}
+
+// Fail when the directory of the socket can be accessed by the other users.
+func TestStart_3(t *testing.T) {
+	psLog.DisableOutput()
+	defer psLog.EnableOutput()
+
+	dir, err := ioutil.TempDir("", "ctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Chmod(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	if err := Start(&wg, filepath.Join(dir, "pstack.sock")); err != psErr.CantCreateEndpoint {
+		t.Errorf("Start() = %s; want %s", err, psErr.CantCreateEndpoint)
+	}
+}
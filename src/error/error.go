@@ -1,7 +1,8 @@
 package error
 
 const (
-	AlreadyBound Err = iota
+	AddressTentative Err = iota
+	AlreadyBound
 	ArpIncomplete
 	BacklogFull
 	CantAllocatePcb
@@ -11,11 +12,14 @@ const (
 	CantModifyIOResourceParameter
 	CantOpenIOResource
 	ChecksumMismatch
+	ConnectionRefused
 	DeviceNotOpened
 	Error
 	Exist
+	HostUnreachable
 	InterfaceNotFound
 	Interrupted
+	InvalidFormat
 	InvalidPacket
 	InvalidPacketLength
 	InvalidPcbState
@@ -30,12 +34,14 @@ const (
 	ReadFromBufError
 	RouteNotFound
 	SyscallError
+	Timeout
 	TtlExpired
 	UnsupportedProtocol
 	WriteToBufError
 )
 
 var errors = map[Err]string{
+	AddressTentative:              "ADDRESS_TENTATIVE",
 	AlreadyBound:                  "ALREADY_BOUND",
 	ArpIncomplete:                 "ARP_INCOMPLETE",
 	BacklogFull:                   "BACKLOG_FULL",
@@ -46,11 +52,14 @@ var errors = map[Err]string{
 	CantModifyIOResourceParameter: "CANT_MODIFY_IO_RESOURCE_PARAMETER",
 	CantOpenIOResource:            "CANT_OPEN_IO_RESOURCE",
 	ChecksumMismatch:              "CHECKSUM_MISMATCH",
+	ConnectionRefused:             "CONNECTION_REFUSED",
 	DeviceNotOpened:               "DEVICE_NOT_OPENED",
 	Error:                         "ERROR",
 	Exist:                         "EXIST",
+	HostUnreachable:               "HOST_UNREACHABLE",
 	InterfaceNotFound:             "INTERFACE_NOT_FOUND",
 	Interrupted:                   "INTERRUPTED",
+	InvalidFormat:                 "INVALID_FORMAT",
 	InvalidPacket:                 "INVALID_PACKET",
 	InvalidPacketLength:           "INVALID_PACKET_LENGTH",
 	InvalidPcbState:               "INVALID_PCB_STATE",
@@ -65,6 +74,7 @@ var errors = map[Err]string{
 	ReadFromBufError:              "READ_FROM_BUFFER_ERROR",
 	RouteNotFound:                 "ROUTE_NOT_FOUND",
 	SyscallError:                  "SYSTEM_CALL_ERROR",
+	Timeout:                       "TIMEOUT",
 	TtlExpired:                    "TTL_EXPIRED",
 	UnsupportedProtocol:           "UNSUPPORTED_PROTOCOL",
 	WriteToBufError:               "WRITE_TO_BUFFER_ERROR",
@@ -111,6 +111,13 @@ func DisableDebug() {
 	debug = false
 }
 
+// IsDebug reports whether debug messages are printed, so that building expensive arguments can be skipped.
+func IsDebug() bool {
+	defer mtx.Unlock()
+	mtx.Lock()
+	return debug
+}
+
 func EnableOutput() {
 	resetOutput()
 }
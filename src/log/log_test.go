@@ -41,6 +41,18 @@ func TestD_2(t *testing.T) {
 	}
 }
 
+func TestIsDebug(t *testing.T) {
+	defer EnableDebug()
+
+	if got := IsDebug(); got != true {
+		t.Errorf("IsDebug() = %t; want %t", got, true)
+	}
+	DisableDebug()
+	if got := IsDebug(); got != false {
+		t.Errorf("IsDebug() = %t; want %t", got, false)
+	}
+}
+
 func TestI(t *testing.T) {
 	want, _ := regexp.Compile(`^[0-9]{2}:[0-9]{2}:[0-9]{2}\.[0-9]{3} \[I] Info$`)
 	got := CaptureLogOutput(func() {
@@ -0,0 +1,98 @@
+package mw
+
+import (
+	"fmt"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/pcap"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+var capturer *capturer_
+var capturerMtx sync.Mutex
+
+type capturer_ struct {
+	w      *pcap.NgWriter
+	c      io.Closer
+	ifaces map[string]uint32 // interface id of each device
+}
+
+// StartCapture starts writing every frame read or written by devices into a pcapng file.
+func StartCapture(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		psLog.E(fmt.Sprintf("can't create capture file: %s", err))
+		return psErr.CantOpenIOResource
+	}
+
+	w, err := pcap.NewNgWriter(f)
+	if err != psErr.OK {
+		_ = f.Close()
+		return psErr.Error
+	}
+
+	capturerMtx.Lock()
+	defer capturerMtx.Unlock()
+
+	capturer = &capturer_{
+		w:      w,
+		c:      f,
+		ifaces: make(map[string]uint32),
+	}
+
+	psLog.I(fmt.Sprintf("capturing frames into %s", path))
+
+	return psErr.OK
+}
+
+// StopCapture stops capturing and closes the capture file.
+func StopCapture() error {
+	capturerMtx.Lock()
+	defer capturerMtx.Unlock()
+
+	if capturer == nil {
+		return psErr.OK
+	}
+
+	err := capturer.c.Close()
+	capturer = nil
+	if err != nil {
+		return psErr.CantCloseIOResource
+	}
+
+	return psErr.OK
+}
+
+// CaptureFrame records a frame which passes through the device. It does nothing when capturing is not started.
+func CaptureFrame(dev IDevice, dir pcap.Direction, frame []byte) {
+	capturerMtx.Lock()
+	defer capturerMtx.Unlock()
+
+	if capturer == nil {
+		return
+	}
+
+	if err := capturer.write(dev, dir, frame); err != psErr.OK {
+		psLog.W(fmt.Sprintf("can't capture frame: %s", err))
+	}
+}
+
+func (p *capturer_) write(dev IDevice, dir pcap.Direction, frame []byte) error {
+	id, ok := p.ifaces[dev.Name()]
+	if !ok {
+		// TUN device exchanges IP packets without ethernet header
+		linkType := pcap.LinkTypeEthernet
+		if dev.Type() == TunDevice {
+			linkType = pcap.LinkTypeRaw
+		}
+		var err error
+		if id, err = p.w.AddIface(dev.Name(), linkType); err != psErr.OK {
+			return err
+		}
+		p.ifaces[dev.Name()] = id
+	}
+	return p.w.WritePacket(id, time.Now(), frame, dir)
+}
@@ -0,0 +1,120 @@
+package mw
+
+import (
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	"github.com/42milez/ProtocolStack/src/pcap"
+	psSyscall "github.com/42milez/ProtocolStack/src/syscall"
+	"github.com/google/go-cmp/cmp"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestStartCapture_1(t *testing.T) {
+	ctrl, teardown := setupEthTest(t)
+	defer teardown()
+
+	dir, err := ioutil.TempDir("", "capture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "capture.pcapng")
+
+	if err := StartCapture(path); err != psErr.OK {
+		t.Fatalf("StartCapture() = %s; want %s", err, psErr.OK)
+	}
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().Write(any, any).Return(0, nil)
+	m.EXPECT().Read(any, any).Return(EthFrameLenMin, nil)
+	m.EXPECT().Read(any, any).Return(-1, syscall.EAGAIN)
+	psSyscall.Syscall = m
+
+	dev := NewMockIDevice(ctrl)
+	dev.EXPECT().Addr().Return(EthAddr{11, 12, 13, 14, 15, 16}).AnyTimes()
+	dev.EXPECT().Name().Return("net0").AnyTimes()
+	dev.EXPECT().Type().Return(EthernetDevice).AnyTimes()
+	dev.EXPECT().Flag().Return(DevFlag(0)).AnyTimes()
+
+	_ = WriteFrame(3, dev, EthBroadcast, EtARP, make([]byte, EthPayloadLenMin))
+	_, _ = ReadFrames(3, dev)
+
+	if err := StopCapture(); err != psErr.OK {
+		t.Fatalf("StopCapture() = %s; want %s", err, psErr.OK)
+	}
+
+	f, _ := os.Open(path)
+	defer f.Close()
+	r, err := pcap.NewReader(f)
+	if err != psErr.OK {
+		t.Fatalf("pcap.NewReader() = %s; want %s", err, psErr.OK)
+	}
+
+	var got []pcap.Direction
+	for {
+		packet, err := r.ReadPacket()
+		if err != psErr.OK {
+			break
+		}
+		got = append(got, packet.Direction)
+	}
+	want := []pcap.Direction{pcap.DirectionOutbound, pcap.DirectionInbound}
+	if d := cmp.Diff(got, want); d != "" {
+		t.Errorf("captured frames differ: (-got +want)\n%s", d)
+	}
+}
+
+// Success when packets of TUN device are recorded as raw IP.
+func TestCaptureFrame_1(t *testing.T) {
+	ctrl, teardown := setupEthTest(t)
+	defer teardown()
+
+	dir, err := ioutil.TempDir("", "capture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "capture.pcapng")
+
+	if err := StartCapture(path); err != psErr.OK {
+		t.Fatalf("StartCapture() = %s; want %s", err, psErr.OK)
+	}
+
+	dev := NewMockIDevice(ctrl)
+	dev.EXPECT().Name().Return("net0").AnyTimes()
+	dev.EXPECT().Type().Return(TunDevice).AnyTimes()
+
+	CaptureFrame(dev, pcap.DirectionInbound, []byte{0x45, 0x00, 0x00, 0x14})
+
+	if err := StopCapture(); err != psErr.OK {
+		t.Fatalf("StopCapture() = %s; want %s", err, psErr.OK)
+	}
+
+	f, _ := os.Open(path)
+	defer f.Close()
+	r, err := pcap.NewReader(f)
+	if err != psErr.OK {
+		t.Fatalf("pcap.NewReader() = %s; want %s", err, psErr.OK)
+	}
+	packet, err := r.ReadPacket()
+	if err != psErr.OK {
+		t.Fatalf("ReadPacket() = %s; want %s", err, psErr.OK)
+	}
+	if packet.LinkType != pcap.LinkTypeRaw {
+		t.Errorf("Packet.LinkType = %s; want %s", packet.LinkType, pcap.LinkTypeRaw)
+	}
+}
+
+// Fail when the capture file can't be created.
+func TestStartCapture_2(t *testing.T) {
+	_, teardown := setupEthTest(t)
+	defer teardown()
+
+	got := StartCapture(filepath.Join("not", "exist", "capture.pcapng"))
+	if got != psErr.CantOpenIOResource {
+		t.Errorf("StartCapture() = %s; want %s", got, psErr.CantOpenIOResource)
+	}
+}
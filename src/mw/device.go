@@ -2,25 +2,69 @@
 
 package mw
 
+import (
+	"bytes"
+	"fmt"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
 const UpFlag DevFlag = 0x0001
 const LoopbackFlag DevFlag = 0x0010
 const BroadcastFlag DevFlag = 0x0020
 const NeedArpFlag DevFlag = 0x0100
+const PromiscFlag DevFlag = 0x0200
 const (
 	EthernetDevice DevType = iota
 	LoopbackDevice
 	NullDevice
+	TunDevice
 )
 
 var devTypes = [...]string{
 	0: "Ethernet",
 	1: "Loopback",
 	2: "Null",
+	3: "TUN",
 }
 
-type DevFlag uint16
+var rxNotifier func(dev IDevice)
+var rxNotifierMtx sync.RWMutex
+
+// the multicast addresses which each device has joined and the number of times they have been joined
+var mcastAddrs map[string]map[EthAddr]int
+var mcastMtx sync.RWMutex
+
+// the number of the users of the promiscuous mode of each device
+var promiscUsers map[string]int
+var promiscMtx sync.Mutex
+
+// DevFlag is read by the workers while it's changed, so it's accessed atomically as a 32-bit word.
+type DevFlag uint32
 type DevType int
 
+// Offload is a set of the works which a device does in place of the protocol stack.
+type Offload uint8
+
+const (
+	// RxCsumOffload means that the checksums of received packets have been verified, or can be left unverified since
+	// they are partial (e.g. packets sent by the host through a TAP interface).
+	RxCsumOffload Offload = 1 << iota
+	// TxCsumOffload means that the device completes the IP header checksum and the TCP checksum of transmitted
+	// packets. The stack stores the checksum of the pseudo header in the TCP checksum field instead.
+	TxCsumOffload
+	// TsoOffload means that the device splits a TCP segment which is larger than the MTU.
+	TsoOffload
+)
+
+// Has returns true when all the works of vv are included in v.
+func (v Offload) Has(vv Offload) bool {
+	return v&vv == vv
+}
+
 func (v DevType) String() string {
 	return devTypes[v]
 }
@@ -28,10 +72,18 @@ func (v DevType) String() string {
 type IDevice interface {
 	Open() error
 	Close() error
+	// Poll reads a frame without blocking. It returns NoDataToRead when no frame is ready.
 	Poll() error
 	Transmit(dst EthAddr, payload []byte, typ EthType) error
 	Up()
 	Down()
+	// SetPromisc counts a user of the promiscuous mode in or out. The device receives all the frames regardless of
+	// their destination while the mode has any user.
+	SetPromisc(on bool) error
+	// JoinMulticast makes the device receive the frames sent to the multicast address. The address is joined until
+	// LeaveMulticast() is called as many times as JoinMulticast().
+	JoinMulticast(addr EthAddr) error
+	LeaveMulticast(addr EthAddr) error
 	Equal(dev IDevice) bool
 	IsUp() bool
 	Type() DevType
@@ -42,6 +94,18 @@ type IDevice interface {
 	Priv() Privilege
 }
 
+// IOffloader is implemented by the devices which take over some works of the protocol stack. The stack skips the
+// works which the device reports through Offload().
+type IOffloader interface {
+	Offload() Offload
+}
+
+// IFrameTransmitter is implemented by the ethernet devices which can transmit a frame built elsewhere (e.g. a frame
+// forwarded by a bridge) without rewriting its header.
+type IFrameTransmitter interface {
+	TransmitFrame(frame []byte) error
+}
+
 type Device struct {
 	Type_ DevType
 	Name_ string
@@ -52,11 +116,110 @@ type Device struct {
 }
 
 func (p *Device) Up() {
-	p.Flag_ |= UpFlag
+	p.setFlag(UpFlag, true)
 }
 
 func (p *Device) Down() {
-	p.Flag_ &= ^UpFlag
+	p.setFlag(UpFlag, false)
+}
+
+func (p *Device) SetPromisc(on bool) error {
+	return p.CountPromisc(on, nil)
+}
+
+// CountPromisc counts a user of the promiscuous mode in or out as dev_set_promiscuity() of Linux does. The mode is
+// switched only when the number of the users crosses zero, and then apply is called to switch the mode of the
+// underlying device as well. The user isn't counted when apply fails.
+func (p *Device) CountPromisc(on bool, apply func(on bool) error) error {
+	promiscMtx.Lock()
+	n := promiscUsers[p.Name_]
+	if !on && n == 0 {
+		promiscMtx.Unlock()
+		psLog.W("promiscuous mode has no user", "device: "+p.Name_)
+		return psErr.Error
+	}
+	delta := 1
+	if !on {
+		delta = -1
+	}
+	p.setPromiscUsers(n + delta)
+	promiscMtx.Unlock()
+
+	// apply may count a user of another device, so it's called without the lock
+	if (on && n == 0) || (!on && n == 1) {
+		if apply != nil {
+			if err := apply(on); err != psErr.OK {
+				promiscMtx.Lock()
+				p.setPromiscUsers(promiscUsers[p.Name_] - delta)
+				promiscMtx.Unlock()
+				return err
+			}
+		}
+		p.setFlag(PromiscFlag, on)
+	}
+
+	return psErr.OK
+}
+
+// setPromiscUsers records the number of the users of the promiscuous mode. It must be called with the lock held.
+func (p *Device) setPromiscUsers(n int) {
+	if n == 0 {
+		delete(promiscUsers, p.Name_)
+	} else {
+		promiscUsers[p.Name_] = n
+	}
+}
+
+// setFlag sets or clears the bits of the flag atomically.
+func (p *Device) setFlag(flag DevFlag, on bool) {
+	addr := (*uint32)(&p.Flag_)
+	for {
+		old := atomic.LoadUint32(addr)
+		v := old &^ uint32(flag)
+		if on {
+			v = old | uint32(flag)
+		}
+		if atomic.CompareAndSwapUint32(addr, old, v) {
+			return
+		}
+	}
+}
+
+func (p *Device) JoinMulticast(addr EthAddr) error {
+	if !addr.IsMulticast() || addr == EthBroadcast {
+		psLog.E(fmt.Sprintf("not a multicast address: %s", addr), "device: "+p.Name_)
+		return psErr.Error
+	}
+
+	mcastMtx.Lock()
+	defer mcastMtx.Unlock()
+
+	if mcastAddrs[p.Name_] == nil {
+		mcastAddrs[p.Name_] = make(map[EthAddr]int)
+	}
+	mcastAddrs[p.Name_][addr]++
+
+	return psErr.OK
+}
+
+func (p *Device) LeaveMulticast(addr EthAddr) error {
+	mcastMtx.Lock()
+	defer mcastMtx.Unlock()
+
+	n, ok := mcastAddrs[p.Name_][addr]
+	if !ok {
+		return psErr.NotFound
+	}
+	if n > 1 {
+		mcastAddrs[p.Name_][addr] = n - 1
+		return psErr.OK
+	}
+	delete(mcastAddrs[p.Name_], addr)
+	if len(mcastAddrs[p.Name_]) == 0 {
+		delete(mcastAddrs, p.Name_)
+	}
+
+	return psErr.OK
 }
 
 func (p *Device) Equal(pp IDevice) bool {
@@ -64,7 +227,7 @@ func (p *Device) Equal(pp IDevice) bool {
 }
 
 func (p *Device) IsUp() bool {
-	return p.Flag_&UpFlag == 1
+	return p.Flag()&UpFlag != 0
 }
 
 func (p *Device) Type() DevType {
@@ -80,7 +243,7 @@ func (p *Device) Addr() EthAddr {
 }
 
 func (p *Device) Flag() DevFlag {
-	return p.Flag_
+	return DevFlag(atomic.LoadUint32((*uint32)(&p.Flag_)))
 }
 
 func (p *Device) MTU() uint16 {
@@ -91,7 +254,71 @@ func (p *Device) Priv() Privilege {
 	return p.Priv_
 }
 
+// OffloadOf returns the works which the device does in place of the protocol stack. It returns 0 when the device
+// doesn't implement IOffloader.
+func OffloadOf(dev IDevice) Offload {
+	if v, ok := dev.(IOffloader); ok {
+		return v.Offload()
+	}
+	return 0
+}
+
+// MulticastAddrs returns the multicast addresses which the device has joined in ascending order.
+func MulticastAddrs(dev IDevice) []EthAddr {
+	mcastMtx.RLock()
+	defer mcastMtx.RUnlock()
+
+	var ret []EthAddr
+	for addr := range mcastAddrs[dev.Name()] {
+		ret = append(ret, addr)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return bytes.Compare(ret[i][:], ret[j][:]) < 0
+	})
+
+	return ret
+}
+
+// Accepts returns true when the device receives the frame sent to the address: the address of the device, broadcast,
+// or a multicast address which the device has joined. The device receives all the frames in promiscuous mode.
+func Accepts(dev IDevice, dst EthAddr) bool {
+	if dst == dev.Addr() || dst == EthBroadcast || dev.Flag()&PromiscFlag != 0 {
+		return true
+	}
+	if !dst.IsMulticast() {
+		return false
+	}
+
+	mcastMtx.RLock()
+	defer mcastMtx.RUnlock()
+	_, ok := mcastAddrs[dev.Name()][dst]
+
+	return ok
+}
+
 type Privilege struct {
 	Name string
 	FD   int
 }
+
+// SetRxNotifier registers the function which is called when frames are queued in a device that doesn't have a file
+// descriptor. Passing nil unregisters it.
+func SetRxNotifier(f func(dev IDevice)) {
+	rxNotifierMtx.Lock()
+	defer rxNotifierMtx.Unlock()
+	rxNotifier = f
+}
+
+// NotifyRx informs that frames are ready to be read from the device. It does nothing when no notifier is registered.
+func NotifyRx(dev IDevice) {
+	rxNotifierMtx.RLock()
+	defer rxNotifierMtx.RUnlock()
+	if rxNotifier != nil {
+		rxNotifier(dev)
+	}
+}
+
+func init() {
+	mcastAddrs = make(map[string]map[EthAddr]int)
+	promiscUsers = make(map[string]int)
+}
@@ -115,6 +115,34 @@ func (mr *MockIDeviceMockRecorder) IsUp() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsUp", reflect.TypeOf((*MockIDevice)(nil).IsUp))
 }
 
+// JoinMulticast mocks base method.
+func (m *MockIDevice) JoinMulticast(addr EthAddr) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "JoinMulticast", addr)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// JoinMulticast indicates an expected call of JoinMulticast.
+func (mr *MockIDeviceMockRecorder) JoinMulticast(addr interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JoinMulticast", reflect.TypeOf((*MockIDevice)(nil).JoinMulticast), addr)
+}
+
+// LeaveMulticast mocks base method.
+func (m *MockIDevice) LeaveMulticast(addr EthAddr) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LeaveMulticast", addr)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LeaveMulticast indicates an expected call of LeaveMulticast.
+func (mr *MockIDeviceMockRecorder) LeaveMulticast(addr interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LeaveMulticast", reflect.TypeOf((*MockIDevice)(nil).LeaveMulticast), addr)
+}
+
 // MTU mocks base method.
 func (m *MockIDevice) MTU() uint16 {
 	m.ctrl.T.Helper()
@@ -185,6 +213,20 @@ func (mr *MockIDeviceMockRecorder) Priv() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Priv", reflect.TypeOf((*MockIDevice)(nil).Priv))
 }
 
+// SetPromisc mocks base method.
+func (m *MockIDevice) SetPromisc(on bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPromisc", on)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPromisc indicates an expected call of SetPromisc.
+func (mr *MockIDeviceMockRecorder) SetPromisc(on interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPromisc", reflect.TypeOf((*MockIDevice)(nil).SetPromisc), on)
+}
+
 // Transmit mocks base method.
 func (m *MockIDevice) Transmit(dst EthAddr, payload []byte, typ EthType) error {
 	m.ctrl.T.Helper()
@@ -224,3 +266,77 @@ func (mr *MockIDeviceMockRecorder) Up() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Up", reflect.TypeOf((*MockIDevice)(nil).Up))
 }
+
+// MockIOffloader is a mock of IOffloader interface.
+type MockIOffloader struct {
+	ctrl     *gomock.Controller
+	recorder *MockIOffloaderMockRecorder
+}
+
+// MockIOffloaderMockRecorder is the mock recorder for MockIOffloader.
+type MockIOffloaderMockRecorder struct {
+	mock *MockIOffloader
+}
+
+// NewMockIOffloader creates a new mock instance.
+func NewMockIOffloader(ctrl *gomock.Controller) *MockIOffloader {
+	mock := &MockIOffloader{ctrl: ctrl}
+	mock.recorder = &MockIOffloaderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIOffloader) EXPECT() *MockIOffloaderMockRecorder {
+	return m.recorder
+}
+
+// Offload mocks base method.
+func (m *MockIOffloader) Offload() Offload {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Offload")
+	ret0, _ := ret[0].(Offload)
+	return ret0
+}
+
+// Offload indicates an expected call of Offload.
+func (mr *MockIOffloaderMockRecorder) Offload() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Offload", reflect.TypeOf((*MockIOffloader)(nil).Offload))
+}
+
+// MockIFrameTransmitter is a mock of IFrameTransmitter interface.
+type MockIFrameTransmitter struct {
+	ctrl     *gomock.Controller
+	recorder *MockIFrameTransmitterMockRecorder
+}
+
+// MockIFrameTransmitterMockRecorder is the mock recorder for MockIFrameTransmitter.
+type MockIFrameTransmitterMockRecorder struct {
+	mock *MockIFrameTransmitter
+}
+
+// NewMockIFrameTransmitter creates a new mock instance.
+func NewMockIFrameTransmitter(ctrl *gomock.Controller) *MockIFrameTransmitter {
+	mock := &MockIFrameTransmitter{ctrl: ctrl}
+	mock.recorder = &MockIFrameTransmitterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIFrameTransmitter) EXPECT() *MockIFrameTransmitterMockRecorder {
+	return m.recorder
+}
+
+// TransmitFrame mocks base method.
+func (m *MockIFrameTransmitter) TransmitFrame(frame []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TransmitFrame", frame)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TransmitFrame indicates an expected call of TransmitFrame.
+func (mr *MockIFrameTransmitterMockRecorder) TransmitFrame(frame interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransmitFrame", reflect.TypeOf((*MockIFrameTransmitter)(nil).TransmitFrame), frame)
+}
@@ -1,7 +1,10 @@
 package mw
 
 import (
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
 	"github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
 	"testing"
 )
 
@@ -112,3 +115,100 @@ func TestDevice_MTU(t *testing.T) {
 		t.Errorf("MTU() = %d; want %d", got, want)
 	}
 }
+
+func TestDevice_SetPromisc(t *testing.T) {
+	dev := Device{}
+	dev.Up()
+
+	_ = dev.SetPromisc(true)
+	want := UpFlag | PromiscFlag
+	if got := dev.Flag(); got != want {
+		t.Errorf("Device.SetPromisc() = %v; want %v", got, want)
+	}
+
+	_ = dev.SetPromisc(false)
+	want = UpFlag
+	if got := dev.Flag(); got != want {
+		t.Errorf("Device.SetPromisc() = %v; want %v", got, want)
+	}
+}
+
+// Success when the device is promiscuous until all the users count themselves out.
+// Fail when the mode has no user.
+func TestDevice_SetPromisc_2(t *testing.T) {
+	dev := Device{Name_: "net0"}
+
+	_ = dev.SetPromisc(true)
+	_ = dev.SetPromisc(true)
+	_ = dev.SetPromisc(false)
+	if dev.Flag()&PromiscFlag == 0 {
+		t.Errorf("Device.Flag() = %v; want %v", dev.Flag(), PromiscFlag)
+	}
+
+	_ = dev.SetPromisc(false)
+	if dev.Flag()&PromiscFlag != 0 {
+		t.Errorf("Device.Flag() = %v; want %v", dev.Flag(), DevFlag(0))
+	}
+	if got := dev.SetPromisc(false); got != psErr.Error {
+		t.Errorf("Device.SetPromisc() = %v; want %v", got, psErr.Error)
+	}
+}
+
+// Success when the address is left after it's left as many times as it's joined.
+func TestDevice_JoinMulticast_1(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockIDevice(ctrl)
+	m.EXPECT().Addr().Return(EthAddr{11, 12, 13, 14, 15, 16}).AnyTimes()
+	m.EXPECT().Name().Return("net0").AnyTimes()
+	m.EXPECT().Flag().Return(UpFlag).AnyTimes()
+
+	dev := Device{Name_: "net0"}
+	addr := EthAddr{0x01, 0x00, 0x5e, 0x00, 0x00, 0x01}
+
+	_ = dev.JoinMulticast(addr)
+	_ = dev.JoinMulticast(addr)
+	_ = dev.JoinMulticast(EthAddr{0x01, 0x00, 0x5e, 0x00, 0x00, 0x00})
+
+	want := []EthAddr{{0x01, 0x00, 0x5e, 0x00, 0x00, 0x00}, addr}
+	got := MulticastAddrs(m)
+	if d := cmp.Diff(got, want); d != "" {
+		t.Errorf("MulticastAddrs() differs: (-got +want)\n%s", d)
+	}
+
+	_ = dev.LeaveMulticast(addr)
+	if got := Accepts(m, addr); !got {
+		t.Errorf("Accepts() = %t; want %t", got, true)
+	}
+	_ = dev.LeaveMulticast(addr)
+	if got := Accepts(m, addr); got {
+		t.Errorf("Accepts() = %t; want %t", got, false)
+	}
+	if got := dev.LeaveMulticast(addr); got != psErr.NotFound {
+		t.Errorf("Device.LeaveMulticast() = %s; want %s", got, psErr.NotFound)
+	}
+
+	_ = dev.LeaveMulticast(EthAddr{0x01, 0x00, 0x5e, 0x00, 0x00, 0x00})
+}
+
+// Fail when the address isn't a multicast address.
+func TestDevice_JoinMulticast_2(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	psLog.DisableOutput()
+	defer psLog.EnableOutput()
+
+	m := NewMockIDevice(ctrl)
+	m.EXPECT().Name().Return("net0").AnyTimes()
+
+	dev := Device{Name_: "net0"}
+	for _, addr := range []EthAddr{{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}, EthBroadcast} {
+		if got := dev.JoinMulticast(addr); got != psErr.Error {
+			t.Errorf("Device.JoinMulticast(%s) = %s; want %s", addr, got, psErr.Error)
+		}
+	}
+	if got := MulticastAddrs(m); len(got) != 0 {
+		t.Errorf("MulticastAddrs() = %v; want []", got)
+	}
+}
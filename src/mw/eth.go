@@ -3,11 +3,15 @@ package mw
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	psBinary "github.com/42milez/ProtocolStack/src/binary"
 	psErr "github.com/42milez/ProtocolStack/src/error"
 	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/pcap"
 	psSyscall "github.com/42milez/ProtocolStack/src/syscall"
+	"sync"
+	"syscall"
 )
 
 const EthHdrLen = 14
@@ -17,10 +21,21 @@ const EthFrameLenMin = 60
 const EthPayloadLenMax = EthFrameLenMax - EthHdrLen
 const EthPayloadLenMin = EthFrameLenMin - EthHdrLen
 
+// RxBatchMax is the maximum number of frames which ReadFrames() reads at once.
+const RxBatchMax = 32
+const rxBufLen = EthFrameLenMax + VlanTagLen
+
 var EthAny = EthAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
 var EthBroadcast = EthAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
 
-var rxBuf []byte
+var rxBufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, rxBufLen)
+		return &b
+	},
+}
+var rxHandlers map[string]func(dev IDevice, frame []byte)
+var rxHandlerMtx sync.RWMutex
 
 type EthAddr [EthAddrLen]byte
 
@@ -28,45 +43,212 @@ func (v EthAddr) Equal(vv EthAddr) bool {
 	return v == vv
 }
 
+// IsMulticast returns true when the address is a group address, which includes broadcast.
+func (v EthAddr) IsMulticast() bool {
+	return v[0]&0x01 != 0
+}
+
 func (v EthAddr) String() string {
 	return fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x", v[0], v[1], v[2], v[3], v[4], v[5])
 }
 
+// ParseEthAddr parses the string in the form of xx:xx:xx:xx:xx:xx as an ethernet address.
+func ParseEthAddr(s string) (EthAddr, error) {
+	var addr EthAddr
+	if len(s) != EthAddrLen*3-1 {
+		return EthAddr{}, psErr.InvalidFormat
+	}
+	for i := range addr {
+		if i > 0 && s[i*3-1] != ':' {
+			return EthAddr{}, psErr.InvalidFormat
+		}
+		hi, ok1 := xtoi(s[i*3])
+		lo, ok2 := xtoi(s[i*3+1])
+		if !ok1 || !ok2 {
+			return EthAddr{}, psErr.InvalidFormat
+		}
+		addr[i] = hi<<4 | lo
+	}
+	return addr, psErr.OK
+}
+
+// xtoi converts the hexadecimal digit to its value.
+func xtoi(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	default:
+		return 0, false
+	}
+}
+
 type EthHdr struct {
 	Dst  EthAddr
 	Src  EthAddr
 	Type EthType
 }
 
-func ReadFrame(fd int, addr EthAddr) (*EthMessage, error) {
-	flen, err := psSyscall.Syscall.Read(fd, rxBuf)
-	if err != nil {
-		return nil, psErr.Error
+// ReadFrames drains the frames pending on the descriptor, which has to be non-blocking, up to RxBatchMax frames. Each
+// frame is read into a buffer taken from a pool, so that the descriptors can be read concurrently. It returns
+// NoDataToRead when no frame is addressed to the device.
+func ReadFrames(fd int, dev IDevice) ([]*EthMessage, error) {
+	return readFrames(fd, dev, &rxBufPool, nil)
+}
+
+// readFrames is the body of ReadFrames(). When strip isn't nil, it takes the frame out of the data which is read.
+func readFrames(fd int, dev IDevice, pool *sync.Pool, strip func(b []byte) ([]byte, error)) ([]*EthMessage, error) {
+	var msgs []*EthMessage
+
+	for i := 0; i < RxBatchMax; i++ {
+		buf := pool.Get().(*[]byte)
+		n, err := psSyscall.Syscall.Read(fd, *buf)
+		if err != nil {
+			pool.Put(buf)
+			if errors.Is(err, syscall.EAGAIN) {
+				break
+			}
+			if i == 0 {
+				return nil, psErr.Error
+			}
+			// the error is reported by the next read
+			break
+		}
+		frame := (*buf)[:n]
+
+		if strip != nil {
+			if frame, err = strip(frame); err != psErr.OK {
+				pool.Put(buf)
+				psLog.W(fmt.Sprintf("frame was discarded: %s", err), "device: "+dev.Name())
+				continue
+			}
+		}
+
+		CaptureFrame(dev, pcap.DirectionInbound, frame)
+
+		msg, err := ReceiveFrame(frame, dev)
+		pool.Put(buf)
+		if err != psErr.OK {
+			if err != psErr.NoDataToRead {
+				psLog.W(fmt.Sprintf("frame was discarded: %s", err), "device: "+dev.Name())
+			}
+			continue
+		}
+		msgs = append(msgs, msg)
+	}
+
+	if len(msgs) == 0 {
+		return nil, psErr.NoDataToRead
+	}
+
+	return msgs, psErr.OK
+}
+
+// ReceiveFrame decodes a frame which arrived at the device. A frame tagged with 802.1Q is passed to the VLAN device
+// attached to the device. When the device has a receive handler, the frame is passed to the handler as it is, and
+// NoDataToRead is returned. NoDataToRead is also returned for the frame which is discarded, e.g. the one which isn't
+// addressed to the device or is malformed.
+func ReceiveFrame(frame []byte, dev IDevice) (*EthMessage, error) {
+	if handler := rxHandler(dev); handler != nil {
+		handler(dev, frame)
+		return nil, psErr.NoDataToRead
+	}
+
+	msg, err := decodeFrame(frame, func(dst EthAddr) bool {
+		return Accepts(dev, dst)
+	})
+	if err == psErr.OK {
+		msg.Dev = dev
+		if msg.Type == EtVLAN {
+			msg, err = demuxVlan(msg)
+		}
 	}
+	if err != psErr.OK {
+		// the malformed frame is discarded the same way as the frame which isn't addressed to the device, so that it
+		// doesn't stop reading the device
+		return nil, psErr.NoDataToRead
+	}
+
+	return msg, psErr.OK
+}
+
+func WriteFrame(fd int, dev IDevice, dst EthAddr, typ EthType, payload []byte) error {
+	frame, err := EncodeFrame(dst, dev.Addr(), typ, payload)
+	if err != psErr.OK {
+		return psErr.Error
+	}
+	return WriteRawFrame(fd, dev, frame)
+}
+
+// WriteRawFrame writes the frame as it is.
+func WriteRawFrame(fd int, dev IDevice, frame []byte) error {
+	if _, err := psSyscall.Syscall.Write(fd, frame); err != nil {
+		return psErr.SyscallError
+	}
+	CaptureFrame(dev, pcap.DirectionOutbound, frame)
+
+	return psErr.OK
+}
+
+// SetRxHandler makes frames which arrive at the device passed to the handler instead of the protocol stack. The frame
+// passed to the handler is valid only until the handler returns. Passing nil unregisters the handler.
+func SetRxHandler(dev IDevice, handler func(dev IDevice, frame []byte)) error {
+	rxHandlerMtx.Lock()
+	defer rxHandlerMtx.Unlock()
+
+	if handler == nil {
+		delete(rxHandlers, dev.Name())
+		return psErr.OK
+	}
+	if _, ok := rxHandlers[dev.Name()]; ok {
+		psLog.E("receive handler is already registered", "device: "+dev.Name())
+		return psErr.Exist
+	}
+	rxHandlers[dev.Name()] = handler
 
+	return psErr.OK
+}
+
+func rxHandler(dev IDevice) func(dev IDevice, frame []byte) {
+	rxHandlerMtx.RLock()
+	defer rxHandlerMtx.RUnlock()
+	return rxHandlers[dev.Name()]
+}
+
+// DecodeFrame parses an ethernet frame and returns its payload when the frame is addressed to addr or broadcast.
+func DecodeFrame(frame []byte, addr EthAddr) (*EthMessage, error) {
+	return decodeFrame(frame, func(dst EthAddr) bool {
+		return dst.Equal(addr) || dst.Equal(EthBroadcast)
+	})
+}
+
+// decodeFrame parses an ethernet frame and returns its payload when accept returns true for its destination.
+func decodeFrame(frame []byte, accept func(dst EthAddr) bool) (*EthMessage, error) {
+	flen := len(frame)
 	if flen < EthHdrLen {
 		psLog.E(fmt.Sprintf("ethernet header length is too short: %d bytes", flen))
 		return nil, psErr.Error
 	}
 
-	buf := bytes.NewBuffer(rxBuf)
+	buf := bytes.NewBuffer(frame)
 	hdr := EthHdr{}
 	if err := binary.Read(buf, binary.BigEndian, &hdr); err != nil {
 		return nil, psErr.ReadFromBufError
 	}
 
-	if !hdr.Dst.Equal(addr) {
-		if !hdr.Dst.Equal(EthBroadcast) {
-			return nil, psErr.NoDataToRead
-		}
+	if !accept(hdr.Dst) {
+		return nil, psErr.NoDataToRead
 	}
 
 	payload := make([]byte, flen-EthHdrLen)
-	if err := binary.Read(buf, binary.BigEndian, &payload); err != nil {
-		return nil, psErr.ReadFromBufError
-	}
+	copy(payload, frame[EthHdrLen:])
 
-	psLog.D(fmt.Sprintf("incoming ethernet frame (%d bytes)", flen), dump(rxBuf[:flen])...)
+	if psLog.IsDebug() {
+		psLog.D(fmt.Sprintf("incoming ethernet frame (%d bytes)", flen), dump(frame)...)
+	}
 
 	return &EthMessage{
 		Type:    hdr.Type,
@@ -74,7 +256,8 @@ func ReadFrame(fd int, addr EthAddr) (*EthMessage, error) {
 	}, psErr.OK
 }
 
-func WriteFrame(fd int, dst EthAddr, src EthAddr, typ EthType, payload []byte) error {
+// EncodeFrame builds an ethernet frame which is padded to the minimum frame length.
+func EncodeFrame(dst EthAddr, src EthAddr, typ EthType, payload []byte) ([]byte, error) {
 	hdr := EthHdr{
 		Dst:  dst,
 		Src:  src,
@@ -83,23 +266,21 @@ func WriteFrame(fd int, dst EthAddr, src EthAddr, typ EthType, payload []byte) e
 
 	buf := new(bytes.Buffer)
 	if err := binary.Write(buf, binary.BigEndian, &hdr); err != nil {
-		return psErr.WriteToBufError
+		return nil, psErr.WriteToBufError
 	}
 	if err := binary.Write(buf, binary.BigEndian, &payload); err != nil {
-		return psErr.WriteToBufError
+		return nil, psErr.WriteToBufError
 	}
 	if err := pad(buf); err != psErr.OK {
-		return psErr.Error
+		return nil, psErr.Error
 	}
 	frame := buf.Bytes()
 
-	psLog.D(fmt.Sprintf("outgoing ethernet frame (%d bytes)", EthHdrLen+len(payload)), dump(frame)...)
-
-	if _, err := psSyscall.Syscall.Write(fd, frame); err != nil {
-		return psErr.SyscallError
+	if psLog.IsDebug() {
+		psLog.D(fmt.Sprintf("outgoing ethernet frame (%d bytes)", EthHdrLen+len(payload)), dump(frame)...)
 	}
 
-	return psErr.OK
+	return frame, psErr.OK
 }
 
 func dump(frame []byte) (ret []string) {
@@ -142,5 +323,5 @@ func pad(buf *bytes.Buffer) error {
 }
 
 func init() {
-	rxBuf = make([]byte, EthFrameLenMax)
+	rxHandlers = make(map[string]func(dev IDevice, frame []byte))
 }
@@ -3,7 +3,6 @@ package mw
 import (
 	"bytes"
 	"encoding/binary"
-	"errors"
 	"fmt"
 	psBinary "github.com/42milez/ProtocolStack/src/binary"
 	psErr "github.com/42milez/ProtocolStack/src/error"
@@ -12,6 +11,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"regexp"
 	"strings"
+	"syscall"
 	"testing"
 )
 
@@ -30,6 +30,22 @@ func TestEthAddr_Equal(t *testing.T) {
 	}
 }
 
+func TestParseEthAddr(t *testing.T) {
+	got, err := ParseEthAddr("02:0a:Bc:00:ff:10")
+	if err != psErr.OK {
+		t.Fatalf("ParseEthAddr() = %s; want %s", err, psErr.OK)
+	}
+	if want := (EthAddr{0x02, 0x0a, 0xbc, 0x00, 0xff, 0x10}); got != want {
+		t.Errorf("ParseEthAddr() = %s; want %s", got, want)
+	}
+
+	for _, s := range []string{"", "02:0a:bc:00:ff", "02-0a-bc-00-ff-10", "02:0a:bc:00:ff:1g", "02:0a:bc:00:ff:100"} {
+		if _, err := ParseEthAddr(s); err != psErr.InvalidFormat {
+			t.Errorf("ParseEthAddr(%q) = %s; want %s", s, err, psErr.InvalidFormat)
+		}
+	}
+}
+
 func TestEthType_String(t *testing.T) {
 	want := "IPv4"
 	got := EthType(0x0800).String()
@@ -79,98 +95,126 @@ func TestDumpFrame(t *testing.T) {
 	}
 }
 
-func TestReadFrame_1(t *testing.T) {
+func TestReadFrames_1(t *testing.T) {
 	ctrl, teardown := setupEthTest(t)
 	defer teardown()
 
-	m := psSyscall.NewMockISyscall(ctrl)
-	m.EXPECT().
-		Read(gomock.Any(), gomock.Any()).
-		Do(func(_ int, buf []byte) {
+	frame := func(dst EthAddr) func(_ int, buf []byte) {
+		return func(_ int, buf []byte) {
 			hdr := EthHdr{
-				Dst:  EthAddr{11, 12, 13, 14, 15, 16},
+				Dst:  dst,
 				Src:  EthAddr{21, 22, 23, 24, 25, 26},
 				Type: EthType(0x0800),
 			}
 			b := new(bytes.Buffer)
 			_ = binary.Write(b, binary.BigEndian, &hdr)
 			copy(buf, b.Bytes())
-		}).
-		Return(150, nil)
+		}
+	}
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	gomock.InOrder(
+		m.EXPECT().Read(any, any).Do(frame(EthAddr{11, 12, 13, 14, 15, 16})).Return(60, nil),
+		m.EXPECT().Read(any, any).Do(frame(EthAddr{33, 44, 55, 66, 77, 88})).Return(60, nil),
+		m.EXPECT().Read(any, any).Do(frame(EthBroadcast)).Return(60, nil),
+		m.EXPECT().Read(any, any).Return(-1, syscall.EAGAIN),
+	)
 	psSyscall.Syscall = m
 
-	dev := &Device{Addr_: EthAddr{11, 12, 13, 14, 15, 16}}
+	dev := NewMockIDevice(ctrl)
+	dev.EXPECT().Addr().Return(EthAddr{11, 12, 13, 14, 15, 16}).AnyTimes()
+	dev.EXPECT().Name().Return("net0").AnyTimes()
+	dev.EXPECT().Flag().Return(DevFlag(0)).AnyTimes()
 
-	_, got := ReadFrame(dev.Priv().FD, dev.Addr())
+	msgs, got := ReadFrames(3, dev)
 	if got != psErr.OK {
-		t.Errorf("ReadFrame() = %v; want %v", got, psErr.OK)
+		t.Errorf("ReadFrames() = %s; want %s", got, psErr.OK)
+	}
+	// the frame addressed to the other station is discarded
+	if len(msgs) != 2 {
+		t.Errorf("len(msgs) = %d; want %d", len(msgs), 2)
 	}
 }
 
-// Fail when Read() returns error.
-func TestReadFrame_2(t *testing.T) {
+// Success when it reads RxBatchMax frames at most.
+func TestReadFrames_2(t *testing.T) {
 	ctrl, teardown := setupEthTest(t)
 	defer teardown()
 
-	dev := &Device{Addr_: EthAddr{11, 12, 13, 14, 15, 16}}
+	frame, _ := EncodeFrame(EthBroadcast, EthAddr{21, 22, 23, 24, 25, 26}, EtIPV4, []byte{})
+
 	m := psSyscall.NewMockISyscall(ctrl)
-	m.EXPECT().Read(gomock.Any(), gomock.Any()).Return(-1, errors.New(""))
+	m.EXPECT().Read(any, any).DoAndReturn(func(_ int, buf []byte) (int, error) {
+		return copy(buf, frame), nil
+	}).Times(RxBatchMax)
 	psSyscall.Syscall = m
 
-	_, got := ReadFrame(dev.Priv().FD, dev.Addr())
-	if got != psErr.Error {
-		t.Errorf("ReadFrame() = %v; want %v", got, psErr.Error)
+	dev := NewMockIDevice(ctrl)
+	dev.EXPECT().Addr().Return(EthAddr{11, 12, 13, 14, 15, 16}).AnyTimes()
+	dev.EXPECT().Name().Return("net0").AnyTimes()
+
+	msgs, got := ReadFrames(3, dev)
+	if got != psErr.OK {
+		t.Errorf("ReadFrames() = %s; want %s", got, psErr.OK)
+	}
+	if len(msgs) != RxBatchMax {
+		t.Errorf("len(msgs) = %d; want %d", len(msgs), RxBatchMax)
 	}
 }
 
-// Fail when header length is invalid.
-func TestReadFrame_3(t *testing.T) {
+// Success when no frame is pending.
+func TestReadFrames_3(t *testing.T) {
 	ctrl, teardown := setupEthTest(t)
 	defer teardown()
 
 	m := psSyscall.NewMockISyscall(ctrl)
-	m.EXPECT().Read(gomock.Any(), gomock.Any()).Return(10, nil)
+	m.EXPECT().Read(any, any).Return(-1, syscall.EAGAIN)
 	psSyscall.Syscall = m
 
-	dev := &Device{Addr_: EthAddr{11, 12, 13, 14, 15, 16}}
+	dev := NewMockIDevice(ctrl)
 
-	_, got := ReadFrame(dev.Priv().FD, dev.Addr())
-	if got != psErr.Error {
-		t.Errorf("ReadFrame() = %v; want %v", got, psErr.Error)
+	_, got := ReadFrames(3, dev)
+	if got != psErr.NoDataToRead {
+		t.Errorf("ReadFrames() = %s; want %s", got, psErr.NoDataToRead)
 	}
 }
 
-// Success when no data exits.
-func TestReadFrame_4(t *testing.T) {
+// Fail when Read() returns error.
+func TestReadFrames_4(t *testing.T) {
 	ctrl, teardown := setupEthTest(t)
 	defer teardown()
 
 	m := psSyscall.NewMockISyscall(ctrl)
-	m.EXPECT().Read(gomock.Any(), gomock.Any()).Return(150, nil)
+	m.EXPECT().Read(any, any).Return(-1, syscall.EIO)
 	psSyscall.Syscall = m
 
-	dev := &Device{Addr_: EthAddr{33, 44, 55, 66, 77, 88}}
+	dev := NewMockIDevice(ctrl)
 
-	_, got := ReadFrame(dev.Priv().FD, dev.Addr())
-	if got != psErr.NoDataToRead {
-		t.Errorf("ReadFrame() = %v; want %v", got, psErr.NoDataToRead)
+	_, got := ReadFrames(3, dev)
+	if got != psErr.Error {
+		t.Errorf("ReadFrames() = %s; want %s", got, psErr.Error)
 	}
 }
 
-// Fail when Read() system call returns error.
-func TestReadFrame_5(t *testing.T) {
+// Success when the frame of which header length is invalid is discarded.
+func TestReadFrames_5(t *testing.T) {
 	ctrl, teardown := setupEthTest(t)
 	defer teardown()
 
 	m := psSyscall.NewMockISyscall(ctrl)
-	m.EXPECT().Read(gomock.Any(), gomock.Any()).Return(150, nil)
+	gomock.InOrder(
+		m.EXPECT().Read(any, any).Return(10, nil),
+		m.EXPECT().Read(any, any).Return(-1, syscall.EAGAIN),
+	)
 	psSyscall.Syscall = m
 
-	dev := &Device{Addr_: EthAddr{33, 44, 55, 66, 77, 88}}
+	dev := NewMockIDevice(ctrl)
+	dev.EXPECT().Addr().Return(EthAddr{11, 12, 13, 14, 15, 16}).AnyTimes()
+	dev.EXPECT().Name().Return("net0").AnyTimes()
 
-	_, got := ReadFrame(dev.Priv().FD, dev.Addr())
+	_, got := ReadFrames(3, dev)
 	if got != psErr.NoDataToRead {
-		t.Errorf("ReadFrame() = %v; want %v", got, psErr.NoDataToRead)
+		t.Errorf("ReadFrames() = %s; want %s", got, psErr.NoDataToRead)
 	}
 }
 
@@ -183,18 +227,119 @@ func TestWriteFrame(t *testing.T) {
 	psSyscall.Syscall = m
 
 	fd := 3
+	dev := NewMockIDevice(ctrl)
+	dev.EXPECT().Addr().Return(EthAddr{0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f}).AnyTimes()
 	dst := EthAddr{0x11, 0x12, 0x13, 0x14, 0x15, 0x16}
-	src := EthAddr{0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f}
 	payload := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
 
 	want := psErr.OK
-	got := WriteFrame(fd, dst, src, EtIPV4, payload)
+	got := WriteFrame(fd, dev, dst, EtIPV4, payload)
 
 	if got != want {
 		t.Errorf("WriteFrame() = %s; want %s", got, want)
 	}
 }
 
+// Success when a frame is passed to the receive handler instead of the protocol stack.
+func TestSetRxHandler_1(t *testing.T) {
+	ctrl, teardown := setupEthTest(t)
+	defer teardown()
+
+	dev := NewMockIDevice(ctrl)
+	dev.EXPECT().Name().Return("net0").AnyTimes()
+
+	var got []byte
+	if err := SetRxHandler(dev, func(_ IDevice, frame []byte) { got = frame }); err != psErr.OK {
+		t.Fatalf("SetRxHandler() = %s; want %s", err, psErr.OK)
+	}
+	defer func() { _ = SetRxHandler(dev, nil) }()
+
+	frame := make([]byte, EthFrameLenMin)
+	if _, err := ReceiveFrame(frame, dev); err != psErr.NoDataToRead {
+		t.Errorf("ReceiveFrame() = %s; want %s", err, psErr.NoDataToRead)
+	}
+	if len(got) != len(frame) {
+		t.Errorf("length of frame passed to handler = %d; want %d", len(got), len(frame))
+	}
+}
+
+// Fail when the device already has a receive handler.
+func TestSetRxHandler_2(t *testing.T) {
+	ctrl, teardown := setupEthTest(t)
+	defer teardown()
+
+	dev := NewMockIDevice(ctrl)
+	dev.EXPECT().Name().Return("net0").AnyTimes()
+
+	handler := func(_ IDevice, _ []byte) {}
+	_ = SetRxHandler(dev, handler)
+	defer func() { _ = SetRxHandler(dev, nil) }()
+
+	if err := SetRxHandler(dev, handler); err != psErr.Exist {
+		t.Errorf("SetRxHandler() = %s; want %s", err, psErr.Exist)
+	}
+}
+
+// Success when the frames sent to the joined multicast address are received, and all the frames are received in
+// promiscuous mode.
+func TestReceiveFrame_4(t *testing.T) {
+	ctrl, teardown := setupEthTest(t)
+	defer teardown()
+
+	base := &Device{Name_: "net0"}
+	dev := NewMockIDevice(ctrl)
+	dev.EXPECT().Addr().Return(EthAddr{11, 12, 13, 14, 15, 16}).AnyTimes()
+	dev.EXPECT().Name().Return("net0").AnyTimes()
+	dev.EXPECT().Flag().DoAndReturn(base.Flag).AnyTimes()
+
+	group := EthAddr{0x01, 0x00, 0x5e, 0x00, 0x00, 0xfb}
+
+	receive := func(dst EthAddr) error {
+		frame, _ := EncodeFrame(dst, EthAddr{21, 22, 23, 24, 25, 26}, EtIPV4, make([]byte, EthPayloadLenMin))
+		_, err := ReceiveFrame(frame, dev)
+		return err
+	}
+
+	if got := receive(group); got != psErr.NoDataToRead {
+		t.Errorf("ReceiveFrame() = %s; want %s", got, psErr.NoDataToRead)
+	}
+
+	_ = base.JoinMulticast(group)
+	defer func() { _ = base.LeaveMulticast(group) }()
+	if got := receive(group); got != psErr.OK {
+		t.Errorf("ReceiveFrame() = %s; want %s", got, psErr.OK)
+	}
+
+	other := EthAddr{31, 32, 33, 34, 35, 36}
+	if got := receive(other); got != psErr.NoDataToRead {
+		t.Errorf("ReceiveFrame() = %s; want %s", got, psErr.NoDataToRead)
+	}
+	_ = base.SetPromisc(true)
+	defer func() { _ = base.SetPromisc(false) }()
+	if got := receive(other); got != psErr.OK {
+		t.Errorf("ReceiveFrame() = %s; want %s", got, psErr.OK)
+	}
+}
+
+// Success when the malformed frames are discarded.
+func TestReceiveFrame_5(t *testing.T) {
+	ctrl, teardown := setupEthTest(t)
+	defer teardown()
+
+	dev := NewMockIDevice(ctrl)
+	dev.EXPECT().Addr().Return(EthAddr{11, 12, 13, 14, 15, 16}).AnyTimes()
+	dev.EXPECT().Name().Return("net0").AnyTimes()
+	dev.EXPECT().Flag().Return(BroadcastFlag).AnyTimes()
+
+	// the vlan tag is shorter than 4 bytes
+	tagged, _ := EncodeFrame(EthBroadcast, EthAddr{21, 22, 23, 24, 25, 26}, EtVLAN, nil)
+	for _, frame := range [][]byte{make([]byte, EthHdrLen-1), tagged[:EthHdrLen+2]} {
+		if _, err := ReceiveFrame(frame, dev); err != psErr.NoDataToRead {
+			t.Errorf("ReceiveFrame() = %s; want %s", err, psErr.NoDataToRead)
+		}
+	}
+}
+
 var any = gomock.Any()
 
 func setupEthTest(t *testing.T) (ctrl *gomock.Controller, teardown func()) {
@@ -198,13 +198,13 @@ func Checksum(b []byte, init uint32) uint16 {
 	sum := init
 
 	// sum up all fields of IP header by each 16bits
-	for i := 0; i < len(b); i += 2 {
+	for i := 0; i+1 < len(b); i += 2 {
 		sum += uint32(b[i])<<8 | uint32(b[i+1])
 	}
 
-	// add last 8bits if exists
+	// add last 8bits if exists (it's padded with zero to make a 16bits word)
 	if len(b)%2 != 0 {
-		sum += uint32(b[len(b)-1])
+		sum += uint32(b[len(b)-1]) << 8
 	}
 
 	// fold sum to 16bits
@@ -299,7 +299,7 @@ func parseV4(s string) IP {
 	var p [V4AddrLen]byte
 	for i := 0; i < V4AddrLen; i++ {
 		if i > 0 {
-			if s[0] != '.' {
+			if len(s) == 0 || s[0] != '.' {
 				return nil
 			}
 			s = s[1:]
@@ -311,6 +311,9 @@ func parseV4(s string) IP {
 		s = s[c:]
 		p[i] = byte(n)
 	}
+	if len(s) != 0 {
+		return nil
+	}
 	return V4(p[0], p[1], p[2], p[3])
 }
 
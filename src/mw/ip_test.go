@@ -125,6 +125,14 @@ func TestChecksum(t *testing.T) {
 	if got != want {
 		t.Errorf("Checksum() = 0x%04x; want 0x%04x", got, want)
 	}
+
+	// the last byte of odd length data is padded with zero
+	b = []byte{0x01, 0x02, 0x03}
+	want = uint16(0xfbfd)
+	got = Checksum(b, 0)
+	if got != want {
+		t.Errorf("Checksum() = 0x%04x; want 0x%04x", got, want)
+	}
 }
 
 func TestLongestIP(t *testing.T) {
@@ -161,6 +169,12 @@ func TestParseIP(t *testing.T) {
 	if got != nil {
 		t.Errorf("ParseIP() = %v; want %v", got, want)
 	}
+
+	for _, s := range []string{"192.168.0", "192.168.0.", "192.168.0.1.", "192.168.0.256"} {
+		if got = ParseIP(s); got != nil {
+			t.Errorf("ParseIP(%q) = %v; want %v", s, got, want)
+		}
+	}
 }
 
 func TestV4(t *testing.T) {
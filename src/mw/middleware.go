@@ -1,7 +1,9 @@
 package mw
 
 import (
+	psErr "github.com/42milez/ProtocolStack/src/error"
 	"math/rand"
+	"sync"
 	"time"
 )
 
@@ -9,6 +11,7 @@ const (
 	EtARP  EthType = 0x0806
 	EtIPV4 EthType = 0x0800
 	EtIPV6 EthType = 0x86dd
+	EtVLAN EthType = 0x8100
 )
 const (
 	PnICMP ProtocolNumber = 1
@@ -19,8 +22,9 @@ const maxUint8 = ^uint8(0)
 const maxUint16 = ^uint16(0)
 const xChBufSize = 10
 
-var EthRxCh chan *EthMessage // channel for receiving packets
-var EthTxCh chan *EthMessage // channel for sending packets
+var EthRxCh chan *EthMessage        // channel for receiving packets
+var EthRxBatchCh chan []*EthMessage // channel for receiving packets in batches
+var EthTxCh chan *EthMessage        // channel for sending packets
 var ArpRxCh chan *ArpRxMessage
 var ArpTxCh chan *ArpTxMessage
 var IpRxCh chan *EthMessage
@@ -31,6 +35,12 @@ var IcmpTxCh chan *IcmpTxMessage
 var TcpRxCh chan *TcpRxMessage
 var TcpTxCh chan *TcpTxMessage
 
+var ipReceiver func(packet []byte, dev IDevice) error
+var ipReceiverMtx sync.RWMutex
+
+var neighConfirmer func(dst IP)
+var neighConfirmerMtx sync.RWMutex
+
 // Ethertypes
 // https://www.iana.org/assignments/ieee-802-numbers/ieee-802-numbers.xhtml#ieee-802-numbers-1
 
@@ -38,6 +48,7 @@ var ethTypes = map[EthType]string{
 	0x0800: "IPv4",
 	0x0806: "ARP",
 	0x86dd: "IPv6",
+	0x8100: "VLAN",
 }
 
 type EthType uint16
@@ -50,6 +61,7 @@ type EthMessage struct {
 	Type    EthType
 	Content []byte
 	Dev     IDevice
+	Dst     EthAddr // destination of the frame which is sent through EthTxCh
 }
 
 type ArpRxMessage struct {
@@ -99,6 +111,54 @@ type TcpRxMessage struct {
 
 type TcpTxMessage struct{}
 
+// SetIpReceiver registers the function which processes incoming IP packets in the calling goroutine. Passing nil
+// unregisters it.
+func SetIpReceiver(f func(packet []byte, dev IDevice) error) {
+	ipReceiverMtx.Lock()
+	defer ipReceiverMtx.Unlock()
+	ipReceiver = f
+}
+
+// ReceiveIp processes the IP packet in the calling goroutine, so that receive workers can process packets in parallel.
+// The packet is passed to IpRxCh when no receiver is registered.
+func ReceiveIp(packet []byte, dev IDevice) error {
+	ipReceiverMtx.RLock()
+	f := ipReceiver
+	ipReceiverMtx.RUnlock()
+
+	if f == nil {
+		IpRxCh <- &EthMessage{
+			Type:    EtIPV4,
+			Content: packet,
+			Dev:     dev,
+		}
+		return psErr.OK
+	}
+
+	return f(packet, dev)
+}
+
+// SetNeighborConfirmer registers the function which confirms the reachability of the neighbor on the way to a
+// destination. Passing nil unregisters it.
+func SetNeighborConfirmer(f func(dst IP)) {
+	neighConfirmerMtx.Lock()
+	defer neighConfirmerMtx.Unlock()
+	neighConfirmer = f
+}
+
+// ConfirmNeighbor tells that the destination has answered, so that the neighbor on the way to it is still reachable.
+// Upper layers call it when they see forward progress such as an acknowledgement of new data. It does nothing when no
+// confirmer is registered.
+func ConfirmNeighbor(dst IP) {
+	neighConfirmerMtx.RLock()
+	f := neighConfirmer
+	neighConfirmerMtx.RUnlock()
+
+	if f != nil {
+		f(dst)
+	}
+}
+
 func RandU8() uint8 {
 	return uint8(rand.Intn(int(maxUint8) + 1))
 }
@@ -113,6 +173,7 @@ func RandU32() uint32 {
 
 func init() {
 	EthRxCh = make(chan *EthMessage, xChBufSize)
+	EthRxBatchCh = make(chan []*EthMessage, xChBufSize)
 	EthTxCh = make(chan *EthMessage, xChBufSize)
 	ArpRxCh = make(chan *ArpRxMessage, xChBufSize)
 	ArpTxCh = make(chan *ArpTxMessage, xChBufSize)
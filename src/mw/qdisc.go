@@ -0,0 +1,47 @@
+//go:generate mockgen -source=qdisc.go -destination=qdisc_mock.go -package=$GOPACKAGE -self_package=github.com/42milez/ProtocolStack/src/$GOPACKAGE
+
+package mw
+
+import (
+	"sync"
+	"time"
+)
+
+var qdiscs map[string]IQdisc
+var qdiscMtx sync.RWMutex
+
+// IQdisc is a queueing discipline which decides the order and the timing of the frames sent by a device. It isn't
+// required to be safe for concurrent use.
+type IQdisc interface {
+	// Enqueue adds the frame to the queue. It returns false when the frame is dropped.
+	Enqueue(msg *EthMessage) bool
+	// Dequeue removes the frame to be sent at the time. When no frame can be sent, it returns nil and the time until
+	// the next frame can be sent, which is 0 when the queue is empty.
+	Dequeue(now time.Time) (*EthMessage, time.Duration)
+	// Len returns the number of the frames in the queue.
+	Len() int
+}
+
+// SetQdisc makes the frames sent through the device queued by the discipline and sent by its transmit queue worker.
+// Passing nil makes them sent synchronously again.
+func SetQdisc(dev IDevice, qdisc IQdisc) {
+	qdiscMtx.Lock()
+	defer qdiscMtx.Unlock()
+
+	if qdisc == nil {
+		delete(qdiscs, dev.Name())
+		return
+	}
+	qdiscs[dev.Name()] = qdisc
+}
+
+// QdiscOf returns the queueing discipline of the device. It returns nil when the device sends frames synchronously.
+func QdiscOf(dev IDevice) IQdisc {
+	qdiscMtx.RLock()
+	defer qdiscMtx.RUnlock()
+	return qdiscs[dev.Name()]
+}
+
+func init() {
+	qdiscs = make(map[string]IQdisc)
+}
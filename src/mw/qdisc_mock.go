@@ -0,0 +1,78 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: qdisc.go
+
+// Package mw is a generated GoMock package.
+package mw
+
+import (
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockIQdisc is a mock of IQdisc interface.
+type MockIQdisc struct {
+	ctrl     *gomock.Controller
+	recorder *MockIQdiscMockRecorder
+}
+
+// MockIQdiscMockRecorder is the mock recorder for MockIQdisc.
+type MockIQdiscMockRecorder struct {
+	mock *MockIQdisc
+}
+
+// NewMockIQdisc creates a new mock instance.
+func NewMockIQdisc(ctrl *gomock.Controller) *MockIQdisc {
+	mock := &MockIQdisc{ctrl: ctrl}
+	mock.recorder = &MockIQdiscMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIQdisc) EXPECT() *MockIQdiscMockRecorder {
+	return m.recorder
+}
+
+// Dequeue mocks base method.
+func (m *MockIQdisc) Dequeue(now time.Time) (*EthMessage, time.Duration) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Dequeue", now)
+	ret0, _ := ret[0].(*EthMessage)
+	ret1, _ := ret[1].(time.Duration)
+	return ret0, ret1
+}
+
+// Dequeue indicates an expected call of Dequeue.
+func (mr *MockIQdiscMockRecorder) Dequeue(now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Dequeue", reflect.TypeOf((*MockIQdisc)(nil).Dequeue), now)
+}
+
+// Enqueue mocks base method.
+func (m *MockIQdisc) Enqueue(msg *EthMessage) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Enqueue", msg)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Enqueue indicates an expected call of Enqueue.
+func (mr *MockIQdiscMockRecorder) Enqueue(msg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enqueue", reflect.TypeOf((*MockIQdisc)(nil).Enqueue), msg)
+}
+
+// Len mocks base method.
+func (m *MockIQdisc) Len() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Len")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Len indicates an expected call of Len.
+func (mr *MockIQdiscMockRecorder) Len() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Len", reflect.TypeOf((*MockIQdisc)(nil).Len))
+}
@@ -0,0 +1,99 @@
+package mw
+
+import (
+	"encoding/binary"
+	"fmt"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"sync"
+)
+
+// IEEE 802.1Q
+// https://standards.ieee.org/ieee/802.1Q/10323/
+
+const VlanTagLen = 4
+const VlanIdMin = 1
+const VlanIdMax = 4094
+const vlanIdMask = 0x0fff
+
+var vlans map[vlanKey]IDevice
+var vlanMtx sync.RWMutex
+
+type vlanKey struct {
+	parent string
+	vid    uint16
+}
+
+// AttachVlan makes frames which arrive at parent with the VLAN ID delivered to dev.
+func AttachVlan(parent IDevice, vid uint16, dev IDevice) error {
+	if vid < VlanIdMin || vid > VlanIdMax {
+		psLog.E(fmt.Sprintf("invalid vlan id: %d", vid))
+		return psErr.Error
+	}
+
+	vlanMtx.Lock()
+	defer vlanMtx.Unlock()
+
+	key := vlanKey{parent: parent.Name(), vid: vid}
+	if _, ok := vlans[key]; ok {
+		psLog.E(fmt.Sprintf("vlan %d already exists on %s", vid, parent.Name()))
+		return psErr.Exist
+	}
+	vlans[key] = dev
+
+	return psErr.OK
+}
+
+// DetachVlan stops delivering frames which arrive at parent with the VLAN ID.
+func DetachVlan(parent IDevice, vid uint16) error {
+	vlanMtx.Lock()
+	defer vlanMtx.Unlock()
+
+	key := vlanKey{parent: parent.Name(), vid: vid}
+	if _, ok := vlans[key]; !ok {
+		return psErr.NotFound
+	}
+	delete(vlans, key)
+
+	return psErr.OK
+}
+
+// TagPayload prepends an 802.1Q tag to the payload. The result is transmitted with EtVLAN as ether type.
+func TagPayload(vid uint16, typ EthType, payload []byte) []byte {
+	ret := make([]byte, VlanTagLen+len(payload))
+	binary.BigEndian.PutUint16(ret[0:2], vid&vlanIdMask)
+	binary.BigEndian.PutUint16(ret[2:4], uint16(typ))
+	copy(ret[VlanTagLen:], payload)
+	return ret
+}
+
+// demuxVlan strips the 802.1Q tag from the message and replaces its device with the VLAN device which the tag points
+// to. It returns NoDataToRead when no device is attached to the VLAN ID.
+func demuxVlan(msg *EthMessage) (*EthMessage, error) {
+	if len(msg.Content) < VlanTagLen {
+		psLog.E(fmt.Sprintf("vlan tag length is too short: %d bytes", len(msg.Content)))
+		return nil, psErr.Error
+	}
+
+	vid := binary.BigEndian.Uint16(msg.Content[0:2]) & vlanIdMask
+	typ := EthType(binary.BigEndian.Uint16(msg.Content[2:4]))
+
+	vlanMtx.RLock()
+	dev, ok := vlans[vlanKey{parent: msg.Dev.Name(), vid: vid}]
+	vlanMtx.RUnlock()
+
+	if !ok || !dev.IsUp() {
+		psLog.D(fmt.Sprintf("frame of unknown vlan was discarded: %d", vid), "device: "+msg.Dev.Name())
+		return nil, psErr.NoDataToRead
+	}
+
+	return &EthMessage{
+		Type:    typ,
+		Content: msg.Content[VlanTagLen:],
+		Dev:     dev,
+	}, psErr.OK
+}
+
+func init() {
+	vlans = make(map[vlanKey]IDevice)
+}
@@ -0,0 +1,94 @@
+package mw
+
+import (
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	"github.com/google/go-cmp/cmp"
+	"testing"
+)
+
+func TestTagPayload(t *testing.T) {
+	want := []byte{0x00, 0x0a, 0x08, 0x00, 0x01, 0x02}
+	got := TagPayload(10, EtIPV4, []byte{0x01, 0x02})
+	if d := cmp.Diff(got, want); d != "" {
+		t.Errorf("TagPayload() differs: (-got +want)\n%s", d)
+	}
+}
+
+// Success when a tagged frame is passed to the VLAN device.
+func TestReceiveFrame_1(t *testing.T) {
+	ctrl, teardown := setupEthTest(t)
+	defer teardown()
+
+	addr := EthAddr{11, 12, 13, 14, 15, 16}
+	parent := NewMockIDevice(ctrl)
+	parent.EXPECT().Name().Return("net0").AnyTimes()
+	parent.EXPECT().Addr().Return(addr).AnyTimes()
+	dev := NewMockIDevice(ctrl)
+	dev.EXPECT().IsUp().Return(true).AnyTimes()
+
+	if err := AttachVlan(parent, 10, dev); err != psErr.OK {
+		t.Fatalf("AttachVlan() = %s; want %s", err, psErr.OK)
+	}
+	defer func() { _ = DetachVlan(parent, 10) }()
+
+	frame, _ := EncodeFrame(addr, EthAddr{21, 22, 23, 24, 25, 26}, EtVLAN, TagPayload(10, EtARP, []byte{0x01}))
+
+	msg, err := ReceiveFrame(frame, parent)
+	if err != psErr.OK {
+		t.Fatalf("ReceiveFrame() = %s; want %s", err, psErr.OK)
+	}
+	if msg.Dev != dev || msg.Type != EtARP || msg.Content[0] != 0x01 {
+		t.Errorf("ReceiveFrame() returned unexpected message: %v", msg)
+	}
+}
+
+// Success when a frame of unknown VLAN is discarded.
+func TestReceiveFrame_2(t *testing.T) {
+	ctrl, teardown := setupEthTest(t)
+	defer teardown()
+
+	addr := EthAddr{11, 12, 13, 14, 15, 16}
+	parent := NewMockIDevice(ctrl)
+	parent.EXPECT().Name().Return("net0").AnyTimes()
+	parent.EXPECT().Addr().Return(addr).AnyTimes()
+
+	frame, _ := EncodeFrame(addr, EthAddr{21, 22, 23, 24, 25, 26}, EtVLAN, TagPayload(10, EtARP, []byte{0x01}))
+
+	if _, err := ReceiveFrame(frame, parent); err != psErr.NoDataToRead {
+		t.Errorf("ReceiveFrame() = %s; want %s", err, psErr.NoDataToRead)
+	}
+}
+
+// Success when an untagged frame is passed to the device itself.
+func TestReceiveFrame_3(t *testing.T) {
+	ctrl, teardown := setupEthTest(t)
+	defer teardown()
+
+	addr := EthAddr{11, 12, 13, 14, 15, 16}
+	dev := NewMockIDevice(ctrl)
+	dev.EXPECT().Name().Return("net0").AnyTimes()
+	dev.EXPECT().Addr().Return(addr).AnyTimes()
+
+	frame, _ := EncodeFrame(addr, EthAddr{21, 22, 23, 24, 25, 26}, EtIPV4, []byte{0x01})
+
+	msg, err := ReceiveFrame(frame, dev)
+	if err != psErr.OK {
+		t.Fatalf("ReceiveFrame() = %s; want %s", err, psErr.OK)
+	}
+	if msg.Dev != dev || msg.Type != EtIPV4 {
+		t.Errorf("ReceiveFrame() returned unexpected message: %v", msg)
+	}
+}
+
+// Fail when the VLAN ID is out of range.
+func TestAttachVlan(t *testing.T) {
+	ctrl, teardown := setupEthTest(t)
+	defer teardown()
+
+	parent := NewMockIDevice(ctrl)
+	parent.EXPECT().Name().Return("net0").AnyTimes()
+
+	if err := AttachVlan(parent, 0, NewMockIDevice(ctrl)); err != psErr.Error {
+		t.Errorf("AttachVlan() = %s; want %s", err, psErr.Error)
+	}
+}
@@ -0,0 +1,204 @@
+package mw
+
+import (
+	"encoding/binary"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	"github.com/42milez/ProtocolStack/src/pcap"
+	psSyscall "github.com/42milez/ProtocolStack/src/syscall"
+	"sync"
+)
+
+// struct virtio_net_hdr @ virtio_net.h
+// https://github.com/torvalds/linux/blob/master/include/uapi/linux/virtio_net.h
+
+const VnetHdrLen = 10
+const (
+	VnetFlagNeedsCsum = 0x01 // the checksum is partial and has to be completed from CsumStart
+	VnetFlagDataValid = 0x02 // the checksum has been verified
+)
+const (
+	VnetGsoNone  = 0x00
+	VnetGsoTcpV4 = 0x01
+)
+
+// a GSO frame carries an IP packet up to 64KB
+const vnetBufLen = VnetHdrLen + EthHdrLen + VlanTagLen + 0xffff
+
+// the offsets of the fields which the device fills
+const ipCsumOffset = 10
+const tcpCsumOffset = 16
+
+var vnetBufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, vnetBufLen)
+		return &b
+	},
+}
+
+// VnetHdr precedes the frames of a TAP interface opened with IFF_VNET_HDR. It tells how the checksum and segmentation
+// of the frame are offloaded. The fields are in host byte order, which is little endian on amd64.
+type VnetHdr struct {
+	Flags      uint8
+	GsoType    uint8
+	HdrLen     uint16 // length of the headers which are copied to each segment
+	GsoSize    uint16 // length of the payload of each segment
+	CsumStart  uint16
+	CsumOffset uint16
+}
+
+func (v VnetHdr) encode(b []byte) {
+	b[0] = v.Flags
+	b[1] = v.GsoType
+	binary.LittleEndian.PutUint16(b[2:], v.HdrLen)
+	binary.LittleEndian.PutUint16(b[4:], v.GsoSize)
+	binary.LittleEndian.PutUint16(b[6:], v.CsumStart)
+	binary.LittleEndian.PutUint16(b[8:], v.CsumOffset)
+}
+
+func decodeVnetHdr(b []byte) VnetHdr {
+	return VnetHdr{
+		Flags:      b[0],
+		GsoType:    b[1],
+		HdrLen:     binary.LittleEndian.Uint16(b[2:]),
+		GsoSize:    binary.LittleEndian.Uint16(b[4:]),
+		CsumStart:  binary.LittleEndian.Uint16(b[6:]),
+		CsumOffset: binary.LittleEndian.Uint16(b[8:]),
+	}
+}
+
+// NewVnetHdr builds the header of a frame which the stack sends through a device reporting TxCsumOffload and
+// TsoOffload. The IP header checksum is filled in place, and the TCP checksum, which holds the checksum of the pseudo
+// header, is left to the kernel. A TCP segment larger than the MTU is segmented by the kernel.
+func NewVnetHdr(frame []byte, mtu uint16) VnetHdr {
+	hdr := VnetHdr{}
+
+	packet, ihl := ipv4Packet(frame)
+	if packet == nil {
+		return hdr
+	}
+
+	packet[ipCsumOffset] = 0
+	packet[ipCsumOffset+1] = 0
+	binary.BigEndian.PutUint16(packet[ipCsumOffset:], Checksum(packet[:ihl], 0))
+
+	if ProtocolNumber(packet[9]) != PnTCP || len(packet) < ihl+tcpCsumOffset+2 {
+		return hdr
+	}
+	hdr.Flags = VnetFlagNeedsCsum
+	hdr.CsumStart = uint16(EthHdrLen + ihl)
+	hdr.CsumOffset = tcpCsumOffset
+
+	if len(packet) > int(mtu) {
+		thl := int(packet[ihl+12]>>4) << 2
+		hdr.GsoType = VnetGsoTcpV4
+		hdr.HdrLen = uint16(EthHdrLen + ihl + thl)
+		hdr.GsoSize = mtu - uint16(ihl+thl)
+	}
+
+	return hdr
+}
+
+// ReadVnetFrames works the same way as ReadFrames() for a descriptor of a TAP interface opened with IFF_VNET_HDR.
+// Frames whose checksums neither are partial nor have been verified by the kernel are verified here, so that the
+// device can report RxCsumOffload. Partial checksums are completed when the frame isn't processed by the device itself
+// (i.e. it's passed to a receive handler or a VLAN device).
+func ReadVnetFrames(fd int, dev IDevice) ([]*EthMessage, error) {
+	return readFrames(fd, dev, &vnetBufPool, func(b []byte) ([]byte, error) {
+		return StripVnetHdr(b, dev)
+	})
+}
+
+// StripVnetHdr takes the frame out of the data read from the device, taking care of the checksum as ReadVnetFrames()
+// does.
+func StripVnetHdr(b []byte, dev IDevice) ([]byte, error) {
+	if len(b) < VnetHdrLen+EthHdrLen {
+		return nil, psErr.InvalidPacketLength
+	}
+	hdr := decodeVnetHdr(b)
+	frame := b[VnetHdrLen:]
+
+	direct := rxHandler(dev) == nil && EthType(binary.BigEndian.Uint16(frame[12:])) != EtVLAN
+
+	switch {
+	case hdr.Flags&VnetFlagNeedsCsum != 0:
+		if !direct {
+			return frame, completeCsum(frame, hdr)
+		}
+	case hdr.Flags&VnetFlagDataValid != 0:
+	default:
+		if direct && !verifyCsum(frame) {
+			return nil, psErr.ChecksumMismatch
+		}
+	}
+
+	return frame, psErr.OK
+}
+
+// Prepend returns the frame preceded by the header.
+func (v VnetHdr) Prepend(frame []byte) []byte {
+	b := make([]byte, VnetHdrLen+len(frame))
+	v.encode(b)
+	copy(b[VnetHdrLen:], frame)
+	return b
+}
+
+// WriteVnetFrame writes the frame preceded by the header.
+func WriteVnetFrame(fd int, dev IDevice, hdr VnetHdr, frame []byte) error {
+	if _, err := psSyscall.Syscall.Write(fd, hdr.Prepend(frame)); err != nil {
+		return psErr.SyscallError
+	}
+	CaptureFrame(dev, pcap.DirectionOutbound, frame)
+
+	return psErr.OK
+}
+
+// completeCsum computes the checksum from CsumStart to the end of the frame, which includes the partial checksum.
+func completeCsum(frame []byte, hdr VnetHdr) error {
+	start := int(hdr.CsumStart)
+	offset := start + int(hdr.CsumOffset)
+	if offset+2 > len(frame) {
+		return psErr.InvalidPacket
+	}
+	binary.BigEndian.PutUint16(frame[offset:], Checksum(frame[start:], 0))
+	return psErr.OK
+}
+
+// verifyCsum verifies the IP header checksum and the TCP checksum of the frame. Frames which aren't IPv4 are left to
+// the upper layers, as are malformed ones.
+func verifyCsum(frame []byte) bool {
+	packet, ihl := ipv4Packet(frame)
+	if packet == nil {
+		return true
+	}
+	if Checksum(packet[:ihl], 0) != 0 {
+		return false
+	}
+	if ProtocolNumber(packet[9]) != PnTCP {
+		return true
+	}
+
+	segment := packet[ihl:]
+	pseudo := make([]byte, 12)
+	copy(pseudo[0:8], packet[12:20])
+	pseudo[9] = uint8(PnTCP)
+	binary.BigEndian.PutUint16(pseudo[10:], uint16(len(segment)))
+
+	return Checksum(segment, uint32(^Checksum(pseudo, 0))) == 0
+}
+
+// ipv4Packet returns the IPv4 packet carried by the frame without the ethernet padding, and the length of its header.
+// It returns nil when the frame doesn't carry a well-formed IPv4 packet.
+func ipv4Packet(frame []byte) ([]byte, int) {
+	if len(frame) < EthHdrLen+20 || EthType(binary.BigEndian.Uint16(frame[12:])) != EtIPV4 {
+		return nil, 0
+	}
+	packet := frame[EthHdrLen:]
+
+	ihl := int(packet[0]&0x0f) << 2
+	totalLen := int(binary.BigEndian.Uint16(packet[2:]))
+	if ihl < 20 || totalLen < ihl || totalLen > len(packet) {
+		return nil, 0
+	}
+
+	return packet[:totalLen], ihl
+}
@@ -0,0 +1,179 @@
+package mw
+
+import (
+	"encoding/binary"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psSyscall "github.com/42milez/ProtocolStack/src/syscall"
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	"syscall"
+	"testing"
+)
+
+// Success when the header of a TCP segment larger than the MTU requests segmentation.
+func TestNewVnetHdr_1(t *testing.T) {
+	frame := tcpFrame(EthAddr{11, 12, 13, 14, 15, 16}, 2000, false)
+
+	want := VnetHdr{
+		Flags:      VnetFlagNeedsCsum,
+		GsoType:    VnetGsoTcpV4,
+		HdrLen:     EthHdrLen + 40,
+		GsoSize:    1460,
+		CsumStart:  EthHdrLen + 20,
+		CsumOffset: 16,
+	}
+	got := NewVnetHdr(frame, 1500)
+	if d := cmp.Diff(got, want); d != "" {
+		t.Errorf("NewVnetHdr() differs: (-got +want)\n%s", d)
+	}
+	if csum := Checksum(frame[EthHdrLen:EthHdrLen+20], 0); csum != 0 {
+		t.Errorf("ip header checksum = 0x%04x; want valid", csum)
+	}
+}
+
+// Success when the header of a segment within the MTU only requests the checksum.
+func TestNewVnetHdr_2(t *testing.T) {
+	frame := tcpFrame(EthAddr{11, 12, 13, 14, 15, 16}, 100, false)
+
+	want := VnetHdr{
+		Flags:      VnetFlagNeedsCsum,
+		CsumStart:  EthHdrLen + 20,
+		CsumOffset: 16,
+	}
+	got := NewVnetHdr(frame, 1500)
+	if d := cmp.Diff(got, want); d != "" {
+		t.Errorf("NewVnetHdr() differs: (-got +want)\n%s", d)
+	}
+}
+
+// Success when the header of a frame which doesn't carry TCP is empty.
+func TestNewVnetHdr_3(t *testing.T) {
+	frame, _ := EncodeFrame(EthBroadcast, EthAddr{21, 22, 23, 24, 25, 26}, EtARP, make([]byte, 28))
+
+	got := NewVnetHdr(frame, 1500)
+	if d := cmp.Diff(got, VnetHdr{}); d != "" {
+		t.Errorf("NewVnetHdr() differs: (-got +want)\n%s", d)
+	}
+}
+
+// Success when a frame of which checksum is wrong is discarded unless the kernel has taken care of the checksum.
+func TestReadVnetFrames_1(t *testing.T) {
+	ctrl, teardown := setupEthTest(t)
+	defer teardown()
+
+	addr := EthAddr{11, 12, 13, 14, 15, 16}
+	read := func(hdr VnetHdr, frame []byte) func(_ int, buf []byte) (int, error) {
+		return func(_ int, buf []byte) (int, error) {
+			hdr.encode(buf)
+			return VnetHdrLen + copy(buf[VnetHdrLen:], frame), nil
+		}
+	}
+	partial := VnetHdr{Flags: VnetFlagNeedsCsum, CsumStart: EthHdrLen + 20, CsumOffset: 16}
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	gomock.InOrder(
+		m.EXPECT().Read(any, any).DoAndReturn(read(partial, tcpFrame(addr, 100, true))),
+		m.EXPECT().Read(any, any).DoAndReturn(read(VnetHdr{Flags: VnetFlagDataValid}, tcpFrame(addr, 100, true))),
+		m.EXPECT().Read(any, any).DoAndReturn(read(VnetHdr{}, tcpFrame(addr, 100, false))),
+		m.EXPECT().Read(any, any).DoAndReturn(read(VnetHdr{}, tcpFrame(addr, 100, true))),
+		m.EXPECT().Read(any, any).DoAndReturn(read(VnetHdr{}, tcpFrame(addr, 3000, false))),
+		m.EXPECT().Read(any, any).Return(-1, syscall.EAGAIN),
+	)
+	psSyscall.Syscall = m
+
+	dev := NewMockIDevice(ctrl)
+	dev.EXPECT().Addr().Return(addr).AnyTimes()
+	dev.EXPECT().Name().Return("net0").AnyTimes()
+
+	msgs, got := ReadVnetFrames(3, dev)
+	if got != psErr.OK {
+		t.Errorf("ReadVnetFrames() = %s; want %s", got, psErr.OK)
+	}
+	// the frame with the partial checksum which isn't taken care of is discarded
+	if len(msgs) != 4 {
+		t.Fatalf("len(msgs) = %d; want %d", len(msgs), 4)
+	}
+	// a GSO frame is larger than the MTU
+	if n := len(msgs[3].Content); n != 3040 {
+		t.Errorf("len(msgs[3].Content) = %d; want %d", n, 3040)
+	}
+}
+
+// Success when the partial checksum is completed before the frame is passed to the receive handler.
+func TestReadVnetFrames_2(t *testing.T) {
+	ctrl, teardown := setupEthTest(t)
+	defer teardown()
+
+	addr := EthAddr{11, 12, 13, 14, 15, 16}
+	m := psSyscall.NewMockISyscall(ctrl)
+	gomock.InOrder(
+		m.EXPECT().Read(any, any).DoAndReturn(func(_ int, buf []byte) (int, error) {
+			VnetHdr{Flags: VnetFlagNeedsCsum, CsumStart: EthHdrLen + 20, CsumOffset: 16}.encode(buf)
+			return VnetHdrLen + copy(buf[VnetHdrLen:], tcpFrame(addr, 101, true)), nil
+		}),
+		m.EXPECT().Read(any, any).Return(-1, syscall.EAGAIN),
+	)
+	psSyscall.Syscall = m
+
+	dev := NewMockIDevice(ctrl)
+	dev.EXPECT().Name().Return("net0").AnyTimes()
+
+	var frames [][]byte
+	if err := SetRxHandler(dev, func(_ IDevice, frame []byte) {
+		b := make([]byte, len(frame))
+		copy(b, frame)
+		frames = append(frames, b)
+	}); err != psErr.OK {
+		t.Fatalf("SetRxHandler() = %s; want %s", err, psErr.OK)
+	}
+	defer func() { _ = SetRxHandler(dev, nil) }()
+
+	if _, got := ReadVnetFrames(3, dev); got != psErr.NoDataToRead {
+		t.Errorf("ReadVnetFrames() = %s; want %s", got, psErr.NoDataToRead)
+	}
+	if len(frames) != 1 {
+		t.Fatalf("len(frames) = %d; want %d", len(frames), 1)
+	}
+	if !verifyCsum(frames[0]) {
+		t.Errorf("checksum of the frame is incomplete")
+	}
+}
+
+// tcpFrame builds a frame carrying a TCP segment with the payload of the length. The TCP checksum holds only the
+// checksum of the pseudo header when partial is true.
+func tcpFrame(dst EthAddr, payloadLen int, partial bool) []byte {
+	packet := make([]byte, 40+payloadLen)
+	packet[0] = 0x45
+	binary.BigEndian.PutUint16(packet[2:], uint16(len(packet)))
+	packet[8] = 0xff
+	packet[9] = uint8(PnTCP)
+	copy(packet[12:16], []byte{192, 0, 2, 1})
+	copy(packet[16:20], []byte{192, 0, 2, 2})
+	binary.BigEndian.PutUint16(packet[10:], Checksum(packet[:20], 0))
+
+	segment := packet[20:]
+	binary.BigEndian.PutUint16(segment[0:], 12345)
+	binary.BigEndian.PutUint16(segment[2:], 80)
+	segment[12] = 5 << 4
+	for i := range segment[20:] {
+		segment[20+i] = uint8(i)
+	}
+
+	pseudo := make([]byte, 12)
+	copy(pseudo, packet[12:20])
+	pseudo[9] = uint8(PnTCP)
+	binary.BigEndian.PutUint16(pseudo[10:], uint16(len(segment)))
+	csum := ^Checksum(pseudo, 0)
+	if !partial {
+		csum = Checksum(segment, uint32(csum))
+	}
+	binary.BigEndian.PutUint16(segment[16:], csum)
+
+	frame := make([]byte, EthHdrLen+len(packet))
+	copy(frame, dst[:])
+	copy(frame[EthAddrLen:], []byte{21, 22, 23, 24, 25, 26})
+	binary.BigEndian.PutUint16(frame[12:], uint16(EtIPV4))
+	copy(frame[EthHdrLen:], packet)
+
+	return frame
+}
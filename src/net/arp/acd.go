@@ -0,0 +1,251 @@
+package arp
+
+import (
+	"fmt"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/42milez/ProtocolStack/src/repo"
+	psTime "github.com/42milez/ProtocolStack/src/time"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// IPv4 Address Conflict Detection
+// https://datatracker.ietf.org/doc/html/rfc5227
+
+const (
+	probeWait        = time.Second      // initial random delay
+	probeNum         = 3                // number of probe packets
+	probeMin         = time.Second      // minimum delay until repeated probe
+	probeMax         = 2 * time.Second  // maximum delay until repeated probe
+	announceWait     = 2 * time.Second  // delay before announcing
+	announceNum      = 2                // number of announcement packets
+	announceInterval = 2 * time.Second  // time between announcement packets
+	defendInterval   = 10 * time.Second // minimum interval between defensive ARPs
+)
+
+const (
+	claimWaiting claimStatus = iota
+	claimProbing
+	claimAnnouncing
+	claimBound
+)
+
+var claimStatuses = map[claimStatus]string{
+	claimWaiting:    "WAITING",
+	claimProbing:    "PROBING",
+	claimAnnouncing: "ANNOUNCING",
+	claimBound:      "BOUND",
+}
+
+var claims map[string]*claim
+
+// the devices which are up and resolve addresses with ARP, i.e. the devices of which addresses are probed
+var acdDevs map[string]bool
+var claimMtx sync.Mutex
+
+type claimStatus int
+
+func (v claimStatus) String() string {
+	return claimStatuses[v]
+}
+
+// claim is the state of the address which the interface claims on the link of its device.
+type claim struct {
+	iface      *mw.Iface
+	addr       mw.V4Addr
+	status     claimStatus
+	count      int       // number of the probes or the announcements which have been sent
+	next       time.Time // when the next probe or announcement is sent
+	defendedAt time.Time
+}
+
+type claimPacket struct {
+	iface *mw.Iface
+	spa   mw.V4Addr
+	tpa   mw.V4Addr
+}
+
+// startClaim starts claiming the address of the interface. The address is probed and announced by the timer while the
+// device is up.
+func startClaim(iface *mw.Iface) {
+	addr := iface.Unicast.ToV4()
+	if addr == (mw.V4Addr{}) {
+		return
+	}
+
+	claimMtx.Lock()
+	defer claimMtx.Unlock()
+
+	claims[iface.Dev.Name()] = &claim{
+		iface:  iface,
+		addr:   addr,
+		status: claimWaiting,
+	}
+}
+
+// dropClaim stops claiming the address on the device.
+func dropClaim(dev string, addr mw.IP) {
+	claimMtx.Lock()
+	defer claimMtx.Unlock()
+
+	if c, ok := claims[dev]; ok && addr.EqualV4(c.addr) {
+		delete(claims, dev)
+	}
+}
+
+// deviceChanged starts probing the addresses of the device when it comes up. The address is claimed again each time,
+// since another host may have taken it while the device was down.
+func deviceChanged(dev mw.IDevice, up bool) {
+	name := dev.Name()
+	needArp := dev.Flag()&mw.NeedArpFlag != 0
+
+	claimMtx.Lock()
+	defer claimMtx.Unlock()
+
+	if up && needArp {
+		acdDevs[name] = true
+	} else {
+		delete(acdDevs, name)
+	}
+	if c, ok := claims[name]; ok {
+		c.status = claimWaiting
+		c.count = 0
+	}
+}
+
+// Tentative reports whether the address of the interface is still probed. The address isn't used, i.e. the stack
+// neither replies to the requests for it nor sends packets from it, until the probes complete.
+func Tentative(iface *mw.Iface) bool {
+	claimMtx.Lock()
+	defer claimMtx.Unlock()
+
+	for dev, c := range claims {
+		if c.iface == iface {
+			return acdDevs[dev] && c.addr == iface.Unicast.ToV4() &&
+				(c.status == claimWaiting || c.status == claimProbing)
+		}
+	}
+	return false
+}
+
+// runClaims sends the probes and the announcements which are due on the devices which are up.
+func runClaims(now time.Time) {
+	var packets []claimPacket
+
+	claimMtx.Lock()
+	for dev, c := range claims {
+		if !acdDevs[dev] {
+			continue
+		}
+		switch c.status {
+		case claimWaiting:
+			c.status = claimProbing
+			c.next = now.Add(jitter(0, probeWait))
+		case claimProbing:
+			if now.Before(c.next) {
+				continue
+			}
+			if c.count < probeNum {
+				packets = append(packets, claimPacket{iface: c.iface, tpa: c.addr})
+				c.count++
+				if c.count < probeNum {
+					c.next = now.Add(jitter(probeMin, probeMax))
+				} else {
+					c.next = now.Add(announceWait)
+				}
+				continue
+			}
+			c.status = claimAnnouncing
+			c.count = 0
+			fallthrough
+		case claimAnnouncing:
+			if now.Before(c.next) {
+				continue
+			}
+			packets = append(packets, claimPacket{iface: c.iface, spa: c.addr, tpa: c.addr})
+			c.count++
+			c.next = now.Add(announceInterval)
+			if c.count == announceNum {
+				c.status = claimBound
+				psLog.I(fmt.Sprintf("address was claimed: %s (%s)", c.addr, dev))
+			}
+		}
+	}
+	claimMtx.Unlock()
+
+	for _, v := range packets {
+		if err := sendPacket(v.iface, v.spa, v.tpa, mw.EthBroadcast); err != psErr.OK {
+			psLog.E(fmt.Sprintf("can't send arp probe or announcement: %s", err))
+		}
+	}
+}
+
+// checkConflict reports whether the packet claims the address of the interface. The address is given up when the
+// conflict is detected while it's probed, and it's defended once per defendInterval after it's announced.
+func checkConflict(iface *mw.Iface, packet *Packet, dev mw.IDevice) bool {
+	// our own packets looped back don't conflict
+	if packet.SHA == dev.Addr() {
+		return false
+	}
+
+	addr := iface.Unicast.ToV4()
+	name := dev.Name()
+
+	claimMtx.Lock()
+	c, ok := claims[name]
+	if ok && c.addr != addr {
+		ok = false
+	}
+	tentative := ok && acdDevs[name] && (c.status == claimWaiting || c.status == claimProbing)
+	// another host which probes the address conflicts only with the address which isn't ours yet
+	probed := tentative && packet.Opcode == Request && packet.SPA == (mw.V4Addr{}) && packet.TPA == addr
+	if packet.SPA != addr && !probed {
+		claimMtx.Unlock()
+		return false
+	}
+
+	now := psTime.Time.Now()
+	giveUp := tentative || (ok && !c.defendedAt.IsZero() && now.Sub(c.defendedAt) < defendInterval)
+	if giveUp {
+		delete(claims, name)
+	} else {
+		if !ok {
+			c = &claim{iface: iface, addr: addr, status: claimBound}
+			claims[name] = c
+		}
+		c.defendedAt = now
+	}
+	claimMtx.Unlock()
+
+	if giveUp {
+		psLog.E("address conflict was detected (the address was given up)",
+			fmt.Sprintf("address: %s (%s)", addr, name),
+			fmt.Sprintf("sha:     %s", packet.SHA))
+		if err := repo.IfaceRepo.Unregister(iface); err != psErr.OK {
+			psLog.E(fmt.Sprintf("can't unregister interface: %s", err))
+		}
+		return true
+	}
+
+	psLog.W("address conflict was detected (the address was defended)",
+		fmt.Sprintf("address: %s (%s)", addr, name),
+		fmt.Sprintf("sha:     %s", packet.SHA))
+	if err := sendPacket(iface, addr, addr, mw.EthBroadcast); err != psErr.OK {
+		psLog.E(fmt.Sprintf("can't send arp announcement: %s", err))
+	}
+
+	return true
+}
+
+// jitter returns the random duration in [min, max).
+func jitter(min time.Duration, max time.Duration) time.Duration {
+	return min + time.Duration(rand.Int63n(int64(max-min)))
+}
+
+func init() {
+	claims = make(map[string]*claim)
+	acdDevs = make(map[string]bool)
+}
@@ -0,0 +1,214 @@
+package arp
+
+import (
+	"bytes"
+	"encoding/binary"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/42milez/ProtocolStack/src/repo"
+	psTime "github.com/42milez/ProtocolStack/src/time"
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	"testing"
+	"time"
+)
+
+// Success when the address is probed three times and announced twice.
+func TestRunClaims(t *testing.T) {
+	ctrl, teardown := SetupClaimTest(t)
+	defer teardown()
+
+	var got []*Packet
+	dev := createClaimDevice(ctrl, &got)
+	iface := &mw.Iface{Family: mw.V4AddrFamily, Unicast: mw.ParseIP("192.0.2.1"), Dev: dev}
+	startClaim(iface)
+	deviceChanged(dev, true)
+
+	mockIfaceRepo := repo.NewMockIIfaceRepo(ctrl)
+	mockIfaceRepo.EXPECT().Get(iface.Unicast).Return(iface).Times(5)
+	repo.IfaceRepo = mockIfaceRepo
+
+	now, _ := time.Parse(time.RFC3339, "2021-01-01T00:00:00Z")
+	for i := 0; i < 12; i++ {
+		runClaims(now.Add(time.Duration(i) * probeMax))
+	}
+
+	addr := mw.V4Addr{192, 0, 2, 1}
+	want := [][2]mw.V4Addr{{{}, addr}, {{}, addr}, {{}, addr}, {addr, addr}, {addr, addr}}
+	var sent [][2]mw.V4Addr
+	for _, v := range got {
+		sent = append(sent, [2]mw.V4Addr{v.SPA, v.TPA})
+	}
+	if d := cmp.Diff(sent, want); d != "" {
+		t.Errorf("runClaims() differs: (-got +want)\n%s", d)
+	}
+	if st := claims["net0"].status; st != claimBound {
+		t.Errorf("status = %s; want %s", st, claimBound)
+	}
+	if Tentative(iface) {
+		t.Errorf("Tentative() = true; want false")
+	}
+}
+
+// Success when the address is given up if another host claims it while it's probed.
+func TestCheckConflict_1(t *testing.T) {
+	ctrl, teardown := SetupClaimTest(t)
+	defer teardown()
+
+	m := psTime.NewMockITime(ctrl)
+	m.EXPECT().Now().Return(time.Time{})
+	psTime.Time = m
+
+	var got []*Packet
+	dev := createClaimDevice(ctrl, &got)
+	iface := &mw.Iface{Family: mw.V4AddrFamily, Unicast: mw.ParseIP("192.0.2.1"), Dev: dev}
+	startClaim(iface)
+	deviceChanged(dev, true)
+
+	mockIfaceRepo := repo.NewMockIIfaceRepo(ctrl)
+	mockIfaceRepo.EXPECT().Unregister(iface).Return(psErr.OK)
+	repo.IfaceRepo = mockIfaceRepo
+
+	packet := builder.Default()
+	packet.SPA = mw.V4Addr{}
+	packet.TPA = mw.V4Addr{192, 0, 2, 1}
+
+	if !checkConflict(iface, packet, dev) {
+		t.Errorf("checkConflict() = false; want true")
+	}
+	if _, ok := claims["net0"]; ok {
+		t.Errorf("claim of given up address remains")
+	}
+	if len(got) != 0 {
+		t.Errorf("%d packets were sent; want %d", len(got), 0)
+	}
+}
+
+// Success when the address is defended once and given up if it's claimed again within defendInterval.
+func TestCheckConflict_2(t *testing.T) {
+	ctrl, teardown := SetupClaimTest(t)
+	defer teardown()
+
+	now, _ := time.Parse(time.RFC3339, "2021-01-01T00:00:00Z")
+	m := psTime.NewMockITime(ctrl)
+	m.EXPECT().Now().Return(now)
+	m.EXPECT().Now().Return(now.Add(defendInterval / 2))
+	psTime.Time = m
+
+	var got []*Packet
+	dev := createClaimDevice(ctrl, &got)
+	iface := &mw.Iface{Family: mw.V4AddrFamily, Unicast: mw.ParseIP("192.0.2.1"), Dev: dev}
+
+	mockIfaceRepo := repo.NewMockIIfaceRepo(ctrl)
+	mockIfaceRepo.EXPECT().Get(iface.Unicast).Return(iface)
+	mockIfaceRepo.EXPECT().Unregister(iface).Return(psErr.OK)
+	repo.IfaceRepo = mockIfaceRepo
+
+	packet := builder.Default()
+	packet.Opcode = Reply
+
+	if !checkConflict(iface, packet, dev) {
+		t.Errorf("checkConflict() = false; want true")
+	}
+	if len(got) != 1 || got[0].SPA != packet.SPA || got[0].TPA != packet.SPA {
+		t.Errorf("address wasn't announced to defend it")
+	}
+	if !checkConflict(iface, packet, dev) {
+		t.Errorf("checkConflict() = false; want true")
+	}
+	if len(got) != 1 {
+		t.Errorf("%d packets were sent; want %d", len(got), 1)
+	}
+}
+
+// Fail when the packet is sent from our own device or it doesn't claim our address.
+func TestCheckConflict_3(t *testing.T) {
+	ctrl, teardown := SetupClaimTest(t)
+	defer teardown()
+
+	var got []*Packet
+	dev := createClaimDevice(ctrl, &got)
+	iface := &mw.Iface{Family: mw.V4AddrFamily, Unicast: mw.ParseIP("192.0.2.1"), Dev: dev}
+	startClaim(iface)
+	deviceChanged(dev, true)
+
+	looped := builder.Default()
+	looped.SHA = dev.Addr()
+	other := builder.Default()
+	other.SPA = mw.V4Addr{192, 0, 2, 3}
+
+	for _, v := range []*Packet{looped, other} {
+		if checkConflict(iface, v, dev) {
+			t.Errorf("checkConflict() = true; want false")
+		}
+	}
+	if claims["net0"].status != claimWaiting {
+		t.Errorf("status = %s; want %s", claims["net0"].status, claimWaiting)
+	}
+}
+
+// Success when the request for the address which is still probed isn't replied.
+func TestReceive_8(t *testing.T) {
+	ctrl, teardown := SetupClaimTest(t)
+	defer teardown()
+
+	var got []*Packet
+	dev := createClaimDevice(ctrl, &got)
+	iface := &mw.Iface{Family: mw.V4AddrFamily, Unicast: mw.ParseIP("192.0.2.2"), Dev: dev}
+	startClaim(iface)
+	deviceChanged(dev, true)
+
+	mockIfaceRepo := repo.NewMockIIfaceRepo(ctrl)
+	mockIfaceRepo.EXPECT().Lookup(any, any).Return(iface)
+	repo.IfaceRepo = mockIfaceRepo
+
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.BigEndian, builder.Default())
+
+	if err := Receive(buf.Bytes(), dev); err != psErr.OK {
+		t.Errorf("Receive() = %s; want %s", err, psErr.OK)
+	}
+	if !Tentative(iface) {
+		t.Errorf("Tentative() = false; want true")
+	}
+	if len(got) != 0 {
+		t.Errorf("%d packets were sent; want %d", len(got), 0)
+	}
+}
+
+// createClaimDevice returns the device which is up and records the packets it sends.
+func createClaimDevice(ctrl *gomock.Controller, sent *[]*Packet) *mw.MockIDevice {
+	dev := mw.NewMockIDevice(ctrl)
+	dev.EXPECT().Name().Return("net0").AnyTimes()
+	dev.EXPECT().Addr().Return(mw.EthAddr{0x11, 0x12, 0x13, 0x14, 0x15, 0x16}).AnyTimes()
+	dev.EXPECT().Flag().Return(mw.BroadcastFlag | mw.NeedArpFlag).AnyTimes()
+	dev.EXPECT().IsUp().Return(true).AnyTimes()
+	dev.EXPECT().MTU().Return(uint16(mw.EthPayloadLenMax)).AnyTimes()
+	dev.EXPECT().Transmit(mw.EthBroadcast, any, mw.EtARP).DoAndReturn(
+		func(_ mw.EthAddr, packet []byte, _ mw.EthType) error {
+			p := &Packet{}
+			_ = binary.Read(bytes.NewBuffer(packet), binary.BigEndian, p)
+			*sent = append(*sent, p)
+			return psErr.OK
+		}).AnyTimes()
+	return dev
+}
+
+func SetupClaimTest(t *testing.T) (ctrl *gomock.Controller, teardown func()) {
+	psLog.DisableOutput()
+	ctrl = gomock.NewController(t)
+	backupIfaceRepo := repo.IfaceRepo
+	backupTime := psTime.Time
+
+	teardown = func() {
+		claims = make(map[string]*claim)
+		acdDevs = make(map[string]bool)
+		repo.IfaceRepo = backupIfaceRepo
+		psTime.Time = backupTime
+		ctrl.Finish()
+		psLog.EnableOutput()
+	}
+
+	return
+}
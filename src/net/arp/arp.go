@@ -13,6 +13,7 @@ import (
 	"github.com/42milez/ProtocolStack/src/mw"
 	"github.com/42milez/ProtocolStack/src/net"
 	"github.com/42milez/ProtocolStack/src/repo"
+	psTime "github.com/42milez/ProtocolStack/src/time"
 	"github.com/42milez/ProtocolStack/src/worker"
 	"sync"
 	"time"
@@ -26,6 +27,7 @@ const (
 	Complete Status = iota
 	Incomplete
 	Error
+	Failed
 )
 const xChBufSize = 5
 
@@ -197,14 +199,39 @@ func Receive(packet []byte, dev mw.IDevice) error {
 		return psErr.InterfaceNotFound
 	}
 
-	if iface.Unicast.EqualV4(arpPacket.TPA) {
-		if err := cache.Renew(arpPacket.SPA, arpPacket.SHA, resolved); err == psErr.NotFound {
-			_ = cache.Create(arpPacket.SHA, arpPacket.SPA, resolved)
-		} else {
+	// the packet which claims our address isn't cached
+	if checkConflict(iface, &arpPacket, dev) {
+		return psErr.OK
+	}
+
+	// the address isn't ours until it's claimed
+	forUs := iface.Unicast.EqualV4(arpPacket.TPA) && !Tentative(iface)
+
+	// the sender of a packet which isn't for us updates only the existing entry (Packet Reception of RFC 826), and a
+	// reply to us confirms the neighbor while the other packets only tell its address
+	// https://datatracker.ietf.org/doc/html/rfc826
+	if arpPacket.SPA != (mw.V4Addr{}) {
+		st := stale
+		if forUs && arpPacket.Opcode == Reply {
+			st = reachable
+		}
+		key := neighKey{dev: dev.Name(), pa: arpPacket.SPA}
+		switch cache.Renew(key.dev, key.pa, arpPacket.SHA, st) {
+		case psErr.NotFound:
+			if forUs {
+				if err := cache.Create(key.dev, arpPacket.SHA, key.pa, st); err == psErr.OK {
+					release(key, arpPacket.SHA)
+				}
+			}
+		case psErr.OK:
 			psLog.I("arp cache entry was renewed",
 				fmt.Sprintf("spa: %s", arpPacket.SPA),
 				fmt.Sprintf("sha: %s", arpPacket.SHA))
+			release(key, arpPacket.SHA)
 		}
+	}
+
+	if forUs {
 		if arpPacket.Opcode == Request {
 			if err := SendReply(arpPacket.SHA, arpPacket.SPA, iface); err != psErr.OK {
 				return psErr.Error
@@ -248,7 +275,19 @@ func SendReply(tha mw.EthAddr, tpa mw.V4Addr, iface *mw.Iface) error {
 	return psErr.OK
 }
 
+// SendRequest broadcasts the request for the address.
 func SendRequest(iface *mw.Iface, ip mw.IP) error {
+	return sendRequest(iface, ip, mw.EthBroadcast)
+}
+
+// sendRequest sends the request for the address to the destination, which is the neighbor itself when it's probed.
+func sendRequest(iface *mw.Iface, ip mw.IP, dst mw.EthAddr) error {
+	return sendPacket(iface, iface.Unicast.ToV4(), ip.ToV4(), dst)
+}
+
+// sendPacket sends the request of which sender and target protocol addresses are given. The sender address is zero in
+// the probes and the same as the target address in the announcements.
+func sendPacket(iface *mw.Iface, spa mw.V4Addr, tpa mw.V4Addr, dst mw.EthAddr) error {
 	packet := Packet{
 		Hdr: Hdr{
 			HT:     Ethernet,
@@ -258,9 +297,9 @@ func SendRequest(iface *mw.Iface, ip mw.IP) error {
 			Opcode: Request,
 		},
 		SHA: iface.Dev.Addr(),
-		SPA: iface.Unicast.ToV4(),
+		SPA: spa,
 		THA: mw.EthAddr{},
-		TPA: ip.ToV4(),
+		TPA: tpa,
 	}
 
 	buf := new(bytes.Buffer)
@@ -271,7 +310,7 @@ func SendRequest(iface *mw.Iface, ip mw.IP) error {
 
 	psLog.D("outgoing arp packet", dump(rawPacket)...)
 
-	if err := net.Transmit(mw.EthBroadcast, rawPacket, mw.EtARP, iface); err != psErr.OK {
+	if err := net.Transmit(dst, rawPacket, mw.EtARP, iface); err != psErr.OK {
 		return psErr.Error
 	}
 
@@ -291,18 +330,28 @@ func (resolver) Resolve(iface *mw.Iface, ip mw.IP) (mw.EthAddr, Status) {
 		return mw.EthAddr{}, Error
 	}
 
-	entry := cache.GetEntry(ip.ToV4())
-	if entry == nil {
-		if err := cache.Create(mw.EthAddr{}, ip.ToV4(), incomplete); err != psErr.OK {
+	pa := ip.ToV4()
+	entry, ok := cache.Lookup(iface, pa)
+	if !ok {
+		if err := cache.Create(iface.Dev.Name(), mw.EthAddr{}, pa, incomplete); err != psErr.OK {
 			return mw.EthAddr{}, Error
 		}
+		// the request is sent again by the timer when it's lost
+		cache.Probe(iface, pa, psTime.Time.Now())
 		if err := SendRequest(iface, ip); err != psErr.OK {
 			return mw.EthAddr{}, Error
 		}
 		return mw.EthAddr{}, Incomplete
 	}
 
-	return entry.HA, Complete
+	switch entry.Status {
+	case incomplete:
+		return mw.EthAddr{}, Incomplete
+	case failed:
+		return mw.EthAddr{}, Failed
+	default:
+		return entry.HA, Complete
+	}
 }
 
 func Start(wg *sync.WaitGroup) error {
@@ -361,7 +410,8 @@ func receiver(wg *sync.WaitGroup) {
 				return
 			}
 		case msg := <-mw.ArpRxCh:
-			if err := Receive(msg.Packet, msg.Dev); err != psErr.OK {
+			// the device keeps receiving packets after its address is given up
+			if err := Receive(msg.Packet, msg.Dev); err != psErr.OK && err != psErr.InterfaceNotFound {
 				return
 			}
 		}
@@ -405,18 +455,56 @@ func timer(wg *sync.WaitGroup) {
 				return
 			}
 		default:
-			ret := cache.Expire()
+			// all the timers see the same time in a tick
+			now := psTime.Time.Now()
+			ret := cache.Expire(now)
 			if len(ret) != 0 {
 				psLog.I("arp cache entries were expired")
 				for i, v := range ret {
 					psLog.I(fmt.Sprintf("%d: %s", i+1, v))
 				}
 			}
+			retransmit(now)
+			expireHeld(now)
+			runClaims(now)
 		}
 		time.Sleep(time.Second)
 	}
 }
 
+// retransmit sends the requests for the addresses which haven't been resolved and the neighbors which are probed, and
+// reports the packets which wait for the addresses which couldn't be resolved.
+func retransmit(now time.Time) {
+	probes, failures := cache.Retransmit(now)
+	for _, v := range probes {
+		dst := mw.EthBroadcast
+		if v.Status == probe {
+			dst = v.HA
+		}
+		psLog.D(fmt.Sprintf("arp request was sent: %s (%s, %d/%d)", v.PA, v.Status, v.Probes, maxProbes))
+		if err := sendRequest(v.Iface, mw.V4FromByte(v.PA), dst); err != psErr.OK {
+			psLog.E(fmt.Sprintf("can't send arp request: %s", err))
+		}
+	}
+	for _, key := range failures {
+		psLog.I(fmt.Sprintf("arp resolution failed: %s (%s)", key.pa, key.dev))
+		failHeld(key)
+	}
+}
+
+// purge clears the cache entries of the network which the interface belonged to before its address became invalid.
+func purge(old mw.Iface) {
+	dropClaim(old.Dev.Name(), old.Unicast)
+	dropHeld(old.Dev.Name(), old.Unicast, old.Netmask)
+	ret := cache.Purge(old.Dev.Name(), old.Unicast, old.Netmask)
+	if len(ret) != 0 {
+		psLog.I("arp cache entries were purged")
+		for i, v := range ret {
+			psLog.I(fmt.Sprintf("%d: %s", i+1, v))
+		}
+	}
+}
+
 func init() {
 	rcvMonCh = make(chan *worker.Message, xChBufSize)
 	rcvSigCh = make(chan *worker.Message, xChBufSize)
@@ -430,5 +518,9 @@ func init() {
 	tmrSigCh = make(chan *worker.Message, xChBufSize)
 	timerID = monitor.Register("ARP Timer", tmrMonCh, tmrSigCh)
 
+	repo.AddIfaceHook(purge)
+	repo.AddAddrHook(startClaim)
+	repo.AddDeviceHook(deviceChanged)
+
 	Resolver = &resolver{}
 }
@@ -184,6 +184,78 @@ func TestReceive_6(t *testing.T) {
 	}
 }
 
+// Success when the existing entry is updated with the new address of the neighbor even if the packet is sent to
+// other destination.
+func TestReceive_7(t *testing.T) {
+	ctrl, teardown := SetupReceiveTest(t)
+	defer teardown()
+	cache.Init()
+	defer cache.Init()
+
+	mockIfaceRepo := repo.NewMockIIfaceRepo(ctrl)
+	mockIfaceRepo.EXPECT().Lookup(any, any).Return(&mw.Iface{
+		Family:    mw.V4AddrFamily,
+		Unicast:   mw.ParseIP("192.0.2.2"),
+		Netmask:   mw.ParseIP("255.255.255.0"),
+		Broadcast: mw.ParseIP("192.0.2.255"),
+		Dev:       &eth.TapDevice{},
+	})
+	repo.IfaceRepo = mockIfaceRepo
+
+	_ = cache.Create("net0", mw.EthAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}, mw.V4Addr{192, 0, 2, 1}, reachable)
+
+	packet := builder.CustomTPA(mw.V4Addr{192, 0, 2, 3})
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.BigEndian, packet)
+	dev := &eth.TapDevice{
+		Device: mw.Device{
+			Name_: "net0",
+		},
+	}
+
+	if got := Receive(buf.Bytes(), dev); got != psErr.OK {
+		t.Errorf("Receive() = %s; want %s", got, psErr.OK)
+	}
+	entry := cache.GetEntry("net0", mw.V4Addr{192, 0, 2, 1})
+	if entry.HA != packet.SHA || entry.Status != stale {
+		t.Errorf("(HA, Status) = (%s, %s); want (%s, %s)", entry.HA, entry.Status, packet.SHA, stale)
+	}
+}
+
+// Success when an incomplete or failed entry isn't resolved to its hardware address.
+func TestResolve(t *testing.T) {
+	ctrl, teardown := SetupReceiveTest(t)
+	defer teardown()
+	cache.Init()
+	defer cache.Init()
+
+	dev := mw.NewMockIDevice(ctrl)
+	dev.EXPECT().Type().Return(mw.EthernetDevice).AnyTimes()
+	dev.EXPECT().Name().Return("net0").AnyTimes()
+	iface := &mw.Iface{Family: mw.V4AddrFamily, Dev: dev}
+
+	ha := mw.EthAddr{0x11, 0x12, 0x13, 0x14, 0x15, 0x16}
+	_ = cache.Create("net0", mw.EthAddr{}, mw.V4Addr{192, 0, 2, 1}, incomplete)
+	_ = cache.Create("net0", mw.EthAddr{}, mw.V4Addr{192, 0, 2, 2}, failed)
+	_ = cache.Create("net0", ha, mw.V4Addr{192, 0, 2, 3}, reachable)
+
+	tests := []struct {
+		ip     string
+		ha     mw.EthAddr
+		status Status
+	}{
+		{"192.0.2.1", mw.EthAddr{}, Incomplete},
+		{"192.0.2.2", mw.EthAddr{}, Failed},
+		{"192.0.2.3", ha, Complete},
+	}
+	for _, v := range tests {
+		gotHA, gotStatus := Resolver.Resolve(iface, mw.ParseIP(v.ip))
+		if gotHA != v.ha || gotStatus != v.status {
+			t.Errorf("Resolve(%s) = (%s, %d); want (%s, %d)", v.ip, gotHA, gotStatus, v.ha, v.status)
+		}
+	}
+}
+
 func TestHwType_String(t *testing.T) {
 	want := hwTypes[Ethernet]
 	got := Ethernet.String()
@@ -1,137 +1,510 @@
 package arp
 
 import (
+	"bytes"
 	"fmt"
 	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
 	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/42milez/ProtocolStack/src/repo"
 	psTime "github.com/42milez/ProtocolStack/src/time"
+	"sort"
 	"sync"
 	"time"
 )
 
-const cacheSize = 32
-const lifetime = 24 * time.Hour
+// DefaultCacheSize is the number of the entries which the neighbor table holds unless it's changed with SetCacheSize.
+const DefaultCacheSize = 256
+
+// Timers of Neighbor Unreachability Detection (the defaults of Linux)
+// https://datatracker.ietf.org/doc/html/rfc4861#section-10
 const (
-	free cacheStatus = iota
-	incomplete
-	resolved
-	//static
+	reachableTime       = 30 * time.Second // a confirmed entry becomes stale after this
+	delayFirstProbeTime = 5 * time.Second  // the time which upper layers have to confirm an entry used while stale
+	gcStaleTime         = 60 * time.Second // a stale entry which isn't used for this is removed
+	failedLifetime      = 20 * time.Second // a failed entry answers the lookups until it's removed
+	retransTime         = time.Second      // the interval between the requests, which doubles while incomplete
+	maxProbes           = 3
+)
+
+// Neighbor states
+// https://datatracker.ietf.org/doc/html/rfc4861#section-7.3.2
+const (
+	incomplete cacheStatus = iota // the request has been sent, and no reply has arrived yet
+	reachable                     // the neighbor was confirmed within reachableTime
+	stale                         // the neighbor isn't known to be reachable, but nothing is sent until it's used
+	delay                         // the entry was used while stale, and waits for the confirmation of upper layers
+	probe                         // the requests are sent to the neighbor directly to confirm it
+	failed                        // no reply arrived for maxProbes requests
+	static                        // added by hand, and never expired nor evicted
 )
 
 var cache *arpCache
 
+var cacheStatuses = map[cacheStatus]string{
+	incomplete: "incomplete",
+	reachable:  "reachable",
+	stale:      "stale",
+	delay:      "delay",
+	probe:      "probe",
+	failed:     "failed",
+	static:     "static",
+}
+
+// neighKey identifies a neighbor. Neighbors are scoped per device, so that the same address on different links is
+// resolved separately.
+type neighKey struct {
+	dev string
+	pa  mw.V4Addr
+}
+
+// arpCache is the neighbor table. The oldest failed or stale entry is evicted first when the table is full.
 type arpCache struct {
-	entries [cacheSize]*arpCacheEntry
+	entries map[neighKey]*arpCacheEntry
+	size    int
 	mtx     sync.Mutex
 }
 
 func (p *arpCache) Init() {
-	for i := range p.entries {
-		p.entries[i] = &arpCacheEntry{
-			Status:    free,
-			CreatedAt: time.Unix(0, 0),
-			HA:        mw.EthAddr{},
-			PA:        mw.V4Addr{},
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.entries = make(map[neighKey]*arpCacheEntry)
+	if p.size == 0 {
+		p.size = DefaultCacheSize
+	}
+}
+
+// SetSize changes the number of the entries which the table holds. The entries over the size are evicted.
+func (p *arpCache) SetSize(n int) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.size = n
+	for len(p.entries) > p.size {
+		key, ok := p.victim()
+		if !ok {
+			break
 		}
+		delete(p.entries, key)
 	}
 }
 
-func (p *arpCache) Create(ha mw.EthAddr, pa mw.V4Addr, st cacheStatus) error {
-	var entry *arpCacheEntry
-	if entry = p.GetEntry(pa); entry != nil {
+func (p *arpCache) Create(dev string, ha mw.EthAddr, pa mw.V4Addr, st cacheStatus) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	key := neighKey{dev: dev, pa: pa}
+	if _, ok := p.entries[key]; ok {
 		return psErr.Exist
 	}
-	entry = p.GetReusableEntry()
+	if len(p.entries) >= p.size {
+		victim, ok := p.victim()
+		if !ok {
+			return psErr.Error
+		}
+		delete(p.entries, victim)
+	}
 
+	now := psTime.Time.Now()
+	p.entries[key] = &arpCacheEntry{
+		Status:    st,
+		HA:        ha,
+		PA:        pa,
+		Dev:       dev,
+		UpdatedAt: now,
+		UsedAt:    now,
+	}
+
+	return psErr.OK
+}
+
+// Renew updates the entry with the address which the neighbor announced. The entry is made stale only when the address
+// has changed, so that the packets which don't confirm the neighbor don't take the entry out of reachable. A static
+// entry is updated only to another static entry, and it returns psErr.Exist otherwise.
+func (p *arpCache) Renew(dev string, pa mw.V4Addr, ha mw.EthAddr, st cacheStatus) error {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
 
+	entry := p.entries[neighKey{dev: dev, pa: pa}]
+	if entry == nil {
+		return psErr.NotFound
+	}
+	if entry.Status == static && st != static {
+		return psErr.Exist
+	}
+	if st == stale && entry.HA == ha && entry.Status != incomplete && entry.Status != failed {
+		return psErr.OK
+	}
+
 	entry.Status = st
-	entry.CreatedAt = psTime.Time.Now()
 	entry.HA = ha
-	entry.PA = pa
+	entry.UpdatedAt = psTime.Time.Now()
+	entry.Iface = nil
+	entry.Probes = 0
 
 	return psErr.OK
 }
 
-func (p *arpCache) Renew(pa mw.V4Addr, ha mw.EthAddr, st cacheStatus) error {
-	entry := p.GetEntry(pa)
+// Confirm makes the entry reachable since upper layers have seen that the neighbor is reachable. The entries which
+// don't have the hardware address and static entries are left as they are.
+func (p *arpCache) Confirm(dev string, pa mw.V4Addr) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	entry := p.entries[neighKey{dev: dev, pa: pa}]
 	if entry == nil {
 		return psErr.NotFound
 	}
 
+	switch entry.Status {
+	case reachable, stale, delay, probe:
+		entry.Status = reachable
+		entry.UpdatedAt = psTime.Time.Now()
+		entry.Iface = nil
+		entry.Probes = 0
+	}
+
+	return psErr.OK
+}
+
+// GetEntry returns the entry of the protocol address on the device.
+func (p *arpCache) GetEntry(dev string, pa mw.V4Addr) *arpCacheEntry {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
 
-	entry.Status = st
-	entry.CreatedAt = psTime.Time.Now()
-	entry.HA = ha
-
-	return psErr.OK
+	return p.entries[neighKey{dev: dev, pa: pa}]
 }
 
-func (p *arpCache) GetEntry(ip mw.V4Addr) *arpCacheEntry {
+// Lookup returns a copy of the entry which is used for sending a packet through the interface. A stale entry moves to
+// delay, so that the neighbor is probed unless upper layers confirm it within delayFirstProbeTime.
+func (p *arpCache) Lookup(iface *mw.Iface, pa mw.V4Addr) (arpCacheEntry, bool) {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
 
-	for i, v := range p.entries {
-		if v.PA == ip {
-			return p.entries[i]
-		}
+	entry := p.entries[neighKey{dev: iface.Dev.Name(), pa: pa}]
+	if entry == nil {
+		return arpCacheEntry{}, false
+	}
+
+	now := psTime.Time.Now()
+	entry.UsedAt = now
+	if entry.Status == stale {
+		entry.Status = delay
+		entry.UpdatedAt = now
+		entry.Iface = iface
 	}
 
-	return nil
+	return *entry, true
 }
 
-func (p *arpCache) GetReusableEntry() *arpCacheEntry {
+// Probe records that a request for the incomplete entry was sent through the interface.
+func (p *arpCache) Probe(iface *mw.Iface, pa mw.V4Addr, now time.Time) {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
 
-	oldest := p.entries[0]
-	for _, entry := range p.entries {
-		if entry.Status == free {
-			return entry
+	if entry := p.entries[neighKey{dev: iface.Dev.Name(), pa: pa}]; entry != nil && entry.Status == incomplete {
+		entry.Iface = iface
+		entry.Probes++
+		entry.ProbedAt = now
+	}
+}
+
+// Retransmit returns the copies of the entries of which request should be sent, and makes the entries failed of which
+// requests have been sent maxProbes times without a reply. The requests of incomplete entries are broadcast at
+// doubling intervals, and the ones of the entries in probe are sent to the neighbors directly.
+func (p *arpCache) Retransmit(now time.Time) (probes []arpCacheEntry, failures []neighKey) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for key, v := range p.entries {
+		switch v.Status {
+		case incomplete:
+			if v.Probes == 0 || now.Sub(v.ProbedAt) < retransTime<<(v.Probes-1) {
+				continue
+			}
+		case delay:
+			if now.Sub(v.UpdatedAt) < delayFirstProbeTime {
+				continue
+			}
+			v.Status = probe
+			v.UpdatedAt = now
+			v.Probes = 0
+		case probe:
+			if now.Sub(v.ProbedAt) < retransTime {
+				continue
+			}
+		default:
+			continue
 		}
-		if oldest.CreatedAt.After(entry.CreatedAt) {
-			oldest = entry
+
+		if v.Probes >= maxProbes {
+			v.Status = failed
+			v.UpdatedAt = now
+			v.Iface = nil
+			failures = append(failures, key)
+			continue
 		}
+		v.Probes++
+		v.ProbedAt = now
+		probes = append(probes, *v)
 	}
 
-	return oldest
+	return
 }
 
-func (p *arpCache) Clear(idx int) {
-	p.entries[idx].Status = free
-	p.entries[idx].CreatedAt = time.Unix(0, 0)
-	p.entries[idx].HA = mw.EthAddr{}
-	p.entries[idx].PA = mw.V4Addr{}
+// Expire makes the entries stale which haven't been confirmed within reachableTime, and removes the stale entries
+// which haven't been used within gcStaleTime and the failed entries older than failedLifetime.
+func (p *arpCache) Expire(now time.Time) (invalidations []string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for key, v := range p.entries {
+		switch v.Status {
+		case reachable:
+			if now.Sub(v.UpdatedAt) < reachableTime {
+				continue
+			}
+			v.Status = stale
+			v.UpdatedAt = now
+			fallthrough
+		case stale:
+			if now.Sub(v.UsedAt) < gcStaleTime {
+				continue
+			}
+		case failed:
+			if now.Sub(v.UpdatedAt) < failedLifetime {
+				continue
+			}
+		default:
+			continue
+		}
+		invalidations = append(invalidations, fmt.Sprintf("%s (%s)", v.PA, v.HA))
+		delete(p.entries, key)
+	}
+
+	return
 }
 
-func (p *arpCache) Expire() (invalidations []string) {
+// Purge clears the entries of the device of which protocol address belongs to the network. It's used when an interface
+// of the network is removed or re-addressed. Static entries are kept since they are pinned by hand.
+func (p *arpCache) Purge(dev string, network mw.IP, netmask mw.IP) (invalidations []string) {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
 
-	now := time.Now()
-	for i, v := range p.entries {
-		if v.CreatedAt != time.Unix(0, 0) && now.Sub(v.CreatedAt) > lifetime {
+	for key, v := range p.entries {
+		if v.Dev != dev || v.Status == static {
+			continue
+		}
+		if mw.V4FromByte(v.PA).Mask(netmask).Equal(network.Mask(netmask)) {
 			invalidations = append(invalidations, fmt.Sprintf("%s (%s)", v.PA, v.HA))
-			p.Clear(i)
+			delete(p.entries, key)
 		}
 	}
 
 	return
 }
 
+// Delete clears the entry of the protocol address whether it's static or not. The entries of all the devices are
+// cleared when dev is empty.
+func (p *arpCache) Delete(dev string, pa mw.V4Addr) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	n := 0
+	for key, v := range p.entries {
+		if v.PA == pa && (dev == "" || v.Dev == dev) {
+			delete(p.entries, key)
+			n++
+		}
+	}
+	if n == 0 {
+		return psErr.NotFound
+	}
+
+	return psErr.OK
+}
+
+// Flush clears the entries which aren't static, and returns the number of them.
+func (p *arpCache) Flush() int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	n := 0
+	for key, v := range p.entries {
+		if v.Status != static {
+			delete(p.entries, key)
+			n++
+		}
+	}
+
+	return n
+}
+
+// Snapshot returns the copies of the entries in ascending order of the protocol address and the device.
+func (p *arpCache) Snapshot() []arpCacheEntry {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	var ret []arpCacheEntry
+	for _, v := range p.entries {
+		ret = append(ret, *v)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if d := bytes.Compare(ret[i].PA[:], ret[j].PA[:]); d != 0 {
+			return d < 0
+		}
+		return ret[i].Dev < ret[j].Dev
+	})
+
+	return ret
+}
+
+// victim returns the key of the entry which is evicted when the table is full. Failed entries go first, stale entries
+// next, and the oldest one is chosen among the same rank. Static entries are never evicted.
+func (p *arpCache) victim() (neighKey, bool) {
+	var ret *arpCacheEntry
+	for _, v := range p.entries {
+		if v.Status == static {
+			continue
+		}
+		if ret == nil || evictionRank(v) < evictionRank(ret) ||
+			(evictionRank(v) == evictionRank(ret) && v.UpdatedAt.Before(ret.UpdatedAt)) {
+			ret = v
+		}
+	}
+	if ret == nil {
+		return neighKey{}, false
+	}
+
+	return neighKey{dev: ret.Dev, pa: ret.PA}, true
+}
+
+func evictionRank(entry *arpCacheEntry) int {
+	switch entry.Status {
+	case failed:
+		return 0
+	case stale:
+		return 1
+	default:
+		return 2
+	}
+}
+
 type arpCacheEntry struct {
 	Status    cacheStatus
-	CreatedAt time.Time
 	HA        mw.EthAddr
 	PA        mw.V4Addr
+	Dev       string
+	Iface     *mw.Iface // the interface which sends the requests while the entry is incomplete or probed
+	Probes    int       // the number of the requests which have been sent
+	ProbedAt  time.Time
+	UpdatedAt time.Time // when the entry entered the status
+	UsedAt    time.Time // when the entry was used for sending a packet
 }
 
 type cacheStatus uint8
 
+func (v cacheStatus) String() string {
+	return cacheStatuses[v]
+}
+
+// Entry is a copy of an entry of the neighbor table.
+type Entry struct {
+	PA     mw.V4Addr
+	HA     mw.EthAddr
+	Dev    string
+	Status string
+	Age    time.Duration // the time since the entry entered the status
+}
+
+// Entries returns the entries of the neighbor table in ascending order of the protocol address.
+func Entries() []Entry {
+	now := psTime.Time.Now()
+	var ret []Entry
+	for _, v := range cache.Snapshot() {
+		ret = append(ret, Entry{
+			PA:     v.PA,
+			HA:     v.HA,
+			Dev:    v.Dev,
+			Status: v.Status.String(),
+			Age:    now.Sub(v.UpdatedAt),
+		})
+	}
+	return ret
+}
+
+// SetCacheSize changes the number of the entries which the neighbor table holds.
+func SetCacheSize(n int) error {
+	if n < 1 {
+		psLog.E(fmt.Sprintf("invalid arp cache size: %d", n))
+		return psErr.InvalidFormat
+	}
+	cache.SetSize(n)
+	return psErr.OK
+}
+
+// AddStatic adds a static entry which is never expired. The entry of the same address is replaced. The entry belongs
+// to the device of the route to the address when dev is empty.
+func AddStatic(dev string, ip mw.IP, ha mw.EthAddr) error {
+	if len(ip) != mw.V4AddrLen {
+		psLog.E("invalid protocol address")
+		return psErr.InvalidFormat
+	}
+	if ha == mw.EthAny || ha.IsMulticast() {
+		psLog.E(fmt.Sprintf("invalid hardware address: %s", ha))
+		return psErr.InvalidFormat
+	}
+	if dev == "" {
+		route := repo.RouteRepo.Get(ip)
+		if route == nil {
+			psLog.E(fmt.Sprintf("route to %s not found", ip))
+			return psErr.RouteNotFound
+		}
+		dev = route.Iface.Dev.Name()
+	}
+
+	err := cache.Renew(dev, ip.ToV4(), ha, static)
+	if err == psErr.NotFound {
+		if err = cache.Create(dev, ha, ip.ToV4(), static); err != psErr.OK {
+			psLog.E("arp cache is full of static entries")
+			return err
+		}
+	} else if err != psErr.OK {
+		return err
+	}
+	psLog.I("static arp cache entry was added",
+		fmt.Sprintf("pa:  %s", ip),
+		fmt.Sprintf("ha:  %s", ha),
+		fmt.Sprintf("dev: %s", dev))
+
+	// the packets which have waited for the address are sent to the static one
+	release(neighKey{dev: dev, pa: ip.ToV4()}, ha)
+
+	return psErr.OK
+}
+
+// Delete removes the entry of the address whether it's static or not. The entries of all the devices are removed when
+// dev is empty.
+func Delete(dev string, ip mw.IP) error {
+	if len(ip) != mw.V4AddrLen {
+		return psErr.InvalidFormat
+	}
+	return cache.Delete(dev, ip.ToV4())
+}
+
+// Flush removes all the entries except the static ones, and returns the number of the removed entries.
+func Flush() int {
+	return cache.Flush()
+}
+
+// Confirm tells that upper layers have seen the neighbor reachable through the interface, e.g. a TCP segment
+// acknowledged new data.
+func Confirm(iface *mw.Iface, ip mw.IP) {
+	_ = cache.Confirm(iface.Dev.Name(), ip.ToV4())
+}
+
 func init() {
-	cache = &arpCache{}
+	cache = &arpCache{size: DefaultCacheSize}
 	cache.Init()
 }
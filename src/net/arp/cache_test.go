@@ -1,10 +1,10 @@
 package arp
 
 import (
-	"fmt"
 	psErr "github.com/42milez/ProtocolStack/src/error"
 	psLog "github.com/42milez/ProtocolStack/src/log"
 	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/42milez/ProtocolStack/src/net/eth"
 	psTime "github.com/42milez/ProtocolStack/src/time"
 	"github.com/golang/mock/gomock"
 	"github.com/google/go-cmp/cmp"
@@ -18,10 +18,10 @@ func TestCache_Create_1(t *testing.T) {
 
 	ha := mw.EthAddr{0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
 	pa := mw.V4Addr{192, 168, 1, 1}
-	state := resolved
+	state := reachable
 
 	want := psErr.OK
-	got := cache.Create(ha, pa, state)
+	got := cache.Create("net0", ha, pa, state)
 	if got != want {
 		t.Errorf("ArpCache.Add() = %s; want %s", got, want)
 	}
@@ -32,12 +32,12 @@ func TestCache_Create_2(t *testing.T) {
 
 	ha := mw.EthAddr{0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
 	pa := mw.V4Addr{192, 168, 1, 1}
-	state := resolved
+	state := reachable
 
-	_ = cache.Create(ha, pa, state)
+	_ = cache.Create("net0", ha, pa, state)
 
 	want := psErr.Exist
-	got := cache.Create(ha, pa, state)
+	got := cache.Create("net0", ha, pa, state)
 	if got != want {
 		t.Errorf("ArpCache.Add() = %s; want %s", got, want)
 	}
@@ -53,18 +53,64 @@ func TestCache_Create_3(t *testing.T) {
 
 	m := psTime.NewMockITime(ctrl)
 	psTime.Time = m
-	for i := cacheSize; i >= 0; i-- {
+	base, _ := time.Parse(time.RFC3339, "2021-01-01T00:00:00Z")
+	for i := cache.size; i >= 0; i-- {
 		ha := mw.EthAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
-		pa := mw.V4Addr{192, 168, byte(i), 1}
-		state := resolved
-		createdAt, _ := time.Parse(time.RFC3339, fmt.Sprintf("2021-01-01T00:%02d:00Z", i))
-		m.EXPECT().Now().Return(createdAt)
-		got = cache.Create(ha, pa, state)
+		pa := mw.V4Addr{192, byte(i >> 8), byte(i), 1}
+		state := reachable
+		m.EXPECT().Now().Return(base.Add(time.Duration(i) * time.Minute))
+		got = cache.Create("net0", ha, pa, state)
 	}
 
 	if got != want {
 		t.Errorf("ArpCache.Add() = %s; want %s", got, want)
 	}
+	if n := len(cache.entries); n != cache.size {
+		t.Errorf("len(entries) = %d; want %d", n, cache.size)
+	}
+}
+
+// Fail when all the entries are static.
+func TestCache_Create_4(t *testing.T) {
+	defer cache.Init()
+
+	for i := 0; i < cache.size; i++ {
+		_ = cache.Create("net0", mw.EthAddr{0x02, 0, 0, 0, 0, byte(i)}, mw.V4Addr{192, 0, byte(i >> 8), byte(i)}, static)
+	}
+
+	want := psErr.Error
+	got := cache.Create("net0", mw.EthAddr{0x02, 0, 0, 0, 1, 0}, mw.V4Addr{198, 51, 100, 1}, reachable)
+	if got != want {
+		t.Errorf("ArpCache.Create() = %s; want %s", got, want)
+	}
+}
+
+// Success when a failed entry is evicted before the older reachable one, and static entries are never evicted.
+func TestCache_Create_5(t *testing.T) {
+	ctrl, teardown := SetupCacheTest(t)
+	defer teardown()
+	defer cache.Init()
+	defer cache.SetSize(DefaultCacheSize)
+
+	base, _ := time.Parse(time.RFC3339, "2021-01-01T00:00:00Z")
+	m := psTime.NewMockITime(ctrl)
+	psTime.Time = m
+	cache.SetSize(3)
+
+	states := []cacheStatus{static, reachable, failed, reachable}
+	for i, st := range states {
+		m.EXPECT().Now().Return(base.Add(time.Duration(i) * time.Minute))
+		_ = cache.Create("net0", mw.EthAddr{0x02, 0, 0, 0, 0, byte(i)}, mw.V4Addr{192, 0, 2, byte(i)}, st)
+	}
+
+	want := []mw.V4Addr{{192, 0, 2, 0}, {192, 0, 2, 1}, {192, 0, 2, 3}}
+	var got []mw.V4Addr
+	for _, v := range cache.Snapshot() {
+		got = append(got, v.PA)
+	}
+	if d := cmp.Diff(got, want); d != "" {
+		t.Errorf("Snapshot() differs: (-got +want)\n%s", d)
+	}
 }
 
 func TestCache_Renew_1(t *testing.T) {
@@ -79,20 +125,22 @@ func TestCache_Renew_1(t *testing.T) {
 
 	ha1 := mw.EthAddr{0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
 	pa := mw.V4Addr{192, 168, 1, 1}
-	state := resolved
-	_ = cache.Create(ha1, pa, state)
+	state := reachable
+	_ = cache.Create("net0", ha1, pa, state)
 
 	ha2 := mw.EthAddr{0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f}
-	_ = cache.Renew(pa, ha2, state)
+	_ = cache.Renew("net0", pa, ha2, state)
 
 	want := &arpCacheEntry{
-		Status:    resolved,
-		CreatedAt: createdAt,
+		Status:    reachable,
 		HA:        mw.EthAddr{0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f},
 		PA:        mw.V4Addr{192, 168, 1, 1},
+		Dev:       "net0",
+		UpdatedAt: createdAt,
+		UsedAt:    createdAt,
 	}
 
-	got := cache.GetEntry(pa)
+	got := cache.GetEntry("net0", pa)
 	if d := cmp.Diff(got, want); d != "" {
 		t.Errorf("Renew() differs: (-got +want)\n%s", d)
 	}
@@ -103,15 +151,53 @@ func TestCache_Renew_2(t *testing.T) {
 
 	ha1 := mw.EthAddr{0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
 	pa := mw.V4Addr{192, 168, 1, 1}
-	state := resolved
-	_ = cache.Create(ha1, pa, state)
+	state := reachable
+	_ = cache.Create("net0", ha1, pa, state)
 
 	want := psErr.NotFound
-	got := cache.Renew(mw.V4Addr{192, 0, 2, 1}, mw.EthAddr{}, resolved)
+	got := cache.Renew("net0", mw.V4Addr{192, 0, 2, 1}, mw.EthAddr{}, reachable)
+
+	if got != want {
+		t.Errorf("Renew() = %s; want %s", got, want)
+	}
+}
 
+// Success when a static entry isn't replaced by a dynamic one.
+func TestCache_Renew_3(t *testing.T) {
+	defer cache.Init()
+
+	ha := mw.EthAddr{0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
+	pa := mw.V4Addr{192, 168, 1, 1}
+	_ = cache.Create("net0", ha, pa, static)
+
+	want := psErr.Exist
+	got := cache.Renew("net0", pa, mw.EthAddr{0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f}, reachable)
 	if got != want {
 		t.Errorf("Renew() = %s; want %s", got, want)
 	}
+	if entry := cache.GetEntry("net0", pa); entry.HA != ha || entry.Status != static {
+		t.Errorf("(HA, Status) = (%s, %s); want (%s, %s)", entry.HA, entry.Status, ha, static)
+	}
+}
+
+// Success when a packet which doesn't change the address leaves the entry reachable, and the one which changes it
+// makes the entry stale.
+func TestCache_Renew_4(t *testing.T) {
+	defer cache.Init()
+
+	ha := mw.EthAddr{0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
+	pa := mw.V4Addr{192, 168, 1, 1}
+	_ = cache.Create("net0", ha, pa, reachable)
+
+	_ = cache.Renew("net0", pa, ha, stale)
+	if got := cache.GetEntry("net0", pa).Status; got != reachable {
+		t.Errorf("Status = %s; want %s", got, reachable)
+	}
+
+	_ = cache.Renew("net0", pa, mw.EthAddr{0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f}, stale)
+	if got := cache.GetEntry("net0", pa).Status; got != stale {
+		t.Errorf("Status = %s; want %s", got, stale)
+	}
 }
 
 func TestCache_GetEntry_1(t *testing.T) {
@@ -119,10 +205,10 @@ func TestCache_GetEntry_1(t *testing.T) {
 
 	ha := mw.EthAddr{0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
 	pa := mw.V4Addr{192, 168, 1, 1}
-	state := resolved
-	_ = cache.Create(ha, pa, state)
+	state := reachable
+	_ = cache.Create("net0", ha, pa, state)
 
-	entry := cache.GetEntry(pa)
+	entry := cache.GetEntry("net0", pa)
 	if entry == nil {
 		t.Errorf("arp cache entry does not exist")
 	}
@@ -134,14 +220,229 @@ func TestCache_GetEntry_2(t *testing.T) {
 
 	ha := mw.EthAddr{0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
 	pa := mw.V4Addr{192, 168, 1, 1}
-	state := resolved
-	_ = cache.Create(ha, pa, state)
+	state := reachable
+	_ = cache.Create("net0", ha, pa, state)
 
-	entry := cache.GetEntry(mw.V4Addr{192, 0, 2, 1})
-
-	if entry != nil {
+	if entry := cache.GetEntry("net0", mw.V4Addr{192, 0, 2, 1}); entry != nil {
 		t.Errorf("unexpected arp cache entry exist")
 	}
+	if entry := cache.GetEntry("net1", pa); entry != nil {
+		t.Errorf("arp cache entry of other device exist")
+	}
+}
+
+// Success when a stale entry moves to delay when it's used, and the neighbor is probed directly after
+// delayFirstProbeTime until it's confirmed.
+func TestCache_Lookup(t *testing.T) {
+	ctrl, teardown := SetupCacheTest(t)
+	defer teardown()
+	defer cache.Init()
+
+	usedAt, _ := time.Parse(time.RFC3339, "2021-01-01T00:00:00Z")
+	m := psTime.NewMockITime(ctrl)
+	m.EXPECT().Now().Return(usedAt).AnyTimes()
+	psTime.Time = m
+
+	ha := mw.EthAddr{0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
+	pa := mw.V4Addr{192, 0, 2, 1}
+	iface := createIface("net0")
+	_ = cache.Create("net0", ha, pa, stale)
+
+	if entry, ok := cache.Lookup(iface, pa); !ok || entry.Status != delay || entry.HA != ha {
+		t.Fatalf("Lookup() = (%s, %s, %t); want (%s, %s, %t)", entry.Status, entry.HA, ok, delay, ha, true)
+	}
+
+	if probes, _ := cache.Retransmit(usedAt.Add(delayFirstProbeTime - time.Second)); len(probes) != 0 {
+		t.Errorf("neighbor was probed in delay")
+	}
+	probes, _ := cache.Retransmit(usedAt.Add(delayFirstProbeTime))
+	if len(probes) != 1 || probes[0].Status != probe || probes[0].Iface != iface {
+		t.Fatalf("Retransmit() = %v; want a probe through %s", probes, iface.Dev.Name())
+	}
+
+	_ = cache.Confirm("net0", pa)
+	if got := cache.GetEntry("net0", pa).Status; got != reachable {
+		t.Errorf("Status = %s; want %s", got, reachable)
+	}
+}
+
+// Success when the requests are sent at doubling intervals and the entry fails after maxProbes requests.
+func TestCache_Retransmit(t *testing.T) {
+	ctrl, teardown := SetupCacheTest(t)
+	defer teardown()
+	defer cache.Init()
+
+	probedAt, _ := time.Parse(time.RFC3339, "2021-01-01T00:00:00Z")
+	m := psTime.NewMockITime(ctrl)
+	m.EXPECT().Now().Return(probedAt)
+	psTime.Time = m
+
+	pa := mw.V4Addr{192, 0, 2, 1}
+	iface := createIface("net0")
+	_ = cache.Create("net0", mw.EthAddr{}, pa, incomplete)
+	cache.Probe(iface, pa, probedAt)
+
+	tests := []struct {
+		elapsed  time.Duration
+		probes   int
+		failures int
+	}{
+		{500 * time.Millisecond, 0, 0},
+		{time.Second, 1, 0},
+		{2 * time.Second, 0, 0},
+		{3 * time.Second, 1, 0},
+		{6 * time.Second, 0, 0},
+		{7 * time.Second, 0, 1},
+	}
+	for _, v := range tests {
+		probes, failures := cache.Retransmit(probedAt.Add(v.elapsed))
+		if len(probes) != v.probes || len(failures) != v.failures {
+			t.Errorf("Retransmit() at %s = (%d, %d); want (%d, %d)",
+				v.elapsed, len(probes), len(failures), v.probes, v.failures)
+		}
+		if len(probes) != 0 && probes[0].Iface != iface {
+			t.Errorf("Retransmit() returned the entry of another interface")
+		}
+	}
+	if got := cache.GetEntry("net0", pa).Status; got != failed {
+		t.Errorf("Status = %s; want %s", got, failed)
+	}
+}
+
+func TestCache_Purge(t *testing.T) {
+	defer cache.Init()
+
+	_ = cache.Create("net0", mw.EthAddr{0x11, 0x22, 0x33, 0x44, 0x55, 0x66}, mw.V4Addr{192, 0, 2, 2}, reachable)
+	_ = cache.Create("net0", mw.EthAddr{0x11, 0x22, 0x33, 0x44, 0x55, 0x77}, mw.V4Addr{198, 51, 100, 2}, reachable)
+	_ = cache.Create("net1", mw.EthAddr{0x11, 0x22, 0x33, 0x44, 0x55, 0x88}, mw.V4Addr{192, 0, 2, 2}, reachable)
+
+	want := []string{"192.0.2.2 (11:22:33:44:55:66)"}
+	got := cache.Purge("net0", mw.IP{192, 0, 2, 1}, mw.IP{255, 255, 255, 0})
+	if d := cmp.Diff(got, want); d != "" {
+		t.Errorf("Purge() differs: (-got +want)\n%s", d)
+	}
+	if cache.GetEntry("net0", mw.V4Addr{192, 0, 2, 2}) != nil {
+		t.Errorf("arp cache entry was not purged")
+	}
+	if cache.GetEntry("net0", mw.V4Addr{198, 51, 100, 2}) == nil {
+		t.Errorf("arp cache entry of other network was purged")
+	}
+	if cache.GetEntry("net1", mw.V4Addr{192, 0, 2, 2}) == nil {
+		t.Errorf("arp cache entry of other device was purged")
+	}
+}
+
+// Success when static entries aren't expired.
+func TestCache_Expire_1(t *testing.T) {
+	ctrl, teardown := SetupCacheTest(t)
+	defer teardown()
+	defer cache.Init()
+
+	createdAt, _ := time.Parse(time.RFC3339, "2021-01-01T00:00:00Z")
+	m := psTime.NewMockITime(ctrl)
+	m.EXPECT().Now().Return(createdAt).AnyTimes()
+	psTime.Time = m
+
+	_ = cache.Create("net0", mw.EthAddr{0x11, 0x22, 0x33, 0x44, 0x55, 0x66}, mw.V4Addr{192, 0, 2, 1}, static)
+	_ = cache.Create("net0", mw.EthAddr{0x11, 0x22, 0x33, 0x44, 0x55, 0x77}, mw.V4Addr{192, 0, 2, 2}, reachable)
+
+	want := []string{"192.0.2.2 (11:22:33:44:55:77)"}
+	got := cache.Expire(createdAt.Add(gcStaleTime))
+	if d := cmp.Diff(got, want); d != "" {
+		t.Errorf("Expire() differs: (-got +want)\n%s", d)
+	}
+}
+
+// Success when an entry which isn't confirmed within reachableTime becomes stale, and it's kept while it's used.
+func TestCache_Expire_2(t *testing.T) {
+	ctrl, teardown := SetupCacheTest(t)
+	defer teardown()
+	defer cache.Init()
+
+	createdAt, _ := time.Parse(time.RFC3339, "2021-01-01T00:00:00Z")
+	m := psTime.NewMockITime(ctrl)
+	m.EXPECT().Now().Return(createdAt)
+	m.EXPECT().Now().Return(createdAt.Add(reachableTime))
+	psTime.Time = m
+
+	pa := mw.V4Addr{192, 0, 2, 1}
+	_ = cache.Create("net0", mw.EthAddr{0x11, 0x22, 0x33, 0x44, 0x55, 0x66}, pa, reachable)
+	_, _ = cache.Lookup(createIface("net0"), pa)
+
+	if got := cache.Expire(createdAt.Add(reachableTime)); len(got) != 0 {
+		t.Errorf("Expire() = %v; want nothing", got)
+	}
+	if got := cache.GetEntry("net0", pa).Status; got != stale {
+		t.Errorf("Status = %s; want %s", got, stale)
+	}
+}
+
+// Success when only the entries which aren't static are flushed.
+func TestCache_Flush(t *testing.T) {
+	defer cache.Init()
+
+	_ = cache.Create("net0", mw.EthAddr{0x11, 0x22, 0x33, 0x44, 0x55, 0x66}, mw.V4Addr{192, 0, 2, 1}, static)
+	_ = cache.Create("net0", mw.EthAddr{0x11, 0x22, 0x33, 0x44, 0x55, 0x77}, mw.V4Addr{192, 0, 2, 2}, reachable)
+	_ = cache.Create("net0", mw.EthAddr{}, mw.V4Addr{192, 0, 2, 3}, incomplete)
+
+	if got := cache.Flush(); got != 2 {
+		t.Errorf("Flush() = %d; want %d", got, 2)
+	}
+	if cache.GetEntry("net0", mw.V4Addr{192, 0, 2, 1}) == nil {
+		t.Errorf("static arp cache entry was flushed")
+	}
+}
+
+// Success when the entries over the new size are evicted.
+func TestCache_SetSize(t *testing.T) {
+	defer cache.Init()
+	defer cache.SetSize(DefaultCacheSize)
+
+	for i := 0; i < 4; i++ {
+		_ = cache.Create("net0", mw.EthAddr{0x02, 0, 0, 0, 0, byte(i)}, mw.V4Addr{192, 0, 2, byte(i)}, reachable)
+	}
+	cache.SetSize(2)
+
+	if n := len(cache.Snapshot()); n != 2 {
+		t.Errorf("len(Snapshot()) = %d; want %d", n, 2)
+	}
+}
+
+// Success when a static entry replaces the dynamic one, and it can be deleted.
+func TestAddStatic_1(t *testing.T) {
+	psLog.DisableOutput()
+	defer psLog.EnableOutput()
+	defer cache.Init()
+
+	ip := mw.ParseIP("192.0.2.1")
+	ha := mw.EthAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	_ = cache.Create("net0", mw.EthAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x02}, ip.ToV4(), reachable)
+
+	if got := AddStatic("net0", ip, ha); got != psErr.OK {
+		t.Fatalf("AddStatic() = %s; want %s", got, psErr.OK)
+	}
+	entries := Entries()
+	if len(entries) != 1 || entries[0].HA != ha || entries[0].Status != "static" || entries[0].Dev != "net0" {
+		t.Errorf("Entries() = %v; want a static entry of %s", entries, ha)
+	}
+
+	if got := Delete("", ip); got != psErr.OK {
+		t.Errorf("Delete() = %s; want %s", got, psErr.OK)
+	}
+	if got := Delete("", ip); got != psErr.NotFound {
+		t.Errorf("Delete() = %s; want %s", got, psErr.NotFound)
+	}
+}
+
+// Fail when the hardware address is a group address.
+func TestAddStatic_2(t *testing.T) {
+	psLog.DisableOutput()
+	defer psLog.EnableOutput()
+	defer cache.Init()
+
+	if got := AddStatic("net0", mw.ParseIP("192.0.2.1"), mw.EthBroadcast); got != psErr.InvalidFormat {
+		t.Errorf("AddStatic() = %s; want %s", got, psErr.InvalidFormat)
+	}
 }
 
 func TestTimer_1(t *testing.T) {
@@ -152,10 +453,12 @@ func TestTimer_1(t *testing.T) {
 	createdAt, _ := time.Parse(time.RFC3339, "2021-01-01T00:00:00Z")
 	m := psTime.NewMockITime(ctrl)
 	m.EXPECT().Now().Return(createdAt)
+	// the timer reads the time from the clock of the stack
+	m.EXPECT().Now().Return(createdAt.Add(reachableTime + gcStaleTime)).AnyTimes()
 	psTime.Time = m
 
 	pa := mw.V4Addr{192, 168, 1, 1}
-	_ = cache.Create(mw.EthAddr{0x11, 0x12, 0x13, 0x14, 0x15, 0x16}, pa, resolved)
+	_ = cache.Create("net0", mw.EthAddr{0x11, 0x12, 0x13, 0x14, 0x15, 0x16}, pa, reachable)
 
 	var wg sync.WaitGroup
 	_ = Start(&wg)
@@ -165,7 +468,7 @@ func TestTimer_1(t *testing.T) {
 	Stop()
 	wg.Wait()
 
-	got := cache.GetEntry(pa)
+	got := cache.GetEntry("net0", pa)
 	if got != nil {
 		t.Errorf("ARP cache is not expired")
 	}
@@ -184,7 +487,7 @@ func TestTimer_2(t *testing.T) {
 	Stop()
 	wg.Wait()
 
-	got := cache.GetEntry(mw.V4Addr{192, 168, 0, 1})
+	got := cache.GetEntry("net0", mw.V4Addr{192, 168, 0, 1})
 	if got != nil {
 		t.Errorf("ARP cache exists")
 	}
@@ -203,3 +506,15 @@ func SetupCacheTest(t *testing.T) (ctrl *gomock.Controller, teardown func()) {
 
 	return
 }
+
+func createIface(name string) *mw.Iface {
+	return &mw.Iface{
+		Family: mw.V4AddrFamily,
+		Dev: &eth.TapDevice{
+			Device: mw.Device{
+				Type_: mw.EthernetDevice,
+				Name_: name,
+			},
+		},
+	}
+}
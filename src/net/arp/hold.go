@@ -0,0 +1,145 @@
+package arp
+
+import (
+	"fmt"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/42milez/ProtocolStack/src/net"
+	psTime "github.com/42milez/ProtocolStack/src/time"
+	"sync"
+	"time"
+)
+
+// HoldTimeout is the time which packets wait for the address of their next hop to be resolved. It's longer than the
+// time the requests take to fail, so that the packets are usually reported when the resolution fails.
+const HoldTimeout = 10 * time.Second
+
+// holdQueueLen is the number of packets which wait for the address of a neighbor. The oldest packet is dropped when
+// the queue is full.
+const holdQueueLen = 16
+
+var holdQueues map[neighKey][]*heldPacket
+var holdMtx sync.Mutex
+
+var unreachableHandler func(iface *mw.Iface, packet []byte)
+var unreachableHandlerMtx sync.RWMutex
+
+type heldPacket struct {
+	iface  *mw.Iface
+	packet []byte
+	heldAt time.Time
+}
+
+// Hold queues the IP packet until the address of the next hop is resolved. The packet is sent automatically when the
+// reply arrives, and it's passed to the unreachable handler when the address isn't resolved within HoldTimeout.
+func Hold(iface *mw.Iface, nextHop mw.IP, packet []byte) {
+	holdMtx.Lock()
+	defer holdMtx.Unlock()
+
+	key := neighKey{dev: iface.Dev.Name(), pa: nextHop.ToV4()}
+	q := holdQueues[key]
+	if len(q) >= holdQueueLen {
+		psLog.W(fmt.Sprintf("hold queue is full (packet was dropped): %s", nextHop))
+		q[0] = nil
+		q = q[1:]
+	}
+	holdQueues[key] = append(q, &heldPacket{
+		iface:  iface,
+		packet: packet,
+		heldAt: psTime.Time.Now(),
+	})
+}
+
+// SetUnreachableHandler registers the function which is called with the packets of which next hop couldn't be
+// resolved. Passing nil unregisters it.
+func SetUnreachableHandler(f func(iface *mw.Iface, packet []byte)) {
+	unreachableHandlerMtx.Lock()
+	defer unreachableHandlerMtx.Unlock()
+	unreachableHandler = f
+}
+
+// release sends the packets which wait for the address.
+func release(key neighKey, ha mw.EthAddr) {
+	holdMtx.Lock()
+	q := holdQueues[key]
+	delete(holdQueues, key)
+	holdMtx.Unlock()
+
+	for _, v := range q {
+		if err := net.Transmit(ha, v.packet, mw.EtIPV4, v.iface); err != psErr.OK {
+			psLog.E(fmt.Sprintf("can't send held packet: %s", err))
+		}
+	}
+	if len(q) != 0 {
+		psLog.D(fmt.Sprintf("%d held packets were sent to %s", len(q), key.pa))
+	}
+}
+
+// expireHeld removes the packets which have waited for HoldTimeout and passes them to the unreachable handler.
+func expireHeld(now time.Time) {
+	var expired []*heldPacket
+
+	holdMtx.Lock()
+	for key, q := range holdQueues {
+		n := 0
+		for n < len(q) && now.Sub(q[n].heldAt) >= HoldTimeout {
+			n++
+		}
+		if n == 0 {
+			continue
+		}
+		psLog.I(fmt.Sprintf("host unreachable: %s (%d packets were dropped)", key.pa, n))
+		expired = append(expired, q[:n]...)
+		if n == len(q) {
+			delete(holdQueues, key)
+		} else {
+			holdQueues[key] = q[n:]
+		}
+	}
+	holdMtx.Unlock()
+
+	reportHeld(expired)
+}
+
+// failHeld removes the packets which wait for the address which couldn't be resolved and passes them to the
+// unreachable handler.
+func failHeld(key neighKey) {
+	holdMtx.Lock()
+	q := holdQueues[key]
+	delete(holdQueues, key)
+	holdMtx.Unlock()
+
+	if len(q) != 0 {
+		psLog.I(fmt.Sprintf("host unreachable: %s (%d packets were dropped)", key.pa, len(q)))
+	}
+	reportHeld(q)
+}
+
+func reportHeld(packets []*heldPacket) {
+	unreachableHandlerMtx.RLock()
+	f := unreachableHandler
+	unreachableHandlerMtx.RUnlock()
+	if f == nil {
+		return
+	}
+	for _, v := range packets {
+		f(v.iface, v.packet)
+	}
+}
+
+// dropHeld discards the packets which wait for the addresses of the network on the device without reporting them.
+func dropHeld(dev string, network mw.IP, netmask mw.IP) {
+	holdMtx.Lock()
+	defer holdMtx.Unlock()
+
+	for key := range holdQueues {
+		if key.dev == dev && mw.V4FromByte(key.pa).Mask(netmask).Equal(network.Mask(netmask)) {
+			delete(holdQueues, key)
+		}
+	}
+}
+
+func init() {
+	holdQueues = make(map[neighKey][]*heldPacket)
+}
@@ -0,0 +1,124 @@
+package arp
+
+import (
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/42milez/ProtocolStack/src/repo"
+	psTime "github.com/42milez/ProtocolStack/src/time"
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	"testing"
+	"time"
+)
+
+// Success when the held packets are sent in the order they are held.
+func TestRelease(t *testing.T) {
+	ctrl, teardown := SetupHoldTest(t)
+	defer teardown()
+
+	heldAt, _ := time.Parse(time.RFC3339, "2021-01-01T00:00:00Z")
+	m := psTime.NewMockITime(ctrl)
+	m.EXPECT().Now().Return(heldAt).AnyTimes()
+	psTime.Time = m
+
+	ha := mw.EthAddr{0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
+	var got [][]byte
+	dev := mw.NewMockIDevice(ctrl)
+	dev.EXPECT().IsUp().Return(true).AnyTimes()
+	dev.EXPECT().MTU().Return(uint16(mw.EthPayloadLenMax)).AnyTimes()
+	dev.EXPECT().Name().Return("net0").AnyTimes()
+	dev.EXPECT().Transmit(ha, any, mw.EtIPV4).DoAndReturn(func(_ mw.EthAddr, packet []byte, _ mw.EthType) error {
+		got = append(got, packet)
+		return psErr.OK
+	}).Times(2)
+	iface := &mw.Iface{Dev: dev}
+
+	mockIfaceRepo := repo.NewMockIIfaceRepo(ctrl)
+	mockIfaceRepo.EXPECT().Get(iface.Unicast).Return(iface).Times(2)
+	repo.IfaceRepo = mockIfaceRepo
+
+	Hold(iface, mw.ParseIP("192.0.2.1"), []byte{0x01})
+	Hold(iface, mw.ParseIP("192.0.2.1"), []byte{0x02})
+	Hold(iface, mw.ParseIP("192.0.2.2"), []byte{0x03})
+	release(neighKey{dev: "net0", pa: mw.V4Addr{192, 0, 2, 1}}, ha)
+
+	want := [][]byte{{0x01}, {0x02}}
+	if d := cmp.Diff(got, want); d != "" {
+		t.Errorf("release() differs: (-got +want)\n%s", d)
+	}
+	if len(holdQueues) != 1 {
+		t.Errorf("len(holdQueues) = %d; want %d", len(holdQueues), 1)
+	}
+}
+
+// Success when the packets which have waited for HoldTimeout are passed to the unreachable handler.
+func TestExpireHeld(t *testing.T) {
+	ctrl, teardown := SetupHoldTest(t)
+	defer teardown()
+
+	heldAt, _ := time.Parse(time.RFC3339, "2021-01-01T00:00:00Z")
+	m := psTime.NewMockITime(ctrl)
+	m.EXPECT().Now().Return(heldAt)
+	m.EXPECT().Now().Return(heldAt.Add(time.Second))
+	psTime.Time = m
+
+	var got [][]byte
+	SetUnreachableHandler(func(_ *mw.Iface, packet []byte) {
+		got = append(got, packet)
+	})
+
+	iface := createIface("net0")
+	Hold(iface, mw.ParseIP("192.0.2.1"), []byte{0x01})
+	Hold(iface, mw.ParseIP("192.0.2.1"), []byte{0x02})
+	expireHeld(heldAt.Add(HoldTimeout))
+
+	want := [][]byte{{0x01}}
+	if d := cmp.Diff(got, want); d != "" {
+		t.Errorf("expireHeld() differs: (-got +want)\n%s", d)
+	}
+	if n := len(holdQueues[neighKey{dev: "net0", pa: mw.V4Addr{192, 0, 2, 1}}]); n != 1 {
+		t.Errorf("len(holdQueues[192.0.2.1]) = %d; want %d", n, 1)
+	}
+}
+
+// Success when the oldest packet is dropped when the hold queue is full.
+func TestHold(t *testing.T) {
+	ctrl, teardown := SetupHoldTest(t)
+	defer teardown()
+
+	m := psTime.NewMockITime(ctrl)
+	m.EXPECT().Now().Return(time.Time{}).AnyTimes()
+	psTime.Time = m
+
+	iface := createIface("net0")
+	for i := 0; i <= holdQueueLen; i++ {
+		Hold(iface, mw.ParseIP("192.0.2.1"), []byte{byte(i)})
+	}
+
+	q := holdQueues[neighKey{dev: "net0", pa: mw.V4Addr{192, 0, 2, 1}}]
+	if len(q) != holdQueueLen {
+		t.Errorf("len(holdQueues[192.0.2.1]) = %d; want %d", len(q), holdQueueLen)
+	}
+	if got := q[0].packet[0]; got != 1 {
+		t.Errorf("oldest packet = %d; want %d", got, 1)
+	}
+}
+
+func SetupHoldTest(t *testing.T) (ctrl *gomock.Controller, teardown func()) {
+	psLog.DisableOutput()
+	backup := psTime.Time
+	backupIfaceRepo := repo.IfaceRepo
+	ctrl = gomock.NewController(t)
+
+	teardown = func() {
+		holdQueues = make(map[neighKey][]*heldPacket)
+		repo.IfaceRepo = backupIfaceRepo
+		SetUnreachableHandler(nil)
+		psLog.EnableOutput()
+		psTime.Time = backup
+		ctrl.Finish()
+	}
+
+	return
+}
@@ -0,0 +1,305 @@
+package eth
+
+import (
+	"fmt"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/42milez/ProtocolStack/src/pcap"
+	psTime "github.com/42milez/ProtocolStack/src/time"
+	"sync"
+	"time"
+)
+
+const BridgeAgeingTime = 300 * time.Second
+const fdbSize = 1024
+
+// BridgeDevice is a learning bridge which forwards frames between its ports. Frames which arrive at a port are taken
+// from the port by mw.ReceiveFrame(), so the ports have to be registered in the device repository as well to be read.
+// Frames addressed to the bridge itself are delivered to the protocol stack through the bridge, so the bridge can have
+// an interface.
+type BridgeDevice struct {
+	mw.Device
+	AgeingTime time.Duration
+	ports      []mw.IDevice
+	fdb        map[mw.EthAddr]*fdbEntry
+	mtx        sync.Mutex
+	queue      frameQueue
+}
+
+// fdbEntry is an entry of the forwarding database, which tells the port that a station is reachable through.
+type fdbEntry struct {
+	port      mw.IDevice
+	updatedAt time.Time
+}
+
+// AddPort enslaves the device to the bridge. Only ethernet devices which implement mw.IFrameTransmitter can be
+// enslaved.
+func (p *BridgeDevice) AddPort(dev mw.IDevice) error {
+	if dev.Type() != mw.EthernetDevice {
+		psLog.E(fmt.Sprintf("%s device can't be enslaved", dev.Type()), "device: "+dev.Name())
+		return psErr.Error
+	}
+	if _, ok := dev.(mw.IFrameTransmitter); !ok {
+		psLog.E("device can't transmit frames as they are", "device: "+dev.Name())
+		return psErr.Error
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for _, port := range p.ports {
+		if port.Equal(dev) {
+			return psErr.Exist
+		}
+	}
+	if p.IsUp() {
+		if err := p.attach(dev); err != psErr.OK {
+			return err
+		}
+	}
+	p.ports = append(p.ports, dev)
+
+	return psErr.OK
+}
+
+// RemovePort releases the device from the bridge. The stations learned on the port are forgotten.
+func (p *BridgeDevice) RemovePort(dev mw.IDevice) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for i, port := range p.ports {
+		if port.Equal(dev) {
+			p.detach(port)
+			p.ports = append(p.ports[:i], p.ports[i+1:]...)
+			for addr, entry := range p.fdb {
+				if entry.port == port {
+					delete(p.fdb, addr)
+				}
+			}
+			return psErr.OK
+		}
+	}
+
+	return psErr.NotFound
+}
+
+func (p *BridgeDevice) Open() error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.queue.reset()
+	p.fdb = make(map[mw.EthAddr]*fdbEntry)
+
+	for i, port := range p.ports {
+		if err := p.attach(port); err != psErr.OK {
+			for _, v := range p.ports[:i] {
+				p.detach(v)
+			}
+			return err
+		}
+	}
+
+	return psErr.OK
+}
+
+func (p *BridgeDevice) Close() error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for _, port := range p.ports {
+		p.detach(port)
+	}
+	p.queue.reset()
+
+	return psErr.OK
+}
+
+// Poll reads a frame which is addressed to the bridge itself.
+func (p *BridgeDevice) Poll() error {
+	frame := p.queue.pop()
+	if frame == nil {
+		return psErr.NoDataToRead
+	}
+
+	mw.CaptureFrame(p, pcap.DirectionInbound, frame)
+
+	msg, err := mw.ReceiveFrame(frame, p)
+	if err != psErr.OK {
+		if err != psErr.NoDataToRead {
+			return psErr.Error
+		}
+		return psErr.OK
+	}
+	mw.EthRxCh <- msg
+
+	return psErr.OK
+}
+
+// Transmit sends a frame from the bridge itself. The frame is forwarded the same way as the frames which arrive at
+// the ports.
+func (p *BridgeDevice) Transmit(dst mw.EthAddr, payload []byte, typ mw.EthType) error {
+	frame, err := mw.EncodeFrame(dst, p.Addr_, typ, payload)
+	if err != psErr.OK {
+		return psErr.Error
+	}
+
+	mw.CaptureFrame(p, pcap.DirectionOutbound, frame)
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.forward(nil, frame)
+
+	return psErr.OK
+}
+
+// attach makes the frames which arrive at the port passed to the bridge. The port is promiscuous while it's attached,
+// since it receives the frames addressed to the other stations.
+func (p *BridgeDevice) attach(port mw.IDevice) error {
+	if err := port.SetPromisc(true); err != psErr.OK {
+		return err
+	}
+	if err := mw.SetRxHandler(port, p.receive); err != psErr.OK {
+		_ = port.SetPromisc(false)
+		return err
+	}
+	return psErr.OK
+}
+
+func (p *BridgeDevice) detach(port mw.IDevice) {
+	_ = mw.SetRxHandler(port, nil)
+	_ = port.SetPromisc(false)
+}
+
+// receive is the receive handler of the ports.
+func (p *BridgeDevice) receive(port mw.IDevice, frame []byte) {
+	if !p.IsUp() || len(frame) < mw.EthHdrLen {
+		return
+	}
+
+	// the frame is reused by the port after the handler returns
+	b := make([]byte, len(frame))
+	copy(b, frame)
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	var src mw.EthAddr
+	copy(src[:], b[mw.EthAddrLen:])
+	p.learn(src, port)
+	p.forward(port, b)
+}
+
+// learn records that the station is reachable through the port. Group addresses are never learned.
+func (p *BridgeDevice) learn(addr mw.EthAddr, port mw.IDevice) {
+	if addr.IsMulticast() {
+		return
+	}
+
+	now := psTime.Time.Now()
+
+	if entry, ok := p.fdb[addr]; ok {
+		if entry.port != port {
+			psLog.D(fmt.Sprintf("station moved: %s", addr), "device: "+p.Name_, "port: "+port.Name())
+		}
+		entry.port = port
+		entry.updatedAt = now
+		return
+	}
+
+	if len(p.fdb) >= fdbSize {
+		p.age(now)
+		if len(p.fdb) >= fdbSize {
+			psLog.W("forwarding database is full", "device: "+p.Name_)
+			return
+		}
+	}
+	p.fdb[addr] = &fdbEntry{
+		port:      port,
+		updatedAt: now,
+	}
+}
+
+// lookup returns the port which the station is reachable through. It returns nil when the station is unknown.
+func (p *BridgeDevice) lookup(addr mw.EthAddr) mw.IDevice {
+	entry, ok := p.fdb[addr]
+	if !ok {
+		return nil
+	}
+	if psTime.Time.Now().Sub(entry.updatedAt) > p.AgeingTime {
+		delete(p.fdb, addr)
+		return nil
+	}
+	return entry.port
+}
+
+// age removes the stations which haven't been seen for the ageing time.
+func (p *BridgeDevice) age(now time.Time) {
+	for addr, entry := range p.fdb {
+		if now.Sub(entry.updatedAt) > p.AgeingTime {
+			delete(p.fdb, addr)
+		}
+	}
+}
+
+// forward delivers the frame to the ports and/or the bridge itself. The ingress is nil when the bridge itself sends
+// the frame. It must be called with the lock held.
+func (p *BridgeDevice) forward(ingress mw.IDevice, frame []byte) {
+	var dst mw.EthAddr
+	copy(dst[:], frame)
+
+	if dst.IsMulticast() {
+		if ingress != nil {
+			p.deliver(frame)
+		}
+		p.flood(ingress, frame)
+		return
+	}
+
+	if dst == p.Addr_ {
+		if ingress != nil {
+			p.deliver(frame)
+		}
+		return
+	}
+
+	// frames passing through the bridge are seen by the bridge itself as well in promiscuous mode
+	if ingress != nil && p.Flag()&mw.PromiscFlag != 0 {
+		p.deliver(frame)
+	}
+
+	port := p.lookup(dst)
+	if port == nil {
+		p.flood(ingress, frame)
+		return
+	}
+	if port != ingress {
+		p.transmit(port, frame)
+	}
+}
+
+func (p *BridgeDevice) flood(ingress mw.IDevice, frame []byte) {
+	for _, port := range p.ports {
+		if port != ingress {
+			p.transmit(port, frame)
+		}
+	}
+}
+
+func (p *BridgeDevice) transmit(port mw.IDevice, frame []byte) {
+	if !port.IsUp() {
+		return
+	}
+	if err := port.(mw.IFrameTransmitter).TransmitFrame(frame); err != psErr.OK {
+		psLog.E(fmt.Sprintf("can't forward frame: %s", err), "device: "+p.Name_, "port: "+port.Name())
+	}
+}
+
+// deliver queues the frame to be read by the bridge itself.
+func (p *BridgeDevice) deliver(frame []byte) {
+	if !p.queue.push(frame) {
+		psLog.W("receive queue is full (frame was dropped)", "device: "+p.Name_)
+		return
+	}
+	mw.NotifyRx(p)
+}
@@ -0,0 +1,270 @@
+package eth
+
+import (
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	"github.com/42milez/ProtocolStack/src/mw"
+	psTime "github.com/42milez/ProtocolStack/src/time"
+	"github.com/golang/mock/gomock"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var bridgeEthAddr = mw.EthAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0xff}
+
+// genBridgeTopology generates a bridge which has three ports. Each port is connected to a host with a veth pair.
+func genBridgeTopology(t *testing.T) (br *BridgeDevice, ports [3]*VethDevice, hosts [3]*VethDevice, teardown func()) {
+	br = GenBridgeDevice("br0", bridgeEthAddr)
+	for i := range ports {
+		n := strconv.Itoa(i)
+		hosts[i], ports[i] = GenVethPair("host"+n, "port"+n, mw.EthAddr{0x02, 0, 0, 0, 0, byte(i)}, mw.EthAddr{0x02, 0, 0, 0, 1, byte(i)})
+		openVethDevice(hosts[i], ports[i])
+		if err := br.AddPort(ports[i]); err != psErr.OK {
+			t.Fatalf("BridgeDevice.AddPort() = %v; want %v", err, psErr.OK)
+		}
+	}
+	if err := br.Open(); err != psErr.OK {
+		t.Fatalf("BridgeDevice.Open() = %v; want %v", err, psErr.OK)
+	}
+	br.Up()
+	teardown = func() {
+		_ = br.Close()
+	}
+	return
+}
+
+func queuedFrames(dev *VethDevice) int {
+	dev.queue.mtx.Lock()
+	defer dev.queue.mtx.Unlock()
+	return len(dev.queue.frames)
+}
+
+func checkQueuedFrames(t *testing.T, hosts [3]*VethDevice, want [3]int) {
+	for i, host := range hosts {
+		if got := queuedFrames(host); got != want[i] {
+			t.Errorf("%s received %d frames; want %d", host.Name(), got, want[i])
+		}
+	}
+}
+
+// Success when a frame addressed to an unknown station is flooded.
+func TestBridgeDevice_Forward_1(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	_, ports, hosts, brTeardown := genBridgeTopology(t)
+	defer brTeardown()
+
+	_ = hosts[0].Transmit(hosts[1].Addr(), []byte{0x01}, mw.EtIPV4)
+	pollVethDevice(t, ports[0])
+
+	checkQueuedFrames(t, hosts, [3]int{0, 1, 1})
+	if len(mw.EthRxCh) != 0 {
+		t.Errorf("len(mw.EthRxCh) = %d; want %d", len(mw.EthRxCh), 0)
+	}
+}
+
+// Success when a frame is forwarded only to the port which the station was learned on.
+func TestBridgeDevice_Forward_2(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	_, ports, hosts, brTeardown := genBridgeTopology(t)
+	defer brTeardown()
+
+	_ = hosts[1].Transmit(hosts[0].Addr(), []byte{0x01}, mw.EtIPV4)
+	pollVethDevice(t, ports[1])
+	checkQueuedFrames(t, hosts, [3]int{1, 0, 1})
+
+	_ = hosts[0].Transmit(hosts[1].Addr(), []byte{0x01}, mw.EtIPV4)
+	pollVethDevice(t, ports[0])
+	checkQueuedFrames(t, hosts, [3]int{1, 1, 1})
+}
+
+// Success when a broadcast frame is flooded and delivered to the bridge itself.
+func TestBridgeDevice_Forward_3(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	br, ports, hosts, brTeardown := genBridgeTopology(t)
+	defer brTeardown()
+
+	_ = hosts[0].Transmit(mw.EthBroadcast, []byte{0x01}, mw.EtARP)
+	pollVethDevice(t, ports[0])
+	checkQueuedFrames(t, hosts, [3]int{0, 1, 1})
+
+	if got := br.Poll(); got != psErr.OK {
+		t.Fatalf("BridgeDevice.Poll() = %v; want %v", got, psErr.OK)
+	}
+	if len(mw.EthRxCh) != 1 {
+		t.Fatalf("len(mw.EthRxCh) = %d; want %d", len(mw.EthRxCh), 1)
+	}
+	if msg := <-mw.EthRxCh; msg.Dev != br || msg.Type != mw.EtARP {
+		t.Errorf("BridgeDevice.Poll() delivered unexpected message: %v", msg)
+	}
+}
+
+// Success when a frame addressed to the bridge is delivered only to the bridge itself.
+func TestBridgeDevice_Forward_4(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	br, ports, hosts, brTeardown := genBridgeTopology(t)
+	defer brTeardown()
+
+	_ = hosts[0].Transmit(bridgeEthAddr, []byte{0x01}, mw.EtIPV4)
+	pollVethDevice(t, ports[0])
+	checkQueuedFrames(t, hosts, [3]int{0, 0, 0})
+
+	if got := br.Poll(); got != psErr.OK {
+		t.Fatalf("BridgeDevice.Poll() = %v; want %v", got, psErr.OK)
+	}
+	if len(mw.EthRxCh) != 1 {
+		t.Fatalf("len(mw.EthRxCh) = %d; want %d", len(mw.EthRxCh), 1)
+	}
+	<-mw.EthRxCh
+}
+
+// Success when a station is forgotten after the ageing time.
+func TestBridgeDevice_Forward_5(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	backupTime := psTime.Time
+	defer func() { psTime.Time = backupTime }()
+
+	_, ports, hosts, brTeardown := genBridgeTopology(t)
+	defer brTeardown()
+
+	learnedAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := psTime.NewMockITime(ctrl)
+	m.EXPECT().Now().Return(learnedAt)
+	m.EXPECT().Now().Return(learnedAt.Add(BridgeAgeingTime + time.Second)).AnyTimes()
+	psTime.Time = m
+
+	_ = hosts[1].Transmit(hosts[0].Addr(), []byte{0x01}, mw.EtIPV4)
+	pollVethDevice(t, ports[1])
+	checkQueuedFrames(t, hosts, [3]int{1, 0, 1})
+
+	_ = hosts[0].Transmit(hosts[1].Addr(), []byte{0x01}, mw.EtIPV4)
+	pollVethDevice(t, ports[0])
+	checkQueuedFrames(t, hosts, [3]int{1, 1, 2})
+}
+
+// Success when a frame passing through the bridge is delivered to the bridge itself in promiscuous mode.
+func TestBridgeDevice_Forward_6(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	br, ports, hosts, brTeardown := genBridgeTopology(t)
+	defer brTeardown()
+
+	for _, port := range ports {
+		if port.Flag()&mw.PromiscFlag == 0 {
+			t.Errorf("%s isn't promiscuous", port.Name())
+		}
+	}
+
+	_ = br.SetPromisc(true)
+	defer func() { _ = br.SetPromisc(false) }()
+	_ = hosts[0].Transmit(hosts[1].Addr(), []byte{0x01}, mw.EtIPV4)
+	pollVethDevice(t, ports[0])
+	checkQueuedFrames(t, hosts, [3]int{0, 1, 1})
+
+	if got := br.Poll(); got != psErr.OK {
+		t.Fatalf("BridgeDevice.Poll() = %v; want %v", got, psErr.OK)
+	}
+	if len(mw.EthRxCh) != 1 {
+		t.Fatalf("len(mw.EthRxCh) = %d; want %d", len(mw.EthRxCh), 1)
+	}
+	<-mw.EthRxCh
+}
+
+// Success when a frame transmitted by the bridge reaches the hosts.
+func TestBridgeDevice_Transmit(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	br, _, hosts, brTeardown := genBridgeTopology(t)
+	defer brTeardown()
+
+	if got := br.Transmit(mw.EthBroadcast, []byte{0x01}, mw.EtARP); got != psErr.OK {
+		t.Errorf("BridgeDevice.Transmit() = %v; want %v", got, psErr.OK)
+	}
+	checkQueuedFrames(t, hosts, [3]int{1, 1, 1})
+
+	if got := br.Poll(); got != psErr.NoDataToRead {
+		t.Errorf("BridgeDevice.Poll() = %v; want %v", got, psErr.NoDataToRead)
+	}
+}
+
+// Fail when the device can't be enslaved.
+func TestBridgeDevice_AddPort_1(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	br := GenBridgeDevice("br0", bridgeEthAddr)
+	if got := br.AddPort(GenLoopbackDevice("lo")); got != psErr.Error {
+		t.Errorf("BridgeDevice.AddPort() = %v; want %v", got, psErr.Error)
+	}
+}
+
+// Fail when the device is already enslaved.
+func TestBridgeDevice_AddPort_2(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	br, ports, _, brTeardown := genBridgeTopology(t)
+	defer brTeardown()
+
+	if got := br.AddPort(ports[0]); got != psErr.Exist {
+		t.Errorf("BridgeDevice.AddPort() = %v; want %v", got, psErr.Exist)
+	}
+}
+
+// Success when frames which arrive at the released device are passed to the protocol stack.
+func TestBridgeDevice_RemovePort(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	br, ports, hosts, brTeardown := genBridgeTopology(t)
+	defer brTeardown()
+
+	if got := br.RemovePort(ports[0]); got != psErr.OK {
+		t.Errorf("BridgeDevice.RemovePort() = %v; want %v", got, psErr.OK)
+	}
+	if got := br.RemovePort(ports[0]); got != psErr.NotFound {
+		t.Errorf("BridgeDevice.RemovePort() = %v; want %v", got, psErr.NotFound)
+	}
+	if ports[0].Flag()&mw.PromiscFlag != 0 {
+		t.Errorf("%s is still promiscuous", ports[0].Name())
+	}
+
+	_ = hosts[0].Transmit(mw.EthBroadcast, []byte{0x01}, mw.EtARP)
+	pollVethDevice(t, ports[0])
+	checkQueuedFrames(t, hosts, [3]int{0, 0, 0})
+	if len(mw.EthRxCh) != 1 {
+		t.Errorf("len(mw.EthRxCh) = %d; want %d", len(mw.EthRxCh), 1)
+	}
+}
+
+// Success when the promiscuous mode which is set to the port apart from the bridge is kept after the port is released.
+func TestBridgeDevice_RemovePort_2(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	br, ports, _, brTeardown := genBridgeTopology(t)
+	defer brTeardown()
+
+	_ = ports[0].SetPromisc(true)
+	defer func() { _ = ports[0].SetPromisc(false) }()
+
+	if got := br.RemovePort(ports[0]); got != psErr.OK {
+		t.Errorf("BridgeDevice.RemovePort() = %v; want %v", got, psErr.OK)
+	}
+	if ports[0].Flag()&mw.PromiscFlag == 0 {
+		t.Errorf("%s isn't promiscuous", ports[0].Name())
+	}
+}
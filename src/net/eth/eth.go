@@ -57,24 +57,44 @@ func receiver(wg *sync.WaitGroup) {
 				return
 			}
 		case msg := <-mw.EthRxCh:
-			switch msg.Type {
-			case mw.EtARP:
-				mw.ArpRxCh <- &mw.ArpRxMessage{
-					Packet: msg.Content,
-					Dev:    msg.Dev,
-				}
-			case mw.EtIPV4:
-				mw.IpRxCh <- msg
-			default:
-				psLog.W(fmt.Sprintf("unknown ether type: 0x%04x", uint16(msg.Type)))
+			dispatch(msg)
+		case msgs := <-mw.EthRxBatchCh:
+			for _, msg := range msgs {
+				dispatch(msg)
 			}
-		case <-mw.EthTxCh:
-			// TODO:
-			psLog.E("not implemented")
 		}
 	}
 }
 
+// dispatch passes the payload of the frame to the protocol of its type.
+func dispatch(msg *mw.EthMessage) {
+	switch msg.Type {
+	case mw.EtARP:
+		mw.ArpRxCh <- &mw.ArpRxMessage{
+			Packet: msg.Content,
+			Dev:    msg.Dev,
+		}
+	case mw.EtIPV4:
+		mw.IpRxCh <- msg
+	default:
+		psLog.W(fmt.Sprintf("unknown ether type: 0x%04x", uint16(msg.Type)))
+	}
+}
+
+// receiveInline processes the payload of the frame in the calling goroutine as far as possible. Receive workers use it
+// instead of EthRxCh so that frames which arrive at different queues are processed in parallel, while frames of a
+// queue are processed in the order they arrived.
+func receiveInline(msg *mw.EthMessage) {
+	if msg.Type != mw.EtIPV4 {
+		dispatch(msg)
+		return
+	}
+	if err := mw.ReceiveIp(msg.Content, msg.Dev); err != psErr.OK {
+		psLog.W(fmt.Sprintf("ip packet was discarded: %s", err), "device: "+msg.Dev.Name())
+	}
+}
+
+// sender passes the frames sent through EthTxCh to the transmit queues of their devices.
 func sender(wg *sync.WaitGroup) {
 	defer func() {
 		psLog.D("eht sender stopped")
@@ -87,13 +107,19 @@ func sender(wg *sync.WaitGroup) {
 	}
 
 	for {
-		msg := <-sndSigCh
-		if msg.Desired == worker.Stopped {
-			sndMonCh <- &worker.Message{
-				ID:      senderID,
-				Current: worker.Stopped,
+		select {
+		case msg := <-sndSigCh:
+			if msg.Desired == worker.Stopped {
+				stopTxQueues()
+				sndMonCh <- &worker.Message{
+					ID:      senderID,
+					Current: worker.Stopped,
+				}
+				return
 			}
-			return
+		case msg := <-mw.EthTxCh:
+			// the frame which is dropped is counted by its transmit queue
+			_ = enqueueTx(msg)
 		}
 	}
 }
@@ -20,6 +20,10 @@ func TestStart(t *testing.T) {
 	if rcvMonMsg.Current != worker.Running || sndMonMsg.Current != worker.Running {
 		t.Errorf("Start() failed")
 	}
+
+	Stop()
+	<-rcvMonCh
+	<-sndMonCh
 }
 
 func TestStop(t *testing.T) {
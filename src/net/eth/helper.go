@@ -1,6 +1,11 @@
 package eth
 
-import "github.com/42milez/ProtocolStack/src/mw"
+import (
+	"fmt"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/mw"
+	"math/rand"
+)
 
 // GenLoopbackDevice generates loopback device object.
 func GenLoopbackDevice(name string) *LoopbackDevice {
@@ -11,6 +16,9 @@ func GenLoopbackDevice(name string) *LoopbackDevice {
 			Addr_: mw.EthAny,
 			Flag_: mw.LoopbackFlag,
 			MTU_:  LoopbackMTU,
+			Priv_: mw.Privilege{
+				FD: -1,
+			},
 		},
 	}
 }
@@ -29,5 +37,200 @@ func GenTapDevice(devName string, privName string, addr mw.EthAddr) *TapDevice {
 				Name: privName,
 			},
 		},
+		epfd: -1,
+	}
+}
+
+// GenPcapDevice generates pcap device object. The device replays frames in rxPath and records transmitted frames into
+// txPath. Transmitted frames are discarded when txPath is empty.
+func GenPcapDevice(devName string, rxPath string, txPath string, addr mw.EthAddr) *PcapDevice {
+	return &PcapDevice{
+		Device: mw.Device{
+			Type_: mw.EthernetDevice,
+			Name_: devName,
+			Addr_: addr,
+			Flag_: mw.BroadcastFlag | mw.NeedArpFlag,
+			MTU_:  mw.EthPayloadLenMax,
+			Priv_: mw.Privilege{
+				FD:   -1,
+				Name: rxPath,
+			},
+		},
+		TxPath: txPath,
+	}
+}
+
+// GenTunDevice generates TUN device object. The device doesn't have ethernet address.
+func GenTunDevice(devName string, privName string) *TunDevice {
+	return &TunDevice{
+		Device: mw.Device{
+			Type_: mw.TunDevice,
+			Name_: devName,
+			Addr_: mw.EthAny,
+			MTU_:  TunMTU,
+			Priv_: mw.Privilege{
+				FD:   -1,
+				Name: privName,
+			},
+		},
+		epfd: -1,
+	}
+}
+
+// GenPacketSocketDevice generates packet socket device object. The device is attached to the interface of the host
+// named ifName.
+func GenPacketSocketDevice(devName string, ifName string, addr mw.EthAddr) *PacketSocketDevice {
+	return &PacketSocketDevice{
+		Device: mw.Device{
+			Type_: mw.EthernetDevice,
+			Name_: devName,
+			Addr_: addr,
+			Flag_: mw.BroadcastFlag | mw.NeedArpFlag,
+			MTU_:  mw.EthPayloadLenMax,
+			Priv_: mw.Privilege{
+				FD:   -1,
+				Name: ifName,
+			},
+		},
+		epfd: -1,
+	}
+}
+
+// GenVethPair generates a pair of virtual ethernet devices. A frame transmitted by one device is received by the other.
+func GenVethPair(devName1 string, devName2 string, addr1 mw.EthAddr, addr2 mw.EthAddr) (*VethDevice, *VethDevice) {
+	dev1 := genVethDevice(devName1, "veth", addr1)
+	dev2 := genVethDevice(devName2, "veth", addr2)
+	link := &vethPair{ends: [2]*VethDevice{dev1, dev2}}
+	dev1.link = link
+	dev2.link = link
+	return dev1, dev2
+}
+
+// GenHub generates hub object. Devices are connected to the hub with Hub.AddPort().
+func GenHub() *Hub {
+	return &Hub{}
+}
+
+// GenVlanDevice generates VLAN device object. The device exchanges frames tagged with vid through parent.
+func GenVlanDevice(devName string, parent mw.IDevice, vid uint16) *VlanDevice {
+	return &VlanDevice{
+		Device: mw.Device{
+			Type_: mw.EthernetDevice,
+			Name_: devName,
+			Addr_: mw.EthAny,
+			Flag_: mw.BroadcastFlag | mw.NeedArpFlag,
+			MTU_:  parent.MTU(),
+			Priv_: mw.Privilege{
+				FD:   -1,
+				Name: parent.Name(),
+			},
+		},
+		Parent: parent,
+		VID:    vid,
+	}
+}
+
+// GenBridgeDevice generates bridge device object. Ports are enslaved to the bridge with BridgeDevice.AddPort().
+func GenBridgeDevice(devName string, addr mw.EthAddr) *BridgeDevice {
+	return &BridgeDevice{
+		Device: mw.Device{
+			Type_: mw.EthernetDevice,
+			Name_: devName,
+			Addr_: addr,
+			Flag_: mw.BroadcastFlag | mw.NeedArpFlag,
+			MTU_:  mw.EthPayloadLenMax,
+			Priv_: mw.Privilege{
+				FD:   -1,
+				Name: "bridge",
+			},
+		},
+		AgeingTime: BridgeAgeingTime,
+	}
+}
+
+// GenVxlanDevice generates VXLAN device object. The device receives VXLAN packets on localPort of the host. Remote
+// endpoints are added with VxlanDevice.AddRemote().
+func GenVxlanDevice(devName string, vni uint32, localPort int, addr mw.EthAddr) *VxlanDevice {
+	return &VxlanDevice{
+		Device: mw.Device{
+			Type_: mw.EthernetDevice,
+			Name_: devName,
+			Addr_: addr,
+			Flag_: mw.BroadcastFlag | mw.NeedArpFlag,
+			MTU_:  VxlanMTU,
+			Priv_: mw.Privilege{
+				FD:   -1,
+				Name: "vxlan",
+			},
+		},
+		VNI:       vni,
+		LocalPort: localPort,
+		epfd:      -1,
+	}
+}
+
+// GenNullDevice generates null device object. The device discards transmitted payloads and injects the payloads which
+// source generates. The source can be nil.
+func GenNullDevice(name string, source TrafficSource) *NullDevice {
+	return &NullDevice{
+		Device: mw.Device{
+			Type_: mw.NullDevice,
+			Name_: name,
+			Addr_: mw.EthAny,
+			MTU_:  mw.EthPayloadLenMax,
+			Priv_: mw.Privilege{
+				FD: -1,
+			},
+		},
+		Source: source,
+	}
+}
+
+// GenNetemDevice generates netem device object which wraps inner. The impairments are chosen with random numbers
+// generated from seed. No impairment is applied until it's configured.
+func GenNetemDevice(devName string, inner mw.IDevice, seed int64) *NetemDevice {
+	return &NetemDevice{
+		Device: mw.Device{
+			Type_: inner.Type(),
+			Name_: devName,
+			Addr_: mw.EthAny,
+			Flag_: inner.Flag() &^ mw.UpFlag,
+			MTU_:  inner.MTU(),
+			Priv_: mw.Privilege{
+				FD:   -1,
+				Name: inner.Name(),
+			},
+		},
+		Inner: inner,
+		rx:    netemPath{rand: rand.New(rand.NewSource(seed))},
+		tx:    netemPath{rand: rand.New(rand.NewSource(seed + 1))},
+	}
+}
+
+// GenFifoQdisc generates FIFO queueing discipline object which holds up to TxQueueLen frames.
+func GenFifoQdisc() *FifoQdisc {
+	return &FifoQdisc{
+		Limit: TxQueueLen,
+	}
+}
+
+// GenTbfQdisc generates token bucket queueing discipline object which sends rate bytes per second with bursts of
+// burst bytes at most. It returns nil when rate or burst isn't positive.
+func GenTbfQdisc(rate int, burst int) *TbfQdisc {
+	if rate <= 0 || burst <= 0 {
+		psLog.E(fmt.Sprintf("invalid token bucket: rate = %d, burst = %d", rate, burst))
+		return nil
+	}
+	return &TbfQdisc{
+		Rate:  rate,
+		Burst: burst,
+		Limit: TxQueueLen,
+	}
+}
+
+// GenPrioQdisc generates priority queueing discipline object of which each band holds up to TxQueueLen frames.
+func GenPrioQdisc() *PrioQdisc {
+	return &PrioQdisc{
+		Limit: TxQueueLen,
 	}
 }
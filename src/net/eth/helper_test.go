@@ -7,16 +7,17 @@ import (
 )
 
 func TestGenLoopbackDevice(t *testing.T) {
-	want := &LoopbackDevice{
-		Device: mw.Device{
-			Type_: mw.LoopbackDevice,
-			Name_: "net0",
-			MTU_:  LoopbackMTU,
-			Flag_: mw.LoopbackFlag,
+	want := mw.Device{
+		Type_: mw.LoopbackDevice,
+		Name_: "net0",
+		MTU_:  LoopbackMTU,
+		Flag_: mw.LoopbackFlag,
+		Priv_: mw.Privilege{
+			FD: -1,
 		},
 	}
 	got := GenLoopbackDevice("net0")
-	if d := cmp.Diff(*got, *want); d != "" {
+	if d := cmp.Diff(got.Device, want); d != "" {
 		t.Errorf("GenLoopbackDevice() differs: (-got +want)\n%s", d)
 	}
 }
@@ -25,21 +26,221 @@ func TestGenTapDevice(t *testing.T) {
 	devName := "net0"
 	privName := "tap0"
 	devEthAddr := mw.EthAddr{11, 12, 13, 14, 15, 16}
-	want := &TapDevice{
-		Device: mw.Device{
-			Type_: mw.EthernetDevice,
-			Name_: devName,
-			MTU_:  mw.EthPayloadLenMax,
-			Flag_: mw.BroadcastFlag | mw.NeedArpFlag,
-			Addr_: devEthAddr,
-			Priv_: mw.Privilege{
-				FD:   -1,
-				Name: privName,
-			},
+	want := mw.Device{
+		Type_: mw.EthernetDevice,
+		Name_: devName,
+		MTU_:  mw.EthPayloadLenMax,
+		Flag_: mw.BroadcastFlag | mw.NeedArpFlag,
+		Addr_: devEthAddr,
+		Priv_: mw.Privilege{
+			FD:   -1,
+			Name: privName,
 		},
 	}
 	got := GenTapDevice(devName, privName, devEthAddr)
-	if d := cmp.Diff(*got, *want); d != "" {
+	if d := cmp.Diff(got.Device, want); d != "" {
 		t.Errorf("GenTapDevice() differs: (-got +want)\n%s", d)
 	}
+	if got.epfd != -1 {
+		t.Errorf("GenTapDevice().epfd = %d; want %d", got.epfd, -1)
+	}
+}
+
+func TestGenTunDevice(t *testing.T) {
+	devName := "net0"
+	privName := "tun0"
+	want := mw.Device{
+		Type_: mw.TunDevice,
+		Name_: devName,
+		MTU_:  TunMTU,
+		Addr_: mw.EthAny,
+		Priv_: mw.Privilege{
+			FD:   -1,
+			Name: privName,
+		},
+	}
+	got := GenTunDevice(devName, privName)
+	if d := cmp.Diff(got.Device, want); d != "" {
+		t.Errorf("GenTunDevice() differs: (-got +want)\n%s", d)
+	}
+	if got.epfd != -1 {
+		t.Errorf("GenTunDevice().epfd = %d; want %d", got.epfd, -1)
+	}
+}
+
+func TestGenPacketSocketDevice(t *testing.T) {
+	devName := "net0"
+	ifName := "veth0"
+	devEthAddr := mw.EthAddr{11, 12, 13, 14, 15, 16}
+	want := mw.Device{
+		Type_: mw.EthernetDevice,
+		Name_: devName,
+		MTU_:  mw.EthPayloadLenMax,
+		Flag_: mw.BroadcastFlag | mw.NeedArpFlag,
+		Addr_: devEthAddr,
+		Priv_: mw.Privilege{
+			FD:   -1,
+			Name: ifName,
+		},
+	}
+	got := GenPacketSocketDevice(devName, ifName, devEthAddr)
+	if d := cmp.Diff(got.Device, want); d != "" {
+		t.Errorf("GenPacketSocketDevice() differs: (-got +want)\n%s", d)
+	}
+	if got.epfd != -1 {
+		t.Errorf("GenPacketSocketDevice().epfd = %d; want %d", got.epfd, -1)
+	}
+}
+
+func TestGenPcapDevice(t *testing.T) {
+	devName := "net0"
+	rxPath := "rx.pcap"
+	txPath := "tx.pcap"
+	devEthAddr := mw.EthAddr{11, 12, 13, 14, 15, 16}
+	want := mw.Device{
+		Type_: mw.EthernetDevice,
+		Name_: devName,
+		MTU_:  mw.EthPayloadLenMax,
+		Flag_: mw.BroadcastFlag | mw.NeedArpFlag,
+		Addr_: devEthAddr,
+		Priv_: mw.Privilege{
+			FD:   -1,
+			Name: rxPath,
+		},
+	}
+	got := GenPcapDevice(devName, rxPath, txPath, devEthAddr)
+	if d := cmp.Diff(got.Device, want); d != "" {
+		t.Errorf("GenPcapDevice() differs: (-got +want)\n%s", d)
+	}
+	if got.TxPath != txPath {
+		t.Errorf("GenPcapDevice().TxPath = %s; want %s", got.TxPath, txPath)
+	}
+}
+
+func TestGenVethPair(t *testing.T) {
+	devEthAddr1 := mw.EthAddr{11, 12, 13, 14, 15, 16}
+	devEthAddr2 := mw.EthAddr{21, 22, 23, 24, 25, 26}
+	want := mw.Device{
+		Type_: mw.EthernetDevice,
+		Name_: "net0",
+		MTU_:  mw.EthPayloadLenMax,
+		Flag_: mw.BroadcastFlag | mw.NeedArpFlag,
+		Addr_: devEthAddr1,
+		Priv_: mw.Privilege{
+			FD:   -1,
+			Name: "veth",
+		},
+	}
+	got1, got2 := GenVethPair("net0", "net1", devEthAddr1, devEthAddr2)
+	if d := cmp.Diff(got1.Device, want); d != "" {
+		t.Errorf("GenVethPair() differs: (-got +want)\n%s", d)
+	}
+	if got1.link != got2.link {
+		t.Errorf("GenVethPair() generated devices which are not linked")
+	}
+}
+
+func TestGenVlanDevice(t *testing.T) {
+	parent, _ := GenVethPair("net0", "net1", mw.EthAddr{11, 12, 13, 14, 15, 16}, mw.EthAddr{21, 22, 23, 24, 25, 26})
+	want := mw.Device{
+		Type_: mw.EthernetDevice,
+		Name_: "net0.10",
+		MTU_:  mw.EthPayloadLenMax,
+		Flag_: mw.BroadcastFlag | mw.NeedArpFlag,
+		Addr_: mw.EthAny,
+		Priv_: mw.Privilege{
+			FD:   -1,
+			Name: "net0",
+		},
+	}
+	got := GenVlanDevice("net0.10", parent, 10)
+	if d := cmp.Diff(got.Device, want); d != "" {
+		t.Errorf("GenVlanDevice() differs: (-got +want)\n%s", d)
+	}
+	if got.Parent != parent || got.VID != 10 {
+		t.Errorf("GenVlanDevice() = (%v, %d); want (%v, %d)", got.Parent, got.VID, parent, 10)
+	}
+}
+
+func TestGenBridgeDevice(t *testing.T) {
+	devEthAddr := mw.EthAddr{11, 12, 13, 14, 15, 16}
+	want := mw.Device{
+		Type_: mw.EthernetDevice,
+		Name_: "br0",
+		MTU_:  mw.EthPayloadLenMax,
+		Flag_: mw.BroadcastFlag | mw.NeedArpFlag,
+		Addr_: devEthAddr,
+		Priv_: mw.Privilege{
+			FD:   -1,
+			Name: "bridge",
+		},
+	}
+	got := GenBridgeDevice("br0", devEthAddr)
+	if d := cmp.Diff(got.Device, want); d != "" {
+		t.Errorf("GenBridgeDevice() differs: (-got +want)\n%s", d)
+	}
+	if got.AgeingTime != BridgeAgeingTime {
+		t.Errorf("GenBridgeDevice().AgeingTime = %v; want %v", got.AgeingTime, BridgeAgeingTime)
+	}
+}
+
+func TestGenVxlanDevice(t *testing.T) {
+	devEthAddr := mw.EthAddr{11, 12, 13, 14, 15, 16}
+	want := mw.Device{
+		Type_: mw.EthernetDevice,
+		Name_: "net0",
+		MTU_:  VxlanMTU,
+		Flag_: mw.BroadcastFlag | mw.NeedArpFlag,
+		Addr_: devEthAddr,
+		Priv_: mw.Privilege{
+			FD:   -1,
+			Name: "vxlan",
+		},
+	}
+	got := GenVxlanDevice("net0", 100, VxlanPort, devEthAddr)
+	if d := cmp.Diff(got.Device, want); d != "" {
+		t.Errorf("GenVxlanDevice() differs: (-got +want)\n%s", d)
+	}
+	if got.VNI != 100 || got.LocalPort != VxlanPort || got.epfd != -1 {
+		t.Errorf("GenVxlanDevice() = (%d, %d, %d); want (%d, %d, %d)", got.VNI, got.LocalPort, got.epfd, 100, VxlanPort, -1)
+	}
+}
+
+func TestGenNullDevice(t *testing.T) {
+	want := mw.Device{
+		Type_: mw.NullDevice,
+		Name_: "null0",
+		MTU_:  mw.EthPayloadLenMax,
+		Addr_: mw.EthAny,
+		Priv_: mw.Privilege{
+			FD: -1,
+		},
+	}
+	got := GenNullDevice("null0", nil)
+	if d := cmp.Diff(got.Device, want); d != "" {
+		t.Errorf("GenNullDevice() differs: (-got +want)\n%s", d)
+	}
+}
+
+func TestGenNetemDevice(t *testing.T) {
+	inner := GenTapDevice("net0", "tap0", mw.EthAddr{11, 12, 13, 14, 15, 16})
+	inner.Up()
+	want := mw.Device{
+		Type_: mw.EthernetDevice,
+		Name_: "netem0",
+		MTU_:  mw.EthPayloadLenMax,
+		Flag_: mw.BroadcastFlag | mw.NeedArpFlag,
+		Addr_: mw.EthAny,
+		Priv_: mw.Privilege{
+			FD:   -1,
+			Name: "net0",
+		},
+	}
+	got := GenNetemDevice("netem0", inner, 1)
+	if d := cmp.Diff(got.Device, want); d != "" {
+		t.Errorf("GenNetemDevice() differs: (-got +want)\n%s", d)
+	}
+	if got.Inner != inner {
+		t.Errorf("GenNetemDevice().Inner = %v; want %v", got.Inner, inner)
+	}
 }
@@ -1,29 +1,76 @@
 package eth
 
 import (
+	"bytes"
+	"encoding/binary"
 	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
 	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/42milez/ProtocolStack/src/pcap"
 	"math"
 )
 
 const LoopbackMTU = math.MaxUint16
 
+// LoopbackDevice delivers transmitted payloads back to the stack. The payloads are queued by Transmit() and injected
+// into the ethernet receiver by Poll(). ARP is never used since the device doesn't have NeedArpFlag.
 type LoopbackDevice struct {
 	mw.Device
+	queue frameQueue
 }
 
 func (p *LoopbackDevice) Open() error {
+	p.queue.reset()
 	return psErr.OK
 }
 
 func (p *LoopbackDevice) Close() error {
+	p.queue.reset()
 	return psErr.OK
 }
 
 func (p *LoopbackDevice) Poll() error {
+	frame := p.queue.pop()
+	if frame == nil {
+		return psErr.NoDataToRead
+	}
+
+	mw.CaptureFrame(p, pcap.DirectionInbound, frame)
+
+	msg, err := mw.ReceiveFrame(frame, p)
+	if err != psErr.OK {
+		if err != psErr.NoDataToRead {
+			return psErr.Error
+		}
+		return psErr.OK
+	}
+	mw.EthRxCh <- msg
+
 	return psErr.OK
 }
 
 func (p *LoopbackDevice) Transmit(dst mw.EthAddr, payload []byte, typ mw.EthType) error {
+	// Frames on the loopback device have zero addresses and aren't padded.
+	hdr := mw.EthHdr{
+		Dst:  mw.EthAny,
+		Src:  mw.EthAny,
+		Type: typ,
+	}
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.BigEndian, &hdr); err != nil {
+		return psErr.WriteToBufError
+	}
+	buf.Write(payload)
+	frame := buf.Bytes()
+
+	// drop the frame the same way as the queue of a network device overflows
+	if !p.queue.push(frame) {
+		psLog.W("loopback queue is full (frame was dropped)")
+		return psErr.OK
+	}
+
+	mw.CaptureFrame(p, pcap.DirectionOutbound, frame)
+	mw.NotifyRx(p)
+
 	return psErr.OK
 }
@@ -1,6 +1,7 @@
 package eth
 
 import (
+	"bytes"
 	psErr "github.com/42milez/ProtocolStack/src/error"
 	psLog "github.com/42milez/ProtocolStack/src/log"
 	"github.com/42milez/ProtocolStack/src/mw"
@@ -60,3 +61,42 @@ func TestLoopbackDevice_Transmit(t *testing.T) {
 		t.Errorf("LoopbackDevice.Transmit() = %v; want %v", got, psErr.OK)
 	}
 }
+
+func TestLoopbackDevice_Poll(t *testing.T) {
+	ctrl, teardown := setupLoopbackTest(t)
+	defer teardown()
+
+	psSyscall.Syscall = psSyscall.NewMockISyscall(ctrl)
+
+	loopbackDev := GenLoopbackDevice("net0")
+	_ = loopbackDev.Open()
+
+	// nothing is delivered until a payload is transmitted
+	if got := loopbackDev.Poll(); got != psErr.NoDataToRead {
+		t.Errorf("LoopbackDevice.Poll() = %v; want %v", got, psErr.NoDataToRead)
+	}
+	if len(mw.EthRxCh) != 0 {
+		t.Fatalf("len(mw.EthRxCh) = %d; want %d", len(mw.EthRxCh), 0)
+	}
+
+	payload := []byte{0x01, 0x02, 0x03, 0x04}
+	_ = loopbackDev.Transmit(mw.EthAny, payload, mw.EtIPV4)
+	payload[0] = 0xff
+
+	if got := loopbackDev.Poll(); got != psErr.OK {
+		t.Errorf("LoopbackDevice.Poll() = %v; want %v", got, psErr.OK)
+	}
+	if len(mw.EthRxCh) != 1 {
+		t.Fatalf("len(mw.EthRxCh) = %d; want %d", len(mw.EthRxCh), 1)
+	}
+
+	want := &mw.EthMessage{
+		Type:    mw.EtIPV4,
+		Content: []byte{0x01, 0x02, 0x03, 0x04},
+		Dev:     loopbackDev,
+	}
+	got := <-mw.EthRxCh
+	if got.Type != want.Type || !bytes.Equal(got.Content, want.Content) || got.Dev != want.Dev {
+		t.Errorf("LoopbackDevice.Poll() delivered %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,378 @@
+package eth
+
+import (
+	"fmt"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/mw"
+	psTime "github.com/42milez/ProtocolStack/src/time"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// NetemConfig is a set of impairments applied to the frames which go in a direction. Probabilities range from 0 to 1.
+type NetemConfig struct {
+	Latency   time.Duration // delay added to every frame
+	Jitter    time.Duration // variation of the delay, which is chosen uniformly from Latency ± Jitter
+	Loss      float64       // probability that a frame is dropped
+	Duplicate float64       // probability that a frame is delivered twice
+	Reorder   float64       // probability that a frame isn't delayed, so that it overtakes the delayed frames
+	Corrupt   float64       // probability that a bit of the payload is flipped
+}
+
+func (v NetemConfig) validate() error {
+	if v.Latency < 0 || v.Jitter < 0 {
+		return psErr.Error
+	}
+	for _, prob := range []float64{v.Loss, v.Duplicate, v.Reorder, v.Corrupt} {
+		if prob < 0 || prob > 1 {
+			return psErr.Error
+		}
+	}
+	return psErr.OK
+}
+
+// NetemDevice wraps a device and impairs the frames which it transmits and receives like netem of Linux. Frames which
+// arrive at the inner device are taken by mw.ReceiveFrame(), so the inner device has to be registered in the device
+// repository as well to be read, and the interface is assigned to the wrapper. The impairments are chosen with random
+// numbers generated from the seed, and the delays are measured with psTime.Time, so the same sequence of frames is
+// impaired the same way every time.
+type NetemDevice struct {
+	mw.Device
+	Inner mw.IDevice
+	rx    netemPath
+	tx    netemPath
+	queue frameQueue
+}
+
+// RxConfig returns the impairments applied to received frames.
+func (p *NetemDevice) RxConfig() NetemConfig {
+	return p.rx.config()
+}
+
+// TxConfig returns the impairments applied to transmitted frames.
+func (p *NetemDevice) TxConfig() NetemConfig {
+	return p.tx.config()
+}
+
+// SetRxConfig changes the impairments applied to received frames. Frames which are already delayed aren't affected.
+func (p *NetemDevice) SetRxConfig(cfg NetemConfig) error {
+	return p.rx.setConfig(cfg)
+}
+
+// SetTxConfig changes the impairments applied to transmitted frames. Frames which are already delayed aren't affected.
+func (p *NetemDevice) SetTxConfig(cfg NetemConfig) error {
+	return p.tx.setConfig(cfg)
+}
+
+func (p *NetemDevice) Open() error {
+	if p.Inner.Type() == mw.TunDevice || p.Inner.Equal(p) {
+		psLog.E(fmt.Sprintf("%s can't be wrapped", p.Inner.Name()), "device: "+p.Name_)
+		return psErr.Error
+	}
+	if err := mw.SetRxHandler(p.Inner, p.receive); err != psErr.OK {
+		return err
+	}
+	p.queue.reset()
+	p.rx.line.start()
+	p.tx.line.start()
+	return psErr.OK
+}
+
+func (p *NetemDevice) Close() error {
+	p.rx.line.stop()
+	p.tx.line.stop()
+	p.queue.reset()
+	return mw.SetRxHandler(p.Inner, nil)
+}
+
+// Poll reads a frame which has passed through the impairments.
+func (p *NetemDevice) Poll() error {
+	frame := p.queue.pop()
+	if frame == nil {
+		return psErr.NoDataToRead
+	}
+
+	msg, err := mw.ReceiveFrame(frame, p)
+	if err != psErr.OK {
+		if err != psErr.NoDataToRead {
+			return psErr.Error
+		}
+		return psErr.OK
+	}
+	mw.EthRxCh <- msg
+
+	return psErr.OK
+}
+
+// Transmit passes the payload to the inner device through the impairments. The payload is accepted even if it's
+// dropped, the same way as a lossy link does.
+func (p *NetemDevice) Transmit(dst mw.EthAddr, payload []byte, typ mw.EthType) error {
+	b := make([]byte, len(payload))
+	copy(b, payload)
+
+	p.tx.apply(b, 0, func(data []byte) {
+		if err := p.Inner.Transmit(dst, data, typ); err != psErr.OK {
+			psLog.E(fmt.Sprintf("can't transmit frame: %s", err), "device: "+p.Name_)
+		}
+	})
+
+	return psErr.OK
+}
+
+func (p *NetemDevice) Addr() mw.EthAddr {
+	return p.Inner.Addr()
+}
+
+// SetPromisc makes the inner device promiscuous as well while the device is promiscuous, since the inner device
+// filters the frames before they are passed to the wrapper.
+func (p *NetemDevice) SetPromisc(on bool) error {
+	return p.CountPromisc(on, p.Inner.SetPromisc)
+}
+
+// JoinMulticast makes the inner device join the multicast address as well.
+func (p *NetemDevice) JoinMulticast(addr mw.EthAddr) error {
+	if err := p.Inner.JoinMulticast(addr); err != psErr.OK {
+		return err
+	}
+	return p.Device.JoinMulticast(addr)
+}
+
+func (p *NetemDevice) LeaveMulticast(addr mw.EthAddr) error {
+	if err := p.Device.LeaveMulticast(addr); err != psErr.OK {
+		return err
+	}
+	return p.Inner.LeaveMulticast(addr)
+}
+
+// Offload returns the transmit offloads of the inner device, which the payloads are passed to. The checksums of
+// received frames are always verified by the stack so that corrupted frames are detected. Note that the checksums of
+// TCP segments are completed by the inner device after the impairments, so their corruption isn't detected.
+func (p *NetemDevice) Offload() mw.Offload {
+	return mw.OffloadOf(p.Inner) &^ mw.RxCsumOffload
+}
+
+// receive is the receive handler of the inner device.
+func (p *NetemDevice) receive(_ mw.IDevice, frame []byte) {
+	if !p.IsUp() {
+		return
+	}
+
+	// the frame is reused by the inner device after the handler returns
+	b := make([]byte, len(frame))
+	copy(b, frame)
+
+	p.rx.apply(b, mw.EthHdrLen, func(data []byte) {
+		if !p.queue.push(data) {
+			psLog.W("receive queue is full (frame was dropped)", "device: "+p.Name_)
+			return
+		}
+		mw.NotifyRx(p)
+	})
+}
+
+// netemPath impairs the frames which go in a direction.
+type netemPath struct {
+	cfg  NetemConfig
+	rand *rand.Rand
+	mtx  sync.Mutex
+	line delayLine
+}
+
+func (p *netemPath) config() NetemConfig {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.cfg
+}
+
+func (p *netemPath) setConfig(cfg NetemConfig) error {
+	if err := cfg.validate(); err != psErr.OK {
+		psLog.E(fmt.Sprintf("invalid impairments: %+v", cfg))
+		return err
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.cfg = cfg
+	return psErr.OK
+}
+
+// apply impairs the data and passes the result to deliver. Bytes before offset (e.g. ethernet header) are never
+// corrupted. Copies which aren't delayed are delivered before apply returns.
+func (p *netemPath) apply(data []byte, offset int, deliver func(data []byte)) {
+	type copy_ struct {
+		data  []byte
+		delay time.Duration
+	}
+	var copies []copy_
+
+	p.mtx.Lock()
+	cfg := p.cfg
+	if cfg.Loss > 0 && p.rand.Float64() < cfg.Loss {
+		p.mtx.Unlock()
+		return
+	}
+	n := 1
+	if cfg.Duplicate > 0 && p.rand.Float64() < cfg.Duplicate {
+		n = 2
+	}
+	for i := 0; i < n; i++ {
+		b := data
+		if cfg.Corrupt > 0 && p.rand.Float64() < cfg.Corrupt && len(data) > offset {
+			b = make([]byte, len(data))
+			copy(b, data)
+			b[offset+p.rand.Intn(len(data)-offset)] ^= 1 << uint(p.rand.Intn(8))
+		}
+		copies = append(copies, copy_{data: b, delay: p.delay(cfg)})
+	}
+	p.mtx.Unlock()
+
+	for _, v := range copies {
+		data := v.data
+		if v.delay == 0 {
+			deliver(data)
+			continue
+		}
+		p.line.schedule(v.delay, func() { deliver(data) })
+	}
+}
+
+// delay chooses the delay of a frame. It must be called with the lock held.
+func (p *netemPath) delay(cfg NetemConfig) time.Duration {
+	if cfg.Reorder > 0 && p.rand.Float64() < cfg.Reorder {
+		return 0
+	}
+	d := cfg.Latency
+	if cfg.Jitter > 0 {
+		d += time.Duration(p.rand.Int63n(int64(2*cfg.Jitter)+1)) - cfg.Jitter
+	}
+	if d < 0 {
+		d = 0
+	}
+	return d
+}
+
+// delayLine runs functions when their delays elapse. Functions which are due at the same time run in the order they
+// are scheduled.
+type delayLine struct {
+	items  []*delayedItem
+	clock  psTime.ITime
+	seq    uint64
+	mtx    sync.Mutex
+	wakeCh chan bool
+	stopCh chan bool
+	doneCh chan bool
+}
+
+type delayedItem struct {
+	due time.Time
+	seq uint64
+	f   func()
+}
+
+func (p *delayLine) start() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.stopCh != nil {
+		return
+	}
+	p.items = nil
+	p.clock = psTime.Time
+	p.wakeCh = make(chan bool, 1)
+	p.stopCh = make(chan bool)
+	p.doneCh = make(chan bool)
+
+	go p.run(p.clock, p.wakeCh, p.stopCh, p.doneCh)
+}
+
+// stop discards the functions which haven't run yet. It waits for the running function to return.
+func (p *delayLine) stop() {
+	p.mtx.Lock()
+	stopCh := p.stopCh
+	doneCh := p.doneCh
+	p.stopCh = nil
+	p.items = nil
+	p.mtx.Unlock()
+
+	if stopCh == nil {
+		return
+	}
+	close(stopCh)
+	<-doneCh
+}
+
+// schedule runs the function after the delay. The function is discarded when the line isn't running.
+func (p *delayLine) schedule(delay time.Duration, f func()) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.stopCh == nil {
+		return
+	}
+
+	item := &delayedItem{
+		due: p.clock.Now().Add(delay),
+		seq: p.seq,
+		f:   f,
+	}
+	p.seq++
+
+	i := sort.Search(len(p.items), func(i int) bool {
+		return p.items[i].due.After(item.due)
+	})
+	p.items = append(p.items, nil)
+	copy(p.items[i+1:], p.items[i:])
+	p.items[i] = item
+
+	// the earliest due time has changed
+	if i == 0 {
+		select {
+		case p.wakeCh <- true:
+		default:
+		}
+	}
+}
+
+func (p *delayLine) run(clock psTime.ITime, wakeCh chan bool, stopCh chan bool, doneCh chan bool) {
+	defer close(doneCh)
+
+	for {
+		var f func()
+		wait := time.Duration(-1)
+
+		p.mtx.Lock()
+		if len(p.items) != 0 {
+			if d := p.items[0].due.Sub(clock.Now()); d > 0 {
+				wait = d
+			} else {
+				f = p.items[0].f
+				p.items[0] = nil
+				p.items = p.items[1:]
+			}
+		}
+		p.mtx.Unlock()
+
+		if f != nil {
+			f()
+			continue
+		}
+
+		var timerCh <-chan time.Time
+		stopTimer := func() {}
+		if wait >= 0 {
+			timerCh, stopTimer = clock.After(wait)
+		}
+
+		select {
+		case <-stopCh:
+			stopTimer()
+			return
+		case <-wakeCh:
+		case <-timerCh:
+		}
+
+		stopTimer()
+	}
+}
@@ -0,0 +1,384 @@
+package eth
+
+import (
+	"bytes"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	"github.com/42milez/ProtocolStack/src/mw"
+	psTime "github.com/42milez/ProtocolStack/src/time"
+	"github.com/google/go-cmp/cmp"
+	"sync"
+	"testing"
+	"time"
+)
+
+const netemTestTimeout = time.Second
+
+// genNetemTopology generates a netem device which wraps one end of a veth pair. The other end is returned as peer.
+func genNetemTopology(t *testing.T, seed int64) (dev *NetemDevice, inner *VethDevice, peer *VethDevice, teardown func()) {
+	inner, peer = GenVethPair("net0", "net1", vethEthAddr1, vethEthAddr2)
+	openVethDevice(inner, peer)
+	dev = GenNetemDevice("netem0", inner, seed)
+	if err := dev.Open(); err != psErr.OK {
+		t.Fatalf("NetemDevice.Open() = %v; want %v", err, psErr.OK)
+	}
+	dev.Up()
+	teardown = func() {
+		_ = dev.Close()
+	}
+	return
+}
+
+// transmitSeq transmits n frames of which payload is the sequence number.
+func transmitSeq(dev mw.IDevice, n int) {
+	for i := 0; i < n; i++ {
+		_ = dev.Transmit(vethEthAddr2, []byte{byte(i)}, mw.EtIPV4)
+	}
+}
+
+// receivedSeq returns the sequence numbers of the frames queued in the device.
+func receivedSeq(dev *VethDevice) (ret []byte) {
+	for {
+		frame := dev.queue.pop()
+		if frame == nil {
+			return
+		}
+		ret = append(ret, frame[mw.EthHdrLen])
+	}
+}
+
+// waitForIdle waits until the delay line waits for the clock, and returns when the next function is due. It returns
+// false when no function is left.
+func waitForIdle(t *testing.T, line *delayLine, clock *fakeClock) (time.Time, bool) {
+	zero := time.Now()
+	for {
+		line.mtx.Lock()
+		n := len(line.items)
+		var due time.Time
+		if n != 0 {
+			due = line.items[0].due
+		}
+		line.mtx.Unlock()
+
+		if n == 0 {
+			return time.Time{}, false
+		}
+		if clock.waiting() {
+			return due, true
+		}
+		if time.Since(zero) > netemTestTimeout {
+			t.Fatalf("delay line doesn't wait for the clock")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+type netemDelivery struct {
+	Seq byte
+	At  time.Duration
+}
+
+// fakeClock is the clock which advances only when it's set.
+type fakeClock struct {
+	now    time.Time
+	timers []*fakeTimer
+	mtx    sync.Mutex
+}
+
+type fakeTimer struct {
+	due time.Time
+	c   chan time.Time
+}
+
+func (p *fakeClock) Now() time.Time {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.now
+}
+
+func (p *fakeClock) After(d time.Duration) (<-chan time.Time, func()) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	timer := &fakeTimer{due: p.now.Add(d), c: make(chan time.Time, 1)}
+	p.timers = append(p.timers, timer)
+
+	return timer.c, func() { p.remove(timer) }
+}
+
+// set advances the clock and fires the timers which are due.
+func (p *fakeClock) set(now time.Time) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.now = now
+	timers := p.timers[:0]
+	for _, v := range p.timers {
+		if v.due.After(now) {
+			timers = append(timers, v)
+			continue
+		}
+		v.c <- now
+	}
+	p.timers = timers
+}
+
+// waiting reports whether a timer is waiting to be fired.
+func (p *fakeClock) waiting() bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return len(p.timers) != 0
+}
+
+func (p *fakeClock) remove(timer *fakeTimer) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for i, v := range p.timers {
+		if v == timer {
+			p.timers = append(p.timers[:i], p.timers[i+1:]...)
+			return
+		}
+	}
+}
+
+func waitForFrames(t *testing.T, dev *VethDevice, n int) {
+	zero := time.Now()
+	for queuedFrames(dev) < n {
+		if time.Since(zero) > netemTestTimeout {
+			t.Fatalf("%s received %d frames; want %d", dev.Name(), queuedFrames(dev), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Success when frames pass through the device as they are without impairments.
+func TestNetemDevice_1(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	dev, inner, peer, netemTeardown := genNetemTopology(t, 1)
+	defer netemTeardown()
+
+	transmitSeq(dev, 3)
+	if got := receivedSeq(peer); !bytes.Equal(got, []byte{0, 1, 2}) {
+		t.Errorf("received frames = %v; want %v", got, []byte{0, 1, 2})
+	}
+
+	_ = peer.Transmit(vethEthAddr1, []byte{0x01}, mw.EtIPV4)
+	pollVethDevice(t, inner)
+	if len(mw.EthRxCh) != 0 {
+		t.Fatalf("len(mw.EthRxCh) = %d; want %d", len(mw.EthRxCh), 0)
+	}
+	if got := dev.Poll(); got != psErr.OK {
+		t.Fatalf("NetemDevice.Poll() = %v; want %v", got, psErr.OK)
+	}
+	if msg := <-mw.EthRxCh; msg.Dev != dev {
+		t.Errorf("message was delivered to %s; want %s", msg.Dev.Name(), dev.Name())
+	}
+}
+
+// Success when all the frames are lost.
+func TestNetemDevice_2(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	dev, inner, peer, netemTeardown := genNetemTopology(t, 1)
+	defer netemTeardown()
+
+	_ = dev.SetTxConfig(NetemConfig{Loss: 1})
+	_ = dev.SetRxConfig(NetemConfig{Loss: 1})
+
+	transmitSeq(dev, 3)
+	if got := queuedFrames(peer); got != 0 {
+		t.Errorf("%s received %d frames; want %d", peer.Name(), got, 0)
+	}
+
+	_ = peer.Transmit(vethEthAddr1, []byte{0x01}, mw.EtIPV4)
+	pollVethDevice(t, inner)
+	if got := dev.Poll(); got != psErr.NoDataToRead {
+		t.Errorf("NetemDevice.Poll() = %v; want %v", got, psErr.NoDataToRead)
+	}
+}
+
+// Success when all the frames are duplicated.
+func TestNetemDevice_3(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	dev, _, peer, netemTeardown := genNetemTopology(t, 1)
+	defer netemTeardown()
+
+	_ = dev.SetTxConfig(NetemConfig{Duplicate: 1})
+
+	transmitSeq(dev, 2)
+	if got := receivedSeq(peer); !bytes.Equal(got, []byte{0, 0, 1, 1}) {
+		t.Errorf("received frames = %v; want %v", got, []byte{0, 0, 1, 1})
+	}
+}
+
+// Success when frames are delayed.
+func TestNetemDevice_4(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	dev, _, peer, netemTeardown := genNetemTopology(t, 1)
+	defer netemTeardown()
+
+	latency := 50 * time.Millisecond
+	_ = dev.SetTxConfig(NetemConfig{Latency: latency})
+
+	zero := time.Now()
+	transmitSeq(dev, 3)
+	if got := queuedFrames(peer); got != 0 {
+		t.Errorf("%s received %d frames; want %d", peer.Name(), got, 0)
+	}
+	waitForFrames(t, peer, 3)
+	if elapsed := time.Since(zero); elapsed < latency {
+		t.Errorf("frames were delivered in %v; want %v or later", elapsed, latency)
+	}
+	if got := receivedSeq(peer); !bytes.Equal(got, []byte{0, 1, 2}) {
+		t.Errorf("received frames = %v; want %v", got, []byte{0, 1, 2})
+	}
+}
+
+// Success when frames which aren't delayed overtake delayed ones.
+func TestNetemDevice_5(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	dev, _, peer, netemTeardown := genNetemTopology(t, 1)
+	defer netemTeardown()
+
+	_ = dev.SetTxConfig(NetemConfig{Latency: 50 * time.Millisecond})
+	transmitSeq(dev, 1)
+	_ = dev.SetTxConfig(NetemConfig{Latency: 50 * time.Millisecond, Reorder: 1})
+	_ = dev.Transmit(vethEthAddr2, []byte{1}, mw.EtIPV4)
+
+	waitForFrames(t, peer, 2)
+	if got := receivedSeq(peer); !bytes.Equal(got, []byte{1, 0}) {
+		t.Errorf("received frames = %v; want %v", got, []byte{1, 0})
+	}
+}
+
+// Success when a bit of the payload of received frame is flipped.
+func TestNetemDevice_6(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	dev, inner, peer, netemTeardown := genNetemTopology(t, 1)
+	defer netemTeardown()
+
+	_ = dev.SetRxConfig(NetemConfig{Corrupt: 1})
+
+	payload := make([]byte, mw.EthPayloadLenMin)
+	_ = peer.Transmit(vethEthAddr1, payload, mw.EtIPV4)
+	pollVethDevice(t, inner)
+	if got := dev.Poll(); got != psErr.OK {
+		t.Fatalf("NetemDevice.Poll() = %v; want %v", got, psErr.OK)
+	}
+
+	msg := <-mw.EthRxCh
+	flipped := 0
+	for _, v := range msg.Content {
+		for ; v != 0; v &= v - 1 {
+			flipped++
+		}
+	}
+	if flipped != 1 {
+		t.Errorf("%d bits were flipped; want %d", flipped, 1)
+	}
+}
+
+// Success when the same frames are impaired the same way with the same seed.
+func TestNetemDevice_7(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	cfg := NetemConfig{Loss: 0.3, Duplicate: 0.3}
+	var results [2][]byte
+	for i := range results {
+		dev, _, peer, netemTeardown := genNetemTopology(t, 42)
+		_ = dev.SetTxConfig(cfg)
+		transmitSeq(dev, 100)
+		results[i] = receivedSeq(peer)
+		netemTeardown()
+	}
+
+	if !bytes.Equal(results[0], results[1]) {
+		t.Errorf("received frames differ: %v, %v", results[0], results[1])
+	}
+	if len(results[0]) == 100 {
+		t.Errorf("no frame was impaired")
+	}
+}
+
+// Success when the frames are delivered in the same order at the same time with the same seed.
+func TestNetemDevice_8(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	backupTime := psTime.Time
+	defer func() { psTime.Time = backupTime }()
+
+	cfg := NetemConfig{
+		Latency:   50 * time.Millisecond,
+		Jitter:    20 * time.Millisecond,
+		Duplicate: 0.2,
+		Reorder:   0.2,
+	}
+	var results [2][]netemDelivery
+	for i := range results {
+		zero, _ := time.Parse(time.RFC3339, "2021-01-01T00:00:00Z")
+		clock := &fakeClock{now: zero}
+		psTime.Time = clock
+
+		dev, _, peer, netemTeardown := genNetemTopology(t, 42)
+		_ = dev.SetTxConfig(cfg)
+		transmitSeq(dev, 20)
+		for {
+			for _, v := range receivedSeq(peer) {
+				results[i] = append(results[i], netemDelivery{Seq: v, At: clock.Now().Sub(zero)})
+			}
+			due, ok := waitForIdle(t, &dev.tx.line, clock)
+			if !ok {
+				break
+			}
+			clock.set(due)
+		}
+		netemTeardown()
+		for _, v := range receivedSeq(peer) {
+			results[i] = append(results[i], netemDelivery{Seq: v, At: clock.Now().Sub(zero)})
+		}
+	}
+
+	if d := cmp.Diff(results[0], results[1]); d != "" {
+		t.Errorf("deliveries differs: (-got +want)\n%s", d)
+	}
+	if len(results[0]) <= 20 {
+		t.Errorf("%d frames were delivered; want more than %d", len(results[0]), 20)
+	}
+	for _, v := range results[0] {
+		if v.At != 0 && (v.At < cfg.Latency-cfg.Jitter || v.At > cfg.Latency+cfg.Jitter) {
+			t.Errorf("frame %d was delivered at %v; want %v ± %v", v.Seq, v.At, cfg.Latency, cfg.Jitter)
+		}
+	}
+}
+
+// Fail when the configuration is invalid.
+func TestNetemDevice_SetTxConfig(t *testing.T) {
+	dev := GenNetemDevice("netem0", GenLoopbackDevice("net0"), 1)
+	if got := dev.SetTxConfig(NetemConfig{Loss: 1.5}); got != psErr.Error {
+		t.Errorf("NetemDevice.SetTxConfig() = %v; want %v", got, psErr.Error)
+	}
+	if got := dev.SetTxConfig(NetemConfig{Latency: -time.Second}); got != psErr.Error {
+		t.Errorf("NetemDevice.SetTxConfig() = %v; want %v", got, psErr.Error)
+	}
+}
+
+// Fail when the inner device is TUN device.
+func TestNetemDevice_Open(t *testing.T) {
+	dev := GenNetemDevice("netem0", GenTunDevice("net0", "tun0"), 1)
+	if got := dev.Open(); got != psErr.Error {
+		t.Errorf("NetemDevice.Open() = %v; want %v", got, psErr.Error)
+	}
+}
@@ -0,0 +1,71 @@
+package eth
+
+import (
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	"github.com/42milez/ProtocolStack/src/mw"
+	"sync/atomic"
+)
+
+// NullDevice discards all the payloads transmitted to it. It's used as the device of blackhole routes, and to benchmark
+// the upper layers without any I/O. When Source is set, Poll() injects the payloads which the source generates as if
+// they had arrived at the device. The payloads don't have ethernet header, so they aren't captured.
+type NullDevice struct {
+	mw.Device
+	Source       TrafficSource
+	dropped      uint64
+	droppedBytes uint64
+	injected     uint64
+}
+
+// A TrafficSource generates a payload to be injected by the null device. It returns false when it has nothing to
+// generate. Call mw.NotifyRx() with the device when the source gets ready again.
+type TrafficSource func() (payload []byte, typ mw.EthType, ok bool)
+
+type NullDeviceStats struct {
+	Dropped      uint64
+	DroppedBytes uint64
+	Injected     uint64
+}
+
+func (p *NullDevice) Open() error {
+	return psErr.OK
+}
+
+func (p *NullDevice) Close() error {
+	return psErr.OK
+}
+
+func (p *NullDevice) Poll() error {
+	if p.Source == nil {
+		return psErr.NoDataToRead
+	}
+
+	payload, typ, ok := p.Source()
+	if !ok {
+		return psErr.NoDataToRead
+	}
+	atomic.AddUint64(&p.injected, 1)
+
+	mw.EthRxCh <- &mw.EthMessage{
+		Type:    typ,
+		Content: payload,
+		Dev:     p,
+	}
+
+	return psErr.OK
+}
+
+func (p *NullDevice) Transmit(dst mw.EthAddr, payload []byte, typ mw.EthType) error {
+	atomic.AddUint64(&p.dropped, 1)
+	atomic.AddUint64(&p.droppedBytes, uint64(len(payload)))
+	return psErr.OK
+}
+
+// Stats returns the number of the payloads the device has discarded and injected.
+func (p *NullDevice) Stats() NullDeviceStats {
+	return NullDeviceStats{
+		Dropped:      atomic.LoadUint64(&p.dropped),
+		DroppedBytes: atomic.LoadUint64(&p.droppedBytes),
+		Injected:     atomic.LoadUint64(&p.injected),
+	}
+}
@@ -0,0 +1,66 @@
+package eth
+
+import (
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	"github.com/42milez/ProtocolStack/src/mw"
+	"testing"
+)
+
+func TestNullDevice_Transmit(t *testing.T) {
+	dev := GenNullDevice("null0", nil)
+
+	for i := 0; i < 3; i++ {
+		if got := dev.Transmit(mw.EthAny, make([]byte, 10), mw.EtIPV4); got != psErr.OK {
+			t.Errorf("NullDevice.Transmit() = %v; want %v", got, psErr.OK)
+		}
+	}
+	if got, want := dev.Stats(), (NullDeviceStats{Dropped: 3, DroppedBytes: 30}); got != want {
+		t.Errorf("NullDevice.Stats() = %+v; want %+v", got, want)
+	}
+}
+
+// Success when nothing is injected without source.
+func TestNullDevice_Poll_1(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	dev := GenNullDevice("null0", nil)
+
+	if got := dev.Poll(); got != psErr.NoDataToRead {
+		t.Errorf("NullDevice.Poll() = %v; want %v", got, psErr.NoDataToRead)
+	}
+}
+
+// Success when the payloads which the source generates are injected.
+func TestNullDevice_Poll_2(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	n := 2
+	dev := GenNullDevice("null0", func() ([]byte, mw.EthType, bool) {
+		if n == 0 {
+			return nil, 0, false
+		}
+		n--
+		return []byte{0x45}, mw.EtIPV4, true
+	})
+
+	for i := 0; i < 2; i++ {
+		if got := dev.Poll(); got != psErr.OK {
+			t.Errorf("NullDevice.Poll() = %v; want %v", got, psErr.OK)
+		}
+	}
+	if got := dev.Poll(); got != psErr.NoDataToRead {
+		t.Errorf("NullDevice.Poll() = %v; want %v", got, psErr.NoDataToRead)
+	}
+
+	if len(mw.EthRxCh) != 2 {
+		t.Fatalf("len(mw.EthRxCh) = %d; want %d", len(mw.EthRxCh), 2)
+	}
+	if msg := <-mw.EthRxCh; msg.Dev != dev || msg.Type != mw.EtIPV4 {
+		t.Errorf("NullDevice.Poll() delivered unexpected message: %v", msg)
+	}
+	if got := dev.Stats().Injected; got != 2 {
+		t.Errorf("NullDevice.Stats().Injected = %d; want %d", got, 2)
+	}
+}
@@ -0,0 +1,230 @@
+// +build amd64,linux
+
+package eth
+
+import (
+	"errors"
+	"fmt"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/mw"
+	psSyscall "github.com/42milez/ProtocolStack/src/syscall"
+	"syscall"
+	"unsafe"
+)
+
+// packet(7)
+// https://man7.org/linux/man-pages/man7/packet.7.html
+
+type IfreqIndex struct {
+	Name  [syscall.IFNAMSIZ]byte
+	Index int32
+	_     [20]byte // padding to the size of struct ifreq
+}
+
+// PacketSocketDevice exchanges frames through an AF_PACKET socket bound to an existing interface of the host (e.g. an
+// end of a veth pair or a bridge port). Priv().Name is the name of the interface.
+//
+// The interface of the host filters frames by itself, so the promiscuous mode and the multicast addresses of the
+// device are applied to the interface through the memberships of the socket. They are applied again when the device
+// is opened since the memberships are released with the socket.
+type PacketSocketDevice struct {
+	mw.Device
+	epfd    int
+	ifindex int
+}
+
+func (p *PacketSocketDevice) Open() error {
+	var fd int
+	var epfd int
+	var err error
+
+	// receive frames of all protocols
+	proto := htons(syscall.ETH_P_ALL)
+
+	// the socket is non-blocking since all the pending frames are read at once
+	fd, err = psSyscall.Syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW|syscall.SOCK_NONBLOCK, int(proto))
+	if err != nil {
+		return psErr.CantCreateEndpoint
+	}
+
+	ifrIndex := IfreqIndex{}
+	copy(ifrIndex.Name[:], p.Priv().Name)
+	if errno := psSyscall.Syscall.Ioctl(fd, syscall.SIOCGIFINDEX, unsafe.Pointer(&ifrIndex)); errno != 0 {
+		_ = psSyscall.Syscall.Close(fd)
+		return psErr.CantModifyIOResourceParameter
+	}
+
+	sa := &syscall.SockaddrLinklayer{
+		Protocol: proto,
+		Ifindex:  int(ifrIndex.Index),
+	}
+	if err = psSyscall.Syscall.Bind(fd, sa); err != nil {
+		_ = psSyscall.Syscall.Close(fd)
+		return psErr.CantCreateEndpoint
+	}
+
+	//  determine hardware address if the default is equal to any
+	// --------------------------------------------------
+
+	if p.Addr_ == mw.EthAny {
+		ifrSockAddr := IfreqSockAddr{}
+		copy(ifrSockAddr.Name[:], p.Priv().Name)
+		if errno := psSyscall.Syscall.Ioctl(fd, syscall.SIOCGIFHWADDR, unsafe.Pointer(&ifrSockAddr)); errno != 0 {
+			_ = psSyscall.Syscall.Close(fd)
+			return psErr.CantModifyIOResourceParameter
+		}
+		copy(p.Addr_[:], ifrSockAddr.Addr.Data[:])
+	}
+
+	// --------------------------------------------------
+
+	p.ifindex = int(ifrIndex.Index)
+	if err = p.addMemberships(fd); err != psErr.OK {
+		_ = psSyscall.Syscall.Close(fd)
+		return err
+	}
+
+	if epfd, err = createEpoll(fd); err != psErr.OK {
+		_ = psSyscall.Syscall.Close(fd)
+		return err
+	}
+
+	p.Priv_.FD = fd
+	p.epfd = epfd
+
+	return psErr.OK
+}
+
+// addMemberships applies the promiscuous mode and the multicast addresses of the device to the socket.
+func (p *PacketSocketDevice) addMemberships(fd int) error {
+	if p.Flag()&mw.PromiscFlag != 0 {
+		err := p.membership(fd, syscall.PACKET_ADD_MEMBERSHIP, syscall.PACKET_MR_PROMISC, mw.EthAny)
+		if err != psErr.OK {
+			return err
+		}
+	}
+	for _, addr := range mw.MulticastAddrs(p) {
+		err := p.membership(fd, syscall.PACKET_ADD_MEMBERSHIP, syscall.PACKET_MR_MULTICAST, addr)
+		if err != psErr.OK {
+			return err
+		}
+	}
+	return psErr.OK
+}
+
+// membership adds or drops a membership of the socket.
+func (p *PacketSocketDevice) membership(fd int, opt int, typ uint16, addr mw.EthAddr) error {
+	mreq := psSyscall.PacketMreq{
+		Ifindex: int32(p.ifindex),
+		Type:    typ,
+	}
+	if typ == syscall.PACKET_MR_MULTICAST {
+		mreq.Alen = mw.EthAddrLen
+		copy(mreq.Address[:], addr[:])
+	}
+	if err := psSyscall.Syscall.SetsockoptPacketMreq(fd, opt, &mreq); err != nil {
+		psLog.E(fmt.Sprintf("can't change membership: %s", err), "device: "+p.Name_)
+		return psErr.CantModifyIOResourceParameter
+	}
+	return psErr.OK
+}
+
+// SetPromisc switches the promiscuous mode of the interface as well while the device is open.
+func (p *PacketSocketDevice) SetPromisc(on bool) error {
+	return p.CountPromisc(on, func(on bool) error {
+		if p.Priv_.FD < 0 {
+			return psErr.OK
+		}
+		opt := syscall.PACKET_DROP_MEMBERSHIP
+		if on {
+			opt = syscall.PACKET_ADD_MEMBERSHIP
+		}
+		return p.membership(p.Priv_.FD, opt, syscall.PACKET_MR_PROMISC, mw.EthAny)
+	})
+}
+
+// JoinMulticast makes the interface receive the frames sent to the address as well while the device is open.
+func (p *PacketSocketDevice) JoinMulticast(addr mw.EthAddr) error {
+	if p.Priv_.FD >= 0 && !p.joined(addr) {
+		err := p.membership(p.Priv_.FD, syscall.PACKET_ADD_MEMBERSHIP, syscall.PACKET_MR_MULTICAST, addr)
+		if err != psErr.OK {
+			return err
+		}
+	}
+	return p.Device.JoinMulticast(addr)
+}
+
+func (p *PacketSocketDevice) LeaveMulticast(addr mw.EthAddr) error {
+	if err := p.Device.LeaveMulticast(addr); err != psErr.OK {
+		return err
+	}
+	if p.Priv_.FD >= 0 && !p.joined(addr) {
+		return p.membership(p.Priv_.FD, syscall.PACKET_DROP_MEMBERSHIP, syscall.PACKET_MR_MULTICAST, addr)
+	}
+	return psErr.OK
+}
+
+func (p *PacketSocketDevice) joined(addr mw.EthAddr) bool {
+	for _, v := range mw.MulticastAddrs(p) {
+		if v == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *PacketSocketDevice) Close() error {
+	if err := psSyscall.Syscall.Close(p.epfd); err != nil {
+		return psErr.SyscallError
+	}
+	p.epfd = -1
+	if err := psSyscall.Syscall.Close(p.Priv_.FD); err != nil {
+		return psErr.SyscallError
+	}
+	p.Priv_.FD = -1
+	return psErr.OK
+}
+
+func (p *PacketSocketDevice) Poll() error {
+	var events [maxEpollEvents]syscall.EpollEvent
+	nEvents, err := psSyscall.Syscall.EpollWait(p.epfd, events[:], epollTimeout)
+	if err != nil {
+		if !errors.Is(err, syscall.EINTR) {
+			return psErr.SyscallError
+		}
+		return psErr.Interrupted
+	}
+
+	if nEvents == 0 {
+		return psErr.NoDataToRead
+	}
+
+	psLog.D("event occurred",
+		fmt.Sprintf("events: %v", nEvents),
+		fmt.Sprintf("device: %v (%v)", p.Name_, p.Priv_.Name))
+
+	// all the pending frames are read at once so that they don't pile up in the kernel
+	if msgs, err := mw.ReadFrames(p.Priv_.FD, p); err != psErr.OK {
+		if err != psErr.NoDataToRead {
+			return psErr.Error
+		}
+	} else {
+		mw.EthRxBatchCh <- msgs
+	}
+
+	return psErr.OK
+}
+
+func (p *PacketSocketDevice) Transmit(dst mw.EthAddr, payload []byte, typ mw.EthType) error {
+	return mw.WriteFrame(p.Priv_.FD, p, dst, typ, payload)
+}
+
+func (p *PacketSocketDevice) TransmitFrame(frame []byte) error {
+	return mw.WriteRawFrame(p.Priv_.FD, p, frame)
+}
+
+// htons converts a value from host byte order (little endian) to network byte order.
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
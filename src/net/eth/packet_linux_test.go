@@ -0,0 +1,316 @@
+package eth
+
+import (
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/mw"
+	psSyscall "github.com/42milez/ProtocolStack/src/syscall"
+	"github.com/golang/mock/gomock"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+var packetDevEthAddr = mw.EthAddr{11, 12, 13, 14, 15, 16}
+
+func setupPacketLinuxTest(t *testing.T) (ctrl *gomock.Controller, teardown func()) {
+	psLog.DisableOutput()
+	ctrl = gomock.NewController(t)
+	teardown = func() {
+		ctrl.Finish()
+		psLog.EnableOutput()
+	}
+	return
+}
+
+func TestPacketSocketDevice_Open_1(t *testing.T) {
+	ctrl, teardown := setupPacketLinuxTest(t)
+	defer teardown()
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().
+		Socket(syscall.AF_PACKET, syscall.SOCK_RAW|syscall.SOCK_NONBLOCK, int(htons(syscall.ETH_P_ALL))).
+		Return(3, nil)
+	m.EXPECT().Ioctl(3, syscall.SIOCGIFINDEX, any).DoAndReturn(func(fd int, code int, data unsafe.Pointer) syscall.Errno {
+		(*IfreqIndex)(data).Index = 7
+		return ErrnoSuccess
+	})
+	m.EXPECT().Bind(3, &syscall.SockaddrLinklayer{Protocol: htons(syscall.ETH_P_ALL), Ifindex: 7}).Return(nil)
+	m.EXPECT().Ioctl(3, syscall.SIOCGIFHWADDR, any).DoAndReturn(func(fd int, code int, data unsafe.Pointer) syscall.Errno {
+		copy((*IfreqSockAddr)(data).Addr.Data[:], packetDevEthAddr[:])
+		return ErrnoSuccess
+	})
+	m.EXPECT().EpollCreate1(any).Return(4, nil)
+	m.EXPECT().EpollCtl(4, syscall.EPOLL_CTL_ADD, 3, any).Return(nil)
+	psSyscall.Syscall = m
+
+	dev := GenPacketSocketDevice("net0", "veth0", mw.EthAny)
+
+	got := dev.Open()
+	if got != psErr.OK {
+		t.Errorf("PacketSocketDevice.Open() = %v; want %v", got, psErr.OK)
+	}
+	if dev.Addr_ != packetDevEthAddr {
+		t.Errorf("PacketSocketDevice.Addr_ = %s; want %s", dev.Addr_, packetDevEthAddr)
+	}
+	if dev.Priv_.FD != 3 || dev.epfd != 4 {
+		t.Errorf("(fd, epfd) = (%d, %d); want (%d, %d)", dev.Priv_.FD, dev.epfd, 3, 4)
+	}
+}
+
+// Fail when Socket() returns error.
+func TestPacketSocketDevice_Open_2(t *testing.T) {
+	ctrl, teardown := setupPacketLinuxTest(t)
+	defer teardown()
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().Socket(any, any, any).Return(RetValOnFail, ErrorWithNoMessage)
+	psSyscall.Syscall = m
+
+	dev := GenPacketSocketDevice("net0", "veth0", packetDevEthAddr)
+
+	got := dev.Open()
+	if got != psErr.CantCreateEndpoint {
+		t.Errorf("PacketSocketDevice.Open() = %v; want %v", got, psErr.CantCreateEndpoint)
+	}
+}
+
+// Fail when the interface doesn't exist.
+func TestPacketSocketDevice_Open_3(t *testing.T) {
+	ctrl, teardown := setupPacketLinuxTest(t)
+	defer teardown()
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().Socket(any, any, any).Return(3, nil)
+	m.EXPECT().Ioctl(3, syscall.SIOCGIFINDEX, any).Return(syscall.ENODEV)
+	m.EXPECT().Close(3).Return(nil)
+	psSyscall.Syscall = m
+
+	dev := GenPacketSocketDevice("net0", "veth0", packetDevEthAddr)
+
+	got := dev.Open()
+	if got != psErr.CantModifyIOResourceParameter {
+		t.Errorf("PacketSocketDevice.Open() = %v; want %v", got, psErr.CantModifyIOResourceParameter)
+	}
+}
+
+// Fail when Bind() returns error.
+func TestPacketSocketDevice_Open_4(t *testing.T) {
+	ctrl, teardown := setupPacketLinuxTest(t)
+	defer teardown()
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().Socket(any, any, any).Return(3, nil)
+	m.EXPECT().Ioctl(3, syscall.SIOCGIFINDEX, any).Return(ErrnoSuccess)
+	m.EXPECT().Bind(3, any).Return(syscall.EPERM)
+	m.EXPECT().Close(3).Return(nil)
+	psSyscall.Syscall = m
+
+	dev := GenPacketSocketDevice("net0", "veth0", packetDevEthAddr)
+
+	got := dev.Open()
+	if got != psErr.CantCreateEndpoint {
+		t.Errorf("PacketSocketDevice.Open() = %v; want %v", got, psErr.CantCreateEndpoint)
+	}
+}
+
+// Fail when EpollCreate1() returns error.
+func TestPacketSocketDevice_Open_5(t *testing.T) {
+	ctrl, teardown := setupPacketLinuxTest(t)
+	defer teardown()
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().Socket(any, any, any).Return(3, nil)
+	m.EXPECT().Ioctl(3, syscall.SIOCGIFINDEX, any).Return(ErrnoSuccess)
+	m.EXPECT().Bind(3, any).Return(nil)
+	m.EXPECT().EpollCreate1(any).Return(RetValOnFail, ErrorWithNoMessage)
+	m.EXPECT().Close(3).Return(nil)
+	psSyscall.Syscall = m
+
+	dev := GenPacketSocketDevice("net0", "veth0", packetDevEthAddr)
+
+	got := dev.Open()
+	if got != psErr.CantCreateEpollInstance {
+		t.Errorf("PacketSocketDevice.Open() = %v; want %v", got, psErr.CantCreateEpollInstance)
+	}
+}
+
+// Success when the promiscuous mode and the multicast addresses of the device are applied to the new socket.
+func TestPacketSocketDevice_Open_6(t *testing.T) {
+	ctrl, teardown := setupPacketLinuxTest(t)
+	defer teardown()
+
+	group := mw.EthAddr{0x01, 0x00, 0x5e, 0x00, 0x00, 0x01}
+	promisc := &psSyscall.PacketMreq{Ifindex: 7, Type: syscall.PACKET_MR_PROMISC}
+	mcast := &psSyscall.PacketMreq{Ifindex: 7, Type: syscall.PACKET_MR_MULTICAST, Alen: mw.EthAddrLen}
+	copy(mcast.Address[:], group[:])
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().Socket(any, any, any).Return(3, nil)
+	m.EXPECT().Ioctl(3, syscall.SIOCGIFINDEX, any).DoAndReturn(func(fd int, code int, data unsafe.Pointer) syscall.Errno {
+		(*IfreqIndex)(data).Index = 7
+		return ErrnoSuccess
+	})
+	m.EXPECT().Bind(3, any).Return(nil)
+	gomock.InOrder(
+		m.EXPECT().SetsockoptPacketMreq(3, syscall.PACKET_ADD_MEMBERSHIP, promisc).Return(nil),
+		m.EXPECT().SetsockoptPacketMreq(3, syscall.PACKET_ADD_MEMBERSHIP, mcast).Return(nil),
+	)
+	m.EXPECT().EpollCreate1(any).Return(4, nil)
+	m.EXPECT().EpollCtl(4, syscall.EPOLL_CTL_ADD, 3, any).Return(nil)
+	psSyscall.Syscall = m
+
+	dev := GenPacketSocketDevice("net0", "veth0", packetDevEthAddr)
+	_ = dev.SetPromisc(true)
+	defer func() { _ = dev.Device.SetPromisc(false) }()
+	_ = dev.JoinMulticast(group)
+	defer func() { _ = dev.Device.LeaveMulticast(group) }()
+
+	if got := dev.Open(); got != psErr.OK {
+		t.Errorf("PacketSocketDevice.Open() = %v; want %v", got, psErr.OK)
+	}
+}
+
+// Success when the membership is added at the first join and dropped at the last leave.
+func TestPacketSocketDevice_JoinMulticast(t *testing.T) {
+	ctrl, teardown := setupPacketLinuxTest(t)
+	defer teardown()
+
+	group := mw.EthAddr{0x01, 0x00, 0x5e, 0x00, 0x00, 0x01}
+	mreq := &psSyscall.PacketMreq{Ifindex: 7, Type: syscall.PACKET_MR_MULTICAST, Alen: mw.EthAddrLen}
+	copy(mreq.Address[:], group[:])
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	gomock.InOrder(
+		m.EXPECT().SetsockoptPacketMreq(3, syscall.PACKET_ADD_MEMBERSHIP, mreq).Return(nil),
+		m.EXPECT().SetsockoptPacketMreq(3, syscall.PACKET_DROP_MEMBERSHIP, mreq).Return(nil),
+	)
+	psSyscall.Syscall = m
+
+	dev := GenPacketSocketDevice("net0", "veth0", packetDevEthAddr)
+	dev.Priv_.FD = 3
+	dev.ifindex = 7
+
+	for i := 0; i < 2; i++ {
+		if got := dev.JoinMulticast(group); got != psErr.OK {
+			t.Errorf("PacketSocketDevice.JoinMulticast() = %v; want %v", got, psErr.OK)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if got := dev.LeaveMulticast(group); got != psErr.OK {
+			t.Errorf("PacketSocketDevice.LeaveMulticast() = %v; want %v", got, psErr.OK)
+		}
+	}
+}
+
+func TestPacketSocketDevice_Close(t *testing.T) {
+	ctrl, teardown := setupPacketLinuxTest(t)
+	defer teardown()
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().Close(4).Return(nil)
+	m.EXPECT().Close(3).Return(nil)
+	psSyscall.Syscall = m
+
+	dev := GenPacketSocketDevice("net0", "veth0", packetDevEthAddr)
+	dev.Priv_.FD = 3
+	dev.epfd = 4
+
+	got := dev.Close()
+	if got != psErr.OK {
+		t.Errorf("PacketSocketDevice.Close() = %v; want %v", got, psErr.OK)
+	}
+}
+
+// Success when no event occurs.
+func TestPacketSocketDevice_Poll_1(t *testing.T) {
+	ctrl, teardown := setupPacketLinuxTest(t)
+	defer teardown()
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().EpollWait(any, any, 0).Return(0, nil)
+	psSyscall.Syscall = m
+
+	dev := GenPacketSocketDevice("net0", "veth0", packetDevEthAddr)
+
+	got := dev.Poll()
+	if got != psErr.NoDataToRead {
+		t.Errorf("PacketSocketDevice.Poll() = %v; want %v", got, psErr.NoDataToRead)
+	}
+}
+
+// Success when the frames addressed to the device arrive.
+func TestPacketSocketDevice_Poll_2(t *testing.T) {
+	ctrl, teardown := setupPacketLinuxTest(t)
+	defer teardown()
+
+	payload := []byte{0x01, 0x02, 0x03, 0x04}
+	frame, _ := mw.EncodeFrame(packetDevEthAddr, mw.EthAddr{1, 2, 3, 4, 5, 6}, mw.EtIPV4, payload)
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().EpollWait(any, any, any).Return(1, nil)
+	gomock.InOrder(
+		m.EXPECT().Read(3, any).DoAndReturn(func(fd int, p []byte) (int, error) {
+			return copy(p, frame), nil
+		}),
+		m.EXPECT().Read(3, any).DoAndReturn(func(fd int, p []byte) (int, error) {
+			return copy(p, frame), nil
+		}),
+		m.EXPECT().Read(3, any).Return(RetValOnFail, syscall.EAGAIN),
+	)
+	psSyscall.Syscall = m
+
+	dev := GenPacketSocketDevice("net0", "veth0", packetDevEthAddr)
+	dev.Priv_.FD = 3
+
+	got := dev.Poll()
+	if got != psErr.OK {
+		t.Errorf("PacketSocketDevice.Poll() = %v; want %v", got, psErr.OK)
+	}
+	if len(mw.EthRxBatchCh) != 1 {
+		t.Fatalf("len(mw.EthRxBatchCh) = %d; want %d", len(mw.EthRxBatchCh), 1)
+	}
+	msgs := <-mw.EthRxBatchCh
+	if len(msgs) != 2 {
+		t.Fatalf("len(msgs) = %d; want %d", len(msgs), 2)
+	}
+	for _, msg := range msgs {
+		if msg.Dev != dev || msg.Type != mw.EtIPV4 {
+			t.Errorf("PacketSocketDevice.Poll() delivered unexpected message: %v", msg)
+		}
+	}
+}
+
+// Fail when EpollWait() returns EFAULT.
+func TestPacketSocketDevice_Poll_3(t *testing.T) {
+	ctrl, teardown := setupPacketLinuxTest(t)
+	defer teardown()
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().EpollWait(any, any, any).Return(RetValOnFail, syscall.EFAULT)
+	psSyscall.Syscall = m
+
+	dev := GenPacketSocketDevice("net0", "veth0", packetDevEthAddr)
+
+	got := dev.Poll()
+	if got != psErr.SyscallError {
+		t.Errorf("PacketSocketDevice.Poll() = %v; want %v", got, psErr.SyscallError)
+	}
+}
+
+func TestPacketSocketDevice_Transmit(t *testing.T) {
+	ctrl, teardown := setupPacketLinuxTest(t)
+	defer teardown()
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().Write(3, any).Return(0, nil)
+	psSyscall.Syscall = m
+
+	dev := GenPacketSocketDevice("net0", "veth0", packetDevEthAddr)
+	dev.Priv_.FD = 3
+
+	got := dev.Transmit(mw.EthBroadcast, make([]byte, 0), mw.EtARP)
+	if got != psErr.OK {
+		t.Errorf("PacketSocketDevice.Transmit() = %v; want %v", got, psErr.OK)
+	}
+}
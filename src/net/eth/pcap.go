@@ -0,0 +1,127 @@
+package eth
+
+import (
+	"fmt"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/42milez/ProtocolStack/src/pcap"
+	"os"
+	"sync"
+	"time"
+)
+
+// PcapDevice replays frames stored in a pcap/pcapng file and records outgoing frames into another pcap file. The
+// privilege name holds the path of the file to replay.
+type PcapDevice struct {
+	mw.Device
+	TxPath string
+	rxFile *os.File
+	rx     pcap.IReader
+	txFile *os.File
+	tx     *pcap.Writer
+	txMtx  sync.Mutex
+}
+
+func (p *PcapDevice) Open() error {
+	var err error
+
+	if p.rxFile, err = os.Open(p.Priv_.Name); err != nil {
+		return psErr.CantOpenIOResource
+	}
+
+	if p.rx, err = pcap.NewReader(p.rxFile); err != psErr.OK {
+		_ = p.rxFile.Close()
+		return psErr.InvalidFormat
+	}
+
+	if p.TxPath == "" {
+		return psErr.OK
+	}
+
+	if p.txFile, err = os.Create(p.TxPath); err != nil {
+		_ = p.rxFile.Close()
+		return psErr.CantOpenIOResource
+	}
+
+	if p.tx, err = pcap.NewWriter(p.txFile, pcap.LinkTypeEthernet); err != psErr.OK {
+		_ = p.rxFile.Close()
+		_ = p.txFile.Close()
+		return psErr.Error
+	}
+
+	return psErr.OK
+}
+
+func (p *PcapDevice) Close() error {
+	if p.rxFile != nil {
+		if err := p.rxFile.Close(); err != nil {
+			return psErr.CantCloseIOResource
+		}
+	}
+
+	p.txMtx.Lock()
+	defer p.txMtx.Unlock()
+
+	if p.txFile != nil {
+		if err := p.txFile.Close(); err != nil {
+			return psErr.CantCloseIOResource
+		}
+		p.txFile = nil
+		p.tx = nil
+	}
+
+	return psErr.OK
+}
+
+// Poll replays the next ethernet frame in the capture file. Frames which are not addressed to the device are skipped
+// the same way as the TAP device does.
+func (p *PcapDevice) Poll() error {
+	packet, err := p.rx.ReadPacket()
+	if err != psErr.OK {
+		if err == psErr.NoDataToRead {
+			return psErr.NoDataToRead
+		}
+		return psErr.Error
+	}
+
+	if packet.LinkType != pcap.LinkTypeEthernet {
+		psLog.W(fmt.Sprintf("unsupported link type: %s (%d)", packet.LinkType, uint16(packet.LinkType)))
+		return psErr.OK
+	}
+
+	mw.CaptureFrame(p, pcap.DirectionInbound, packet.Data)
+
+	msg, err := mw.ReceiveFrame(packet.Data, p)
+	if err != psErr.OK {
+		if err != psErr.NoDataToRead {
+			return psErr.Error
+		}
+		return psErr.OK
+	}
+	mw.EthRxCh <- msg
+
+	return psErr.OK
+}
+
+func (p *PcapDevice) Transmit(dst mw.EthAddr, payload []byte, typ mw.EthType) error {
+	frame, err := mw.EncodeFrame(dst, p.Addr_, typ, payload)
+	if err != psErr.OK {
+		return psErr.Error
+	}
+	return p.TransmitFrame(frame)
+}
+
+// TransmitFrame records the frame into the file as it is.
+func (p *PcapDevice) TransmitFrame(frame []byte) error {
+	p.txMtx.Lock()
+	defer p.txMtx.Unlock()
+
+	mw.CaptureFrame(p, pcap.DirectionOutbound, frame)
+
+	if p.tx == nil {
+		return psErr.OK
+	}
+
+	return p.tx.WritePacket(time.Now(), frame)
+}
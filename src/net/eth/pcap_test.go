@@ -0,0 +1,130 @@
+package eth
+
+import (
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/42milez/ProtocolStack/src/pcap"
+	"github.com/google/go-cmp/cmp"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var pcapDevEthAddr = mw.EthAddr{11, 12, 13, 14, 15, 16}
+
+func setupPcapTest(t *testing.T) (dir string, teardown func()) {
+	psLog.DisableOutput()
+	dir, err := ioutil.TempDir("", "pcap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	teardown = func() {
+		_ = os.RemoveAll(dir)
+		psLog.EnableOutput()
+	}
+	return
+}
+
+func writePcapFile(t *testing.T, path string, frames ...[]byte) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w, _ := pcap.NewWriter(f, pcap.LinkTypeEthernet)
+	for _, frame := range frames {
+		_ = w.WritePacket(time.Now(), frame)
+	}
+}
+
+// Fail when the file to replay doesn't exist.
+func TestPcapDevice_Open(t *testing.T) {
+	dir, teardown := setupPcapTest(t)
+	defer teardown()
+
+	dev := GenPcapDevice("net0", filepath.Join(dir, "rx.pcap"), "", pcapDevEthAddr)
+
+	got := dev.Open()
+	if got != psErr.CantOpenIOResource {
+		t.Errorf("PcapDevice.Open() = %v; want %v", got, psErr.CantOpenIOResource)
+	}
+}
+
+func TestPcapDevice_Poll(t *testing.T) {
+	dir, teardown := setupPcapTest(t)
+	defer teardown()
+
+	payload := []byte{0x01, 0x02, 0x03, 0x04}
+	toDev, _ := mw.EncodeFrame(pcapDevEthAddr, mw.EthAddr{1, 2, 3, 4, 5, 6}, mw.EtIPV4, payload)
+	toOther, _ := mw.EncodeFrame(mw.EthAddr{1, 2, 3, 4, 5, 6}, mw.EthAddr{1, 2, 3, 4, 5, 6}, mw.EtIPV4, payload)
+	rxPath := filepath.Join(dir, "rx.pcap")
+	writePcapFile(t, rxPath, toOther, toDev)
+
+	dev := GenPcapDevice("net0", rxPath, "", pcapDevEthAddr)
+	if err := dev.Open(); err != psErr.OK {
+		t.Fatalf("PcapDevice.Open() = %v; want %v", err, psErr.OK)
+	}
+	defer dev.Close()
+
+	// the first frame is not addressed to the device
+	for i := 0; i < 2; i++ {
+		if got := dev.Poll(); got != psErr.OK {
+			t.Errorf("PcapDevice.Poll() = %v; want %v", got, psErr.OK)
+		}
+	}
+	if got := dev.Poll(); got != psErr.NoDataToRead {
+		t.Errorf("PcapDevice.Poll() = %v; want %v", got, psErr.NoDataToRead)
+	}
+
+	if len(mw.EthRxCh) != 1 {
+		t.Fatalf("len(mw.EthRxCh) = %d; want %d", len(mw.EthRxCh), 1)
+	}
+	msg := <-mw.EthRxCh
+	if msg.Dev != dev {
+		t.Errorf("EthMessage.Dev = %v; want %v", msg.Dev, dev)
+	}
+	if d := cmp.Diff(msg.Content[:len(payload)], payload); d != "" {
+		t.Errorf("EthMessage.Content differs: (-got +want)\n%s", d)
+	}
+}
+
+func TestPcapDevice_Transmit(t *testing.T) {
+	dir, teardown := setupPcapTest(t)
+	defer teardown()
+
+	rxPath := filepath.Join(dir, "rx.pcap")
+	txPath := filepath.Join(dir, "tx.pcap")
+	writePcapFile(t, rxPath)
+
+	dev := GenPcapDevice("net0", rxPath, txPath, pcapDevEthAddr)
+	if err := dev.Open(); err != psErr.OK {
+		t.Fatalf("PcapDevice.Open() = %v; want %v", err, psErr.OK)
+	}
+
+	dst := mw.EthAddr{1, 2, 3, 4, 5, 6}
+	payload := []byte{0x01, 0x02, 0x03, 0x04}
+	if got := dev.Transmit(dst, payload, mw.EtIPV4); got != psErr.OK {
+		t.Errorf("PcapDevice.Transmit() = %v; want %v", got, psErr.OK)
+	}
+	if got := dev.Close(); got != psErr.OK {
+		t.Errorf("PcapDevice.Close() = %v; want %v", got, psErr.OK)
+	}
+
+	f, _ := os.Open(txPath)
+	defer f.Close()
+	r, err := pcap.NewReader(f)
+	if err != psErr.OK {
+		t.Fatalf("pcap.NewReader() = %v; want %v", err, psErr.OK)
+	}
+	packet, err := r.ReadPacket()
+	if err != psErr.OK {
+		t.Fatalf("ReadPacket() = %v; want %v", err, psErr.OK)
+	}
+	want, _ := mw.EncodeFrame(dst, pcapDevEthAddr, mw.EtIPV4, payload)
+	if d := cmp.Diff(packet.Data, want); d != "" {
+		t.Errorf("written frame differs: (-got +want)\n%s", d)
+	}
+}
@@ -0,0 +1,199 @@
+package eth
+
+import (
+	"github.com/42milez/ProtocolStack/src/mw"
+	"time"
+)
+
+// TxQueueLen is the default number of frames which a queueing discipline holds (txqueuelen of Linux).
+const TxQueueLen = 1000
+
+// PrioBands is the number of the bands of PrioQdisc. The frames of band 0 are sent first.
+const PrioBands = 3
+
+// IP type of service
+// https://datatracker.ietf.org/doc/html/rfc1349
+const (
+	tosLowDelay      = 0x10
+	tosThroughput    = 0x08
+	tosReliability   = 0x04
+	tosMinCost       = 0x02
+	tosPrecedenceMin = 0xa0 // CRITIC/ECP and the higher precedences are sent as low delay
+)
+
+// msgQueue is a FIFO queue of frames.
+type msgQueue struct {
+	msgs []*mw.EthMessage
+}
+
+func (p *msgQueue) push(msg *mw.EthMessage) {
+	p.msgs = append(p.msgs, msg)
+}
+
+func (p *msgQueue) peek() *mw.EthMessage {
+	if len(p.msgs) == 0 {
+		return nil
+	}
+	return p.msgs[0]
+}
+
+func (p *msgQueue) pop() *mw.EthMessage {
+	msg := p.msgs[0]
+	p.msgs[0] = nil
+	p.msgs = p.msgs[1:]
+	return msg
+}
+
+// FifoQdisc sends frames in the order they are queued. Frames are dropped when Limit frames are waiting.
+type FifoQdisc struct {
+	Limit int
+	q     msgQueue
+}
+
+func (p *FifoQdisc) Enqueue(msg *mw.EthMessage) bool {
+	if len(p.q.msgs) >= p.Limit {
+		return false
+	}
+	p.q.push(msg)
+	return true
+}
+
+func (p *FifoQdisc) Dequeue(_ time.Time) (*mw.EthMessage, time.Duration) {
+	if len(p.q.msgs) == 0 {
+		return nil, 0
+	}
+	return p.q.pop(), 0
+}
+
+func (p *FifoQdisc) Len() int {
+	return len(p.q.msgs)
+}
+
+// TbfQdisc limits the rate of frames with a token bucket. The bucket gets Rate bytes per second up to Burst bytes, and
+// a frame is sent when the bucket has as many bytes as the frame. Frames larger than Burst are dropped since they
+// can't be sent at all, and so are all the frames while Rate or Burst isn't positive.
+type TbfQdisc struct {
+	Rate   int // bytes per second
+	Burst  int // bytes
+	Limit  int // frames
+	q      msgQueue
+	tokens int64 // bytes scaled by time.Second, so that a fraction of a byte isn't lost
+	last   time.Time
+}
+
+func (p *TbfQdisc) Enqueue(msg *mw.EthMessage) bool {
+	if !p.valid() || len(p.q.msgs) >= p.Limit || frameLen(msg) > p.Burst {
+		return false
+	}
+	p.q.push(msg)
+	return true
+}
+
+func (p *TbfQdisc) Dequeue(now time.Time) (*mw.EthMessage, time.Duration) {
+	if !p.valid() {
+		return nil, 0
+	}
+	p.fill(now)
+
+	msg := p.q.peek()
+	if msg == nil {
+		return nil, 0
+	}
+	need := int64(frameLen(msg)) * int64(time.Second)
+	if p.tokens < need {
+		// the time until the bucket gets the bytes which are short, rounded up
+		rate := int64(p.Rate)
+		return nil, time.Duration((need - p.tokens + rate - 1) / rate)
+	}
+	p.tokens -= need
+
+	return p.q.pop(), 0
+}
+
+func (p *TbfQdisc) Len() int {
+	return len(p.q.msgs)
+}
+
+// valid reports whether the bucket can get any bytes.
+func (p *TbfQdisc) valid() bool {
+	return p.Rate > 0 && p.Burst > 0
+}
+
+// fill adds the tokens which the bucket got since the last time. The bucket is full at first.
+func (p *TbfQdisc) fill(now time.Time) {
+	full := int64(p.Burst) * int64(time.Second)
+	if p.last.IsZero() {
+		p.tokens = full
+	} else if elapsed := now.Sub(p.last); elapsed > 0 {
+		// the elapsed time is capped so that the product doesn't overflow
+		if elapsed > time.Duration(full/int64(p.Rate)) {
+			p.tokens = full
+		} else {
+			p.tokens += int64(elapsed) * int64(p.Rate)
+		}
+	}
+	if p.tokens > full {
+		p.tokens = full
+	}
+	p.last = now
+}
+
+// PrioQdisc sends frames by the priority which the type of service of their IP header indicates. Frames of a band
+// aren't sent while the bands of higher priority have frames. Each band holds up to Limit frames. Band 0 holds the
+// frames which request low delay or have precedence of CRITIC/ECP or higher, band 2 holds the ones which request high
+// throughput, high reliability or low cost, and band 1 holds the rest including the frames which don't carry IP.
+type PrioQdisc struct {
+	Limit int
+	bands [PrioBands]msgQueue
+}
+
+func (p *PrioQdisc) Enqueue(msg *mw.EthMessage) bool {
+	band := &p.bands[prioBand(msg)]
+	if len(band.msgs) >= p.Limit {
+		return false
+	}
+	band.push(msg)
+	return true
+}
+
+func (p *PrioQdisc) Dequeue(_ time.Time) (*mw.EthMessage, time.Duration) {
+	for i := range p.bands {
+		if len(p.bands[i].msgs) != 0 {
+			return p.bands[i].pop(), 0
+		}
+	}
+	return nil, 0
+}
+
+func (p *PrioQdisc) Len() int {
+	n := 0
+	for i := range p.bands {
+		n += len(p.bands[i].msgs)
+	}
+	return n
+}
+
+// prioBand returns the band of PrioQdisc which the frame is queued in.
+func prioBand(msg *mw.EthMessage) int {
+	if msg.Type != mw.EtIPV4 || len(msg.Content) < 2 {
+		return 1
+	}
+	tos := msg.Content[1]
+	switch {
+	case tos&tosLowDelay != 0 || tos >= tosPrecedenceMin:
+		return 0
+	case tos&(tosThroughput|tosReliability|tosMinCost) != 0:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// frameLen returns the length of the frame which carries the message.
+func frameLen(msg *mw.EthMessage) int {
+	n := mw.EthHdrLen + len(msg.Content)
+	if n < mw.EthFrameLenMin {
+		return mw.EthFrameLenMin
+	}
+	return n
+}
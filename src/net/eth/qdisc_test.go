@@ -0,0 +1,109 @@
+package eth
+
+import (
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/mw"
+	"testing"
+	"time"
+)
+
+// Success when frames are sent in the order they are queued and dropped when the queue is full.
+func TestFifoQdisc_1(t *testing.T) {
+	qdisc := &FifoQdisc{Limit: 2}
+	msgs := []*mw.EthMessage{{}, {}, {}}
+	for i, msg := range msgs {
+		if got := qdisc.Enqueue(msg); got != (i < 2) {
+			t.Errorf("FifoQdisc.Enqueue() = %t; want %t", got, i < 2)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if got, _ := qdisc.Dequeue(time.Now()); got != msgs[i] {
+			t.Errorf("FifoQdisc.Dequeue() = %p; want %p", got, msgs[i])
+		}
+	}
+	if got, wait := qdisc.Dequeue(time.Now()); got != nil || wait != 0 {
+		t.Errorf("FifoQdisc.Dequeue() = (%p, %s); want (nil, 0s)", got, wait)
+	}
+}
+
+// Success when a frame waits for the bucket to get as many bytes as the frame.
+func TestTbfQdisc_1(t *testing.T) {
+	qdisc := GenTbfQdisc(1000, 100)
+	now := time.Unix(0, 0)
+	msg := &mw.EthMessage{Content: make([]byte, 86)}
+
+	for i := 0; i < 2; i++ {
+		if !qdisc.Enqueue(msg) {
+			t.Fatalf("TbfQdisc.Enqueue() = false; want true")
+		}
+	}
+	// the bucket is full at first
+	if got, _ := qdisc.Dequeue(now); got != msg {
+		t.Errorf("TbfQdisc.Dequeue() = %p; want %p", got, msg)
+	}
+	// the bucket is empty, so that it takes 100ms to get the 100 bytes of the frame
+	if got, wait := qdisc.Dequeue(now); got != nil || wait != 100*time.Millisecond {
+		t.Errorf("TbfQdisc.Dequeue() = (%p, %s); want (nil, %s)", got, wait, 100*time.Millisecond)
+	}
+	if got, _ := qdisc.Dequeue(now.Add(100 * time.Millisecond)); got != msg {
+		t.Errorf("TbfQdisc.Dequeue() = %p; want %p", got, msg)
+	}
+}
+
+// Fail when the frame is larger than the bucket.
+func TestTbfQdisc_2(t *testing.T) {
+	qdisc := GenTbfQdisc(1000, 100)
+	if qdisc.Enqueue(&mw.EthMessage{Content: make([]byte, 87)}) {
+		t.Errorf("TbfQdisc.Enqueue() = true; want false")
+	}
+}
+
+// Fail when the rate or the burst isn't positive.
+func TestTbfQdisc_3(t *testing.T) {
+	psLog.DisableOutput()
+	defer psLog.EnableOutput()
+
+	for _, v := range [][2]int{{0, 100}, {-1, 100}, {1000, 0}, {1000, -1}} {
+		if got := GenTbfQdisc(v[0], v[1]); got != nil {
+			t.Errorf("GenTbfQdisc(%d, %d) = %p; want nil", v[0], v[1], got)
+		}
+	}
+
+	msg := &mw.EthMessage{Content: make([]byte, 86)}
+	qdisc := &TbfQdisc{Rate: 0, Burst: 100, Limit: TxQueueLen}
+	if qdisc.Enqueue(msg) {
+		t.Errorf("TbfQdisc.Enqueue() = true; want false")
+	}
+	// the frame which was queued before the rate was changed isn't sent
+	qdisc = GenTbfQdisc(1000, 100)
+	qdisc.Enqueue(msg)
+	qdisc.Rate = 0
+	if got, wait := qdisc.Dequeue(time.Now()); got != nil || wait != 0 {
+		t.Errorf("TbfQdisc.Dequeue() = (%p, %s); want (nil, 0s)", got, wait)
+	}
+}
+
+// Success when frames are sent by the priority of their type of service.
+func TestPrioQdisc_1(t *testing.T) {
+	qdisc := GenPrioQdisc()
+	packet := func(tos uint8) *mw.EthMessage {
+		return &mw.EthMessage{Type: mw.EtIPV4, Content: []byte{0x45, tos}}
+	}
+	bulk := packet(tosThroughput)
+	normal := packet(0)
+	arp := &mw.EthMessage{Type: mw.EtARP}
+	interactive := packet(tosLowDelay)
+	control := packet(0xc0)
+
+	for _, msg := range []*mw.EthMessage{bulk, normal, arp, interactive, control} {
+		qdisc.Enqueue(msg)
+	}
+	if got := qdisc.Len(); got != 5 {
+		t.Errorf("PrioQdisc.Len() = %d; want %d", got, 5)
+	}
+	for _, want := range []*mw.EthMessage{interactive, control, normal, arp, bulk} {
+		if got, _ := qdisc.Dequeue(time.Now()); got != want {
+			t.Errorf("PrioQdisc.Dequeue() = %v; want %v", got, want)
+		}
+	}
+}
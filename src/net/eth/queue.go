@@ -0,0 +1,45 @@
+package eth
+
+import "sync"
+
+const frameQueueSize = 1000
+
+// frameQueue is a bounded FIFO queue of frames used by the devices which are backed by memory.
+type frameQueue struct {
+	frames [][]byte
+	mtx    sync.Mutex
+}
+
+// push appends a frame to the queue. It returns false when the queue is full.
+func (p *frameQueue) push(frame []byte) bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if len(p.frames) >= frameQueueSize {
+		return false
+	}
+	p.frames = append(p.frames, frame)
+
+	return true
+}
+
+// pop removes the oldest frame from the queue. It returns nil when the queue is empty.
+func (p *frameQueue) pop() []byte {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if len(p.frames) == 0 {
+		return nil
+	}
+	frame := p.frames[0]
+	p.frames[0] = nil
+	p.frames = p.frames[1:]
+
+	return frame
+}
+
+func (p *frameQueue) reset() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.frames = nil
+}
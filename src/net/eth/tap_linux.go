@@ -8,18 +8,36 @@ import (
 	psErr "github.com/42milez/ProtocolStack/src/error"
 	psLog "github.com/42milez/ProtocolStack/src/log"
 	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/42milez/ProtocolStack/src/pcap"
 	psSyscall "github.com/42milez/ProtocolStack/src/syscall"
+	"sync"
 	"syscall"
 	"unsafe"
 )
 
 var HwAddr = mw.EthAddr{0x00, 0x00, 0x5e, 0x00, 0x53, 0x01}
 
-const epollTimeout = 1000
+// Poll() doesn't block since the reactor of the repo waits for frames to arrive.
+const epollTimeout = 0
 const maxEpollEvents = 32
 const virtualNetworkDevice = "/dev/net/tun"
 
-var epfd int
+// TapQueueMax is the maximum number of queues of a TAP interface (MAX_TAP_QUEUES of Linux).
+const TapQueueMax = 256
+
+// an 8-byte integer in host byte order which is added to the counter of the eventfd
+var evfdIncrement = []byte{1, 0, 0, 0, 0, 0, 0, 0}
+
+// iffMultiQueue is IFF_MULTI_QUEUE of if_tun.h, which the syscall package doesn't define.
+const iffMultiQueue = 0x0100
+
+// TUNSETOFFLOAD and its flags @ if_tun.h
+// https://github.com/torvalds/linux/blob/master/include/uapi/linux/if_tun.h
+const tunSetOffload = 0x400454d0
+const (
+	tunFCsum = 0x01
+	tunFTso4 = 0x02
+)
 
 // src/syscall/zerrors_linux_amd64.go
 // https://golang.org/src/syscall/zerrors_linux_amd64.go
@@ -36,93 +54,294 @@ var epfd int
 type IfreqFlags struct {
 	Name  [syscall.IFNAMSIZ]byte
 	Flags uint16
+	_     [22]byte // padding to the size of struct ifreq
 }
 
 type IfreqSockAddr struct {
 	Name [syscall.IFNAMSIZ]byte
 	Addr struct {
-		Data   [14]byte
 		Family uint16
+		Data   [14]byte
 	}
+	_ [8]byte // padding to the size of struct ifreq
 }
 
+// TapDevice exchanges frames with the kernel through a TAP interface. Each device owns an epoll instance so that any
+// number of devices can be opened at once.
+//
+// When Queues is more than 1, the interface is opened with IFF_MULTI_QUEUE and a receive worker per queue reads
+// frames and processes them through the eth/ip pipeline by itself, instead of the reactor. The kernel steers the
+// frames of a flow to the same queue, so frames of a flow are processed in the order they arrived.
+//
+// When VnetHdr is true, the interface is opened with IFF_VNET_HDR and frames are exchanged with virtio-net headers.
+// The kernel sends frames with partial checksums and large TCP segments (GSO) to the device, and completes the
+// checksums of TCP segments which the device sends and splits the ones larger than the MTU. The device reports the
+// offloads through Offload() so that the IP and TCP layers skip their checksums.
+//
+// When Backend is IoUringBackend, frames are read and written through io_uring instead of read(2) and write(2). The
+// reactor watches the ring, and the reads which complete at a time are processed and handed to the kernel again with a
+// single system call. It can't be used with multiple queues.
 type TapDevice struct {
 	mw.Device
+	Queues  int        // number of queues (a single queue is used when it's 0 or 1)
+	VnetHdr bool       // offload checksums and segmentation to the kernel
+	Backend TapBackend // how frames are read and written
+	epfd    int
+	queues  []*tapQueue
+	evfd    int // eventfd which stops the receive workers
+	wg      sync.WaitGroup
+	uring   *tapUring
+}
+
+// tapQueue is a queue of a multi-queue TAP interface.
+type tapQueue struct {
+	fd   int
+	epfd int
 }
 
 func (p *TapDevice) Open() error {
+	if p.Backend == IoUringBackend {
+		return p.openUring()
+	}
+	if p.Queues > 1 {
+		return p.openQueues()
+	}
+
 	var fd int
+	var epfd int
 	var err error
 
-	fd, err = psSyscall.Syscall.Open(virtualNetworkDevice, syscall.O_RDWR, 0666)
+	if fd, err = p.openTap(syscall.IFF_TAP, syscall.O_NONBLOCK); err != psErr.OK {
+		return err
+	}
+
+	if err = p.resolveAddr(); err != psErr.OK {
+		_ = psSyscall.Syscall.Close(fd)
+		return err
+	}
+
+	if epfd, err = createEpoll(fd); err != psErr.OK {
+		_ = psSyscall.Syscall.Close(fd)
+		return err
+	}
+
+	p.Priv_.FD = fd
+	p.epfd = epfd
+
+	return psErr.OK
+}
+
+// openQueues opens the queues and starts a receive worker per queue. The device doesn't have a file descriptor to be
+// watched by the reactor since the workers read the queues.
+func (p *TapDevice) openQueues() error {
+	if p.Queues > TapQueueMax {
+		psLog.E(fmt.Sprintf("too many queues: %d", p.Queues), "device: "+p.Name_)
+		return psErr.Error
+	}
+
+	evfd, err := psSyscall.Syscall.Eventfd(0, 0)
 	if err != nil {
 		return psErr.CantOpenIOResource
 	}
+	p.evfd = evfd
 
-	ifrFlags := IfreqFlags{}
-	ifrFlags.Flags = syscall.IFF_TAP | syscall.IFF_NO_PI
-	copy(ifrFlags.Name[:], p.Priv().Name)
-	if errno := psSyscall.Syscall.Ioctl(fd, syscall.TUNSETIFF, unsafe.Pointer(&ifrFlags)); errno != 0 {
+	for i := 0; i < p.Queues; i++ {
+		q, err := p.openQueue()
+		if err != psErr.OK {
+			p.closeQueues()
+			return err
+		}
+		p.queues = append(p.queues, q)
+	}
+
+	if err := p.resolveAddr(); err != psErr.OK {
+		p.closeQueues()
+		return err
+	}
+
+	for _, q := range p.queues {
+		p.wg.Add(1)
+		go p.runQueue(q)
+	}
+
+	return psErr.OK
+}
+
+func (p *TapDevice) openQueue() (*tapQueue, error) {
+	fd, err := p.openTap(syscall.IFF_TAP|iffMultiQueue, syscall.O_NONBLOCK)
+	if err != psErr.OK {
+		return nil, err
+	}
+
+	epfd, err := createEpoll(fd)
+	if err != psErr.OK {
 		_ = psSyscall.Syscall.Close(fd)
-		return psErr.CantModifyIOResourceParameter
+		return nil, err
+	}
+
+	// the eventfd wakes the worker up to stop it
+	var event syscall.EpollEvent
+	event.Events = syscall.EPOLLIN
+	event.Fd = int32(p.evfd)
+	if err := psSyscall.Syscall.EpollCtl(epfd, syscall.EPOLL_CTL_ADD, p.evfd, &event); err != nil {
+		_ = psSyscall.Syscall.Close(epfd)
+		_ = psSyscall.Syscall.Close(fd)
+		return nil, psErr.CantModifyIOResourceParameter
 	}
 
-	//  determine hardware address if the default is equal to any
-	// --------------------------------------------------
+	return &tapQueue{
+		fd:   fd,
+		epfd: epfd,
+	}, psErr.OK
+}
 
-	if p.Addr_ == mw.EthAny {
-		var soc int
-		soc, err = psSyscall.Syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
-		if err != nil {
-			_ = psSyscall.Syscall.Close(fd)
-			return psErr.CantCreateEndpoint
+// closeQueues stops the receive workers and closes the queues.
+func (p *TapDevice) closeQueues() error {
+	ret := psErr.OK
+
+	if _, err := psSyscall.Syscall.Write(p.evfd, evfdIncrement); err != nil {
+		ret = psErr.SyscallError
+	}
+	p.wg.Wait()
+
+	for _, q := range p.queues {
+		if err := psSyscall.Syscall.Close(q.epfd); err != nil {
+			ret = psErr.SyscallError
 		}
+		if err := psSyscall.Syscall.Close(q.fd); err != nil {
+			ret = psErr.SyscallError
+		}
+	}
+	p.queues = nil
 
-		ifrSockAddr := IfreqSockAddr{}
-		ifrSockAddr.Addr.Family = syscall.AF_INET
-		copy(ifrSockAddr.Name[:], p.Priv().Name)
+	if err := psSyscall.Syscall.Close(p.evfd); err != nil {
+		ret = psErr.SyscallError
+	}
+	p.evfd = -1
+
+	return ret
+}
+
+// runQueue is the receive worker of the queue. It reads all the pending frames at a time and processes them in the
+// order they arrived.
+func (p *TapDevice) runQueue(q *tapQueue) {
+	defer p.wg.Done()
+
+	var events [maxEpollEvents]syscall.EpollEvent
+	for {
+		nEvents, err := psSyscall.Syscall.EpollWait(q.epfd, events[:], -1)
+		if err != nil {
+			if errors.Is(err, syscall.EINTR) {
+				continue
+			}
+			psLog.E(fmt.Sprintf("can't wait for frames: %s", err), "device: "+p.Name_)
+			return
+		}
+		for i := 0; i < nEvents; i++ {
+			if events[i].Fd == int32(p.evfd) {
+				return
+			}
+		}
 
-		if errno := psSyscall.Syscall.Ioctl(soc, syscall.SIOCGIFHWADDR, unsafe.Pointer(&ifrSockAddr)); errno != 0 {
-			_ = psSyscall.Syscall.Close(soc)
-			return psErr.CantModifyIOResourceParameter
+		msgs, e := p.readFrames(q.fd)
+		if e != psErr.OK {
+			if e != psErr.NoDataToRead {
+				psLog.E(fmt.Sprintf("can't read frame: %s", e), "device: "+p.Name_)
+				return
+			}
+			continue
 		}
-		copy(p.Addr_[:], ifrSockAddr.Addr.Data[:])
-		if err = psSyscall.Syscall.Close(soc); err != nil {
-			return psErr.CantCloseIOResource
+		if !p.IsUp() {
+			continue
+		}
+		for _, msg := range msgs {
+			receiveInline(msg)
 		}
 	}
+}
 
-	// --------------------------------------------------
+// openTap attaches the interface to a descriptor. The offloads are enabled when the device uses virtio-net headers.
+func (p *TapDevice) openTap(flags uint16, mode int) (int, error) {
+	if !p.VnetHdr {
+		return openTunTap(p.Priv().Name, flags, mode)
+	}
 
-	epfd, err = psSyscall.Syscall.EpollCreate1(0)
+	fd, err := openTunTap(p.Priv().Name, flags|syscall.IFF_VNET_HDR, mode)
+	if err != psErr.OK {
+		return -1, err
+	}
+	if errno := psSyscall.Syscall.IoctlInt(fd, tunSetOffload, tunFCsum|tunFTso4); errno != 0 {
+		psLog.E(fmt.Sprintf("can't enable offloads: %s", errno), "device: "+p.Name_)
+		_ = psSyscall.Syscall.Close(fd)
+		return -1, psErr.CantModifyIOResourceParameter
+	}
+
+	return fd, psErr.OK
+}
+
+func (p *TapDevice) readFrames(fd int) ([]*mw.EthMessage, error) {
+	if p.VnetHdr {
+		return mw.ReadVnetFrames(fd, p)
+	}
+	return mw.ReadFrames(fd, p)
+}
+
+// resolveAddr determines the hardware address from the interface if the default is equal to any.
+func (p *TapDevice) resolveAddr() error {
+	if p.Addr_ != mw.EthAny {
+		return psErr.OK
+	}
+
+	soc, err := psSyscall.Syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
 	if err != nil {
-		return psErr.CantCreateEpollInstance
+		return psErr.CantCreateEndpoint
 	}
 
-	var event syscall.EpollEvent
-	event.Events = syscall.EPOLLIN
-	event.Fd = int32(fd)
+	ifrSockAddr := IfreqSockAddr{}
+	ifrSockAddr.Addr.Family = syscall.AF_INET
+	copy(ifrSockAddr.Name[:], p.Priv().Name)
 
-	if err := psSyscall.Syscall.EpollCtl(epfd, syscall.EPOLL_CTL_ADD, fd, &event); err != nil {
-		_ = psSyscall.Syscall.Close(epfd)
+	if errno := psSyscall.Syscall.Ioctl(soc, syscall.SIOCGIFHWADDR, unsafe.Pointer(&ifrSockAddr)); errno != 0 {
+		_ = psSyscall.Syscall.Close(soc)
 		return psErr.CantModifyIOResourceParameter
 	}
-
-	p.Priv_.FD = fd
+	copy(p.Addr_[:], ifrSockAddr.Addr.Data[:])
+	if err = psSyscall.Syscall.Close(soc); err != nil {
+		return psErr.CantCloseIOResource
+	}
 
 	return psErr.OK
 }
 
 func (p *TapDevice) Close() error {
-	if err := psSyscall.Syscall.Close(epfd); err != nil {
+	if p.uring != nil {
+		return p.closeUring()
+	}
+	if p.queues != nil {
+		return p.closeQueues()
+	}
+	if err := psSyscall.Syscall.Close(p.epfd); err != nil {
 		return psErr.SyscallError
 	}
+	p.epfd = -1
+	if err := psSyscall.Syscall.Close(p.Priv_.FD); err != nil {
+		return psErr.SyscallError
+	}
+	p.Priv_.FD = -1
 	return psErr.OK
 }
 
+// Poll reads the pending frames. The frames of a multi-queue device are read by its receive workers instead.
 func (p *TapDevice) Poll() error {
+	if p.queues != nil {
+		return psErr.NoDataToRead
+	}
+	if p.uring != nil {
+		return p.pollUring()
+	}
+
 	var events [maxEpollEvents]syscall.EpollEvent
-	nEvents, err := psSyscall.Syscall.EpollWait(epfd, events[:], epollTimeout)
+	nEvents, err := psSyscall.Syscall.EpollWait(p.epfd, events[:], epollTimeout)
 	if err != nil {
 		// https://man7.org/linux/man-pages/man2/epoll_wait.2.html#RETURN_VALUE
 		// ignore EINTR
@@ -132,23 +351,125 @@ func (p *TapDevice) Poll() error {
 		return psErr.Interrupted
 	}
 
-	if nEvents > 0 {
-		psLog.D("event occurred",
-			fmt.Sprintf("events: %v", nEvents),
-			fmt.Sprintf("device: %v (%v)", p.Name_, p.Priv_.Name))
-		if msg, err := mw.ReadFrame(p.Priv_.FD, p.Addr_); err != psErr.OK {
-			if err != psErr.NoDataToRead {
-				return psErr.Error
-			}
-		} else {
-			msg.Dev = p
-			mw.EthRxCh <- msg
+	if nEvents == 0 {
+		return psErr.NoDataToRead
+	}
+
+	psLog.D("event occurred",
+		fmt.Sprintf("events: %v", nEvents),
+		fmt.Sprintf("device: %v (%v)", p.Name_, p.Priv_.Name))
+
+	// all the pending frames are read at once so that they don't pile up in the kernel
+	if msgs, err := p.readFrames(p.Priv_.FD); err != psErr.OK {
+		if err != psErr.NoDataToRead {
+			return psErr.Error
 		}
+	} else {
+		mw.EthRxBatchCh <- msgs
 	}
 
 	return psErr.OK
 }
 
+// Transmit sends the payload built by the stack. When the device uses virtio-net headers, the checksums of the payload
+// are completed by the device and the kernel.
 func (p *TapDevice) Transmit(dst mw.EthAddr, payload []byte, typ mw.EthType) error {
-	return mw.WriteFrame(p.Priv_.FD, dst, p.Addr_, typ, payload)
+	if !p.VnetHdr && p.uring == nil {
+		return mw.WriteFrame(p.txFD(), p, dst, typ, payload)
+	}
+
+	frame, err := mw.EncodeFrame(dst, p.Addr_, typ, payload)
+	if err != psErr.OK {
+		return psErr.Error
+	}
+	hdr := mw.VnetHdr{}
+	if p.VnetHdr {
+		hdr = mw.NewVnetHdr(frame, p.MTU_)
+	}
+	return p.write(hdr, frame)
+}
+
+// TransmitFrame sends the frame as it is. Its checksums have to be complete.
+func (p *TapDevice) TransmitFrame(frame []byte) error {
+	return p.write(mw.VnetHdr{}, frame)
+}
+
+// write writes the frame through the backend. The header precedes the frame when the device uses virtio-net headers.
+func (p *TapDevice) write(hdr mw.VnetHdr, frame []byte) error {
+	if p.uring == nil {
+		if p.VnetHdr {
+			return mw.WriteVnetFrame(p.txFD(), p, hdr, frame)
+		}
+		return mw.WriteRawFrame(p.txFD(), p, frame)
+	}
+
+	// the data is kept until the write completes, so it's copied from the frame which the caller owns
+	var b []byte
+	if p.VnetHdr {
+		b = hdr.Prepend(frame)
+	} else {
+		b = make([]byte, len(frame))
+		copy(b, frame)
+	}
+	if err := p.writeUring(b); err != psErr.OK {
+		return err
+	}
+	mw.CaptureFrame(p, pcap.DirectionOutbound, frame)
+
+	return psErr.OK
+}
+
+// Offload returns the checksum and segmentation offloads when the device uses virtio-net headers.
+func (p *TapDevice) Offload() mw.Offload {
+	if !p.VnetHdr {
+		return 0
+	}
+	return mw.RxCsumOffload | mw.TxCsumOffload | mw.TsoOffload
+}
+
+// txFD returns the descriptor which frames are written to. Frames of a multi-queue device are written to the first
+// queue.
+func (p *TapDevice) txFD() int {
+	if p.queues != nil {
+		return p.queues[0].fd
+	}
+	return p.Priv_.FD
+}
+
+// openTunTap attaches a TUN/TAP interface to a descriptor of the clone device, which is opened with O_RDWR and the
+// mode (e.g. O_NONBLOCK). The packet information header isn't prepended to frames.
+func openTunTap(name string, flags uint16, mode int) (int, error) {
+	fd, err := psSyscall.Syscall.Open(virtualNetworkDevice, syscall.O_RDWR|mode, 0666)
+	if err != nil {
+		return -1, psErr.CantOpenIOResource
+	}
+
+	ifrFlags := IfreqFlags{}
+	ifrFlags.Flags = flags | syscall.IFF_NO_PI
+	copy(ifrFlags.Name[:], name)
+	if errno := psSyscall.Syscall.Ioctl(fd, syscall.TUNSETIFF, unsafe.Pointer(&ifrFlags)); errno != 0 {
+		_ = psSyscall.Syscall.Close(fd)
+		return -1, psErr.CantModifyIOResourceParameter
+	}
+
+	return fd, psErr.OK
+}
+
+// createEpoll creates an epoll instance which watches fd.
+func createEpoll(fd int) (int, error) {
+	epfd, err := psSyscall.Syscall.EpollCreate1(0)
+	if err != nil {
+		return -1, psErr.CantCreateEpollInstance
+	}
+
+	var event syscall.EpollEvent
+	event.Events = syscall.EPOLLIN
+	event.Fd = int32(fd)
+
+	if err := psSyscall.Syscall.EpollCtl(epfd, syscall.EPOLL_CTL_ADD, fd, &event); err != nil {
+		_ = psSyscall.Syscall.Close(epfd)
+		return -1, psErr.CantModifyIOResourceParameter
+	}
+
+	return epfd, psErr.OK
 }
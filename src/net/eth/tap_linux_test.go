@@ -9,6 +9,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"syscall"
 	"testing"
+	"unsafe"
 )
 
 const ErrnoSuccess = syscall.Errno(0)
@@ -16,6 +17,7 @@ const ErrnoSuccess = syscall.Errno(0)
 var ErrorWithNoMessage error
 var RetValOnFail = -1
 var any = gomock.Any()
+var sysImpl = psSyscall.Syscall
 
 func setupTapLinuxTest(t *testing.T) (ctrl *gomock.Controller, teardown func()) {
 	psLog.DisableOutput()
@@ -125,7 +127,8 @@ func TestTapDevice_Open_5(t *testing.T) {
 	m.EXPECT().Ioctl(any, syscall.TUNSETIFF, any).Return(ErrnoSuccess)
 	m.EXPECT().Socket(any, any, any).Return(fd2, nil)
 	m.EXPECT().Ioctl(any, syscall.SIOCGIFHWADDR, any).Return(syscall.EBADF)
-	m.EXPECT().Close(any).Return(nil)
+	m.EXPECT().Close(fd2).Return(nil)
+	m.EXPECT().Close(fd1).Return(nil)
 
 	psSyscall.Syscall = m
 
@@ -152,7 +155,8 @@ func TestTapDevice_Open_6(t *testing.T) {
 	m.EXPECT().Socket(any, any, any).Return(fd2, nil)
 	m.EXPECT().Ioctl(any, syscall.SIOCGIFHWADDR, any).Return(ErrnoSuccess)
 	m.EXPECT().EpollCreate1(any).Return(RetValOnFail, ErrorWithNoMessage)
-	m.EXPECT().Close(any).Return(nil)
+	m.EXPECT().Close(fd2).Return(nil)
+	m.EXPECT().Close(fd1).Return(nil)
 
 	psSyscall.Syscall = m
 
@@ -188,21 +192,226 @@ func TestTapDevice_Open_7(t *testing.T) {
 	}
 }
 
-func TestTapDevice_Close(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
+// Success when any number of devices are opened (each device owns its epoll instance).
+func TestTapDevice_Open_8(t *testing.T) {
+	ctrl, teardown := setupTapLinuxTest(t)
+	defer teardown()
 
 	m := psSyscall.NewMockISyscall(ctrl)
-	m.EXPECT().Close(any).Return(nil)
+	gomock.InOrder(
+		m.EXPECT().Open(any, any, any).Return(3, nil),
+		m.EXPECT().Ioctl(3, syscall.TUNSETIFF, any).Return(ErrnoSuccess),
+		m.EXPECT().EpollCreate1(any).Return(4, nil),
+		m.EXPECT().EpollCtl(4, syscall.EPOLL_CTL_ADD, 3, any).Return(nil),
+		m.EXPECT().Open(any, any, any).Return(5, nil),
+		m.EXPECT().Ioctl(5, syscall.TUNSETIFF, any).Return(ErrnoSuccess),
+		m.EXPECT().EpollCreate1(any).Return(6, nil),
+		m.EXPECT().EpollCtl(6, syscall.EPOLL_CTL_ADD, 5, any).Return(nil),
+		m.EXPECT().EpollWait(4, any, any).Return(0, nil),
+		m.EXPECT().EpollWait(6, any, any).Return(0, nil),
+		m.EXPECT().Close(4).Return(nil),
+		m.EXPECT().Close(3).Return(nil),
+	)
+	psSyscall.Syscall = m
+
+	tapDev1 := GenTapDevice("net0", "tap0", mw.EthAddr{11, 12, 13, 14, 15, 16})
+	tapDev2 := GenTapDevice("net1", "tap1", mw.EthAddr{21, 22, 23, 24, 25, 26})
+
+	for _, dev := range []*TapDevice{tapDev1, tapDev2} {
+		if got := dev.Open(); got != psErr.OK {
+			t.Errorf("TapDevice.Open() = %v; want %v", got, psErr.OK)
+		}
+	}
+	for _, dev := range []*TapDevice{tapDev1, tapDev2} {
+		if got := dev.Poll(); got != psErr.NoDataToRead {
+			t.Errorf("TapDevice.Poll() = %v; want %v", got, psErr.NoDataToRead)
+		}
+	}
+
+	// closing a device doesn't affect the others
+	if got := tapDev1.Close(); got != psErr.OK {
+		t.Errorf("TapDevice.Close() = %v; want %v", got, psErr.OK)
+	}
+	if tapDev2.epfd != 6 || tapDev2.Priv_.FD != 5 {
+		t.Errorf("(epfd, fd) = (%d, %d); want (%d, %d)", tapDev2.epfd, tapDev2.Priv_.FD, 6, 5)
+	}
+}
 
+// Success when the queues are opened and the receive workers process frames through the pipeline.
+func TestTapDevice_Open_9(t *testing.T) {
+	ctrl, teardown := setupTapLinuxTest(t)
+	defer teardown()
+
+	evfd := 10
+	frame, _ := mw.EncodeFrame(mw.EthAddr{11, 12, 13, 14, 15, 16}, mw.EthAddr{21, 22, 23, 24, 25, 26}, mw.EtIPV4, []byte{})
+	event := func(fd int) func(_ int, events []syscall.EpollEvent, _ int) (int, error) {
+		return func(_ int, events []syscall.EpollEvent, _ int) (int, error) {
+			events[0].Fd = int32(fd)
+			return 1, nil
+		}
+	}
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	gomock.InOrder(
+		m.EXPECT().Eventfd(any, any).Return(evfd, nil),
+		m.EXPECT().Open(any, any, any).Return(3, nil),
+		m.EXPECT().Ioctl(3, syscall.TUNSETIFF, any).Return(ErrnoSuccess),
+		m.EXPECT().EpollCreate1(any).Return(4, nil),
+		m.EXPECT().EpollCtl(4, syscall.EPOLL_CTL_ADD, 3, any).Return(nil),
+		m.EXPECT().EpollCtl(4, syscall.EPOLL_CTL_ADD, evfd, any).Return(nil),
+		m.EXPECT().Open(any, any, any).Return(5, nil),
+		m.EXPECT().Ioctl(5, syscall.TUNSETIFF, any).Return(ErrnoSuccess),
+		m.EXPECT().EpollCreate1(any).Return(6, nil),
+		m.EXPECT().EpollCtl(6, syscall.EPOLL_CTL_ADD, 5, any).Return(nil),
+		m.EXPECT().EpollCtl(6, syscall.EPOLL_CTL_ADD, evfd, any).Return(nil),
+	)
+	// the worker of the first queue reads a frame, and the other worker is stopped
+	gomock.InOrder(
+		m.EXPECT().EpollWait(4, any, -1).DoAndReturn(event(3)),
+		m.EXPECT().Read(3, any).DoAndReturn(func(_ int, buf []byte) (int, error) {
+			return copy(buf, frame), nil
+		}),
+		m.EXPECT().Read(3, any).Return(RetValOnFail, syscall.EAGAIN),
+		m.EXPECT().EpollWait(4, any, -1).DoAndReturn(event(evfd)),
+	)
+	m.EXPECT().EpollWait(6, any, -1).DoAndReturn(event(evfd))
+	m.EXPECT().Write(evfd, any).Return(8, nil)
+	for _, fd := range []int{3, 4, 5, 6, evfd} {
+		m.EXPECT().Close(fd).Return(nil)
+	}
 	psSyscall.Syscall = m
 
-	tapDev := TapDevice{}
+	tapDev := GenTapDevice("net0", "tap0", mw.EthAddr{11, 12, 13, 14, 15, 16})
+	tapDev.Queues = 2
+	tapDev.Up()
+
+	if got := tapDev.Open(); got != psErr.OK {
+		t.Fatalf("TapDevice.Open() = %s; want %s", got, psErr.OK)
+	}
+	// the reactor doesn't read the device
+	if tapDev.Priv().FD != -1 {
+		t.Errorf("TapDevice.Priv().FD = %d; want %d", tapDev.Priv().FD, -1)
+	}
+	if got := tapDev.Poll(); got != psErr.NoDataToRead {
+		t.Errorf("TapDevice.Poll() = %s; want %s", got, psErr.NoDataToRead)
+	}
+	if got := tapDev.Close(); got != psErr.OK {
+		t.Errorf("TapDevice.Close() = %s; want %s", got, psErr.OK)
+	}
+
+	if len(mw.IpRxCh) != 1 {
+		t.Fatalf("len(mw.IpRxCh) = %d; want %d", len(mw.IpRxCh), 1)
+	}
+	if msg := <-mw.IpRxCh; msg.Dev != tapDev {
+		t.Errorf("message isn't sent from the device")
+	}
+}
+
+// Fail when the number of queues exceeds the limit.
+func TestTapDevice_Open_10(t *testing.T) {
+	_, teardown := setupTapLinuxTest(t)
+	defer teardown()
+
+	tapDev := GenTapDevice("net0", "tap0", mw.EthAddr{11, 12, 13, 14, 15, 16})
+	tapDev.Queues = TapQueueMax + 1
+
+	if got := tapDev.Open(); got != psErr.Error {
+		t.Errorf("TapDevice.Open() = %s; want %s", got, psErr.Error)
+	}
+}
+
+// Success when the offloads are enabled on the interface opened with IFF_VNET_HDR.
+func TestTapDevice_Open_11(t *testing.T) {
+	ctrl, teardown := setupTapLinuxTest(t)
+	defer teardown()
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	gomock.InOrder(
+		m.EXPECT().Open(any, any, any).Return(3, nil),
+		m.EXPECT().Ioctl(3, syscall.TUNSETIFF, any).DoAndReturn(func(_ int, _ int, data unsafe.Pointer) syscall.Errno {
+			if flags := (*IfreqFlags)(data).Flags; flags&syscall.IFF_VNET_HDR == 0 {
+				t.Errorf("flags = 0x%04x; want IFF_VNET_HDR", flags)
+			}
+			return ErrnoSuccess
+		}),
+		m.EXPECT().IoctlInt(3, tunSetOffload, uint(tunFCsum|tunFTso4)).Return(ErrnoSuccess),
+		m.EXPECT().EpollCreate1(any).Return(5, nil),
+		m.EXPECT().EpollCtl(5, any, 3, any).Return(nil),
+	)
+	psSyscall.Syscall = m
+
+	tapDev := GenTapDevice("net0", "tap0", mw.EthAddr{11, 12, 13, 14, 15, 16})
+	tapDev.VnetHdr = true
+
+	if got := tapDev.Open(); got != psErr.OK {
+		t.Errorf("TapDevice.Open() = %s; want %s", got, psErr.OK)
+	}
+	want := mw.RxCsumOffload | mw.TxCsumOffload | mw.TsoOffload
+	if got := mw.OffloadOf(tapDev); got != want {
+		t.Errorf("OffloadOf() = %d; want %d", got, want)
+	}
+}
+
+// Fail when the offloads can't be enabled.
+func TestTapDevice_Open_12(t *testing.T) {
+	ctrl, teardown := setupTapLinuxTest(t)
+	defer teardown()
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	gomock.InOrder(
+		m.EXPECT().Open(any, any, any).Return(3, nil),
+		m.EXPECT().Ioctl(3, syscall.TUNSETIFF, any).Return(ErrnoSuccess),
+		m.EXPECT().IoctlInt(3, tunSetOffload, any).Return(syscall.EINVAL),
+		m.EXPECT().Close(3).Return(nil),
+	)
+	psSyscall.Syscall = m
+
+	tapDev := GenTapDevice("net0", "tap0", mw.EthAddr{11, 12, 13, 14, 15, 16})
+	tapDev.VnetHdr = true
+
+	if got := tapDev.Open(); got != psErr.CantModifyIOResourceParameter {
+		t.Errorf("TapDevice.Open() = %s; want %s", got, psErr.CantModifyIOResourceParameter)
+	}
+}
+
+func TestTapDevice_Close_1(t *testing.T) {
+	ctrl, teardown := setupTapLinuxTest(t)
+	defer teardown()
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().Close(4).Return(nil)
+	m.EXPECT().Close(3).Return(nil)
+
+	psSyscall.Syscall = m
+
+	tapDev := TapDevice{epfd: 4}
+	tapDev.Priv_.FD = 3
 
 	got := tapDev.Close()
 	if got != psErr.OK {
 		t.Errorf("TapDevice.Close() = %v; want %v", got, psErr.OK)
 	}
+	if tapDev.epfd != -1 || tapDev.Priv_.FD != -1 {
+		t.Errorf("(epfd, fd) = (%d, %d); want (%d, %d)", tapDev.epfd, tapDev.Priv_.FD, -1, -1)
+	}
+}
+
+// Fail when Close() returns error.
+func TestTapDevice_Close_2(t *testing.T) {
+	ctrl, teardown := setupTapLinuxTest(t)
+	defer teardown()
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().Close(any).Return(syscall.EBADF)
+
+	psSyscall.Syscall = m
+
+	tapDev := TapDevice{}
+
+	got := tapDev.Close()
+	if got != psErr.SyscallError {
+		t.Errorf("TapDevice.Close() = %v; want %v", got, psErr.SyscallError)
+	}
 }
 
 func TestTapDevice_Transmit(t *testing.T) {
@@ -222,21 +431,77 @@ func TestTapDevice_Transmit(t *testing.T) {
 	}
 }
 
-// Success when no event occurs.
+// Success when a TCP segment larger than the MTU is written with the header which requests segmentation.
+func TestTapDevice_Transmit_2(t *testing.T) {
+	ctrl, teardown := setupTapLinuxTest(t)
+	defer teardown()
+
+	packet := make([]byte, 3000)
+	packet[0] = 0x45
+	packet[2], packet[3] = uint8(len(packet)>>8), uint8(len(packet))
+	packet[9] = uint8(mw.PnTCP)
+	packet[32] = 5 << 4
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().Write(3, any).DoAndReturn(func(_ int, b []byte) (int, error) {
+		if len(b) != mw.VnetHdrLen+mw.EthHdrLen+len(packet) {
+			t.Errorf("len(b) = %d; want %d", len(b), mw.VnetHdrLen+mw.EthHdrLen+len(packet))
+		}
+		if b[0] != mw.VnetFlagNeedsCsum || b[1] != mw.VnetGsoTcpV4 {
+			t.Errorf("(flags, gso type) = (%d, %d); want (%d, %d)", b[0], b[1], mw.VnetFlagNeedsCsum, mw.VnetGsoTcpV4)
+		}
+		if csum := mw.Checksum(b[mw.VnetHdrLen+mw.EthHdrLen:mw.VnetHdrLen+mw.EthHdrLen+20], 0); csum != 0 {
+			t.Errorf("ip header checksum = 0x%04x; want valid", csum)
+		}
+		return len(b), nil
+	})
+	psSyscall.Syscall = m
+
+	tapDev := GenTapDevice("net0", "tap0", mw.EthAddr{11, 12, 13, 14, 15, 16})
+	tapDev.Priv_.FD = 3
+	tapDev.MTU_ = 1500
+	tapDev.VnetHdr = true
+
+	if got := tapDev.Transmit(mw.EthAddr{21, 22, 23, 24, 25, 26}, packet, mw.EtIPV4); got != psErr.OK {
+		t.Errorf("TapDevice.Transmit() = %s; want %s", got, psErr.OK)
+	}
+}
+
+// Success when the frame is written as it is.
+func TestTapDevice_TransmitFrame(t *testing.T) {
+	ctrl, teardown := setupTapLinuxTest(t)
+	defer teardown()
+
+	frame := make([]byte, mw.EthFrameLenMin)
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().Write(3, frame).Return(len(frame), nil)
+	psSyscall.Syscall = m
+
+	tapDev := GenTapDevice("net0", "tap0", mw.EthAddr{})
+	tapDev.Priv_.FD = 3
+
+	got := tapDev.TransmitFrame(frame)
+	if got != psErr.OK {
+		t.Errorf("TapDevice.TransmitFrame() = %v; want %v", got, psErr.OK)
+	}
+}
+
+// Success when no event occurs (Poll() doesn't block).
 func TestTapDevice_Poll_1(t *testing.T) {
 	ctrl, teardown := setupTapLinuxTest(t)
 	defer teardown()
 
 	m := psSyscall.NewMockISyscall(ctrl)
-	m.EXPECT().EpollWait(any, any, any).Return(0, nil)
+	m.EXPECT().EpollWait(any, any, 0).Return(0, nil)
 
 	psSyscall.Syscall = m
 
 	tapDev := TapDevice{}
 
 	got := tapDev.Poll()
-	if got != psErr.OK {
-		t.Errorf("TapDevice.Poll() = %v; want %v", got, psErr.OK)
+	if got != psErr.NoDataToRead {
+		t.Errorf("TapDevice.Poll() = %v; want %v", got, psErr.NoDataToRead)
 	}
 }
 
@@ -245,9 +510,14 @@ func TestTapDevice_Poll_2(t *testing.T) {
 	ctrl, teardown := setupTapLinuxTest(t)
 	defer teardown()
 
+	frame, _ := mw.EncodeFrame(mw.EthBroadcast, mw.EthAddr{21, 22, 23, 24, 25, 26}, mw.EtIPV4, []byte{})
+
 	m := psSyscall.NewMockISyscall(ctrl)
 	m.EXPECT().EpollWait(any, any, any).Return(1, nil)
-	m.EXPECT().Read(any, any).Return(150, nil)
+	m.EXPECT().Read(any, any).DoAndReturn(func(_ int, buf []byte) (int, error) {
+		return copy(buf, frame), nil
+	}).Times(2)
+	m.EXPECT().Read(any, any).Return(RetValOnFail, syscall.EAGAIN)
 
 	psSyscall.Syscall = m
 
@@ -257,6 +527,14 @@ func TestTapDevice_Poll_2(t *testing.T) {
 	if got != psErr.OK {
 		t.Errorf("TapDevice.Poll() = %v; want %v", got, psErr.OK)
 	}
+
+	// the pending frames are passed in a batch
+	if len(mw.EthRxBatchCh) != 1 {
+		t.Fatalf("len(mw.EthRxBatchCh) = %d; want %d", len(mw.EthRxBatchCh), 1)
+	}
+	if msgs := <-mw.EthRxBatchCh; len(msgs) != 2 {
+		t.Errorf("len(msgs) = %d; want %d", len(msgs), 2)
+	}
 }
 
 // Fail when EpollWait() is interrupted.
@@ -295,7 +573,7 @@ func TestTapDevice_Poll_5(t *testing.T) {
 	}
 }
 
-// Fail when ReadFrame() failed.
+// Fail when ReadFrames() failed.
 func TestTapDevice_Poll_6(t *testing.T) {
 	ctrl, teardown := setupTapLinuxTest(t)
 	defer teardown()
@@ -314,6 +592,78 @@ func TestTapDevice_Poll_6(t *testing.T) {
 	}
 }
 
+// BenchmarkTapDevice_Poll measures the receive path which drains the pending frames per wakeup.
+func BenchmarkTapDevice_Poll(b *testing.B) {
+	benchmarkRx(b, EpollBackend, func(dev *TapDevice) error {
+		return dev.Poll()
+	})
+}
+
+// benchmarkRx feeds frames to a device through a datagram socket pair, which keeps the boundaries of frames the same
+// way as a TAP interface does, and polls the device until the frames are consumed.
+func benchmarkRx(b *testing.B, backend TapBackend, poll func(dev *TapDevice) error) {
+	psLog.DisableDebug()
+	defer psLog.EnableDebug()
+	psSyscall.Syscall = sysImpl
+
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_DGRAM|syscall.SOCK_NONBLOCK, 0)
+	if err != nil {
+		b.Fatalf("Socketpair() = %v", err)
+	}
+	defer func() {
+		_ = syscall.Close(fds[0])
+		_ = syscall.Close(fds[1])
+	}()
+
+	dev := GenTapDevice("net0", "tap0", mw.EthAddr{11, 12, 13, 14, 15, 16})
+	if backend == IoUringBackend {
+		u, e := newTapUring(fds[0], mw.EthFrameLenMax)
+		if e != psErr.OK {
+			b.Fatalf("newTapUring() = %s", e)
+		}
+		defer func() { _ = u.ring.close() }()
+		dev.uring = u
+		dev.Priv_.FD = u.ring.fd
+	} else {
+		epfd, e := createEpoll(fds[0])
+		if e != psErr.OK {
+			b.Fatalf("createEpoll() = %s", e)
+		}
+		defer func() { _ = syscall.Close(epfd) }()
+		dev.Priv_.FD = fds[0]
+		dev.epfd = epfd
+	}
+
+	frame, _ := mw.EncodeFrame(dev.Addr_, mw.EthAddr{21, 22, 23, 24, 25, 26}, mw.EtIPV4, make([]byte, 46))
+
+	done := make(chan bool)
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-mw.EthRxCh:
+			case <-mw.EthRxBatchCh:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i += mw.RxBatchMax {
+		b.StopTimer()
+		for j := 0; j < mw.RxBatchMax; j++ {
+			if _, err := syscall.Write(fds[1], frame); err != nil {
+				b.Fatalf("Write() = %v", err)
+			}
+		}
+		b.StartTimer()
+		for poll(dev) == psErr.OK {
+		}
+	}
+}
+
 func init() {
 	ErrorWithNoMessage = errors.New("")
 }
@@ -0,0 +1,265 @@
+// +build amd64,linux
+
+package eth
+
+import (
+	"fmt"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/42milez/ProtocolStack/src/pcap"
+	psSyscall "github.com/42milez/ProtocolStack/src/syscall"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// TapBackend is the way a TAP device reads and writes frames.
+type TapBackend int
+
+const (
+	// EpollBackend waits for frames with epoll and reads them with read(2) one by one.
+	EpollBackend TapBackend = iota
+	// IoUringBackend keeps reads in flight with io_uring. The reads which complete at a time are handed to the kernel
+	// again with a single system call, and so are writes.
+	IoUringBackend
+)
+
+const tapRingEntries = 128
+
+// tapTxInflightMax is the number of writes which can be in flight, so that the submission queue has room for the
+// reads.
+const tapTxInflightMax = tapRingEntries - mw.RxBatchMax
+
+// txTag marks the user data of writes.
+const txTag = 1 << 63
+
+// tapUring reads and writes a TAP interface through io_uring. The ring is watched by the reactor instead of the
+// interface since it becomes readable when requests complete.
+type tapUring struct {
+	fd     int // descriptor of the interface
+	ring   *ioUring
+	rxBufs [][]byte
+	rxDone []rxCompletion // reads which have completed but haven't been processed yet
+	tx     map[uint64][]byte
+	txSeq  uint64
+	mtx    sync.Mutex
+}
+
+type rxCompletion struct {
+	idx int
+	n   int
+}
+
+func (p *TapDevice) openUring() error {
+	if p.Queues > 1 {
+		psLog.E("io_uring backend doesn't support multiple queues", "device: "+p.Name_)
+		return psErr.Error
+	}
+
+	// the interface is blocking so that the reads wait for frames in the kernel
+	fd, err := p.openTap(syscall.IFF_TAP, 0)
+	if err != psErr.OK {
+		return err
+	}
+
+	if err = p.resolveAddr(); err != psErr.OK {
+		_ = psSyscall.Syscall.Close(fd)
+		return err
+	}
+
+	bufLen := mw.EthFrameLenMax + mw.VlanTagLen
+	if p.VnetHdr {
+		bufLen = mw.VnetHdrLen + mw.EthHdrLen + mw.VlanTagLen + 0xffff
+	}
+	u, err := newTapUring(fd, bufLen)
+	if err != psErr.OK {
+		_ = psSyscall.Syscall.Close(fd)
+		return err
+	}
+
+	p.uring = u
+	p.Priv_.FD = u.ring.fd
+
+	return psErr.OK
+}
+
+func (p *TapDevice) closeUring() error {
+	ret := psErr.OK
+	// the ring is closed at first to cancel the reads
+	if err := p.uring.ring.close(); err != psErr.OK {
+		ret = psErr.SyscallError
+	}
+	if err := psSyscall.Syscall.Close(p.uring.fd); err != nil {
+		ret = psErr.SyscallError
+	}
+	p.uring = nil
+	p.Priv_.FD = -1
+	return ret
+}
+
+// pollUring processes the reads which have completed and hands them to the kernel again.
+func (p *TapDevice) pollUring() error {
+	u := p.uring
+
+	u.mtx.Lock()
+	u.ring.reap(p.complete)
+	done := u.rxDone
+	u.rxDone = nil
+	u.mtx.Unlock()
+
+	if len(done) == 0 {
+		return psErr.NoDataToRead
+	}
+
+	// the buffers aren't read by the kernel until they are handed again
+	var msgs []*mw.EthMessage
+	for _, v := range done {
+		if v.n < 0 {
+			continue
+		}
+		if msg := p.accept(u.rxBufs[v.idx][:v.n]); msg != nil {
+			msgs = append(msgs, msg)
+		}
+	}
+
+	u.mtx.Lock()
+	for _, v := range done {
+		// the buffer is left unused when the interface can't be read any more
+		if v.n >= 0 || v.n == -int(syscall.EINTR) || v.n == -int(syscall.EAGAIN) {
+			u.read(v.idx)
+		}
+	}
+	err := u.ring.submit(0)
+	u.mtx.Unlock()
+	if err != psErr.OK {
+		return err
+	}
+
+	if len(msgs) != 0 {
+		mw.EthRxBatchCh <- msgs
+	}
+
+	return psErr.OK
+}
+
+// writeUring queues the data to be written. The result of the write is reported when it's reaped.
+func (p *TapDevice) writeUring(b []byte) error {
+	u := p.uring
+	notify := false
+
+	u.mtx.Lock()
+	sqe := u.ring.get()
+	for sqe == nil || len(u.tx) >= tapTxInflightMax {
+		if sqe != nil {
+			// the entry doesn't request anything
+			sqe.Opcode = psSyscall.IoUringOpNop
+			sqe.UserData = txTag
+		}
+		if err := u.ring.submit(1); err != psErr.OK {
+			u.mtx.Unlock()
+			return err
+		}
+		u.ring.reap(p.complete)
+		notify = notify || len(u.rxDone) != 0
+		sqe = u.ring.get()
+	}
+	u.txSeq++
+	ud := txTag | u.txSeq
+	u.tx[ud] = b
+	u.prepare(sqe, psSyscall.IoUringOpWrite, b, ud)
+	err := u.ring.submit(0)
+	u.mtx.Unlock()
+
+	// the reads which completed while waiting are processed by the reactor
+	if notify {
+		mw.NotifyRx(p)
+	}
+
+	return err
+}
+
+// complete is called for each completion queue entry with the lock held.
+func (p *TapDevice) complete(cqe *psSyscall.IoUringCqe) {
+	u := p.uring
+	if cqe.UserData&txTag != 0 {
+		if cqe.Res < 0 {
+			psLog.E(fmt.Sprintf("can't write frame: %s", syscall.Errno(-cqe.Res)), "device: "+p.Name_)
+		}
+		delete(u.tx, cqe.UserData)
+		return
+	}
+	if cqe.Res < 0 {
+		psLog.E(fmt.Sprintf("can't read frame: %s", syscall.Errno(-cqe.Res)), "device: "+p.Name_)
+	}
+	u.rxDone = append(u.rxDone, rxCompletion{
+		idx: int(cqe.UserData),
+		n:   int(cqe.Res),
+	})
+}
+
+// accept takes the frame out of the data which is read. It returns nil when the frame isn't addressed to the device.
+func (p *TapDevice) accept(b []byte) *mw.EthMessage {
+	frame := b
+	if p.VnetHdr {
+		var err error
+		if frame, err = mw.StripVnetHdr(b, p); err != psErr.OK {
+			psLog.W(fmt.Sprintf("frame was discarded: %s", err), "device: "+p.Name_)
+			return nil
+		}
+	}
+
+	mw.CaptureFrame(p, pcap.DirectionInbound, frame)
+
+	msg, err := mw.ReceiveFrame(frame, p)
+	if err != psErr.OK {
+		if err != psErr.NoDataToRead {
+			psLog.W(fmt.Sprintf("frame was discarded: %s", err), "device: "+p.Name_)
+		}
+		return nil
+	}
+	return msg
+}
+
+// newTapUring creates a ring for the descriptor and keeps a read in flight for each of RxBatchMax buffers.
+func newTapUring(fd int, bufLen int) (*tapUring, error) {
+	ring, err := newIoUring(tapRingEntries)
+	if err != psErr.OK {
+		return nil, err
+	}
+
+	u := &tapUring{
+		fd:   fd,
+		ring: ring,
+		tx:   make(map[uint64][]byte),
+	}
+	for i := 0; i < mw.RxBatchMax; i++ {
+		u.rxBufs = append(u.rxBufs, make([]byte, bufLen))
+		u.read(i)
+	}
+	if err = ring.submit(0); err != psErr.OK {
+		_ = ring.close()
+		return nil, err
+	}
+
+	return u, psErr.OK
+}
+
+// read queues a read into the buffer. It must be called with the lock held.
+func (p *tapUring) read(idx int) {
+	sqe := p.ring.get()
+	if sqe == nil {
+		// it doesn't happen since the entries are submitted as soon as they are queued
+		psLog.E("submission queue is full")
+		return
+	}
+	p.prepare(sqe, psSyscall.IoUringOpRead, p.rxBufs[idx], uint64(idx))
+}
+
+func (p *tapUring) prepare(sqe *psSyscall.IoUringSqe, op uint8, b []byte, ud uint64) {
+	sqe.Opcode = op
+	sqe.Fd = int32(p.fd)
+	sqe.Addr = uint64(uintptr(unsafe.Pointer(&b[0])))
+	sqe.Len = uint32(len(b))
+	sqe.UserData = ud
+}
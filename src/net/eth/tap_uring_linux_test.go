@@ -0,0 +1,267 @@
+package eth
+
+import (
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	"github.com/42milez/ProtocolStack/src/mw"
+	psSyscall "github.com/42milez/ProtocolStack/src/syscall"
+	"github.com/golang/mock/gomock"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+const fakeRingFD = 6
+
+// fakeRing plays the kernel with the memory which an io_uring instance shares.
+type fakeRing struct {
+	params    psSyscall.IoUringParams
+	sqRing    []byte
+	cqRing    []byte
+	sqes      []byte
+	submitted []psSyscall.IoUringSqe
+}
+
+func newFakeRing() *fakeRing {
+	r := &fakeRing{}
+	r.params.SqEntries = tapRingEntries
+	r.params.CqEntries = tapRingEntries * 2
+	r.params.SqOff = psSyscall.IoSqringOffsets{Head: 0, Tail: 4, RingMask: 8, RingEntries: 12, Array: 64}
+	r.params.CqOff = psSyscall.IoCqringOffsets{Head: 0, Tail: 4, RingMask: 8, RingEntries: 12, Cqes: 64}
+	r.sqRing = make([]byte, 64+tapRingEntries*4)
+	r.cqRing = make([]byte, 64+tapRingEntries*2*cqeSize)
+	r.sqes = make([]byte, tapRingEntries*sqeSize)
+	*u32(r.sqRing, 8) = tapRingEntries - 1
+	*u32(r.sqRing, 12) = tapRingEntries
+	*u32(r.cqRing, 8) = tapRingEntries*2 - 1
+	*u32(r.cqRing, 12) = tapRingEntries * 2
+	return r
+}
+
+// expect sets the expectations which create the ring and submit the entries to it.
+func (r *fakeRing) expect(m *psSyscall.MockISyscall) {
+	m.EXPECT().IoUringSetup(uint32(tapRingEntries), any).DoAndReturn(
+		func(_ uint32, params *psSyscall.IoUringParams) (int, error) {
+			*params = r.params
+			return fakeRingFD, nil
+		})
+	m.EXPECT().Mmap(fakeRingFD, any, any, any, any).DoAndReturn(
+		func(_ int, offset int64, _, _, _ int) ([]byte, error) {
+			switch offset {
+			case psSyscall.IoUringOffSqRing:
+				return r.sqRing, nil
+			case psSyscall.IoUringOffCqRing:
+				return r.cqRing, nil
+			default:
+				return r.sqes, nil
+			}
+		}).Times(3)
+	m.EXPECT().IoUringEnter(fakeRingFD, any, any, any).DoAndReturn(
+		func(_ int, toSubmit, _, _ uint32) (int, error) {
+			head := *u32(r.sqRing, 0)
+			tail := *u32(r.sqRing, 4)
+			for ; head != tail; head++ {
+				idx := *u32(r.sqRing, 64+(head&(tapRingEntries-1))*4)
+				r.submitted = append(r.submitted, *(*psSyscall.IoUringSqe)(unsafe.Pointer(&r.sqes[int(idx)*sqeSize])))
+			}
+			*u32(r.sqRing, 0) = head
+			return int(toSubmit), nil
+		}).AnyTimes()
+}
+
+// complete posts a completion queue entry.
+func (r *fakeRing) complete(ud uint64, res int32) {
+	tail := *u32(r.cqRing, 4)
+	cqe := (*psSyscall.IoUringCqe)(unsafe.Pointer(&r.cqRing[64+int(tail&(tapRingEntries*2-1))*cqeSize]))
+	cqe.UserData = ud
+	cqe.Res = res
+	*u32(r.cqRing, 4) = tail + 1
+}
+
+// openUringDevice opens a device of the io_uring backend with the fake ring.
+func openUringDevice(t *testing.T, m *psSyscall.MockISyscall, r *fakeRing) *TapDevice {
+	m.EXPECT().Open(any, syscall.O_RDWR, any).Return(3, nil)
+	m.EXPECT().Ioctl(3, syscall.TUNSETIFF, any).Return(ErrnoSuccess)
+	r.expect(m)
+
+	tapDev := GenTapDevice("net0", "tap0", mw.EthAddr{11, 12, 13, 14, 15, 16})
+	tapDev.Backend = IoUringBackend
+	if got := tapDev.Open(); got != psErr.OK {
+		t.Fatalf("TapDevice.Open() = %s; want %s", got, psErr.OK)
+	}
+	return tapDev
+}
+
+// Success when a read of each buffer is submitted at a time and the reactor watches the ring.
+func TestTapDevice_Open_13(t *testing.T) {
+	ctrl, teardown := setupTapLinuxTest(t)
+	defer teardown()
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	psSyscall.Syscall = m
+	r := newFakeRing()
+
+	tapDev := openUringDevice(t, m, r)
+
+	if tapDev.Priv_.FD != fakeRingFD {
+		t.Errorf("FD = %d; want %d", tapDev.Priv_.FD, fakeRingFD)
+	}
+	if len(r.submitted) != mw.RxBatchMax {
+		t.Fatalf("len(submitted) = %d; want %d", len(r.submitted), mw.RxBatchMax)
+	}
+	for i, sqe := range r.submitted {
+		if sqe.Opcode != psSyscall.IoUringOpRead || sqe.Fd != 3 || sqe.UserData != uint64(i) {
+			t.Errorf("(opcode, fd, user data) = (%d, %d, %d); want (%d, %d, %d)",
+				sqe.Opcode, sqe.Fd, sqe.UserData, psSyscall.IoUringOpRead, 3, i)
+		}
+	}
+}
+
+// Fail when the io_uring backend is used with multiple queues.
+func TestTapDevice_Open_14(t *testing.T) {
+	ctrl, teardown := setupTapLinuxTest(t)
+	defer teardown()
+
+	psSyscall.Syscall = psSyscall.NewMockISyscall(ctrl)
+
+	tapDev := GenTapDevice("net0", "tap0", mw.EthAddr{11, 12, 13, 14, 15, 16})
+	tapDev.Backend = IoUringBackend
+	tapDev.Queues = 2
+
+	if got := tapDev.Open(); got != psErr.Error {
+		t.Errorf("TapDevice.Open() = %s; want %s", got, psErr.Error)
+	}
+}
+
+// Success when the ring and the interface are closed.
+func TestTapDevice_Close_3(t *testing.T) {
+	ctrl, teardown := setupTapLinuxTest(t)
+	defer teardown()
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	psSyscall.Syscall = m
+	r := newFakeRing()
+	tapDev := openUringDevice(t, m, r)
+
+	gomock.InOrder(
+		m.EXPECT().Munmap(any).Return(nil).Times(3),
+		m.EXPECT().Close(fakeRingFD).Return(nil),
+		m.EXPECT().Close(3).Return(nil),
+	)
+
+	if got := tapDev.Close(); got != psErr.OK {
+		t.Errorf("TapDevice.Close() = %s; want %s", got, psErr.OK)
+	}
+	if tapDev.uring != nil || tapDev.Priv_.FD != -1 {
+		t.Errorf("(uring, fd) = (%v, %d); want (nil, %d)", tapDev.uring, tapDev.Priv_.FD, -1)
+	}
+}
+
+// Success when the completed reads are passed in a batch and submitted again.
+func TestTapDevice_Poll_7(t *testing.T) {
+	ctrl, teardown := setupTapLinuxTest(t)
+	defer teardown()
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	psSyscall.Syscall = m
+	r := newFakeRing()
+	tapDev := openUringDevice(t, m, r)
+
+	if got := tapDev.Poll(); got != psErr.NoDataToRead {
+		t.Errorf("TapDevice.Poll() = %s; want %s", got, psErr.NoDataToRead)
+	}
+
+	frame, _ := mw.EncodeFrame(tapDev.Addr_, mw.EthAddr{21, 22, 23, 24, 25, 26}, mw.EtIPV4, make([]byte, 46))
+	for _, idx := range []int{5, 2} {
+		r.complete(uint64(idx), int32(copy(tapDev.uring.rxBufs[idx], frame)))
+	}
+	r.submitted = nil
+
+	if got := tapDev.Poll(); got != psErr.OK {
+		t.Errorf("TapDevice.Poll() = %s; want %s", got, psErr.OK)
+	}
+	if len(mw.EthRxBatchCh) != 1 {
+		t.Fatalf("len(mw.EthRxBatchCh) = %d; want %d", len(mw.EthRxBatchCh), 1)
+	}
+	if msgs := <-mw.EthRxBatchCh; len(msgs) != 2 {
+		t.Errorf("len(msgs) = %d; want %d", len(msgs), 2)
+	}
+	// the buffers are read again in the order they completed
+	if len(r.submitted) != 2 || r.submitted[0].UserData != 5 || r.submitted[1].UserData != 2 {
+		t.Errorf("submitted = %v; want reads of the buffers 5 and 2", r.submitted)
+	}
+}
+
+// Success when the frame is written through the ring and released when the write completes.
+func TestTapDevice_Transmit_3(t *testing.T) {
+	ctrl, teardown := setupTapLinuxTest(t)
+	defer teardown()
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	psSyscall.Syscall = m
+	r := newFakeRing()
+	tapDev := openUringDevice(t, m, r)
+	r.submitted = nil
+
+	frame := make([]byte, mw.EthFrameLenMin)
+	if got := tapDev.TransmitFrame(frame); got != psErr.OK {
+		t.Errorf("TapDevice.TransmitFrame() = %s; want %s", got, psErr.OK)
+	}
+	if len(r.submitted) != 1 {
+		t.Fatalf("len(submitted) = %d; want %d", len(r.submitted), 1)
+	}
+	sqe := r.submitted[0]
+	if sqe.Opcode != psSyscall.IoUringOpWrite || sqe.Fd != 3 || sqe.Len != uint32(len(frame)) {
+		t.Errorf("(opcode, fd, len) = (%d, %d, %d); want (%d, %d, %d)",
+			sqe.Opcode, sqe.Fd, sqe.Len, psSyscall.IoUringOpWrite, 3, len(frame))
+	}
+	if len(tapDev.uring.tx) != 1 {
+		t.Errorf("len(tx) = %d; want %d", len(tapDev.uring.tx), 1)
+	}
+
+	r.complete(sqe.UserData, int32(len(frame)))
+	if got := tapDev.Poll(); got != psErr.NoDataToRead {
+		t.Errorf("TapDevice.Poll() = %s; want %s", got, psErr.NoDataToRead)
+	}
+	if len(tapDev.uring.tx) != 0 {
+		t.Errorf("len(tx) = %d; want %d", len(tapDev.uring.tx), 0)
+	}
+}
+
+// Success when the writer waits for the writes in flight to complete.
+func TestTapDevice_Transmit_4(t *testing.T) {
+	ctrl, teardown := setupTapLinuxTest(t)
+	defer teardown()
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	psSyscall.Syscall = m
+	r := newFakeRing()
+	tapDev := openUringDevice(t, m, r)
+
+	frame := make([]byte, mw.EthFrameLenMin)
+	for i := 0; i < tapTxInflightMax; i++ {
+		if got := tapDev.TransmitFrame(frame); got != psErr.OK {
+			t.Fatalf("TapDevice.TransmitFrame() = %s; want %s", got, psErr.OK)
+		}
+	}
+	// a write and a read complete while the writer waits
+	r.complete(r.submitted[len(r.submitted)-1].UserData, int32(len(frame)))
+	r.complete(0, int32(len(frame)))
+
+	if got := tapDev.TransmitFrame(frame); got != psErr.OK {
+		t.Errorf("TapDevice.TransmitFrame() = %s; want %s", got, psErr.OK)
+	}
+	if len(tapDev.uring.tx) != tapTxInflightMax {
+		t.Errorf("len(tx) = %d; want %d", len(tapDev.uring.tx), tapTxInflightMax)
+	}
+	// the read is left to the reactor
+	if len(tapDev.uring.rxDone) != 1 {
+		t.Errorf("len(rxDone) = %d; want %d", len(tapDev.uring.rxDone), 1)
+	}
+}
+
+// BenchmarkTapDevice_Poll_IoUring measures the receive path of the io_uring backend.
+func BenchmarkTapDevice_Poll_IoUring(b *testing.B) {
+	benchmarkRx(b, IoUringBackend, func(dev *TapDevice) error {
+		return dev.Poll()
+	})
+}
@@ -0,0 +1,112 @@
+// +build amd64,linux
+
+package eth
+
+import (
+	"errors"
+	"fmt"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/42milez/ProtocolStack/src/pcap"
+	psSyscall "github.com/42milez/ProtocolStack/src/syscall"
+	"syscall"
+)
+
+const TunMTU = 1500
+
+// TunDevice exchanges IPv4 packets with the kernel through a TUN interface. The packets don't have ethernet header,
+// so the device is neither addressed by ethernet address nor needs ARP.
+type TunDevice struct {
+	mw.Device
+	epfd int
+}
+
+func (p *TunDevice) Open() error {
+	var fd int
+	var epfd int
+	var err error
+
+	if fd, err = openTunTap(p.Priv().Name, syscall.IFF_TUN, syscall.O_NONBLOCK); err != psErr.OK {
+		return err
+	}
+
+	if epfd, err = createEpoll(fd); err != psErr.OK {
+		_ = psSyscall.Syscall.Close(fd)
+		return err
+	}
+
+	p.Priv_.FD = fd
+	p.epfd = epfd
+
+	return psErr.OK
+}
+
+func (p *TunDevice) Close() error {
+	if err := psSyscall.Syscall.Close(p.epfd); err != nil {
+		return psErr.SyscallError
+	}
+	p.epfd = -1
+	if err := psSyscall.Syscall.Close(p.Priv_.FD); err != nil {
+		return psErr.SyscallError
+	}
+	p.Priv_.FD = -1
+	return psErr.OK
+}
+
+// Poll reads an IP packet and passes it to the IP layer directly. Packets other than IPv4 are discarded.
+func (p *TunDevice) Poll() error {
+	var events [maxEpollEvents]syscall.EpollEvent
+	nEvents, err := psSyscall.Syscall.EpollWait(p.epfd, events[:], epollTimeout)
+	if err != nil {
+		if !errors.Is(err, syscall.EINTR) {
+			return psErr.SyscallError
+		}
+		return psErr.Interrupted
+	}
+
+	if nEvents == 0 {
+		return psErr.NoDataToRead
+	}
+
+	psLog.D("event occurred",
+		fmt.Sprintf("events: %v", nEvents),
+		fmt.Sprintf("device: %v (%v)", p.Name_, p.Priv_.Name))
+
+	buf := make([]byte, p.MTU_)
+	n, err := psSyscall.Syscall.Read(p.Priv_.FD, buf)
+	if err != nil {
+		return psErr.Error
+	}
+	packet := buf[:n]
+
+	mw.CaptureFrame(p, pcap.DirectionInbound, packet)
+
+	if n == 0 || packet[0]>>4 != 4 {
+		psLog.D("non-IPv4 packet was discarded", fmt.Sprintf("device: %v (%v)", p.Name_, p.Priv_.Name))
+		return psErr.OK
+	}
+
+	mw.IpRxCh <- &mw.EthMessage{
+		Type:    mw.EtIPV4,
+		Content: packet,
+		Dev:     p,
+	}
+
+	return psErr.OK
+}
+
+// Transmit writes the payload as it is. Only IPv4 packets can be transmitted, and dst is ignored.
+func (p *TunDevice) Transmit(dst mw.EthAddr, payload []byte, typ mw.EthType) error {
+	if typ != mw.EtIPV4 {
+		psLog.E(fmt.Sprintf("can't transmit %s over TUN device", typ))
+		return psErr.Error
+	}
+
+	if _, err := psSyscall.Syscall.Write(p.Priv_.FD, payload); err != nil {
+		return psErr.SyscallError
+	}
+	mw.CaptureFrame(p, pcap.DirectionOutbound, payload)
+
+	return psErr.OK
+}
@@ -0,0 +1,176 @@
+package eth
+
+import (
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/mw"
+	psSyscall "github.com/42milez/ProtocolStack/src/syscall"
+	"github.com/golang/mock/gomock"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+func setupTunLinuxTest(t *testing.T) (ctrl *gomock.Controller, teardown func()) {
+	psLog.DisableOutput()
+	ctrl = gomock.NewController(t)
+	drain := func() {
+		for len(mw.IpRxCh) != 0 {
+			<-mw.IpRxCh
+		}
+	}
+	drain()
+	teardown = func() {
+		ctrl.Finish()
+		drain()
+		psLog.EnableOutput()
+	}
+	return
+}
+
+func TestTunDevice_Open_1(t *testing.T) {
+	ctrl, teardown := setupTunLinuxTest(t)
+	defer teardown()
+
+	var flags uint16
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().Open(virtualNetworkDevice, any, any).Return(3, nil)
+	m.EXPECT().Ioctl(3, syscall.TUNSETIFF, any).DoAndReturn(func(fd int, code int, data unsafe.Pointer) syscall.Errno {
+		flags = (*IfreqFlags)(data).Flags
+		return ErrnoSuccess
+	})
+	m.EXPECT().EpollCreate1(any).Return(4, nil)
+	m.EXPECT().EpollCtl(4, syscall.EPOLL_CTL_ADD, 3, any).Return(nil)
+	psSyscall.Syscall = m
+
+	dev := GenTunDevice("net0", "tun0")
+
+	got := dev.Open()
+	if got != psErr.OK {
+		t.Errorf("TunDevice.Open() = %v; want %v", got, psErr.OK)
+	}
+	if want := uint16(syscall.IFF_TUN | syscall.IFF_NO_PI); flags != want {
+		t.Errorf("flags = 0x%04x; want 0x%04x", flags, want)
+	}
+}
+
+// Fail when Ioctl() returns error.
+func TestTunDevice_Open_2(t *testing.T) {
+	ctrl, teardown := setupTunLinuxTest(t)
+	defer teardown()
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().Open(any, any, any).Return(3, nil)
+	m.EXPECT().Ioctl(3, syscall.TUNSETIFF, any).Return(syscall.EBUSY)
+	m.EXPECT().Close(3).Return(nil)
+	psSyscall.Syscall = m
+
+	dev := GenTunDevice("net0", "tun0")
+
+	got := dev.Open()
+	if got != psErr.CantModifyIOResourceParameter {
+		t.Errorf("TunDevice.Open() = %v; want %v", got, psErr.CantModifyIOResourceParameter)
+	}
+}
+
+// Success when an IPv4 packet is passed to the IP layer directly.
+func TestTunDevice_Poll_1(t *testing.T) {
+	ctrl, teardown := setupTunLinuxTest(t)
+	defer teardown()
+
+	packet := []byte{0x45, 0x00, 0x00, 0x14, 0x00, 0x00, 0x00, 0x00, 0xff, 0x01}
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().EpollWait(any, any, 0).Return(1, nil)
+	m.EXPECT().Read(3, any).DoAndReturn(func(fd int, p []byte) (int, error) {
+		return copy(p, packet), nil
+	})
+	psSyscall.Syscall = m
+
+	dev := GenTunDevice("net0", "tun0")
+	dev.Priv_.FD = 3
+
+	if got := dev.Poll(); got != psErr.OK {
+		t.Errorf("TunDevice.Poll() = %v; want %v", got, psErr.OK)
+	}
+	if len(mw.IpRxCh) != 1 {
+		t.Fatalf("len(mw.IpRxCh) = %d; want %d", len(mw.IpRxCh), 1)
+	}
+	if msg := <-mw.IpRxCh; msg.Dev != dev || msg.Type != mw.EtIPV4 || len(msg.Content) != len(packet) {
+		t.Errorf("TunDevice.Poll() delivered unexpected message: %v", msg)
+	}
+}
+
+// Success when a packet other than IPv4 is discarded.
+func TestTunDevice_Poll_2(t *testing.T) {
+	ctrl, teardown := setupTunLinuxTest(t)
+	defer teardown()
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().EpollWait(any, any, 0).Return(1, nil)
+	m.EXPECT().Read(3, any).DoAndReturn(func(fd int, p []byte) (int, error) {
+		return copy(p, []byte{0x60, 0x00, 0x00, 0x00}), nil
+	})
+	psSyscall.Syscall = m
+
+	dev := GenTunDevice("net0", "tun0")
+	dev.Priv_.FD = 3
+
+	if got := dev.Poll(); got != psErr.OK {
+		t.Errorf("TunDevice.Poll() = %v; want %v", got, psErr.OK)
+	}
+	if len(mw.IpRxCh) != 0 {
+		t.Errorf("len(mw.IpRxCh) = %d; want %d", len(mw.IpRxCh), 0)
+	}
+}
+
+// Success when no event occurs.
+func TestTunDevice_Poll_3(t *testing.T) {
+	ctrl, teardown := setupTunLinuxTest(t)
+	defer teardown()
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().EpollWait(any, any, 0).Return(0, nil)
+	psSyscall.Syscall = m
+
+	dev := GenTunDevice("net0", "tun0")
+
+	if got := dev.Poll(); got != psErr.NoDataToRead {
+		t.Errorf("TunDevice.Poll() = %v; want %v", got, psErr.NoDataToRead)
+	}
+}
+
+// Success when the payload is written without ethernet header.
+func TestTunDevice_Transmit_1(t *testing.T) {
+	ctrl, teardown := setupTunLinuxTest(t)
+	defer teardown()
+
+	payload := []byte{0x45, 0x00, 0x00, 0x14}
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().Write(3, payload).Return(len(payload), nil)
+	psSyscall.Syscall = m
+
+	dev := GenTunDevice("net0", "tun0")
+	dev.Priv_.FD = 3
+
+	if got := dev.Transmit(mw.EthAny, payload, mw.EtIPV4); got != psErr.OK {
+		t.Errorf("TunDevice.Transmit() = %v; want %v", got, psErr.OK)
+	}
+}
+
+// Fail when the payload is not IPv4 packet.
+func TestTunDevice_Transmit_2(t *testing.T) {
+	ctrl, teardown := setupTunLinuxTest(t)
+	defer teardown()
+
+	psSyscall.Syscall = psSyscall.NewMockISyscall(ctrl)
+
+	dev := GenTunDevice("net0", "tun0")
+	dev.Priv_.FD = 3
+
+	if got := dev.Transmit(mw.EthBroadcast, make([]byte, 28), mw.EtARP); got != psErr.Error {
+		t.Errorf("TunDevice.Transmit() = %v; want %v", got, psErr.Error)
+	}
+}
@@ -0,0 +1,171 @@
+package eth
+
+import (
+	"fmt"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/mw"
+	psTime "github.com/42milez/ProtocolStack/src/time"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var txQueues map[string]*txQueue
+var txQueueMtx sync.Mutex
+
+// txQueue holds the frames which a device sends in its queueing discipline, and its worker sends them when the
+// discipline allows.
+type txQueue struct {
+	dev     mw.IDevice
+	qdisc   mw.IQdisc
+	clock   psTime.ITime
+	dropped uint64
+	mtx     sync.Mutex
+	wakeCh  chan bool
+	stopCh  chan bool
+	doneCh  chan bool
+}
+
+// enqueueTx queues the frame in the transmit queue of its device. The queue and its worker are created at the first
+// frame of the device, and created again when the discipline of the device is replaced. It returns BacklogFull when
+// the discipline drops the frame.
+func enqueueTx(msg *mw.EthMessage) error {
+	qdisc := mw.QdiscOf(msg.Dev)
+
+	txQueueMtx.Lock()
+	q := txQueues[msg.Dev.Name()]
+	if q == nil || q.qdisc != qdisc {
+		if q != nil {
+			// the frames queued in the old discipline are discarded
+			q.stop()
+			delete(txQueues, msg.Dev.Name())
+		}
+		if qdisc != nil {
+			q = newTxQueue(msg.Dev, qdisc)
+			txQueues[msg.Dev.Name()] = q
+		}
+	}
+	txQueueMtx.Unlock()
+
+	if qdisc == nil {
+		// the discipline has been removed since the frame was queued
+		return transmit(msg)
+	}
+	return q.enqueue(msg)
+}
+
+// TxDropped returns the number of the frames which the queueing discipline of the device has dropped. The number is
+// counted from when the discipline is set.
+func TxDropped(dev mw.IDevice) uint64 {
+	txQueueMtx.Lock()
+	q := txQueues[dev.Name()]
+	txQueueMtx.Unlock()
+
+	if q == nil || q.qdisc != mw.QdiscOf(dev) {
+		return 0
+	}
+	return atomic.LoadUint64(&q.dropped)
+}
+
+// stopTxQueues stops all the transmit queues. The frames which haven't been sent are discarded.
+func stopTxQueues() {
+	txQueueMtx.Lock()
+	defer txQueueMtx.Unlock()
+
+	for name, q := range txQueues {
+		q.stop()
+		delete(txQueues, name)
+	}
+}
+
+func newTxQueue(dev mw.IDevice, qdisc mw.IQdisc) *txQueue {
+	q := &txQueue{
+		dev:    dev,
+		qdisc:  qdisc,
+		clock:  psTime.Time,
+		wakeCh: make(chan bool, 1),
+		stopCh: make(chan bool),
+		doneCh: make(chan bool),
+	}
+	go q.run()
+	return q
+}
+
+func (p *txQueue) enqueue(msg *mw.EthMessage) error {
+	p.mtx.Lock()
+	ok := p.qdisc.Enqueue(msg)
+	p.mtx.Unlock()
+
+	if !ok {
+		atomic.AddUint64(&p.dropped, 1)
+		psLog.W("frame was dropped by queueing discipline", "device: "+p.dev.Name())
+		return psErr.BacklogFull
+	}
+
+	select {
+	case p.wakeCh <- true:
+	default:
+	}
+
+	return psErr.OK
+}
+
+// stop stops the worker. It waits for the frame being sent.
+func (p *txQueue) stop() {
+	close(p.stopCh)
+	<-p.doneCh
+}
+
+func (p *txQueue) run() {
+	defer close(p.doneCh)
+
+	for {
+		p.mtx.Lock()
+		msg, wait := p.qdisc.Dequeue(p.clock.Now())
+		p.mtx.Unlock()
+
+		if msg != nil {
+			select {
+			case <-p.stopCh:
+				return
+			default:
+			}
+			_ = transmit(msg)
+			continue
+		}
+
+		var timerCh <-chan time.Time
+		stopTimer := func() {}
+		if wait > 0 {
+			timerCh, stopTimer = p.clock.After(wait)
+		}
+
+		select {
+		case <-p.stopCh:
+			stopTimer()
+			return
+		case <-p.wakeCh:
+		case <-timerCh:
+		}
+
+		stopTimer()
+	}
+}
+
+// transmit sends the frame through its device. The frame is discarded when the device has gone down.
+func transmit(msg *mw.EthMessage) error {
+	if !msg.Dev.IsUp() {
+		psLog.W("frame was discarded: device is down", "device: "+msg.Dev.Name())
+		return psErr.DeviceNotOpened
+	}
+	if err := msg.Dev.Transmit(msg.Dst, msg.Content, msg.Type); err != psErr.OK {
+		psLog.E(fmt.Sprintf("can't transmit frame: %s", err), "device: "+msg.Dev.Name())
+		return psErr.Error
+	}
+	return psErr.OK
+}
+
+func init() {
+	txQueues = make(map[string]*txQueue)
+}
@@ -0,0 +1,177 @@
+package eth
+
+import (
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	"github.com/42milez/ProtocolStack/src/mw"
+	psTime "github.com/42milez/ProtocolStack/src/time"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Success when the frames sent through EthTxCh are sent by the transmit queue of the device.
+func TestSender_1(t *testing.T) {
+	_, teardown := setupEthTest(t)
+	defer teardown()
+
+	var wg sync.WaitGroup
+	_ = Start(&wg)
+	<-rcvMonCh
+	<-sndMonCh
+	defer func() {
+		Stop()
+		<-rcvMonCh
+		<-sndMonCh
+	}()
+
+	dev := GenNullDevice("null0", nil)
+	dev.Up()
+	mw.SetQdisc(dev, GenFifoQdisc())
+	defer mw.SetQdisc(dev, nil)
+
+	for i := 0; i < 3; i++ {
+		mw.EthTxCh <- &mw.EthMessage{Type: mw.EtIPV4, Content: make([]byte, 100), Dev: dev}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for dev.Stats().Dropped != 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := dev.Stats().Dropped; got != 3 {
+		t.Errorf("Stats().Dropped = %d; want %d", got, 3)
+	}
+}
+
+// Success when the worker waits until the discipline allows the frame to be sent.
+func TestTxQueue_1(t *testing.T) {
+	_, teardown := setupEthTest(t)
+	defer teardown()
+
+	dev := GenNullDevice("null0", nil)
+	dev.Up()
+	// the second frame is sent 100ms after the first one
+	mw.SetQdisc(dev, GenTbfQdisc(1000, 100))
+	defer func() {
+		mw.SetQdisc(dev, nil)
+		stopTxQueues()
+	}()
+
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		enqueueTx(&mw.EthMessage{Type: mw.EtIPV4, Content: make([]byte, 86), Dev: dev})
+	}
+
+	deadline := start.Add(time.Second)
+	for dev.Stats().Dropped != 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := dev.Stats().Dropped; got != 2 {
+		t.Fatalf("Stats().Dropped = %d; want %d", got, 2)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("elapsed = %s; want %s at least", elapsed, 100*time.Millisecond)
+	}
+}
+
+// Success when the frame is sent synchronously after the discipline is removed.
+func TestTxQueue_2(t *testing.T) {
+	_, teardown := setupEthTest(t)
+	defer teardown()
+
+	dev := GenNullDevice("null0", nil)
+	dev.Up()
+	mw.SetQdisc(dev, GenFifoQdisc())
+	enqueueTx(&mw.EthMessage{Type: mw.EtIPV4, Content: make([]byte, 100), Dev: dev})
+	mw.SetQdisc(dev, nil)
+	enqueueTx(&mw.EthMessage{Type: mw.EtIPV4, Content: make([]byte, 100), Dev: dev})
+
+	if _, ok := txQueues[dev.Name()]; ok {
+		t.Errorf("transmit queue of %s exists", dev.Name())
+	}
+	// the frame queued in the old discipline may be discarded
+	if got := dev.Stats().Dropped; got < 1 {
+		t.Errorf("Stats().Dropped = %d; want %d at least", got, 1)
+	}
+}
+
+// Success when the worker waits for the clock of the stack until the discipline allows the frame to be sent.
+func TestTxQueue_3(t *testing.T) {
+	_, teardown := setupEthTest(t)
+	defer teardown()
+
+	zero := time.Now()
+	clock := &fakeClock{now: zero}
+	backup := psTime.Time
+	psTime.Time = clock
+	defer func() { psTime.Time = backup }()
+
+	dev := GenNullDevice("null0", nil)
+	dev.Up()
+	// the second frame is sent 100ms after the first one
+	mw.SetQdisc(dev, GenTbfQdisc(1000, 100))
+	defer func() {
+		mw.SetQdisc(dev, nil)
+		stopTxQueues()
+	}()
+
+	for i := 0; i < 2; i++ {
+		_ = enqueueTx(&mw.EthMessage{Type: mw.EtIPV4, Content: make([]byte, 86), Dev: dev})
+	}
+
+	waitForDropped(t, dev, 1)
+	deadline := time.Now().Add(time.Second)
+	for !clock.waiting() {
+		if time.Now().After(deadline) {
+			t.Fatalf("transmit queue doesn't wait for the clock")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := dev.Stats().Dropped; got != 1 {
+		t.Errorf("Stats().Dropped = %d; want %d", got, 1)
+	}
+
+	clock.set(zero.Add(100 * time.Millisecond))
+	waitForDropped(t, dev, 2)
+}
+
+// Fail when the discipline drops the frame.
+func TestTxQueue_4(t *testing.T) {
+	_, teardown := setupEthTest(t)
+	defer teardown()
+
+	dev := GenNullDevice("null0", nil)
+	dev.Up()
+	// the frame is larger than the bucket
+	mw.SetQdisc(dev, GenTbfQdisc(1000, 50))
+	defer func() {
+		mw.SetQdisc(dev, nil)
+		stopTxQueues()
+	}()
+
+	for i := 0; i < 2; i++ {
+		got := enqueueTx(&mw.EthMessage{Type: mw.EtIPV4, Content: make([]byte, 86), Dev: dev})
+		if got != psErr.BacklogFull {
+			t.Errorf("enqueueTx() = %s; want %s", got, psErr.BacklogFull)
+		}
+	}
+	if got := TxDropped(dev); got != 2 {
+		t.Errorf("TxDropped() = %d; want %d", got, 2)
+	}
+
+	// the number is counted from when the discipline is set
+	mw.SetQdisc(dev, GenFifoQdisc())
+	if got := TxDropped(dev); got != 0 {
+		t.Errorf("TxDropped() = %d; want %d", got, 0)
+	}
+}
+
+// waitForDropped waits until the null device discards the frames as many as n.
+func waitForDropped(t *testing.T, dev *NullDevice, n uint64) {
+	deadline := time.Now().Add(time.Second)
+	for dev.Stats().Dropped < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Stats().Dropped = %d; want %d", dev.Stats().Dropped, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,154 @@
+// +build amd64,linux
+
+package eth
+
+import (
+	"errors"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psSyscall "github.com/42milez/ProtocolStack/src/syscall"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+// io_uring
+// https://kernel.dk/io_uring.pdf
+
+const sqeSize = int(unsafe.Sizeof(psSyscall.IoUringSqe{}))
+const cqeSize = int(unsafe.Sizeof(psSyscall.IoUringCqe{}))
+
+// ioUring is an io_uring instance. The submission queue and the completion queue are shared with the kernel, and
+// entries queued with get() are handed to the kernel at once by submit(). It isn't safe for concurrent use.
+type ioUring struct {
+	fd        int
+	sqRing    []byte
+	cqRing    []byte
+	sqes      []byte
+	sqHead    *uint32
+	sqTail    *uint32
+	sqMask    uint32
+	sqEntries uint32
+	sqArray   uint32 // offset of the index array in sqRing
+	cqHead    *uint32
+	cqTail    *uint32
+	cqMask    uint32
+	cqes      uint32 // offset of the completion queue entries in cqRing
+	tail      uint32 // tail of the submission queue which isn't published yet
+	pending   uint32 // number of the entries which aren't submitted yet
+}
+
+func newIoUring(entries uint32) (*ioUring, error) {
+	var params psSyscall.IoUringParams
+	fd, err := psSyscall.Syscall.IoUringSetup(entries, &params)
+	if err != nil {
+		return nil, psErr.CantCreateEndpoint
+	}
+
+	p := &ioUring{fd: fd}
+	mmap := func(offset int64, length uint32) ([]byte, error) {
+		return psSyscall.Syscall.Mmap(fd, offset, int(length), syscall.PROT_READ|syscall.PROT_WRITE,
+			syscall.MAP_SHARED|syscall.MAP_POPULATE)
+	}
+	if p.sqRing, err = mmap(psSyscall.IoUringOffSqRing, params.SqOff.Array+params.SqEntries*4); err != nil {
+		_ = p.close()
+		return nil, psErr.CantCreateEndpoint
+	}
+	if p.cqRing, err = mmap(psSyscall.IoUringOffCqRing, params.CqOff.Cqes+params.CqEntries*uint32(cqeSize)); err != nil {
+		_ = p.close()
+		return nil, psErr.CantCreateEndpoint
+	}
+	if p.sqes, err = mmap(psSyscall.IoUringOffSqes, params.SqEntries*uint32(sqeSize)); err != nil {
+		_ = p.close()
+		return nil, psErr.CantCreateEndpoint
+	}
+
+	p.sqHead = u32(p.sqRing, params.SqOff.Head)
+	p.sqTail = u32(p.sqRing, params.SqOff.Tail)
+	p.sqMask = *u32(p.sqRing, params.SqOff.RingMask)
+	p.sqEntries = *u32(p.sqRing, params.SqOff.RingEntries)
+	p.sqArray = params.SqOff.Array
+	p.cqHead = u32(p.cqRing, params.CqOff.Head)
+	p.cqTail = u32(p.cqRing, params.CqOff.Tail)
+	p.cqMask = *u32(p.cqRing, params.CqOff.RingMask)
+	p.cqes = params.CqOff.Cqes
+	p.tail = atomic.LoadUint32(p.sqTail)
+
+	return p, psErr.OK
+}
+
+// close releases the instance. The requests which are in flight are canceled by the kernel.
+func (p *ioUring) close() error {
+	ret := psErr.OK
+	for _, b := range [][]byte{p.sqes, p.cqRing, p.sqRing} {
+		if b == nil {
+			continue
+		}
+		if err := psSyscall.Syscall.Munmap(b); err != nil {
+			ret = psErr.SyscallError
+		}
+	}
+	p.sqes, p.cqRing, p.sqRing = nil, nil, nil
+	if err := psSyscall.Syscall.Close(p.fd); err != nil {
+		ret = psErr.SyscallError
+	}
+	p.fd = -1
+	return ret
+}
+
+// get returns a cleared submission queue entry. It returns nil when the submission queue is full.
+func (p *ioUring) get() *psSyscall.IoUringSqe {
+	if p.tail-atomic.LoadUint32(p.sqHead) >= p.sqEntries {
+		return nil
+	}
+	idx := p.tail & p.sqMask
+	sqe := (*psSyscall.IoUringSqe)(unsafe.Pointer(&p.sqes[int(idx)*sqeSize]))
+	*sqe = psSyscall.IoUringSqe{}
+	*u32(p.sqRing, p.sqArray+idx*4) = idx
+	p.tail++
+	p.pending++
+	return sqe
+}
+
+// submit hands the queued entries to the kernel with a single system call. It waits for minComplete completions at
+// least.
+func (p *ioUring) submit(minComplete uint32) error {
+	atomic.StoreUint32(p.sqTail, p.tail)
+
+	var flags uint32
+	if minComplete > 0 {
+		flags = psSyscall.IoUringEnterGetEvents
+	}
+	for p.pending > 0 || minComplete > 0 {
+		n, err := psSyscall.Syscall.IoUringEnter(p.fd, p.pending, minComplete, flags)
+		if err != nil {
+			if errors.Is(err, syscall.EINTR) {
+				continue
+			}
+			return psErr.SyscallError
+		}
+		if n == 0 && p.pending > 0 {
+			return psErr.Error
+		}
+		p.pending -= uint32(n)
+		minComplete = 0
+		flags = 0
+	}
+	return psErr.OK
+}
+
+// reap passes the completion queue entries to f in the order they completed, and returns the number of them.
+func (p *ioUring) reap(f func(cqe *psSyscall.IoUringCqe)) int {
+	head := atomic.LoadUint32(p.cqHead)
+	tail := atomic.LoadUint32(p.cqTail)
+	n := int(tail - head)
+	for ; head != tail; head++ {
+		f((*psSyscall.IoUringCqe)(unsafe.Pointer(&p.cqRing[int(p.cqes)+int(head&p.cqMask)*cqeSize])))
+	}
+	atomic.StoreUint32(p.cqHead, head)
+	return n
+}
+
+// u32 returns the pointer to the 32-bit field at the offset of the shared memory.
+func u32(b []byte, offset uint32) *uint32 {
+	return (*uint32)(unsafe.Pointer(&b[offset]))
+}
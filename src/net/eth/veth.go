@@ -0,0 +1,147 @@
+package eth
+
+import (
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/42milez/ProtocolStack/src/pcap"
+	"sync"
+)
+
+// VethDevice is a virtual ethernet device which exchanges frames in memory. A device is either one end of a pair
+// generated by GenVethPair() or a port of a Hub.
+type VethDevice struct {
+	mw.Device
+	link  iLink
+	queue frameQueue
+}
+
+// iLink delivers frames transmitted by a device to other devices.
+type iLink interface {
+	forward(src *VethDevice, frame []byte)
+}
+
+func (p *VethDevice) Open() error {
+	p.queue.reset()
+	return psErr.OK
+}
+
+func (p *VethDevice) Close() error {
+	p.queue.reset()
+	return psErr.OK
+}
+
+func (p *VethDevice) Poll() error {
+	frame := p.queue.pop()
+	if frame == nil {
+		return psErr.NoDataToRead
+	}
+
+	mw.CaptureFrame(p, pcap.DirectionInbound, frame)
+
+	msg, err := mw.ReceiveFrame(frame, p)
+	if err != psErr.OK {
+		if err != psErr.NoDataToRead {
+			return psErr.Error
+		}
+		return psErr.OK
+	}
+	mw.EthRxCh <- msg
+
+	return psErr.OK
+}
+
+func (p *VethDevice) Transmit(dst mw.EthAddr, payload []byte, typ mw.EthType) error {
+	frame, err := mw.EncodeFrame(dst, p.Addr_, typ, payload)
+	if err != psErr.OK {
+		return psErr.Error
+	}
+	return p.TransmitFrame(frame)
+}
+
+func (p *VethDevice) TransmitFrame(frame []byte) error {
+	mw.CaptureFrame(p, pcap.DirectionOutbound, frame)
+
+	if p.link != nil {
+		p.link.forward(p, frame)
+	}
+
+	return psErr.OK
+}
+
+// receive queues a frame which arrived from the link. The frame is dropped while the device is down.
+func (p *VethDevice) receive(frame []byte) {
+	if !p.IsUp() {
+		return
+	}
+	if !p.queue.push(frame) {
+		psLog.W("receive queue is full (frame was dropped)", "device: "+p.Name_)
+		return
+	}
+	mw.NotifyRx(p)
+}
+
+// A vethPair connects two devices directly.
+type vethPair struct {
+	ends [2]*VethDevice
+}
+
+func (p *vethPair) forward(src *VethDevice, frame []byte) {
+	// the peer owns its copy since frames are queued until they are polled
+	b := make([]byte, len(frame))
+	copy(b, frame)
+	if src == p.ends[0] {
+		p.ends[1].receive(b)
+	} else {
+		p.ends[0].receive(b)
+	}
+}
+
+// A Hub repeats frames transmitted by a port to all the other ports.
+type Hub struct {
+	ports []*VethDevice
+	mtx   sync.Mutex
+}
+
+// AddPort generates a device connected to the hub.
+func (p *Hub) AddPort(devName string, addr mw.EthAddr) *VethDevice {
+	dev := genVethDevice(devName, "hub", addr)
+	dev.link = p
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.ports = append(p.ports, dev)
+
+	return dev
+}
+
+func (p *Hub) forward(src *VethDevice, frame []byte) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for _, port := range p.ports {
+		if port == src {
+			continue
+		}
+		// each port owns its copy since frames are queued until they are polled
+		b := make([]byte, len(frame))
+		copy(b, frame)
+		port.receive(b)
+	}
+}
+
+func genVethDevice(devName string, privName string, addr mw.EthAddr) *VethDevice {
+	return &VethDevice{
+		Device: mw.Device{
+			Type_: mw.EthernetDevice,
+			Name_: devName,
+			Addr_: addr,
+			Flag_: mw.BroadcastFlag | mw.NeedArpFlag,
+			MTU_:  mw.EthPayloadLenMax,
+			Priv_: mw.Privilege{
+				FD:   -1,
+				Name: privName,
+			},
+		},
+	}
+}
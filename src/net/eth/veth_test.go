@@ -0,0 +1,172 @@
+package eth
+
+import (
+	"bytes"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/mw"
+	"testing"
+)
+
+var vethEthAddr1 = mw.EthAddr{11, 12, 13, 14, 15, 16}
+var vethEthAddr2 = mw.EthAddr{21, 22, 23, 24, 25, 26}
+var vethEthAddr3 = mw.EthAddr{31, 32, 33, 34, 35, 36}
+
+func setupVethTest(t *testing.T) (teardown func()) {
+	psLog.DisableOutput()
+	drain := func() {
+		for len(mw.EthRxCh) != 0 {
+			<-mw.EthRxCh
+		}
+	}
+	drain()
+	teardown = func() {
+		drain()
+		psLog.EnableOutput()
+	}
+	return
+}
+
+func openVethDevice(devs ...*VethDevice) {
+	for _, dev := range devs {
+		_ = dev.Open()
+		dev.Up()
+	}
+}
+
+// pollVethDevice reads all the frames queued in the device.
+func pollVethDevice(t *testing.T, dev *VethDevice) {
+	for {
+		got := dev.Poll()
+		if got == psErr.NoDataToRead {
+			return
+		}
+		if got != psErr.OK {
+			t.Fatalf("VethDevice.Poll() = %v; want %v", got, psErr.OK)
+		}
+	}
+}
+
+func TestVethDevice_Transmit_1(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	dev1, dev2 := GenVethPair("net0", "net1", vethEthAddr1, vethEthAddr2)
+	openVethDevice(dev1, dev2)
+
+	payload := []byte{0x01, 0x02, 0x03, 0x04}
+	if got := dev1.Transmit(vethEthAddr2, payload, mw.EtIPV4); got != psErr.OK {
+		t.Errorf("VethDevice.Transmit() = %v; want %v", got, psErr.OK)
+	}
+
+	// the frame isn't delivered to the sender
+	pollVethDevice(t, dev1)
+	if len(mw.EthRxCh) != 0 {
+		t.Fatalf("len(mw.EthRxCh) = %d; want %d", len(mw.EthRxCh), 0)
+	}
+
+	pollVethDevice(t, dev2)
+	if len(mw.EthRxCh) != 1 {
+		t.Fatalf("len(mw.EthRxCh) = %d; want %d", len(mw.EthRxCh), 1)
+	}
+	msg := <-mw.EthRxCh
+	if msg.Dev != dev2 || msg.Type != mw.EtIPV4 || !bytes.Equal(msg.Content[:len(payload)], payload) {
+		t.Errorf("VethDevice.Poll() delivered unexpected message: %v", msg)
+	}
+}
+
+// Success when the frame is not addressed to the peer (the frame is discarded).
+func TestVethDevice_Transmit_2(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	dev1, dev2 := GenVethPair("net0", "net1", vethEthAddr1, vethEthAddr2)
+	openVethDevice(dev1, dev2)
+
+	_ = dev1.Transmit(vethEthAddr3, []byte{0x01, 0x02, 0x03, 0x04}, mw.EtIPV4)
+
+	pollVethDevice(t, dev2)
+	if len(mw.EthRxCh) != 0 {
+		t.Errorf("len(mw.EthRxCh) = %d; want %d", len(mw.EthRxCh), 0)
+	}
+}
+
+// Success when the peer is down (the frame is dropped).
+func TestVethDevice_Transmit_3(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	dev1, dev2 := GenVethPair("net0", "net1", vethEthAddr1, vethEthAddr2)
+	openVethDevice(dev1)
+
+	if got := dev1.Transmit(vethEthAddr2, []byte{0x01, 0x02, 0x03, 0x04}, mw.EtIPV4); got != psErr.OK {
+		t.Errorf("VethDevice.Transmit() = %v; want %v", got, psErr.OK)
+	}
+
+	openVethDevice(dev2)
+	pollVethDevice(t, dev2)
+	if len(mw.EthRxCh) != 0 {
+		t.Errorf("len(mw.EthRxCh) = %d; want %d", len(mw.EthRxCh), 0)
+	}
+}
+
+// Success when the frame queued in the peer isn't changed by the sender which reuses its buffer.
+func TestVethDevice_TransmitFrame(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	dev1, dev2 := GenVethPair("net0", "net1", vethEthAddr1, vethEthAddr2)
+	openVethDevice(dev1, dev2)
+
+	frame := make([]byte, mw.EthHdrLen+mw.EthPayloadLenMin)
+	copy(frame, vethEthAddr2[:])
+	want := make([]byte, len(frame))
+	copy(want, frame)
+	if got := dev1.TransmitFrame(frame); got != psErr.OK {
+		t.Errorf("VethDevice.TransmitFrame() = %v; want %v", got, psErr.OK)
+	}
+	for i := range frame {
+		frame[i] = 0xff
+	}
+
+	if got := dev2.queue.pop(); !bytes.Equal(got, want) {
+		t.Errorf("queued frame = %v; want %v", got, want)
+	}
+}
+
+func TestHub_AddPort(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	hub := GenHub()
+	dev1 := hub.AddPort("net0", vethEthAddr1)
+	dev2 := hub.AddPort("net1", vethEthAddr2)
+	dev3 := hub.AddPort("net2", vethEthAddr3)
+	openVethDevice(dev1, dev2, dev3)
+
+	// a broadcast frame is repeated to all the other ports
+	_ = dev1.Transmit(mw.EthBroadcast, []byte{0x01, 0x02, 0x03, 0x04}, mw.EtARP)
+	for _, dev := range []*VethDevice{dev1, dev2, dev3} {
+		pollVethDevice(t, dev)
+	}
+	if len(mw.EthRxCh) != 2 {
+		t.Fatalf("len(mw.EthRxCh) = %d; want %d", len(mw.EthRxCh), 2)
+	}
+	for _, want := range []*VethDevice{dev2, dev3} {
+		if msg := <-mw.EthRxCh; msg.Dev != want {
+			t.Errorf("EthMessage.Dev = %s; want %s", msg.Dev.Name(), want.Name())
+		}
+	}
+
+	// a unicast frame is accepted only by the destination
+	_ = dev1.Transmit(vethEthAddr3, []byte{0x01, 0x02, 0x03, 0x04}, mw.EtIPV4)
+	for _, dev := range []*VethDevice{dev1, dev2, dev3} {
+		pollVethDevice(t, dev)
+	}
+	if len(mw.EthRxCh) != 1 {
+		t.Fatalf("len(mw.EthRxCh) = %d; want %d", len(mw.EthRxCh), 1)
+	}
+	if msg := <-mw.EthRxCh; msg.Dev != dev3 {
+		t.Errorf("EthMessage.Dev = %s; want %s", msg.Dev.Name(), dev3.Name())
+	}
+}
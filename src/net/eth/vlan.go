@@ -0,0 +1,63 @@
+package eth
+
+import (
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	"github.com/42milez/ProtocolStack/src/mw"
+)
+
+// VlanDevice is a sub-device of an ethernet device which exchanges frames tagged with its VLAN ID. Frames are received
+// by the parent device and demultiplexed in mw.ReceiveFrame(), so the device itself has nothing to read. The device
+// shares the ethernet address with the parent.
+type VlanDevice struct {
+	mw.Device
+	Parent mw.IDevice
+	VID    uint16
+}
+
+func (p *VlanDevice) Open() error {
+	if p.Parent.Type() != mw.EthernetDevice {
+		return psErr.Error
+	}
+	return mw.AttachVlan(p.Parent, p.VID, p)
+}
+
+func (p *VlanDevice) Close() error {
+	return mw.DetachVlan(p.Parent, p.VID)
+}
+
+func (p *VlanDevice) Poll() error {
+	return psErr.NoDataToRead
+}
+
+// Transmit passes the payload tagged with the VLAN ID to the parent device.
+func (p *VlanDevice) Transmit(dst mw.EthAddr, payload []byte, typ mw.EthType) error {
+	if !p.Parent.IsUp() {
+		return psErr.DeviceNotOpened
+	}
+	return p.Parent.Transmit(dst, mw.TagPayload(p.VID, typ, payload), mw.EtVLAN)
+}
+
+func (p *VlanDevice) Addr() mw.EthAddr {
+	return p.Parent.Addr()
+}
+
+// SetPromisc makes the parent promiscuous as well while the device is promiscuous, since the parent filters the frames
+// of the device.
+func (p *VlanDevice) SetPromisc(on bool) error {
+	return p.CountPromisc(on, p.Parent.SetPromisc)
+}
+
+// JoinMulticast makes the parent join the address as well.
+func (p *VlanDevice) JoinMulticast(addr mw.EthAddr) error {
+	if err := p.Parent.JoinMulticast(addr); err != psErr.OK {
+		return err
+	}
+	return p.Device.JoinMulticast(addr)
+}
+
+func (p *VlanDevice) LeaveMulticast(addr mw.EthAddr) error {
+	if err := p.Device.LeaveMulticast(addr); err != psErr.OK {
+		return err
+	}
+	return p.Parent.LeaveMulticast(addr)
+}
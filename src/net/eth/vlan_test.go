@@ -0,0 +1,224 @@
+package eth
+
+import (
+	"bytes"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	"github.com/42milez/ProtocolStack/src/mw"
+	"testing"
+)
+
+func openVlanDevice(t *testing.T, devs ...*VlanDevice) (teardown func()) {
+	for _, dev := range devs {
+		if got := dev.Open(); got != psErr.OK {
+			t.Fatalf("VlanDevice.Open() = %v; want %v", got, psErr.OK)
+		}
+		dev.Up()
+	}
+	teardown = func() {
+		for _, dev := range devs {
+			_ = dev.Close()
+		}
+	}
+	return
+}
+
+// Success when a frame is delivered to the VLAN device which has the same VLAN ID.
+func TestVlanDevice_Transmit_1(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	dev1, dev2 := GenVethPair("net0", "net1", vethEthAddr1, vethEthAddr2)
+	openVethDevice(dev1, dev2)
+	vlan1 := GenVlanDevice("net0.10", dev1, 10)
+	vlan2 := GenVlanDevice("net1.10", dev2, 10)
+	vlan3 := GenVlanDevice("net1.20", dev2, 20)
+	defer openVlanDevice(t, vlan1, vlan2, vlan3)()
+
+	payload := []byte{0x01, 0x02, 0x03, 0x04}
+	if got := vlan1.Transmit(vethEthAddr2, payload, mw.EtIPV4); got != psErr.OK {
+		t.Errorf("VlanDevice.Transmit() = %v; want %v", got, psErr.OK)
+	}
+
+	pollVethDevice(t, dev2)
+	if len(mw.EthRxCh) != 1 {
+		t.Fatalf("len(mw.EthRxCh) = %d; want %d", len(mw.EthRxCh), 1)
+	}
+	msg := <-mw.EthRxCh
+	if msg.Dev != vlan2 {
+		t.Errorf("message was delivered to %s; want %s", msg.Dev.Name(), vlan2.Name())
+	}
+	if msg.Type != mw.EtIPV4 {
+		t.Errorf("message type = %s; want %s", msg.Type, mw.EtIPV4)
+	}
+	if !bytes.HasPrefix(msg.Content, payload) {
+		t.Errorf("message content = %v; want %v", msg.Content[:len(payload)], payload)
+	}
+}
+
+// Success when a frame of VLAN which isn't attached to the device is discarded.
+func TestVlanDevice_Transmit_2(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	dev1, dev2 := GenVethPair("net0", "net1", vethEthAddr1, vethEthAddr2)
+	openVethDevice(dev1, dev2)
+	vlan1 := GenVlanDevice("net0.10", dev1, 10)
+	vlan2 := GenVlanDevice("net1.20", dev2, 20)
+	defer openVlanDevice(t, vlan1, vlan2)()
+
+	if got := vlan1.Transmit(mw.EthBroadcast, []byte{0x01}, mw.EtARP); got != psErr.OK {
+		t.Errorf("VlanDevice.Transmit() = %v; want %v", got, psErr.OK)
+	}
+
+	pollVethDevice(t, dev2)
+	if len(mw.EthRxCh) != 0 {
+		t.Errorf("len(mw.EthRxCh) = %d; want %d", len(mw.EthRxCh), 0)
+	}
+}
+
+// Success when an untagged frame is delivered to the parent device.
+func TestVlanDevice_Transmit_3(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	dev1, dev2 := GenVethPair("net0", "net1", vethEthAddr1, vethEthAddr2)
+	openVethDevice(dev1, dev2)
+	vlan := GenVlanDevice("net1.10", dev2, 10)
+	defer openVlanDevice(t, vlan)()
+
+	if got := dev1.Transmit(vethEthAddr2, []byte{0x01}, mw.EtIPV4); got != psErr.OK {
+		t.Errorf("VethDevice.Transmit() = %v; want %v", got, psErr.OK)
+	}
+
+	pollVethDevice(t, dev2)
+	if len(mw.EthRxCh) != 1 {
+		t.Fatalf("len(mw.EthRxCh) = %d; want %d", len(mw.EthRxCh), 1)
+	}
+	if msg := <-mw.EthRxCh; msg.Dev != dev2 {
+		t.Errorf("message was delivered to %s; want %s", msg.Dev.Name(), dev2.Name())
+	}
+}
+
+// Fail when the parent device is down.
+func TestVlanDevice_Transmit_4(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	dev1, _ := GenVethPair("net0", "net1", vethEthAddr1, vethEthAddr2)
+	vlan := GenVlanDevice("net0.10", dev1, 10)
+	defer openVlanDevice(t, vlan)()
+
+	if got := vlan.Transmit(vethEthAddr2, []byte{0x01}, mw.EtIPV4); got != psErr.DeviceNotOpened {
+		t.Errorf("VlanDevice.Transmit() = %v; want %v", got, psErr.DeviceNotOpened)
+	}
+}
+
+// Success when a frame sent to the multicast address which the VLAN device has joined is received through the parent.
+func TestVlanDevice_JoinMulticast(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	dev1, dev2 := GenVethPair("net0", "net1", vethEthAddr1, vethEthAddr2)
+	openVethDevice(dev1, dev2)
+	vlan1 := GenVlanDevice("net0.10", dev1, 10)
+	vlan2 := GenVlanDevice("net1.10", dev2, 10)
+	defer openVlanDevice(t, vlan1, vlan2)()
+
+	group := mw.EthAddr{0x01, 0x00, 0x5e, 0x00, 0x00, 0x01}
+	if got := vlan2.JoinMulticast(group); got != psErr.OK {
+		t.Fatalf("VlanDevice.JoinMulticast() = %v; want %v", got, psErr.OK)
+	}
+	if got := mw.MulticastAddrs(dev2); len(got) != 1 {
+		t.Errorf("parent joined %v; want [%s]", got, group)
+	}
+
+	_ = vlan1.Transmit(group, []byte{0x01}, mw.EtIPV4)
+	pollVethDevice(t, dev2)
+	if len(mw.EthRxCh) != 1 {
+		t.Fatalf("len(mw.EthRxCh) = %d; want %d", len(mw.EthRxCh), 1)
+	}
+	if msg := <-mw.EthRxCh; msg.Dev != vlan2 {
+		t.Errorf("message was delivered to %s; want %s", msg.Dev.Name(), vlan2.Name())
+	}
+
+	_ = vlan2.LeaveMulticast(group)
+	if got := mw.MulticastAddrs(dev2); len(got) != 0 {
+		t.Errorf("parent joined %v; want []", got)
+	}
+}
+
+// Success when the parent stays promiscuous until none of its VLAN devices is promiscuous.
+func TestVlanDevice_SetPromisc(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	dev1, dev2 := GenVethPair("net0", "net1", vethEthAddr1, vethEthAddr2)
+	openVethDevice(dev1, dev2)
+	vlan1 := GenVlanDevice("net0.10", dev1, 10)
+	vlan2 := GenVlanDevice("net0.20", dev1, 20)
+	defer openVlanDevice(t, vlan1, vlan2)()
+
+	_ = vlan1.SetPromisc(true)
+	_ = vlan2.SetPromisc(true)
+	_ = vlan1.SetPromisc(false)
+	if dev1.Flag()&mw.PromiscFlag == 0 {
+		t.Errorf("%s isn't promiscuous", dev1.Name())
+	}
+
+	_ = vlan2.SetPromisc(false)
+	if dev1.Flag()&mw.PromiscFlag != 0 {
+		t.Errorf("%s is still promiscuous", dev1.Name())
+	}
+}
+
+// Fail when the VLAN ID is already used on the parent device.
+func TestVlanDevice_Open_1(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	dev1, _ := GenVethPair("net0", "net1", vethEthAddr1, vethEthAddr2)
+	vlan1 := GenVlanDevice("net0.10", dev1, 10)
+	vlan2 := GenVlanDevice("net0.10a", dev1, 10)
+	defer openVlanDevice(t, vlan1)()
+
+	if got := vlan2.Open(); got != psErr.Exist {
+		t.Errorf("VlanDevice.Open() = %v; want %v", got, psErr.Exist)
+	}
+}
+
+// Fail when the VLAN ID is out of range.
+func TestVlanDevice_Open_2(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	dev1, _ := GenVethPair("net0", "net1", vethEthAddr1, vethEthAddr2)
+	vlan := GenVlanDevice("net0.4095", dev1, 4095)
+
+	if got := vlan.Open(); got != psErr.Error {
+		t.Errorf("VlanDevice.Open() = %v; want %v", got, psErr.Error)
+	}
+}
+
+// Success when the VLAN ID can be used again after the device is closed.
+func TestVlanDevice_Close(t *testing.T) {
+	teardown := setupVethTest(t)
+	defer teardown()
+
+	dev1, _ := GenVethPair("net0", "net1", vethEthAddr1, vethEthAddr2)
+	vlan1 := GenVlanDevice("net0.10", dev1, 10)
+	vlan2 := GenVlanDevice("net0.10a", dev1, 10)
+
+	_ = vlan1.Open()
+	if got := vlan1.Close(); got != psErr.OK {
+		t.Errorf("VlanDevice.Close() = %v; want %v", got, psErr.OK)
+	}
+	defer openVlanDevice(t, vlan2)()
+}
+
+func TestVlanDevice_Addr(t *testing.T) {
+	dev1, _ := GenVethPair("net0", "net1", vethEthAddr1, vethEthAddr2)
+	vlan := GenVlanDevice("net0.10", dev1, 10)
+	if got := vlan.Addr(); got != vethEthAddr1 {
+		t.Errorf("VlanDevice.Addr() = %s; want %s", got, vethEthAddr1)
+	}
+}
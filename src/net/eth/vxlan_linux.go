@@ -0,0 +1,190 @@
+// +build amd64,linux
+
+package eth
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/42milez/ProtocolStack/src/pcap"
+	psSyscall "github.com/42milez/ProtocolStack/src/syscall"
+	"sync"
+	"syscall"
+)
+
+// Virtual eXtensible Local Area Network (VXLAN)
+// https://datatracker.ietf.org/doc/html/rfc7348
+
+const VxlanPort = 4789
+
+// VxlanMTU leaves room for outer IPv4 (20 bytes), UDP (8 bytes), VXLAN (8 bytes) and inner ethernet (14 bytes) headers
+// in an underlay of which MTU is 1500 bytes.
+const VxlanMTU = 1450
+const VxlanVniMax = 1<<24 - 1
+const vxlanHdrLen = 8
+const vxlanFlagVNI = 0x08
+
+// VxlanDevice exchanges ethernet frames encapsulated in VXLAN over a UDP socket of the host, so that stacks in
+// different processes or hosts can share a segment without TAP devices. A frame is sent to all the remote endpoints,
+// and the receivers discard frames which aren't addressed to them.
+type VxlanDevice struct {
+	mw.Device
+	VNI       uint32
+	LocalPort int
+	remotes   []*syscall.SockaddrInet4
+	remoteMtx sync.Mutex
+	rxBuf     []byte
+	epfd      int
+}
+
+// AddRemote adds an endpoint which frames are sent to.
+func (p *VxlanDevice) AddRemote(addr mw.IP, port int) error {
+	p.remoteMtx.Lock()
+	defer p.remoteMtx.Unlock()
+
+	sa := &syscall.SockaddrInet4{Port: port, Addr: addr.ToV4()}
+	for _, v := range p.remotes {
+		if v.Port == sa.Port && v.Addr == sa.Addr {
+			return psErr.Exist
+		}
+	}
+	p.remotes = append(p.remotes, sa)
+
+	return psErr.OK
+}
+
+// RemoveRemote removes an endpoint which frames are sent to.
+func (p *VxlanDevice) RemoveRemote(addr mw.IP, port int) error {
+	p.remoteMtx.Lock()
+	defer p.remoteMtx.Unlock()
+
+	v4 := addr.ToV4()
+	for i, v := range p.remotes {
+		if v.Port == port && v.Addr == v4 {
+			p.remotes = append(p.remotes[:i], p.remotes[i+1:]...)
+			return psErr.OK
+		}
+	}
+
+	return psErr.NotFound
+}
+
+func (p *VxlanDevice) Open() error {
+	var fd int
+	var epfd int
+	var err error
+
+	if p.VNI > VxlanVniMax {
+		psLog.E(fmt.Sprintf("invalid vni: %d", p.VNI))
+		return psErr.Error
+	}
+
+	if fd, err = psSyscall.Syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0); err != nil {
+		return psErr.CantCreateEndpoint
+	}
+
+	if err = psSyscall.Syscall.Bind(fd, &syscall.SockaddrInet4{Port: p.LocalPort}); err != nil {
+		_ = psSyscall.Syscall.Close(fd)
+		return psErr.CantCreateEndpoint
+	}
+
+	if epfd, err = createEpoll(fd); err != psErr.OK {
+		_ = psSyscall.Syscall.Close(fd)
+		return err
+	}
+
+	p.Priv_.FD = fd
+	p.epfd = epfd
+	p.rxBuf = make([]byte, vxlanHdrLen+mw.EthFrameLenMax+mw.VlanTagLen)
+
+	return psErr.OK
+}
+
+func (p *VxlanDevice) Close() error {
+	if err := psSyscall.Syscall.Close(p.epfd); err != nil {
+		return psErr.SyscallError
+	}
+	p.epfd = -1
+	if err := psSyscall.Syscall.Close(p.Priv_.FD); err != nil {
+		return psErr.SyscallError
+	}
+	p.Priv_.FD = -1
+	return psErr.OK
+}
+
+// Poll reads a VXLAN packet and decapsulates the frame. Packets of other VNIs are discarded.
+func (p *VxlanDevice) Poll() error {
+	var events [maxEpollEvents]syscall.EpollEvent
+	nEvents, err := psSyscall.Syscall.EpollWait(p.epfd, events[:], epollTimeout)
+	if err != nil {
+		if !errors.Is(err, syscall.EINTR) {
+			return psErr.SyscallError
+		}
+		return psErr.Interrupted
+	}
+
+	if nEvents == 0 {
+		return psErr.NoDataToRead
+	}
+
+	n, _, err := psSyscall.Syscall.Recvfrom(p.Priv_.FD, p.rxBuf, 0)
+	if err != nil {
+		return psErr.Error
+	}
+	packet := p.rxBuf[:n]
+
+	if n < vxlanHdrLen+mw.EthHdrLen || packet[0]&vxlanFlagVNI == 0 {
+		psLog.D("invalid vxlan packet was discarded", "device: "+p.Name_)
+		return psErr.OK
+	}
+	if vni := binary.BigEndian.Uint32(packet[4:8]) >> 8; vni != p.VNI {
+		psLog.D(fmt.Sprintf("vxlan packet of other vni was discarded: %d", vni), "device: "+p.Name_)
+		return psErr.OK
+	}
+	frame := packet[vxlanHdrLen:]
+
+	mw.CaptureFrame(p, pcap.DirectionInbound, frame)
+
+	msg, err := mw.ReceiveFrame(frame, p)
+	if err != psErr.OK {
+		if err != psErr.NoDataToRead {
+			return psErr.Error
+		}
+		return psErr.OK
+	}
+	mw.EthRxCh <- msg
+
+	return psErr.OK
+}
+
+func (p *VxlanDevice) Transmit(dst mw.EthAddr, payload []byte, typ mw.EthType) error {
+	frame, err := mw.EncodeFrame(dst, p.Addr_, typ, payload)
+	if err != psErr.OK {
+		return psErr.Error
+	}
+	return p.TransmitFrame(frame)
+}
+
+// TransmitFrame encapsulates the frame and sends it to all the remote endpoints.
+func (p *VxlanDevice) TransmitFrame(frame []byte) error {
+	packet := make([]byte, vxlanHdrLen+len(frame))
+	packet[0] = vxlanFlagVNI
+	binary.BigEndian.PutUint32(packet[4:8], p.VNI<<8)
+	copy(packet[vxlanHdrLen:], frame)
+
+	p.remoteMtx.Lock()
+	defer p.remoteMtx.Unlock()
+
+	for _, remote := range p.remotes {
+		if err := psSyscall.Syscall.Sendto(p.Priv_.FD, packet, 0, remote); err != nil {
+			psLog.E(fmt.Sprintf("can't send vxlan packet: %s", err), "device: "+p.Name_)
+			return psErr.SyscallError
+		}
+	}
+	mw.CaptureFrame(p, pcap.DirectionOutbound, frame)
+
+	return psErr.OK
+}
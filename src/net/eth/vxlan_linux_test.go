@@ -0,0 +1,217 @@
+package eth
+
+import (
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/mw"
+	psSyscall "github.com/42milez/ProtocolStack/src/syscall"
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	"syscall"
+	"testing"
+)
+
+var vxlanEthAddr = mw.EthAddr{11, 12, 13, 14, 15, 16}
+
+func setupVxlanLinuxTest(t *testing.T) (ctrl *gomock.Controller, teardown func()) {
+	psLog.DisableOutput()
+	ctrl = gomock.NewController(t)
+	drain := func() {
+		for len(mw.EthRxCh) != 0 {
+			<-mw.EthRxCh
+		}
+	}
+	drain()
+	teardown = func() {
+		ctrl.Finish()
+		drain()
+		psLog.EnableOutput()
+	}
+	return
+}
+
+func genVxlanPacket(vni uint32, dst mw.EthAddr) []byte {
+	frame, _ := mw.EncodeFrame(dst, mw.EthAddr{21, 22, 23, 24, 25, 26}, mw.EtIPV4, []byte{0x01, 0x02})
+	hdr := []byte{vxlanFlagVNI, 0x00, 0x00, 0x00, byte(vni >> 16), byte(vni >> 8), byte(vni), 0x00}
+	return append(hdr, frame...)
+}
+
+func TestVxlanDevice_Open_1(t *testing.T) {
+	ctrl, teardown := setupVxlanLinuxTest(t)
+	defer teardown()
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0).Return(3, nil)
+	m.EXPECT().Bind(3, &syscall.SockaddrInet4{Port: VxlanPort}).Return(nil)
+	m.EXPECT().EpollCreate1(any).Return(4, nil)
+	m.EXPECT().EpollCtl(4, syscall.EPOLL_CTL_ADD, 3, any).Return(nil)
+	psSyscall.Syscall = m
+
+	dev := GenVxlanDevice("net0", 100, VxlanPort, vxlanEthAddr)
+
+	got := dev.Open()
+	if got != psErr.OK {
+		t.Errorf("VxlanDevice.Open() = %v; want %v", got, psErr.OK)
+	}
+	if dev.Priv_.FD != 3 || dev.epfd != 4 {
+		t.Errorf("(fd, epfd) = (%d, %d); want (%d, %d)", dev.Priv_.FD, dev.epfd, 3, 4)
+	}
+}
+
+// Fail when Bind() returns error.
+func TestVxlanDevice_Open_2(t *testing.T) {
+	ctrl, teardown := setupVxlanLinuxTest(t)
+	defer teardown()
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().Socket(any, any, any).Return(3, nil)
+	m.EXPECT().Bind(3, any).Return(syscall.EADDRINUSE)
+	m.EXPECT().Close(3).Return(nil)
+	psSyscall.Syscall = m
+
+	dev := GenVxlanDevice("net0", 100, VxlanPort, vxlanEthAddr)
+
+	got := dev.Open()
+	if got != psErr.CantCreateEndpoint {
+		t.Errorf("VxlanDevice.Open() = %v; want %v", got, psErr.CantCreateEndpoint)
+	}
+}
+
+// Fail when VNI is out of range.
+func TestVxlanDevice_Open_3(t *testing.T) {
+	ctrl, teardown := setupVxlanLinuxTest(t)
+	defer teardown()
+
+	psSyscall.Syscall = psSyscall.NewMockISyscall(ctrl)
+
+	dev := GenVxlanDevice("net0", VxlanVniMax+1, VxlanPort, vxlanEthAddr)
+
+	got := dev.Open()
+	if got != psErr.Error {
+		t.Errorf("VxlanDevice.Open() = %v; want %v", got, psErr.Error)
+	}
+}
+
+// Success when a frame encapsulated with the same VNI arrives.
+func TestVxlanDevice_Poll_1(t *testing.T) {
+	ctrl, teardown := setupVxlanLinuxTest(t)
+	defer teardown()
+
+	packet := genVxlanPacket(100, vxlanEthAddr)
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().EpollWait(any, any, 0).Return(1, nil)
+	m.EXPECT().Recvfrom(3, any, 0).DoAndReturn(func(fd int, p []byte, flags int) (int, syscall.Sockaddr, error) {
+		return copy(p, packet), nil, nil
+	})
+	psSyscall.Syscall = m
+
+	dev := GenVxlanDevice("net0", 100, VxlanPort, vxlanEthAddr)
+	dev.Priv_.FD = 3
+	dev.rxBuf = make([]byte, 1600)
+
+	if got := dev.Poll(); got != psErr.OK {
+		t.Errorf("VxlanDevice.Poll() = %v; want %v", got, psErr.OK)
+	}
+	if len(mw.EthRxCh) != 1 {
+		t.Fatalf("len(mw.EthRxCh) = %d; want %d", len(mw.EthRxCh), 1)
+	}
+	if msg := <-mw.EthRxCh; msg.Dev != dev || msg.Type != mw.EtIPV4 {
+		t.Errorf("VxlanDevice.Poll() delivered unexpected message: %v", msg)
+	}
+}
+
+// Success when a frame encapsulated with other VNI is discarded.
+func TestVxlanDevice_Poll_2(t *testing.T) {
+	ctrl, teardown := setupVxlanLinuxTest(t)
+	defer teardown()
+
+	packet := genVxlanPacket(200, vxlanEthAddr)
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().EpollWait(any, any, 0).Return(1, nil)
+	m.EXPECT().Recvfrom(3, any, 0).DoAndReturn(func(fd int, p []byte, flags int) (int, syscall.Sockaddr, error) {
+		return copy(p, packet), nil, nil
+	})
+	psSyscall.Syscall = m
+
+	dev := GenVxlanDevice("net0", 100, VxlanPort, vxlanEthAddr)
+	dev.Priv_.FD = 3
+	dev.rxBuf = make([]byte, 1600)
+
+	if got := dev.Poll(); got != psErr.OK {
+		t.Errorf("VxlanDevice.Poll() = %v; want %v", got, psErr.OK)
+	}
+	if len(mw.EthRxCh) != 0 {
+		t.Errorf("len(mw.EthRxCh) = %d; want %d", len(mw.EthRxCh), 0)
+	}
+}
+
+// Success when no event occurs.
+func TestVxlanDevice_Poll_3(t *testing.T) {
+	ctrl, teardown := setupVxlanLinuxTest(t)
+	defer teardown()
+
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().EpollWait(any, any, 0).Return(0, nil)
+	psSyscall.Syscall = m
+
+	dev := GenVxlanDevice("net0", 100, VxlanPort, vxlanEthAddr)
+
+	if got := dev.Poll(); got != psErr.NoDataToRead {
+		t.Errorf("VxlanDevice.Poll() = %v; want %v", got, psErr.NoDataToRead)
+	}
+}
+
+// Success when an encapsulated frame is sent to all the remote endpoints.
+func TestVxlanDevice_Transmit(t *testing.T) {
+	ctrl, teardown := setupVxlanLinuxTest(t)
+	defer teardown()
+
+	dst := mw.EthAddr{21, 22, 23, 24, 25, 26}
+	frame, _ := mw.EncodeFrame(dst, vxlanEthAddr, mw.EtIPV4, []byte{0x01, 0x02})
+	want := append([]byte{vxlanFlagVNI, 0x00, 0x00, 0x00, 0x00, 0x00, 0x64, 0x00}, frame...)
+
+	var sent []*syscall.SockaddrInet4
+	m := psSyscall.NewMockISyscall(ctrl)
+	m.EXPECT().Sendto(3, any, 0, any).DoAndReturn(func(fd int, p []byte, flags int, to syscall.Sockaddr) error {
+		if d := cmp.Diff(p, want); d != "" {
+			t.Errorf("Sendto() differs: (-got +want)\n%s", d)
+		}
+		sent = append(sent, to.(*syscall.SockaddrInet4))
+		return nil
+	}).Times(2)
+	psSyscall.Syscall = m
+
+	dev := GenVxlanDevice("net0", 100, VxlanPort, vxlanEthAddr)
+	dev.Priv_.FD = 3
+	_ = dev.AddRemote(mw.ParseIP("192.0.2.1"), VxlanPort)
+	_ = dev.AddRemote(mw.ParseIP("192.0.2.2"), VxlanPort)
+
+	if got := dev.Transmit(dst, []byte{0x01, 0x02}, mw.EtIPV4); got != psErr.OK {
+		t.Errorf("VxlanDevice.Transmit() = %v; want %v", got, psErr.OK)
+	}
+	if len(sent) != 2 || sent[0].Addr != [4]byte{192, 0, 2, 1} || sent[1].Addr != [4]byte{192, 0, 2, 2} {
+		t.Errorf("packets were sent to unexpected endpoints: %v", sent)
+	}
+}
+
+// Fail when the remote endpoint already exists.
+func TestVxlanDevice_AddRemote(t *testing.T) {
+	dev := GenVxlanDevice("net0", 100, VxlanPort, vxlanEthAddr)
+	_ = dev.AddRemote(mw.ParseIP("192.0.2.1"), VxlanPort)
+	if got := dev.AddRemote(mw.ParseIP("192.0.2.1"), VxlanPort); got != psErr.Exist {
+		t.Errorf("VxlanDevice.AddRemote() = %v; want %v", got, psErr.Exist)
+	}
+}
+
+func TestVxlanDevice_RemoveRemote(t *testing.T) {
+	dev := GenVxlanDevice("net0", 100, VxlanPort, vxlanEthAddr)
+	_ = dev.AddRemote(mw.ParseIP("192.0.2.1"), VxlanPort)
+	if got := dev.RemoveRemote(mw.ParseIP("192.0.2.1"), VxlanPort); got != psErr.OK {
+		t.Errorf("VxlanDevice.RemoveRemote() = %v; want %v", got, psErr.OK)
+	}
+	if got := dev.RemoveRemote(mw.ParseIP("192.0.2.1"), VxlanPort); got != psErr.NotFound {
+		t.Errorf("VxlanDevice.RemoveRemote() = %v; want %v", got, psErr.NotFound)
+	}
+}
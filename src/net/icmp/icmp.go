@@ -16,10 +16,20 @@ import (
 
 const Echo = 0x08
 const EchoReply = 0x00
+const DestUnreachable = 0x03
 const HdrLen = 8 // byte
+const ipHdrLenMin = 20
 const replyQueueSize = 5
+const unreachableQueueSize = 5
 const xChBufSize = 5
 
+// Codes of Destination Unreachable
+// https://www.iana.org/assignments/icmp-parameters/icmp-parameters.xhtml#icmp-parameters-codes-3
+const (
+	NetUnreachable  = 0x00
+	HostUnreachable = 0x01
+)
+
 var rcvMonCh chan *worker.Message
 var rcvSigCh chan *worker.Message
 var sndMonCh chan *worker.Message
@@ -30,6 +40,9 @@ var senderID uint32
 
 var ReplyQueue chan *Reply
 
+// UnreachableQueue receives the destinations which packets couldn't reach. It's dropped when the queue is full.
+var UnreachableQueue chan *Unreachable
+
 // ICMP Type Numbers
 // https://www.iana.org/assignments/icmp-parameters/icmp-parameters.xhtml#icmp-parameters-types
 
@@ -79,6 +92,15 @@ type Reply struct {
 	Seq uint16
 }
 
+// Unreachable is a destination which a packet couldn't reach. ID and Seq are those of an echo request.
+type Unreachable struct {
+	Code     uint8
+	Dst      mw.V4Addr
+	ProtoNum mw.ProtocolNumber
+	ID       uint16
+	Seq      uint16
+}
+
 type Hdr struct {
 	Type     uint8
 	Code     uint8
@@ -127,6 +149,8 @@ func Receive(packet []byte, dst [mw.V4AddrLen]byte, src [mw.V4AddrLen]byte, dev
 			ID:  uint16((hdr.Content & 0xffff0000) >> 16),
 			Seq: uint16(hdr.Content & 0x0000ffff),
 		}
+	case DestUnreachable:
+		unreachable(hdr.Code, packet[HdrLen:])
 	default:
 		psLog.E(fmt.Sprintf("unsupported icmp type: %d", hdr.Type))
 		return psErr.Error
@@ -167,6 +191,66 @@ func Send(typ uint8, code uint8, content uint32, data []byte, src mw.IP, dst mw.
 	return psErr.OK
 }
 
+// ReportUnreachable reports that the IP packet which the stack sent couldn't reach its destination. The message is
+// delivered to the stack itself since the packet is originated by the stack.
+func ReportUnreachable(code uint8, packet []byte, iface *mw.Iface) error {
+	if len(packet) < ipHdrLenMin {
+		return psErr.InvalidPacketLength
+	}
+	// the message carries the header and the first 8 bytes of the data of the original datagram
+	n := int(packet[0]&0x0f)<<2 + 8
+	if n > len(packet) {
+		n = len(packet)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.BigEndian, &Hdr{Type: DestUnreachable, Code: code}); err != nil {
+		return psErr.WriteToBufError
+	}
+	buf.Write(packet[:n])
+
+	msg := buf.Bytes()
+	csum := mw.Checksum(msg, 0)
+	msg[2] = uint8((csum & 0xff00) >> 8)
+	msg[3] = uint8(csum & 0x00ff)
+
+	var dst [mw.V4AddrLen]byte
+	copy(dst[:], packet[12:16])
+	mw.IcmpRxCh <- &mw.IcmpRxMessage{
+		Packet: msg,
+		Dst:    dst,
+		Src:    iface.Unicast.ToV4(),
+		Dev:    iface.Dev,
+	}
+
+	return psErr.OK
+}
+
+// unreachable queues the destination of the original datagram which the destination unreachable message carries.
+func unreachable(code uint8, data []byte) {
+	if len(data) < ipHdrLenMin {
+		psLog.E(fmt.Sprintf("original datagram is too short: %d bytes", len(data)))
+		return
+	}
+
+	u := &Unreachable{
+		Code:     code,
+		ProtoNum: mw.ProtocolNumber(data[9]),
+	}
+	copy(u.Dst[:], data[16:20])
+	if ihl := int(data[0]&0x0f) << 2; u.ProtoNum == mw.PnICMP && len(data) >= ihl+HdrLen && data[ihl] == Echo {
+		u.ID = binary.BigEndian.Uint16(data[ihl+4:])
+		u.Seq = binary.BigEndian.Uint16(data[ihl+6:])
+	}
+
+	psLog.I(fmt.Sprintf("destination unreachable: %s (code: %d)", u.Dst, code))
+
+	select {
+	case UnreachableQueue <- u:
+	default:
+	}
+}
+
 func ReadHeader(buf *bytes.Buffer) (hdr *Hdr, err error) {
 	hdr = &Hdr{}
 	err = binary.Read(buf, binary.BigEndian, hdr)
@@ -201,7 +285,7 @@ func dump(packet []byte) (ret []string) {
 	if err := binary.Read(buf, psBinary.Endian, &hdr); err != nil {
 		return nil
 	}
-	data := buf.Bytes()[HdrLen:]
+	data := packet[HdrLen:]
 
 	ret = append(ret, fmt.Sprintf("type:     %s (%d)", types[hdr.Type], hdr.Type))
 	ret = append(ret, fmt.Sprintf("code:     %d", hdr.Code))
@@ -297,4 +381,5 @@ func init() {
 	senderID = monitor.Register("ICMP Sender", sndMonCh, sndSigCh)
 
 	ReplyQueue = make(chan *Reply, replyQueueSize)
+	UnreachableQueue = make(chan *Unreachable, unreachableQueueSize)
 }
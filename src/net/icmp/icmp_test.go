@@ -0,0 +1,128 @@
+package icmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/google/go-cmp/cmp"
+	"testing"
+)
+
+// Success when the data following the header is dumped.
+func TestDump(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want string
+	}{
+		{nil, "data:     -"},
+		{[]byte{0x01, 0x02, 0x03}, "data:     01 02 03 "},
+		{[]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09}, "data:     01 02 03 04 05 06 07 08 09 "},
+	}
+	for _, v := range tests {
+		buf := new(bytes.Buffer)
+		_ = binary.Write(buf, binary.BigEndian, &Hdr{Type: Echo, Content: 0x00010002})
+		buf.Write(v.data)
+
+		got := dump(buf.Bytes())
+		if len(got) == 0 || got[len(got)-1] != v.want {
+			t.Errorf("dump(%v) = %q; want the last line %q", v.data, got, v.want)
+		}
+	}
+}
+
+func TestReportUnreachable(t *testing.T) {
+	psLog.DisableOutput()
+	defer psLog.EnableOutput()
+
+	iface := &mw.Iface{Family: mw.V4AddrFamily, Unicast: mw.IP{192, 0, 2, 1}}
+	tests := []struct {
+		packet []byte
+		want   error
+		quoted int // bytes of the original datagram which the message carries
+	}{
+		// Fail when the packet is shorter than the minimum IP header.
+		{make([]byte, 19), psErr.InvalidPacketLength, 0},
+		// Success when the header and the first 8 bytes of the data are carried.
+		{genTestDatagram(5, mw.PnUDP, make([]byte, 12)), psErr.OK, 28},
+		// Success when the header has options.
+		{genTestDatagram(6, mw.PnUDP, make([]byte, 12)), psErr.OK, 32},
+		// Success when the data is shorter than 8 bytes.
+		{genTestDatagram(5, mw.PnUDP, make([]byte, 3)), psErr.OK, 23},
+	}
+	for i, v := range tests {
+		if got := ReportUnreachable(HostUnreachable, v.packet, iface); got != v.want {
+			t.Errorf("ReportUnreachable() = %s; want %s (%d)", got, v.want, i)
+			continue
+		}
+		if v.want != psErr.OK {
+			if len(mw.IcmpRxCh) != 0 {
+				t.Errorf("message was sent for invalid packet (%d)", i)
+			}
+			continue
+		}
+
+		msg := <-mw.IcmpRxCh
+		if d := cmp.Diff(msg.Packet[HdrLen:], v.packet[:v.quoted]); d != "" {
+			t.Errorf("quoted datagram differs: (-got +want) (%d)\n%s", i, d)
+		}
+		if msg.Packet[0] != DestUnreachable || msg.Packet[1] != HostUnreachable || mw.Checksum(msg.Packet, 0) != 0 {
+			t.Errorf("invalid header: % x (%d)", msg.Packet[:HdrLen], i)
+		}
+		if want := (mw.V4Addr{198, 51, 100, 1}); msg.Dst != want {
+			t.Errorf("Dst = %s; want %s (%d)", mw.V4Addr(msg.Dst), want, i)
+		}
+	}
+}
+
+func TestUnreachable(t *testing.T) {
+	psLog.DisableOutput()
+	defer psLog.EnableOutput()
+
+	echo := new(bytes.Buffer)
+	_ = binary.Write(echo, binary.BigEndian, &Hdr{Type: Echo, Content: 0x00010002})
+	dst := mw.V4Addr{203, 0, 113, 1}
+
+	tests := []struct {
+		data []byte
+		want *Unreachable
+	}{
+		// Success when the ID and the sequence number of the echo request are taken.
+		{
+			genTestDatagram(5, mw.PnICMP, echo.Bytes()),
+			&Unreachable{Code: HostUnreachable, Dst: dst, ProtoNum: mw.PnICMP, ID: 1, Seq: 2},
+		},
+		// Success when the datagram isn't an echo request.
+		{genTestDatagram(5, mw.PnTCP, echo.Bytes()), &Unreachable{Code: HostUnreachable, Dst: dst, ProtoNum: mw.PnTCP}},
+		// Success when the echo request is cut off after the header which has options.
+		{
+			genTestDatagram(6, mw.PnICMP, echo.Bytes()[:4]),
+			&Unreachable{Code: HostUnreachable, Dst: dst, ProtoNum: mw.PnICMP},
+		},
+		// Fail when the quoted header is truncated.
+		{genTestDatagram(5, mw.PnICMP, nil)[:19], nil},
+	}
+	for i, v := range tests {
+		unreachable(HostUnreachable, v.data)
+
+		var got *Unreachable
+		select {
+		case got = <-UnreachableQueue:
+		default:
+		}
+		if d := cmp.Diff(got, v.want); d != "" {
+			t.Errorf("unreachable() differs: (-got +want) (%d)\n%s", i, d)
+		}
+	}
+}
+
+// genTestDatagram generates an IP datagram sent from 198.51.100.1 to 203.0.113.1 of which header is ihl words long.
+func genTestDatagram(ihl int, protoNum mw.ProtocolNumber, data []byte) []byte {
+	packet := make([]byte, ihl<<2, ihl<<2+len(data))
+	packet[0] = 0x40 | uint8(ihl)
+	packet[9] = uint8(protoNum)
+	copy(packet[12:16], []byte{198, 51, 100, 1})
+	copy(packet[16:20], []byte{203, 0, 113, 1})
+	return append(packet, data...)
+}
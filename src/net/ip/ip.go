@@ -11,13 +11,15 @@ import (
 	"github.com/42milez/ProtocolStack/src/mw"
 	"github.com/42milez/ProtocolStack/src/net"
 	"github.com/42milez/ProtocolStack/src/net/arp"
+	"github.com/42milez/ProtocolStack/src/net/icmp"
 	"github.com/42milez/ProtocolStack/src/repo"
 	"github.com/42milez/ProtocolStack/src/worker"
 	"sync"
 )
 
-const HdrLenMax = 60 // bytes
-const HdrLenMin = 20 // bytes
+const HdrLenMax = 60       // bytes
+const HdrLenMin = 20       // bytes
+const TotalLenMax = 0xffff // bytes
 const xChBufSize = 5
 const ipv4 = 4
 
@@ -68,17 +70,25 @@ func Receive(packet []byte, dev mw.IDevice) error {
 		return psErr.InvalidPacketLength
 	}
 
-	if totalLen := int(hdr.TotalLen); packetLen < totalLen {
+	totalLen := int(hdr.TotalLen)
+	if packetLen < totalLen {
 		psLog.E(fmt.Sprintf("ip packet length is too short: Total Length = %d, Actual Length = %d", totalLen, packetLen))
 		return psErr.InvalidPacketLength
 	}
+	if totalLen < hdrLen {
+		psLog.E(fmt.Sprintf("ip total length is too short: Total Length = %d, ihl = %d", totalLen, hdrLen))
+		return psErr.InvalidPacketLength
+	}
+	// remove the padding of the ethernet frame
+	packet = packet[:totalLen]
 
 	if hdr.TTL == 0 {
 		psLog.E("ttl expired")
 		return psErr.TtlExpired
 	}
 
-	if mw.Checksum(packet[:hdrLen], 0) != 0 {
+	// the checksum has been verified by the device
+	if !mw.OffloadOf(dev).Has(mw.RxCsumOffload) && mw.Checksum(packet[:hdrLen], 0) != 0 {
 		psLog.E("checksum mismatch (ip)")
 		return psErr.ChecksumMismatch
 	}
@@ -136,12 +146,28 @@ func Send(protoNum mw.ProtocolNumber, data []byte, src mw.IP, dst mw.IP) error {
 		return psErr.RouteNotFound
 	}
 
-	if packetLen := HdrLenMin + len(data); int(iface.Dev.MTU()) < packetLen {
+	// use the address of the interface when the source address is not specified
+	if src.Equal(mw.V4Any) {
+		src = iface.Unicast
+	}
+
+	// the address which is still probed isn't used (RFC 5227)
+	if src.Equal(iface.Unicast) && arp.Tentative(iface) {
+		psLog.W(fmt.Sprintf("address is tentative: %s", src))
+		return psErr.AddressTentative
+	}
+
+	offload := mw.OffloadOf(iface.Dev)
+
+	// a TCP segment larger than the MTU is segmented by the device
+	if packetLen := HdrLenMin + len(data); packetLen > TotalLenMax ||
+		(int(iface.Dev.MTU()) < packetLen && !(protoNum == mw.PnTCP && offload.Has(mw.TsoOffload))) {
 		psLog.E(fmt.Sprintf("ip packet length is too long: %d", packetLen))
 		return psErr.PacketTooLong
 	}
 
-	packet := createPacket(protoNum, src, dst, data)
+	// the checksum is filled by the device
+	packet := createPacket(protoNum, src, dst, data, !offload.Has(mw.TxCsumOffload))
 	if packet == nil {
 		psLog.E("can't create IP packet")
 		return psErr.Error
@@ -149,9 +175,19 @@ func Send(protoNum mw.ProtocolNumber, data []byte, src mw.IP, dst mw.IP) error {
 
 	psLog.D("outgoing ip packet", dump(packet)...)
 
-	// get eth address from ip address
+	// get eth address from ip address (TUN device sends the packet without ethernet header)
 	var ethAddr mw.EthAddr
 	if ethAddr, err = lookupEthAddr(iface, nextHop); err != psErr.OK {
+		switch err {
+		case psErr.ArpIncomplete:
+			// the packet is sent when the address is resolved
+			arp.Hold(iface, nextHop, packet)
+			return psErr.OK
+		case psErr.HostUnreachable:
+			psLog.E(fmt.Sprintf("host unreachable: %s", nextHop))
+			hostUnreachable(iface, packet)
+			return psErr.HostUnreachable
+		}
 		psLog.E(fmt.Sprintf("ethernet address was not found: %s", err))
 		return psErr.NeedRetry
 	}
@@ -180,7 +216,7 @@ func Stop() {
 	sndSigCh <- msg
 }
 
-func createPacket(protoNum mw.ProtocolNumber, src mw.IP, dst mw.IP, data []byte) []byte {
+func createPacket(protoNum mw.ProtocolNumber, src mw.IP, dst mw.IP, data []byte, fillCsum bool) []byte {
 	hdr := mw.IpHdr{}
 	hdr.VHL = uint8(ipv4<<4) | uint8(HdrLenMin/4)
 	hdr.TotalLen = uint16(HdrLenMin + len(data))
@@ -199,6 +235,10 @@ func createPacket(protoNum mw.ProtocolNumber, src mw.IP, dst mw.IP, data []byte)
 	}
 	packet := buf.Bytes()
 
+	if !fillCsum {
+		return packet
+	}
+
 	hdrLen := (hdr.VHL & 0x0f) << 2
 	csum := mw.Checksum(packet[:hdrLen], 0)
 	packet[10] = uint8((csum & 0xff00) >> 8)
@@ -305,21 +345,48 @@ func dump(packet []byte) (ret []string) {
 	return
 }
 
+// lookupEthAddr returns the ethernet address of the next hop. The address is resolved with ARP if the device needs it.
+// Devices which don't use ethernet (TUN device) don't need the address.
 func lookupEthAddr(iface *mw.Iface, nextHop mw.IP) (mw.EthAddr, error) {
 	var addr mw.EthAddr
+	if iface.Dev.Type() == mw.TunDevice {
+		return addr, psErr.OK
+	}
 	if iface.Dev.Flag()&mw.NeedArpFlag != 0 {
 		if nextHop.Equal(iface.Broadcast) || nextHop.Equal(mw.V4Broadcast) {
 			addr = mw.EthBroadcast
 		} else {
-			var status arp.Status
-			if addr, status = arp.Resolver.Resolve(iface, nextHop); status != arp.Complete {
+			switch addr, status := arp.Resolver.Resolve(iface, nextHop); status {
+			case arp.Complete:
+				return addr, psErr.OK
+			case arp.Incomplete:
 				return mw.EthAddr{}, psErr.ArpIncomplete
+			case arp.Failed:
+				return mw.EthAddr{}, psErr.HostUnreachable
+			default:
+				return mw.EthAddr{}, psErr.Error
 			}
 		}
 	}
 	return addr, psErr.OK
 }
 
+// confirmNeighbor confirms the neighbor which the packets to the destination are sent to.
+func confirmNeighbor(dst mw.IP) {
+	iface, nextHop, err := lookupRoute(dst, mw.V4Any)
+	if err != psErr.OK || iface.Dev.Flag()&mw.NeedArpFlag == 0 {
+		return
+	}
+	arp.Confirm(iface, nextHop)
+}
+
+// hostUnreachable reports the packet of which next hop couldn't be resolved.
+func hostUnreachable(iface *mw.Iface, packet []byte) {
+	if err := icmp.ReportUnreachable(icmp.HostUnreachable, packet, iface); err != psErr.OK {
+		psLog.E(fmt.Sprintf("can't report unreachable destination: %s", err))
+	}
+}
+
 func lookupRoute(dst mw.IP, src mw.IP) (*mw.Iface, mw.IP, error) {
 	var iface *mw.Iface
 	var nextHop mw.IP
@@ -379,7 +446,8 @@ func receiver(wg *sync.WaitGroup) {
 				return
 			}
 		case msg := <-mw.IpRxCh:
-			if err := Receive(msg.Content, msg.Dev); err != psErr.OK {
+			// the device keeps receiving packets after its address is given up
+			if err := Receive(msg.Content, msg.Dev); err != psErr.OK && err != psErr.InterfaceNotFound {
 				return
 			}
 		}
@@ -411,6 +479,8 @@ func sender(wg *sync.WaitGroup) {
 			switch Send(msg.ProtoNum, msg.Packet, mw.V4FromByte(msg.Src), mw.V4FromByte(msg.Dst)) {
 			case psErr.OK:
 			case psErr.RouteNotFound:
+			case psErr.HostUnreachable:
+			case psErr.AddressTentative:
 			case psErr.NeedRetry:
 				switch msg.ProtoNum {
 				case mw.PnICMP:
@@ -441,4 +511,9 @@ func init() {
 	senderID = monitor.Register("IP Sender", sndMonCh, sndSigCh)
 
 	id = &PacketID{}
+
+	mw.SetIpReceiver(Receive)
+
+	arp.SetUnreachableHandler(hostUnreachable)
+	mw.SetNeighborConfirmer(confirmNeighbor)
 }
@@ -150,14 +150,76 @@ func TestReceive_2(t *testing.T) {
 	}
 }
 
+// Success when the checksum isn't verified since the device has verified it.
+func TestReceive_3(t *testing.T) {
+	_, teardown := setupIpTest(t)
+	defer teardown()
+
+	dev := createTapDevice()
+	dev.VnetHdr = true
+
+	packet := createIpPacket()
+	packet[10] = 0x12
+	packet[11] = 0x34
+	// the packet passes the checksum and reaches the lookup of the interface
+	want := psErr.InterfaceNotFound
+	got := Receive(packet, dev)
+	if got != want {
+		t.Errorf("Receive() = %s; want %s", got, want)
+	}
+}
+
+// Success when the padding of the ethernet frame is removed from the packet.
+func TestReceive_4(t *testing.T) {
+	_, teardown := setupIpTest(t)
+	defer teardown()
+
+	dev := createTapDevice()
+	_ = repo.IfaceRepo.Register(createIface(), dev)
+	for len(mw.IcmpRxCh) != 0 {
+		<-mw.IcmpRxCh
+	}
+
+	packet := append(createIpPacket(), make([]byte, 16)...)
+	if got := Receive(packet, dev); got != psErr.OK {
+		t.Fatalf("Receive() = %s; want %s", got, psErr.OK)
+	}
+	msg := <-mw.IcmpRxCh
+	if want := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}; !bytes.Equal(msg.Packet, want) {
+		t.Errorf("payload = %v; want %v", msg.Packet, want)
+	}
+}
+
+// Fail when Total Length is shorter than the header.
+func TestReceive_5(t *testing.T) {
+	_, teardown := setupIpTest(t)
+	defer teardown()
+
+	dev := createTapDevice()
+	_ = repo.IfaceRepo.Register(createIface(), dev)
+
+	packet := createIpPacket()
+	packet[2] = 0x00
+	packet[3] = HdrLenMin - 1
+	packet[10] = 0x00
+	packet[11] = 0x00
+	csum := mw.Checksum(packet[:HdrLenMin], 0)
+	packet[10] = uint8((csum & 0xff00) >> 8)
+	packet[11] = uint8(csum & 0x00ff)
+	if got := Receive(packet, dev); got != psErr.InvalidPacketLength {
+		t.Errorf("Receive() = %s; want %s", got, psErr.InvalidPacketLength)
+	}
+}
+
 func TestSend(t *testing.T) {
 	ctrl, teardown := setupIpTest(t)
 	defer teardown()
 
 	devMock := mw.NewMockIDevice(ctrl)
 	devMock.EXPECT().IsUp().Return(true)
-	devMock.EXPECT().Name().Return("net0")
+	devMock.EXPECT().Name().Return("net0").AnyTimes()
 	devMock.EXPECT().Flag().Return(mw.BroadcastFlag | mw.NeedArpFlag)
+	devMock.EXPECT().Type().Return(mw.EthernetDevice)
 	devMock.EXPECT().MTU().Return(uint16(mw.EthPayloadLenMax)).AnyTimes()
 	devMock.EXPECT().Priv().Return(mw.Privilege{FD: 3, Name: "tap0"})
 	devMock.EXPECT().Transmit(any, any, any).Return(psErr.OK)
@@ -181,6 +243,196 @@ func TestSend(t *testing.T) {
 	}
 }
 
+// Success when the source address is taken from the interface.
+func TestSend_2(t *testing.T) {
+	ctrl, teardown := setupIpTest(t)
+	defer teardown()
+
+	iface := createIface()
+	var got mw.V4Addr
+
+	devMock := mw.NewMockIDevice(ctrl)
+	devMock.EXPECT().IsUp().Return(true)
+	devMock.EXPECT().Name().Return("net0").AnyTimes()
+	devMock.EXPECT().Flag().Return(mw.LoopbackFlag)
+	devMock.EXPECT().Type().Return(mw.LoopbackDevice)
+	devMock.EXPECT().MTU().Return(uint16(mw.EthPayloadLenMax)).AnyTimes()
+	devMock.EXPECT().Priv().Return(mw.Privilege{FD: 3, Name: "tap0"}).AnyTimes()
+	devMock.EXPECT().
+		Transmit(any, any, mw.EtIPV4).
+		Do(func(_ mw.EthAddr, packet []byte, _ mw.EthType) {
+			copy(got[:], packet[12:16])
+		}).
+		Return(psErr.OK)
+
+	_ = repo.IfaceRepo.Register(iface, devMock)
+	repo.RouteRepo.Register(mw.IP{192, 168, 0, 0}, mw.V4Any, iface)
+
+	payload := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	dst := mw.IP{192, 168, 0, 2}
+
+	if err := Send(mw.PnICMP, payload, mw.V4Any, dst); err != psErr.OK {
+		t.Errorf("Send() = %s; want %s", err, psErr.OK)
+	}
+	if want := iface.Unicast.ToV4(); got != want {
+		t.Errorf("source address = %s; want %s", got, mw.V4Addr(want))
+	}
+}
+
+// Success when the packet is sent over TUN device (ARP is not used).
+func TestSend_3(t *testing.T) {
+	ctrl, teardown := setupIpTest(t)
+	defer teardown()
+
+	iface := createIface()
+
+	devMock := mw.NewMockIDevice(ctrl)
+	devMock.EXPECT().IsUp().Return(true)
+	devMock.EXPECT().Name().Return("net0").AnyTimes()
+	devMock.EXPECT().Type().Return(mw.TunDevice)
+	devMock.EXPECT().MTU().Return(uint16(mw.EthPayloadLenMax)).AnyTimes()
+	devMock.EXPECT().Priv().Return(mw.Privilege{FD: 3, Name: "tun0"}).AnyTimes()
+	devMock.EXPECT().Transmit(mw.EthAny, any, mw.EtIPV4).Return(psErr.OK)
+
+	arpMock := arp.NewMockIResolver(ctrl)
+	arp.Resolver = arpMock
+
+	_ = repo.IfaceRepo.Register(iface, devMock)
+	repo.RouteRepo.Register(mw.IP{192, 168, 0, 0}, mw.V4Any, iface)
+
+	payload := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	dst := mw.IP{192, 168, 0, 2}
+
+	if err := Send(mw.PnICMP, payload, mw.V4Any, dst); err != psErr.OK {
+		t.Errorf("Send() = %s; want %s", err, psErr.OK)
+	}
+}
+
+// Success when the packet is sent to the null device (blackhole route).
+func TestSend_4(t *testing.T) {
+	_, teardown := setupIpTest(t)
+	defer teardown()
+
+	iface := createIface()
+	dev := eth.GenNullDevice("null0", nil)
+	dev.Up()
+
+	_ = repo.IfaceRepo.Register(iface, dev)
+	repo.RouteRepo.Register(mw.IP{192, 168, 0, 0}, mw.V4Any, iface)
+
+	payload := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	dst := mw.IP{192, 168, 0, 2}
+
+	if err := Send(mw.PnICMP, payload, mw.V4Any, dst); err != psErr.OK {
+		t.Errorf("Send() = %s; want %s", err, psErr.OK)
+	}
+	if got := dev.Stats().Dropped; got != 1 {
+		t.Errorf("NullDevice.Stats().Dropped = %d; want %d", got, 1)
+	}
+}
+
+// Success when a TCP segment larger than the MTU is passed to the device which segments it.
+func TestSend_5(t *testing.T) {
+	_, teardown := setupIpTest(t)
+	defer teardown()
+
+	iface := createIface()
+	dev := &offloadDevice{
+		NullDevice: eth.GenNullDevice("null0", nil),
+		offload:    mw.TxCsumOffload | mw.TsoOffload,
+	}
+	dev.Up()
+
+	_ = repo.IfaceRepo.Register(iface, dev)
+	repo.RouteRepo.Register(mw.IP{192, 168, 0, 0}, mw.V4Any, iface)
+
+	payload := make([]byte, 3000)
+	dst := mw.IP{192, 168, 0, 2}
+
+	if err := Send(mw.PnTCP, payload, mw.V4Any, dst); err != psErr.OK {
+		t.Errorf("Send() = %s; want %s", err, psErr.OK)
+	}
+	// a packet of other protocols isn't segmented
+	if err := Send(mw.PnICMP, payload, mw.V4Any, dst); err != psErr.PacketTooLong {
+		t.Errorf("Send() = %s; want %s", err, psErr.PacketTooLong)
+	}
+}
+
+// Fail when a TCP segment larger than the MTU is passed to the device which doesn't segment it.
+func TestSend_6(t *testing.T) {
+	_, teardown := setupIpTest(t)
+	defer teardown()
+
+	iface := createIface()
+	dev := eth.GenNullDevice("null0", nil)
+	dev.Up()
+
+	_ = repo.IfaceRepo.Register(iface, dev)
+	repo.RouteRepo.Register(mw.IP{192, 168, 0, 0}, mw.V4Any, iface)
+
+	payload := make([]byte, 3000)
+	dst := mw.IP{192, 168, 0, 2}
+
+	if err := Send(mw.PnTCP, payload, mw.V4Any, dst); err != psErr.PacketTooLong {
+		t.Errorf("Send() = %s; want %s", err, psErr.PacketTooLong)
+	}
+}
+
+// Success when the packet is held until the address of the next hop is resolved.
+func TestSend_7(t *testing.T) {
+	ctrl, teardown := setupIpTest(t)
+	defer teardown()
+
+	devMock := mw.NewMockIDevice(ctrl)
+	devMock.EXPECT().Name().Return("net0").AnyTimes()
+	devMock.EXPECT().Flag().Return(mw.BroadcastFlag | mw.NeedArpFlag)
+	devMock.EXPECT().Type().Return(mw.EthernetDevice)
+	devMock.EXPECT().MTU().Return(uint16(mw.EthPayloadLenMax)).AnyTimes()
+	devMock.EXPECT().Priv().Return(mw.Privilege{FD: 3, Name: "tap0"}).AnyTimes()
+	devMock.EXPECT().Transmit(any, any, any).Times(0)
+
+	iface := createIface()
+	_ = repo.IfaceRepo.Register(iface, devMock)
+
+	arpMock := arp.NewMockIResolver(ctrl)
+	arpMock.EXPECT().Resolve(any, any).Return(mw.EthAddr{}, arp.Incomplete)
+	arp.Resolver = arpMock
+
+	payload := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	src := mw.IP{192, 168, 0, 1}
+	dst := mw.IP{192, 168, 0, 2}
+
+	if err := Send(mw.PnICMP, payload, src, dst); err != psErr.OK {
+		t.Errorf("Send() = %s; want %s", err, psErr.OK)
+	}
+}
+
+func BenchmarkSend(b *testing.B) {
+	psLog.DisableOutput()
+	defer func() {
+		psLog.EnableOutput()
+		repo.IfaceRepo.Init()
+		repo.RouteRepo.Init()
+	}()
+
+	iface := createIface()
+	dev := eth.GenNullDevice("null0", nil)
+	dev.Up()
+
+	_ = repo.IfaceRepo.Register(iface, dev)
+	repo.RouteRepo.Register(mw.IP{192, 168, 0, 0}, mw.V4Any, iface)
+
+	payload := make([]byte, 1000)
+	dst := mw.IP{192, 168, 0, 2}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := Send(mw.PnICMP, payload, mw.V4Any, dst); err != psErr.OK {
+			b.Fatalf("Send() = %s; want %s", err, psErr.OK)
+		}
+	}
+}
+
 func TestStart(t *testing.T) {
 	_, teardown := setupIpTest(t)
 	defer teardown()
@@ -223,6 +475,16 @@ func createIface() *mw.Iface {
 	}
 }
 
+// offloadDevice is a null device which reports the offloads.
+type offloadDevice struct {
+	*eth.NullDevice
+	offload mw.Offload
+}
+
+func (p *offloadDevice) Offload() mw.Offload {
+	return p.offload
+}
+
 func createIpPacket() []byte {
 	payload := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
 
@@ -270,6 +532,7 @@ func setupIpTest(t *testing.T) (ctrl *gomock.Controller, teardown func()) {
 	reset := func() {
 		psLog.EnableOutput()
 		repo.IfaceRepo.Init()
+		repo.RouteRepo.Init()
 	}
 	teardown = func() {
 		ctrl.Finish()
@@ -2,8 +2,11 @@ package tcp
 
 import (
 	"container/list"
+	"fmt"
 	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
 	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/42milez/ProtocolStack/src/repo"
 	"reflect"
 	"sync"
 	"time"
@@ -24,6 +27,8 @@ const (
 	lastAckState
 )
 const tcpConnMax = 32
+const ephemeralPortMin = 49152
+const ephemeralPortMax = 65535
 const windowSize = 65535
 
 var PcbRepo *pcbRepo
@@ -121,6 +126,11 @@ type resendQueueEntry struct {
 type pcbRepo struct {
 	mtx  sync.Mutex
 	pcbs [tcpConnMax]*PCB
+	// stateMtx serializes the processing of the incoming segments, which changes the states of the pcbs, with the
+	// callers waiting for the states
+	stateMtx sync.Mutex
+	// stateCh is closed and replaced each time the states may have changed, so that the waiting callers wake up
+	stateCh chan struct{}
 }
 
 func (p *pcbRepo) Get(id int) *PCB {
@@ -169,6 +179,44 @@ func (p *pcbRepo) LookUp(local *EndPoint, foreign *EndPoint) *PCB {
 	return ret
 }
 
+// Assign sets the endpoints of the pcb. An ephemeral port is selected when the local port is not specified, so that
+// the port is not taken by another pcb in the meantime.
+func (p *pcbRepo) Assign(pcb *PCB, local EndPoint, foreign EndPoint) error {
+	defer p.mtx.Unlock()
+	p.mtx.Lock()
+
+	if local.Port == 0 {
+		if local.Port = p.ephemeralPort(local.Addr); local.Port == 0 {
+			psLog.E("all ephemeral ports are in use")
+			return psErr.CantAllocatePcb
+		}
+	}
+	pcb.Local = local
+	pcb.Foreign = foreign
+
+	return psErr.OK
+}
+
+// ephemeralPort returns a port number which is not used with the address. It returns 0 when all ports are in use. It
+// must be called with the lock held.
+func (p *pcbRepo) ephemeralPort(addr mw.V4Addr) uint16 {
+	for port := ephemeralPortMin; port <= ephemeralPortMax; port++ {
+		ep := &EndPoint{Addr: addr, Port: uint16(port)}
+		inUse := false
+		for _, pcb := range p.pcbs {
+			if pcb.State != freeState && isSameLocalEndpoint(pcb, ep) {
+				inUse = true
+				break
+			}
+		}
+		if !inUse {
+			return uint16(port)
+		}
+	}
+
+	return 0
+}
+
 func (p *pcbRepo) PickNewConnection() (*PCB, int) {
 	for i, pcb := range p.pcbs {
 		if newPcb := pcb.backlog.Pop(); newPcb != nil {
@@ -189,6 +237,23 @@ func (p *pcbRepo) UnusedPcb() *PCB {
 	return nil
 }
 
+// Release releases the pcbs bound to the address, which is no longer assigned to any interface. It returns the number
+// of the released pcbs.
+func (p *pcbRepo) Release(addr mw.V4Addr) int {
+	defer p.mtx.Unlock()
+	p.mtx.Lock()
+
+	n := 0
+	for _, pcb := range p.pcbs {
+		if pcb.State != freeState && pcb.Local.Addr == addr {
+			releasePCB(pcb)
+			n++
+		}
+	}
+
+	return n
+}
+
 func (p *pcbRepo) init() {
 	defer p.mtx.Unlock()
 	p.mtx.Lock()
@@ -197,6 +262,13 @@ func (p *pcbRepo) init() {
 			ID: i,
 		}
 	}
+	p.stateCh = make(chan struct{})
+}
+
+// notifyState wakes up the callers waiting for the states of the pcbs. It must be called with stateMtx held.
+func (p *pcbRepo) notifyState() {
+	close(p.stateCh)
+	p.stateCh = make(chan struct{})
 }
 
 func isSameLocalEndpoint(pcb *PCB, ep *EndPoint) bool {
@@ -216,12 +288,24 @@ func isListen(pcb *PCB) bool {
 	return false
 }
 
+// releasePCB clears the pcb except for its ID, so that the pcb can be got with the same ID again.
 func releasePCB(v *PCB) {
+	id := v.ID
 	p := reflect.ValueOf(v).Elem()
 	p.Set(reflect.Zero(p.Type()))
+	v.ID = id
 }
 
 func init() {
 	PcbRepo = &pcbRepo{}
 	PcbRepo.init()
+
+	repo.AddIfaceHook(func(old mw.Iface) {
+		PcbRepo.stateMtx.Lock()
+		defer PcbRepo.stateMtx.Unlock()
+		if n := PcbRepo.Release(old.Unicast.ToV4()); n != 0 {
+			psLog.I(fmt.Sprintf("%d pcbs were released (%s is no longer assigned)", n, old.Unicast))
+			PcbRepo.notifyState()
+		}
+	})
 }
@@ -9,6 +9,8 @@ import (
 	psLog "github.com/42milez/ProtocolStack/src/log"
 	"github.com/42milez/ProtocolStack/src/monitor"
 	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/42milez/ProtocolStack/src/repo"
+	psTime "github.com/42milez/ProtocolStack/src/time"
 	"github.com/42milez/ProtocolStack/src/worker"
 	"strings"
 	"sync"
@@ -27,6 +29,8 @@ const (
 	HdrLenMax = 60 // byte
 	HdrLenMin = 20
 )
+const connectTimeout = 3 * time.Second
+const synRetransmitInterval = time.Second
 const xChBufSize = 5
 
 var rcvMonCh chan *worker.Message
@@ -79,6 +83,7 @@ func Open() (int, error) {
 		psLog.E("all pcb is in used")
 		return -1, psErr.CantAllocatePcb
 	}
+	pcb.State = closedState
 	return pcb.ID, psErr.OK
 }
 
@@ -141,8 +146,89 @@ func Accept(id int) (int, EndPoint, error) {
 	return pcbId, foreign, psErr.OK
 }
 
+// Connect performs an active OPEN and waits until the connection is established. The local address and port are
+// selected automatically when they are not bound.
 func Connect(id int, foreign EndPoint) error {
-	return psErr.OK
+	pcb := PcbRepo.Get(id)
+	if pcb == nil {
+		psLog.E("pcb not found")
+		return psErr.PcbNotFound
+	}
+
+	PcbRepo.stateMtx.Lock()
+	defer PcbRepo.stateMtx.Unlock()
+
+	if pcb.State != closedState {
+		psLog.E("pcb is NOT in CLOSED state")
+		return psErr.InvalidPcbState
+	}
+
+	local := pcb.Local
+	if mw.V4Any.EqualV4(local.Addr) {
+		route := repo.RouteRepo.Get(mw.V4FromByte(foreign.Addr))
+		if route == nil {
+			psLog.E(fmt.Sprintf("route to %s not found", foreign.Addr))
+			return psErr.RouteNotFound
+		}
+		local.Addr = route.Iface.Unicast.ToV4()
+	}
+	if err := PcbRepo.Assign(pcb, local, foreign); err != psErr.OK {
+		return err
+	}
+	pcb.RCV.WND = windowSize
+
+	// A SYN segment of the form <SEQ=ISS><CTL=SYN> is sent. Set SND.UNA to ISS, SND.NXT to ISS+1, enter SYN-SENT
+	// state, and return.
+	pcb.ISS = mw.RandU32()
+	pcb.SND.UNA = pcb.ISS
+	pcb.SND.NXT = pcb.ISS + 1
+	pcb.State = synSentState
+
+	if err := Send(pcb, synFlag, nil); err != psErr.OK {
+		pcb.State = closedState
+		return psErr.Error
+	}
+
+	psLog.D(fmt.Sprintf("connecting: addr = %s, port = %d", foreign.Addr, foreign.Port))
+
+	retransmit, stopRetransmit := psTime.Time.After(synRetransmitInterval)
+	defer func() { stopRetransmit() }()
+	timeout, stopTimeout := psTime.Time.After(connectTimeout)
+	defer stopTimeout()
+
+	for {
+		switch pcb.State {
+		case establishedState:
+			return psErr.OK
+		case synSentState, synReceivedState:
+		default:
+			psLog.E("connection refused")
+			return psErr.ConnectionRefused
+		}
+
+		// the lock is released while waiting, so that the receiver can process the incoming segments
+		changed := PcbRepo.stateCh
+		PcbRepo.stateMtx.Unlock()
+		select {
+		case <-changed:
+			PcbRepo.stateMtx.Lock()
+		case <-retransmit:
+			PcbRepo.stateMtx.Lock()
+			// The SYN segment is lost when the address of the next hop isn't resolved yet, so send it again.
+			if pcb.State == synSentState {
+				if err := Send(pcb, synFlag, nil); err != psErr.OK {
+					pcb.State = closedState
+					return psErr.Error
+				}
+			}
+			retransmit, stopRetransmit = psTime.Time.After(synRetransmitInterval)
+		case <-timeout:
+			PcbRepo.stateMtx.Lock()
+			psLog.E("connection timed out")
+			pcb.State = closedState
+			return psErr.Timeout
+		}
+	}
 }
 
 func Receive(msg *mw.TcpRxMessage) error {
@@ -160,7 +246,9 @@ func Receive(msg *mw.TcpRxMessage) error {
 	if err := binary.Write(pseudoHdrBuf, binary.BigEndian, &pseudoHdr); err != nil {
 		return psErr.Error
 	}
-	if mw.Checksum(msg.RawSegment, uint32(^mw.Checksum(pseudoHdrBuf.Bytes(), 0))) != 0 {
+	// the checksum has been verified by the device
+	if !mw.OffloadOf(msg.Iface.Dev).Has(mw.RxCsumOffload) &&
+		mw.Checksum(msg.RawSegment, uint32(^mw.Checksum(pseudoHdrBuf.Bytes(), 0))) != 0 {
 		psLog.E("checksum mismatch (tcp)")
 		return psErr.ChecksumMismatch
 	}
@@ -182,7 +270,11 @@ func Receive(msg *mw.TcpRxMessage) error {
 	local := &EndPoint{Addr: msg.Dst, Port: hdr.Dst}
 	foreign := &EndPoint{Addr: msg.Src, Port: hdr.Src}
 
-	if err := receiveCore(hdr, msg.RawSegment[hdrLen:], local, foreign); err != psErr.OK {
+	PcbRepo.stateMtx.Lock()
+	err := receiveCore(hdr, msg.RawSegment[hdrLen:], local, foreign)
+	PcbRepo.notifyState()
+	PcbRepo.stateMtx.Unlock()
+	if err != psErr.OK {
 		psLog.E(fmt.Sprintf("can't process incoming segment: %s", err))
 		return psErr.Error
 	}
@@ -373,6 +465,8 @@ func receiveCore(hdr *Hdr, data []byte, local *EndPoint, foreign *EndPoint) erro
 			// transmission may be included. If there are other controls or text in the segment then continue processing
 			// at the sixth step below where the URG bit is checked, otherwise return.
 			if pcb.SND.UNA > pcb.ISS {
+				// the foreign host has answered our SYN, so that the neighbor on the way to it is reachable
+				mw.ConfirmNeighbor(mw.V4FromByte(pcb.Foreign.Addr))
 				pcb.State = establishedState
 				if err := Send(pcb, ackFlag, nil); err != psErr.OK {
 					return psErr.Error
@@ -604,6 +698,9 @@ func receiveCore(hdr *Hdr, data []byte, local *EndPoint, foreign *EndPoint) erro
 			// If SND.UNA < SEG.ACK =< SND.NXT, the send window should be updated.
 			pcb.SND.UNA = hdr.Ack
 
+			// the acknowledgement of new data confirms the neighbor on the way to the foreign host
+			mw.ConfirmNeighbor(mw.V4FromByte(pcb.Foreign.Addr))
+
 			// TODO: clean up resend queue
 			// ...
 
@@ -817,7 +914,13 @@ func sendCore(info SegmentInfo, data []byte, local *EndPoint, foreign *EndPoint)
 		return psErr.Error
 	}
 
-	csum := mw.Checksum(segment, uint32(^mw.Checksum(pseudoBuf.Bytes(), 0)))
+	// the device completes the checksum from the one of the pseudo header
+	var csum uint16
+	if offloadOf(local.Addr).Has(mw.TxCsumOffload) {
+		csum = ^mw.Checksum(pseudoBuf.Bytes(), 0)
+	} else {
+		csum = mw.Checksum(segment, uint32(^mw.Checksum(pseudoBuf.Bytes(), 0)))
+	}
 	segment[16] = uint8((csum & 0xff00) >> 8)
 	segment[17] = uint8(csum & 0x00ff)
 
@@ -833,6 +936,14 @@ func sendCore(info SegmentInfo, data []byte, local *EndPoint, foreign *EndPoint)
 	return psErr.OK
 }
 
+// offloadOf returns the offloads of the device which segments from the address are sent through.
+func offloadOf(addr mw.V4Addr) mw.Offload {
+	if iface := repo.IfaceRepo.Get(mw.V4FromByte(addr)); iface != nil {
+		return mw.OffloadOf(iface.Dev)
+	}
+	return 0
+}
+
 func Start(wg *sync.WaitGroup) error {
 	wg.Add(2)
 	go receiver(wg)
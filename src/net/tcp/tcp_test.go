@@ -0,0 +1,84 @@
+package tcp
+
+import (
+	"bytes"
+	"encoding/binary"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/mw"
+	psTime "github.com/42milez/ProtocolStack/src/time"
+	"github.com/golang/mock/gomock"
+	"testing"
+	"time"
+)
+
+// Success when the SYN segment is sent again while the connection is in SYN-SENT state, and the connection is closed
+// when it times out.
+func TestConnect_1(t *testing.T) {
+	ctrl, teardown := setupTcpTest(t)
+	defer teardown()
+
+	retransmitCh1 := make(chan time.Time, 1)
+	retransmitCh2 := make(chan time.Time, 1)
+	timeoutCh := make(chan time.Time, 1)
+	m := psTime.NewMockITime(ctrl)
+	gomock.InOrder(
+		m.EXPECT().After(synRetransmitInterval).Return((<-chan time.Time)(retransmitCh1), func() {}),
+		m.EXPECT().After(synRetransmitInterval).Return((<-chan time.Time)(retransmitCh2), func() {}),
+	)
+	m.EXPECT().After(connectTimeout).Return((<-chan time.Time)(timeoutCh), func() {})
+	psTime.Time = m
+
+	id, _ := Open()
+	_ = Bind(id, EndPoint{Addr: mw.V4Addr{192, 0, 2, 1}, Port: 50000})
+
+	errCh := make(chan error)
+	go func() {
+		errCh <- Connect(id, EndPoint{Addr: mw.V4Addr{192, 0, 2, 2}, Port: 80})
+	}()
+
+	syn1 := receiveSegment(t)
+	retransmitCh1 <- time.Time{}
+	syn2 := receiveSegment(t)
+	timeoutCh <- time.Time{}
+
+	if err := <-errCh; err != psErr.Timeout {
+		t.Errorf("Connect() = %s; want %s", err, psErr.Timeout)
+	}
+	for _, v := range []*Hdr{syn1, syn2} {
+		if v.Flag != synFlag || v.Seq != syn1.Seq || v.Src != 50000 || v.Dst != 80 {
+			t.Errorf("segment isn't the SYN segment: %+v", v)
+		}
+	}
+	if pcb := PcbRepo.Get(id); pcb.State != closedState {
+		t.Errorf("pcb state = %d; want %d", pcb.State, closedState)
+	}
+}
+
+// receiveSegment returns the header of the segment which is passed to the ip layer.
+func receiveSegment(t *testing.T) *Hdr {
+	select {
+	case msg := <-mw.IpTxCh:
+		hdr := &Hdr{}
+		_ = binary.Read(bytes.NewBuffer(msg.Packet), binary.BigEndian, hdr)
+		return hdr
+	case <-time.After(time.Second):
+		t.Fatalf("no segment was sent")
+	}
+	return nil
+}
+
+func setupTcpTest(t *testing.T) (ctrl *gomock.Controller, teardown func()) {
+	psLog.DisableOutput()
+	ctrl = gomock.NewController(t)
+	backupTime := psTime.Time
+
+	teardown = func() {
+		PcbRepo.init()
+		psTime.Time = backupTime
+		ctrl.Finish()
+		psLog.EnableOutput()
+	}
+
+	return
+}
@@ -5,19 +5,40 @@ import (
 	psErr "github.com/42milez/ProtocolStack/src/error"
 	psLog "github.com/42milez/ProtocolStack/src/log"
 	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/42milez/ProtocolStack/src/repo"
 )
 
+// Transmit sends the payload through the device of the interface. The payload must not be modified after it returns
+// since it's queued when the device has a queueing discipline.
 func Transmit(dst mw.EthAddr, payload []byte, typ mw.EthType, iface *mw.Iface) error {
+	// the interface which was replaced by IfaceRepo.SetAddr() or detached isn't used anymore
+	if repo.IfaceRepo.Get(iface.Unicast) != iface {
+		psLog.E(fmt.Sprintf("interface is no longer registered: %s", iface.Unicast))
+		return psErr.InterfaceNotFound
+	}
+
 	if !iface.Dev.IsUp() {
 		psLog.E(fmt.Sprintf("device %s is down", iface.Dev.Name()))
 		return psErr.DeviceNotOpened
 	}
 
-	if len(payload) > int(iface.Dev.MTU()) {
+	// a payload larger than the MTU is segmented by the device
+	if len(payload) > int(iface.Dev.MTU()) && !mw.OffloadOf(iface.Dev).Has(mw.TsoOffload) {
 		psLog.E(fmt.Sprintf("ethMessage is too long: mtu = %d, actual = %d", iface.Dev.MTU(), len(payload)))
 		return psErr.PacketTooLong
 	}
 
+	// the frame is sent by the transmit queue worker of the device, so that a slow device doesn't stall the caller
+	if mw.QdiscOf(iface.Dev) != nil {
+		mw.EthTxCh <- &mw.EthMessage{
+			Type:    typ,
+			Content: payload,
+			Dev:     iface.Dev,
+			Dst:     dst,
+		}
+		return psErr.OK
+	}
+
 	if err := iface.Dev.Transmit(dst, payload, typ); err != psErr.OK {
 		return psErr.Error
 	}
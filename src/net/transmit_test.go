@@ -4,6 +4,7 @@ import (
 	psErr "github.com/42milez/ProtocolStack/src/error"
 	psLog "github.com/42milez/ProtocolStack/src/log"
 	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/42milez/ProtocolStack/src/repo"
 	"github.com/42milez/ProtocolStack/src/test"
 	"github.com/golang/mock/gomock"
 	"testing"
@@ -16,10 +17,12 @@ func TestTransmit(t *testing.T) {
 	devMock := mw.NewMockIDevice(ctrl)
 	devMock.EXPECT().IsUp().Return(true)
 	devMock.EXPECT().MTU().Return(uint16(mw.EthPayloadLenMax))
+	devMock.EXPECT().Name().Return("net0").AnyTimes()
+	devMock.EXPECT().Priv().Return(mw.Privilege{Name: "tap0"}).AnyTimes()
 	devMock.EXPECT().Transmit(any, any, any).Return(psErr.OK)
 
 	iface := test.IfaceBuilder.Default()
-	iface.Dev = devMock
+	_ = repo.IfaceRepo.Register(iface, devMock)
 
 	payload := test.PayloadBuilder.Default()
 	dstEthAddr := test.EthAddrBuilder.Default()
@@ -32,12 +35,77 @@ func TestTransmit(t *testing.T) {
 	}
 }
 
+// Success when the payload is queued for the transmit queue worker of the device which has a queueing discipline.
+func TestTransmit_2(t *testing.T) {
+	ctrl, teardown := setupTransmitTest(t)
+	defer teardown()
+
+	devMock := mw.NewMockIDevice(ctrl)
+	devMock.EXPECT().IsUp().Return(true)
+	devMock.EXPECT().MTU().Return(uint16(mw.EthPayloadLenMax))
+	devMock.EXPECT().Name().Return("net0").AnyTimes()
+	devMock.EXPECT().Priv().Return(mw.Privilege{Name: "tap0"}).AnyTimes()
+
+	qdisc := mw.NewMockIQdisc(ctrl)
+	mw.SetQdisc(devMock, qdisc)
+	defer mw.SetQdisc(devMock, nil)
+
+	iface := test.IfaceBuilder.Default()
+	_ = repo.IfaceRepo.Register(iface, devMock)
+
+	payload := test.PayloadBuilder.Default()
+	dstEthAddr := test.EthAddrBuilder.Default()
+
+	if got := Transmit(dstEthAddr, payload, mw.EtIPV4, iface); got != psErr.OK {
+		t.Errorf("Transmit() = %s; want %s", got, psErr.OK)
+	}
+	if len(mw.EthTxCh) != 1 {
+		t.Fatalf("len(mw.EthTxCh) = %d; want %d", len(mw.EthTxCh), 1)
+	}
+	if msg := <-mw.EthTxCh; msg.Dst != dstEthAddr || msg.Dev != devMock {
+		t.Errorf("(Dst, Dev) = (%s, %v); want (%s, %v)", msg.Dst, msg.Dev, dstEthAddr, devMock)
+	}
+}
+
+// Fail when the interface was replaced by IfaceRepo.SetAddr().
+func TestTransmit_3(t *testing.T) {
+	ctrl, teardown := setupTransmitTest(t)
+	defer teardown()
+
+	devMock := mw.NewMockIDevice(ctrl)
+	devMock.EXPECT().IsUp().Return(true)
+	devMock.EXPECT().MTU().Return(uint16(mw.EthPayloadLenMax))
+	devMock.EXPECT().Name().Return("net0").AnyTimes()
+	devMock.EXPECT().Priv().Return(mw.Privilege{Name: "tap0"}).AnyTimes()
+	devMock.EXPECT().Transmit(any, any, any).Return(psErr.OK)
+
+	iface := test.IfaceBuilder.Default()
+	_ = repo.IfaceRepo.Register(iface, devMock)
+	renumbered, _ := repo.IfaceRepo.SetAddr(iface, mw.IP{198, 51, 100, 1}, mw.IP{255, 255, 255, 0},
+		mw.IP{198, 51, 100, 255})
+
+	payload := test.PayloadBuilder.Default()
+	dstEthAddr := test.EthAddrBuilder.Default()
+
+	if got := Transmit(dstEthAddr, payload, mw.EtIPV4, iface); got != psErr.InterfaceNotFound {
+		t.Errorf("Transmit() = %s; want %s", got, psErr.InterfaceNotFound)
+	}
+	// the payload is sent through the interface which is returned
+	if got := Transmit(dstEthAddr, payload, mw.EtIPV4, renumbered); got != psErr.OK {
+		t.Errorf("Transmit() = %s; want %s", got, psErr.OK)
+	}
+}
+
 var any = gomock.Any()
 
 func setupTransmitTest(t *testing.T) (ctrl *gomock.Controller, teardown func()) {
 	ctrl = gomock.NewController(t)
 	psLog.DisableOutput()
+	repo.IfaceRepo.Init()
+	repo.RouteRepo.Init()
 	reset := func() {
+		repo.IfaceRepo.Init()
+		repo.RouteRepo.Init()
 		psLog.EnableOutput()
 	}
 	teardown = func() {
@@ -0,0 +1,170 @@
+package pcap
+
+import (
+	"encoding/binary"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	"io"
+	"time"
+)
+
+// PCAP Capture File Format
+// https://datatracker.ietf.org/doc/html/draft-gharris-opsawg-pcap-01
+
+// PCAP Next Generation (pcapng) Capture File Format
+// https://datatracker.ietf.org/doc/html/draft-tuexen-opsawg-pcapng-03
+
+const (
+	LinkTypeEthernet LinkType = 1
+	LinkTypeRaw      LinkType = 101
+)
+const (
+	magicMicroseconds = 0xa1b2c3d4
+	magicNanoseconds  = 0xa1b23c4d
+	fileHdrLen        = 24
+	recordHdrLen      = 16
+	snapLen           = 262144
+	versionMajor      = 2
+	versionMinor      = 4
+)
+
+var linkTypes = map[LinkType]string{
+	1:   "ETHERNET",
+	101: "RAW",
+}
+
+// LinkType is a link-layer header type of captured packets.
+// https://www.tcpdump.org/linktypes.html
+type LinkType uint16
+
+func (v LinkType) String() string {
+	return linkTypes[v]
+}
+
+// A Packet is a single packet record read from a capture file.
+type Packet struct {
+	Timestamp time.Time
+	LinkType  LinkType
+	Direction Direction // available only in pcapng
+	Data      []byte
+}
+
+type IReader interface {
+	ReadPacket() (*Packet, error)
+}
+
+// NewReader detects whether r is a pcap or a pcapng capture and returns a reader for it.
+func NewReader(r io.Reader) (IReader, error) {
+	var magic [4]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, psErr.InvalidFormat
+	}
+
+	if binary.LittleEndian.Uint32(magic[:]) == blockSectionHdr {
+		return newNgReader(r)
+	}
+
+	return newReader(r, magic)
+}
+
+type reader struct {
+	r        io.Reader
+	order    binary.ByteOrder
+	nano     bool
+	linkType LinkType
+}
+
+func newReader(r io.Reader, magic [4]byte) (*reader, error) {
+	p := &reader{r: r}
+
+	switch {
+	case binary.LittleEndian.Uint32(magic[:]) == magicMicroseconds:
+		p.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(magic[:]) == magicMicroseconds:
+		p.order = binary.BigEndian
+	case binary.LittleEndian.Uint32(magic[:]) == magicNanoseconds:
+		p.order = binary.LittleEndian
+		p.nano = true
+	case binary.BigEndian.Uint32(magic[:]) == magicNanoseconds:
+		p.order = binary.BigEndian
+		p.nano = true
+	default:
+		return nil, psErr.InvalidFormat
+	}
+
+	hdr := make([]byte, fileHdrLen-len(magic))
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, psErr.InvalidFormat
+	}
+	p.linkType = LinkType(p.order.Uint32(hdr[16:20]))
+
+	return p, psErr.OK
+}
+
+func (p *reader) ReadPacket() (*Packet, error) {
+	hdr := make([]byte, recordHdrLen)
+	if _, err := io.ReadFull(p.r, hdr); err != nil {
+		if err == io.EOF {
+			return nil, psErr.NoDataToRead
+		}
+		return nil, psErr.InvalidFormat
+	}
+
+	sec := int64(p.order.Uint32(hdr[0:4]))
+	frac := int64(p.order.Uint32(hdr[4:8]))
+	capLen := p.order.Uint32(hdr[8:12])
+	if capLen > snapLen {
+		return nil, psErr.InvalidFormat
+	}
+
+	data := make([]byte, capLen)
+	if _, err := io.ReadFull(p.r, data); err != nil {
+		return nil, psErr.InvalidFormat
+	}
+
+	if !p.nano {
+		frac *= int64(time.Microsecond)
+	}
+
+	return &Packet{
+		Timestamp: time.Unix(sec, frac),
+		LinkType:  p.linkType,
+		Data:      data,
+	}, psErr.OK
+}
+
+// A Writer writes packets to a pcap capture file with microsecond resolution timestamps.
+type Writer struct {
+	w io.Writer
+}
+
+func NewWriter(w io.Writer, linkType LinkType) (*Writer, error) {
+	hdr := make([]byte, fileHdrLen)
+	binary.LittleEndian.PutUint32(hdr[0:4], magicMicroseconds)
+	binary.LittleEndian.PutUint16(hdr[4:6], versionMajor)
+	binary.LittleEndian.PutUint16(hdr[6:8], versionMinor)
+	binary.LittleEndian.PutUint32(hdr[16:20], snapLen)
+	binary.LittleEndian.PutUint32(hdr[20:24], uint32(linkType))
+
+	if _, err := w.Write(hdr); err != nil {
+		return nil, psErr.WriteToBufError
+	}
+
+	return &Writer{w: w}, psErr.OK
+}
+
+func (p *Writer) WritePacket(ts time.Time, data []byte) error {
+	hdr := make([]byte, recordHdrLen)
+	binary.LittleEndian.PutUint32(hdr[0:4], uint32(ts.Unix()))
+	binary.LittleEndian.PutUint32(hdr[4:8], uint32(ts.Nanosecond()/int(time.Microsecond)))
+	binary.LittleEndian.PutUint32(hdr[8:12], uint32(len(data)))
+	binary.LittleEndian.PutUint32(hdr[12:16], uint32(len(data)))
+
+	if _, err := p.w.Write(hdr); err != nil {
+		return psErr.WriteToBufError
+	}
+	if _, err := p.w.Write(data); err != nil {
+		return psErr.WriteToBufError
+	}
+
+	return psErr.OK
+}
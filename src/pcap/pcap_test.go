@@ -0,0 +1,98 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	"github.com/google/go-cmp/cmp"
+	"testing"
+	"time"
+)
+
+func TestLinkType_String(t *testing.T) {
+	want := "ETHERNET"
+	got := LinkTypeEthernet.String()
+	if got != want {
+		t.Errorf("LinkType.String() = %s; want %s", got, want)
+	}
+}
+
+func TestWriter_WritePacket(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w, err := NewWriter(buf, LinkTypeEthernet)
+	if err != psErr.OK {
+		t.Fatalf("NewWriter() = %s; want %s", err, psErr.OK)
+	}
+
+	ts := time.Unix(1609459200, 123456000)
+	data := []byte{0x01, 0x02, 0x03, 0x04}
+	if err := w.WritePacket(ts, data); err != psErr.OK {
+		t.Fatalf("Writer.WritePacket() = %s; want %s", err, psErr.OK)
+	}
+
+	r, err := NewReader(buf)
+	if err != psErr.OK {
+		t.Fatalf("NewReader() = %s; want %s", err, psErr.OK)
+	}
+
+	want := &Packet{
+		Timestamp: ts,
+		LinkType:  LinkTypeEthernet,
+		Data:      data,
+	}
+	got, err := r.ReadPacket()
+	if err != psErr.OK {
+		t.Fatalf("ReadPacket() = %s; want %s", err, psErr.OK)
+	}
+	if d := cmp.Diff(got, want); d != "" {
+		t.Errorf("ReadPacket() differs: (-got +want)\n%s", d)
+	}
+
+	if _, err = r.ReadPacket(); err != psErr.NoDataToRead {
+		t.Errorf("ReadPacket() = %s; want %s", err, psErr.NoDataToRead)
+	}
+}
+
+// Success when the file is written in big endian with nanosecond resolution.
+func TestReader_ReadPacket_1(t *testing.T) {
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.BigEndian, []uint32{magicNanoseconds, 0x00020004, 0, 0, snapLen, uint32(LinkTypeRaw)})
+	_ = binary.Write(buf, binary.BigEndian, []uint32{1609459200, 123456789, 2, 2})
+	buf.Write([]byte{0xaa, 0xbb})
+
+	r, err := NewReader(buf)
+	if err != psErr.OK {
+		t.Fatalf("NewReader() = %s; want %s", err, psErr.OK)
+	}
+
+	want := &Packet{
+		Timestamp: time.Unix(1609459200, 123456789),
+		LinkType:  LinkTypeRaw,
+		Data:      []byte{0xaa, 0xbb},
+	}
+	got, _ := r.ReadPacket()
+	if d := cmp.Diff(got, want); d != "" {
+		t.Errorf("ReadPacket() differs: (-got +want)\n%s", d)
+	}
+}
+
+// Fail when a record is truncated.
+func TestReader_ReadPacket_2(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w, _ := NewWriter(buf, LinkTypeEthernet)
+	_ = w.WritePacket(time.Now(), []byte{0x01, 0x02, 0x03, 0x04})
+	buf.Truncate(buf.Len() - 1)
+
+	r, _ := NewReader(buf)
+	if _, err := r.ReadPacket(); err != psErr.InvalidFormat {
+		t.Errorf("ReadPacket() = %s; want %s", err, psErr.InvalidFormat)
+	}
+}
+
+// Fail when magic number is unknown.
+func TestNewReader(t *testing.T) {
+	buf := bytes.NewBuffer([]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	if _, err := NewReader(buf); err != psErr.InvalidFormat {
+		t.Errorf("NewReader() = %s; want %s", err, psErr.InvalidFormat)
+	}
+}
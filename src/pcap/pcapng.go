@@ -0,0 +1,365 @@
+package pcap
+
+import (
+	"encoding/binary"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	"io"
+	"math/bits"
+	"time"
+)
+
+const (
+	blockSectionHdr     = 0x0a0d0d0a
+	blockIfaceDesc      = 0x00000001
+	blockSimplePacket   = 0x00000003
+	blockEnhancedPacket = 0x00000006
+	byteOrderMagic      = 0x1a2b3c4d
+	optEndOfOpt         = 0
+	optIfName           = 2
+	optIfTsResol        = 9
+	optEpbFlags         = 2
+	sectionLenUnknown   = 0xffffffffffffffff
+	blockHdrLen         = 8
+	blockTrailerLen     = 4
+	blockLenMax         = 16 * 1024 * 1024
+	defaultTsResol      = 6  // microseconds
+	tsResolPow10Max     = 19 // 10^19 is the largest power of 10 in uint64
+	tsResolPow2Max      = 63
+)
+
+const (
+	DirectionUnknown Direction = iota
+	DirectionInbound
+	DirectionOutbound
+)
+
+// Direction is the direction of a packet stored in the flags word of an enhanced packet block.
+type Direction uint32
+
+type ngIface struct {
+	linkType LinkType
+	tsResol  uint8 // if_tsresol option value
+}
+
+type ngReader struct {
+	r      io.Reader
+	order  binary.ByteOrder
+	ifaces []*ngIface
+}
+
+func newNgReader(r io.Reader) (*ngReader, error) {
+	p := &ngReader{r: r}
+	if err := p.readSectionHdr(); err != psErr.OK {
+		return nil, err
+	}
+	return p, psErr.OK
+}
+
+func (p *ngReader) ReadPacket() (*Packet, error) {
+	for {
+		var hdr [blockHdrLen]byte
+		if _, err := io.ReadFull(p.r, hdr[:4]); err != nil {
+			if err == io.EOF {
+				return nil, psErr.NoDataToRead
+			}
+			return nil, psErr.InvalidFormat
+		}
+
+		if binary.LittleEndian.Uint32(hdr[:4]) == blockSectionHdr {
+			if err := p.readSectionHdr(); err != psErr.OK {
+				return nil, err
+			}
+			continue
+		}
+
+		if _, err := io.ReadFull(p.r, hdr[4:]); err != nil {
+			return nil, psErr.InvalidFormat
+		}
+		typ := p.order.Uint32(hdr[:4])
+		body, err := p.readBody(p.order.Uint32(hdr[4:]))
+		if err != psErr.OK {
+			return nil, err
+		}
+
+		switch typ {
+		case blockIfaceDesc:
+			if err := p.parseIfaceDesc(body); err != psErr.OK {
+				return nil, err
+			}
+		case blockEnhancedPacket:
+			return p.parseEnhancedPacket(body)
+		case blockSimplePacket:
+			return p.parseSimplePacket(body)
+		default:
+			// skip blocks which are not related to packet data (name resolution, statistics, etc.)
+		}
+	}
+}
+
+// readSectionHdr reads the rest of a section header block. The block type has already been consumed.
+func (p *ngReader) readSectionHdr() error {
+	var b [8]byte
+	if _, err := io.ReadFull(p.r, b[:]); err != nil {
+		return psErr.InvalidFormat
+	}
+
+	switch {
+	case binary.LittleEndian.Uint32(b[4:]) == byteOrderMagic:
+		p.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(b[4:]) == byteOrderMagic:
+		p.order = binary.BigEndian
+	default:
+		return psErr.InvalidFormat
+	}
+
+	blockLen := p.order.Uint32(b[:4])
+	if blockLen < blockHdrLen+blockTrailerLen+8 || blockLen > blockLenMax {
+		return psErr.InvalidFormat
+	}
+
+	// skip version, section length, options and trailer
+	rest := make([]byte, blockLen-blockHdrLen-4)
+	if _, err := io.ReadFull(p.r, rest); err != nil {
+		return psErr.InvalidFormat
+	}
+
+	// interface ids are scoped to the section
+	p.ifaces = nil
+
+	return psErr.OK
+}
+
+// readBody reads a block body and its trailer, and returns the body.
+func (p *ngReader) readBody(blockLen uint32) ([]byte, error) {
+	if blockLen < blockHdrLen+blockTrailerLen || blockLen > blockLenMax || blockLen%4 != 0 {
+		return nil, psErr.InvalidFormat
+	}
+	b := make([]byte, blockLen-blockHdrLen)
+	if _, err := io.ReadFull(p.r, b); err != nil {
+		return nil, psErr.InvalidFormat
+	}
+	return b[:len(b)-blockTrailerLen], psErr.OK
+}
+
+func (p *ngReader) parseIfaceDesc(body []byte) error {
+	if len(body) < 8 {
+		return psErr.InvalidFormat
+	}
+
+	iface := &ngIface{
+		linkType: LinkType(p.order.Uint16(body[0:2])),
+		tsResol:  defaultTsResol,
+	}
+
+	opts := body[8:]
+	for len(opts) >= 4 {
+		code := p.order.Uint16(opts[0:2])
+		l := int(p.order.Uint16(opts[2:4]))
+		if code == optEndOfOpt || len(opts) < 4+l {
+			break
+		}
+		if code == optIfTsResol && l >= 1 {
+			if !validTsResol(opts[4]) {
+				return psErr.InvalidFormat
+			}
+			iface.tsResol = opts[4]
+		}
+		opts = opts[4+pad4(l):]
+	}
+
+	p.ifaces = append(p.ifaces, iface)
+
+	return psErr.OK
+}
+
+func (p *ngReader) parseEnhancedPacket(body []byte) (*Packet, error) {
+	if len(body) < 20 {
+		return nil, psErr.InvalidFormat
+	}
+
+	id := int(p.order.Uint32(body[0:4]))
+	if id >= len(p.ifaces) {
+		return nil, psErr.InvalidFormat
+	}
+	iface := p.ifaces[id]
+
+	ts := uint64(p.order.Uint32(body[4:8]))<<32 | uint64(p.order.Uint32(body[8:12]))
+	capLen := int(p.order.Uint32(body[12:16]))
+	if len(body) < 20+capLen {
+		return nil, psErr.InvalidFormat
+	}
+
+	data := make([]byte, capLen)
+	copy(data, body[20:20+capLen])
+
+	var dir Direction
+	if len(body) >= 20+pad4(capLen) {
+		opts := body[20+pad4(capLen):]
+		for len(opts) >= 4 {
+			code := p.order.Uint16(opts[0:2])
+			l := int(p.order.Uint16(opts[2:4]))
+			if code == optEndOfOpt || len(opts) < 4+l {
+				break
+			}
+			if code == optEpbFlags && l == 4 {
+				dir = Direction(p.order.Uint32(opts[4:8]) & 0x3)
+			}
+			opts = opts[4+pad4(l):]
+		}
+	}
+
+	return &Packet{
+		Timestamp: iface.timestamp(ts),
+		LinkType:  iface.linkType,
+		Direction: dir,
+		Data:      data,
+	}, psErr.OK
+}
+
+func (p *ngReader) parseSimplePacket(body []byte) (*Packet, error) {
+	if len(body) < 4 || len(p.ifaces) == 0 {
+		return nil, psErr.InvalidFormat
+	}
+
+	origLen := int(p.order.Uint32(body[0:4]))
+	capLen := len(body) - 4
+	if origLen < capLen {
+		capLen = origLen
+	}
+
+	data := make([]byte, capLen)
+	copy(data, body[4:4+capLen])
+
+	// simple packet blocks don't have a timestamp
+	return &Packet{
+		LinkType: p.ifaces[0].linkType,
+		Data:     data,
+	}, psErr.OK
+}
+
+// timestamp converts a timestamp in units of the interface's resolution into time.Time. The most significant bit of
+// if_tsresol indicates whether the resolution is a negative power of 2 or of 10.
+func (p *ngIface) timestamp(ts uint64) time.Time {
+	var unitsPerSec uint64
+	if p.tsResol&0x80 == 0 {
+		unitsPerSec = 1
+		for i := uint8(0); i < p.tsResol; i++ {
+			unitsPerSec *= 10
+		}
+	} else {
+		unitsPerSec = 1 << (p.tsResol & 0x7f)
+	}
+	sec := ts / unitsPerSec
+	hi, lo := bits.Mul64(ts%unitsPerSec, uint64(time.Second))
+	nsec, _ := bits.Div64(hi, lo, unitsPerSec)
+	return time.Unix(int64(sec), int64(nsec))
+}
+
+// validTsResol reports whether the units per second which if_tsresol indicates fit in uint64.
+func validTsResol(v uint8) bool {
+	if v&0x80 == 0 {
+		return v <= tsResolPow10Max
+	}
+	return v&0x7f <= tsResolPow2Max
+}
+
+func pad4(n int) int {
+	return (n + 3) &^ 3
+}
+
+// A NgWriter writes packets to a pcapng capture file. Each interface has to be described with AddIface before its
+// packets are written.
+type NgWriter struct {
+	w       io.Writer
+	nIfaces uint32
+}
+
+func NewNgWriter(w io.Writer) (*NgWriter, error) {
+	body := make([]byte, 16)
+	binary.LittleEndian.PutUint32(body[0:4], byteOrderMagic)
+	binary.LittleEndian.PutUint16(body[4:6], 1) // major version
+	binary.LittleEndian.PutUint16(body[6:8], 0) // minor version
+	binary.LittleEndian.PutUint64(body[8:16], sectionLenUnknown)
+
+	p := &NgWriter{w: w}
+	if err := p.writeBlock(blockSectionHdr, body); err != psErr.OK {
+		return nil, err
+	}
+
+	return p, psErr.OK
+}
+
+// AddIface writes an interface description block and returns the interface id to be used with WritePacket.
+func (p *NgWriter) AddIface(name string, linkType LinkType) (uint32, error) {
+	body := make([]byte, 8)
+	binary.LittleEndian.PutUint16(body[0:2], uint16(linkType))
+	binary.LittleEndian.PutUint32(body[4:8], snapLen)
+	body = appendOpt(body, optIfName, []byte(name))
+	body = appendOpt(body, optIfTsResol, []byte{defaultTsResol})
+	body = appendOpt(body, optEndOfOpt, nil)
+
+	if err := p.writeBlock(blockIfaceDesc, body); err != psErr.OK {
+		return 0, err
+	}
+
+	id := p.nIfaces
+	p.nIfaces += 1
+
+	return id, psErr.OK
+}
+
+func (p *NgWriter) WritePacket(ifaceID uint32, ts time.Time, data []byte, dir Direction) error {
+	if ifaceID >= p.nIfaces {
+		return psErr.InvalidFormat
+	}
+
+	// timestamps are written in microseconds (if_tsresol = 6)
+	t := uint64(ts.UnixNano()) / uint64(time.Microsecond)
+
+	body := make([]byte, 20, 20+pad4(len(data))+16)
+	binary.LittleEndian.PutUint32(body[0:4], ifaceID)
+	binary.LittleEndian.PutUint32(body[4:8], uint32(t>>32))
+	binary.LittleEndian.PutUint32(body[8:12], uint32(t))
+	binary.LittleEndian.PutUint32(body[12:16], uint32(len(data)))
+	binary.LittleEndian.PutUint32(body[16:20], uint32(len(data)))
+	body = append(body, data...)
+	body = append(body, make([]byte, pad4(len(data))-len(data))...)
+
+	flags := make([]byte, 4)
+	binary.LittleEndian.PutUint32(flags, uint32(dir))
+	body = appendOpt(body, optEpbFlags, flags)
+	body = appendOpt(body, optEndOfOpt, nil)
+
+	return p.writeBlock(blockEnhancedPacket, body)
+}
+
+func (p *NgWriter) writeBlock(typ uint32, body []byte) error {
+	blockLen := uint32(blockHdrLen + len(body) + blockTrailerLen)
+	b := make([]byte, 0, blockLen)
+	b = appendU32(b, typ)
+	b = appendU32(b, blockLen)
+	b = append(b, body...)
+	b = appendU32(b, blockLen)
+
+	if _, err := p.w.Write(b); err != nil {
+		return psErr.WriteToBufError
+	}
+
+	return psErr.OK
+}
+
+// appendOpt appends an option which is padded to 32 bits.
+func appendOpt(b []byte, code uint16, value []byte) []byte {
+	var hdr [4]byte
+	binary.LittleEndian.PutUint16(hdr[0:2], code)
+	binary.LittleEndian.PutUint16(hdr[2:4], uint16(len(value)))
+	b = append(b, hdr[:]...)
+	b = append(b, value...)
+	return append(b, make([]byte, pad4(len(value))-len(value))...)
+}
+
+func appendU32(b []byte, v uint32) []byte {
+	var u [4]byte
+	binary.LittleEndian.PutUint32(u[:], v)
+	return append(b, u[:]...)
+}
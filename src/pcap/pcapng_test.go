@@ -0,0 +1,155 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	"github.com/google/go-cmp/cmp"
+	"testing"
+	"time"
+)
+
+func TestNgReader_ReadPacket_1(t *testing.T) {
+	buf := new(bytes.Buffer)
+	writeBlock(buf, blockSectionHdr, []byte{0x4d, 0x3c, 0x2b, 0x1a, 0x01, 0x00, 0x00, 0x00,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	// interface with nanosecond resolution (if_tsresol = 9)
+	writeBlock(buf, blockIfaceDesc, []byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x04, 0x00,
+		0x09, 0x00, 0x01, 0x00, 0x09, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00})
+	// name resolution block (ignored)
+	writeBlock(buf, 0x00000004, []byte{0x00, 0x00, 0x00, 0x00})
+	ts := uint64(1609459200123456789)
+	epb := new(bytes.Buffer)
+	_ = binary.Write(epb, binary.LittleEndian, []uint32{0, uint32(ts >> 32), uint32(ts), 3, 3})
+	epb.Write([]byte{0xaa, 0xbb, 0xcc, 0x00})
+	writeBlock(buf, blockEnhancedPacket, epb.Bytes())
+	writeBlock(buf, blockSimplePacket, []byte{0x02, 0x00, 0x00, 0x00, 0xdd, 0xee, 0x00, 0x00})
+
+	r, err := NewReader(buf)
+	if err != psErr.OK {
+		t.Fatalf("NewReader() = %s; want %s", err, psErr.OK)
+	}
+
+	want := &Packet{
+		Timestamp: time.Unix(1609459200, 123456789),
+		LinkType:  LinkTypeEthernet,
+		Data:      []byte{0xaa, 0xbb, 0xcc},
+	}
+	got, err := r.ReadPacket()
+	if err != psErr.OK {
+		t.Fatalf("ReadPacket() = %s; want %s", err, psErr.OK)
+	}
+	if d := cmp.Diff(got, want); d != "" {
+		t.Errorf("ReadPacket() differs: (-got +want)\n%s", d)
+	}
+
+	want = &Packet{
+		LinkType: LinkTypeEthernet,
+		Data:     []byte{0xdd, 0xee},
+	}
+	got, _ = r.ReadPacket()
+	if d := cmp.Diff(got, want); d != "" {
+		t.Errorf("ReadPacket() differs: (-got +want)\n%s", d)
+	}
+
+	if _, err = r.ReadPacket(); err != psErr.NoDataToRead {
+		t.Errorf("ReadPacket() = %s; want %s", err, psErr.NoDataToRead)
+	}
+}
+
+// Fail when a packet refers to an interface which is not described.
+func TestNgReader_ReadPacket_2(t *testing.T) {
+	buf := new(bytes.Buffer)
+	writeBlock(buf, blockSectionHdr, []byte{0x4d, 0x3c, 0x2b, 0x1a, 0x01, 0x00, 0x00, 0x00,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	epb := new(bytes.Buffer)
+	_ = binary.Write(epb, binary.LittleEndian, []uint32{0, 0, 0, 0, 0})
+	writeBlock(buf, blockEnhancedPacket, epb.Bytes())
+
+	r, _ := NewReader(buf)
+	if _, err := r.ReadPacket(); err != psErr.InvalidFormat {
+		t.Errorf("ReadPacket() = %s; want %s", err, psErr.InvalidFormat)
+	}
+}
+
+// Fail when if_tsresol indicates the units per second which don't fit in uint64.
+func TestNgReader_ReadPacket_3(t *testing.T) {
+	tests := []struct {
+		tsResol uint8
+		want    error
+	}{
+		{19, psErr.OK},
+		{20, psErr.InvalidFormat},
+		{64, psErr.InvalidFormat},
+		{0x80 | 63, psErr.OK},
+		{0x80 | 64, psErr.InvalidFormat},
+	}
+	for _, v := range tests {
+		buf := new(bytes.Buffer)
+		writeBlock(buf, blockSectionHdr, []byte{0x4d, 0x3c, 0x2b, 0x1a, 0x01, 0x00, 0x00, 0x00,
+			0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+		writeBlock(buf, blockIfaceDesc, []byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x04, 0x00,
+			0x09, 0x00, 0x01, 0x00, v.tsResol, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00})
+		epb := new(bytes.Buffer)
+		_ = binary.Write(epb, binary.LittleEndian, []uint32{0, 0xffffffff, 0xffffffff, 0, 0})
+		writeBlock(buf, blockEnhancedPacket, epb.Bytes())
+
+		r, _ := NewReader(buf)
+		if _, err := r.ReadPacket(); err != v.want {
+			t.Errorf("ReadPacket() = %s; want %s (if_tsresol = 0x%02x)", err, v.want, v.tsResol)
+		}
+	}
+}
+
+func writeBlock(buf *bytes.Buffer, typ uint32, body []byte) {
+	blockLen := uint32(blockHdrLen + len(body) + blockTrailerLen)
+	_ = binary.Write(buf, binary.LittleEndian, []uint32{typ, blockLen})
+	buf.Write(body)
+	_ = binary.Write(buf, binary.LittleEndian, blockLen)
+}
+
+func TestNgWriter_WritePacket(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w, err := NewNgWriter(buf)
+	if err != psErr.OK {
+		t.Fatalf("NewNgWriter() = %s; want %s", err, psErr.OK)
+	}
+
+	// fail when the interface is not described
+	if err := w.WritePacket(0, time.Now(), []byte{0x01}, DirectionInbound); err != psErr.InvalidFormat {
+		t.Errorf("NgWriter.WritePacket() = %s; want %s", err, psErr.InvalidFormat)
+	}
+
+	_, _ = w.AddIface("net0", LinkTypeRaw)
+	id, err := w.AddIface("net1", LinkTypeEthernet)
+	if err != psErr.OK || id != 1 {
+		t.Fatalf("NgWriter.AddIface() = (%d, %s); want (%d, %s)", id, err, 1, psErr.OK)
+	}
+
+	ts := time.Unix(1609459200, 123456000)
+	data := []byte{0x01, 0x02, 0x03, 0x04, 0x05}
+	if err := w.WritePacket(id, ts, data, DirectionOutbound); err != psErr.OK {
+		t.Fatalf("NgWriter.WritePacket() = %s; want %s", err, psErr.OK)
+	}
+
+	r, err := NewReader(buf)
+	if err != psErr.OK {
+		t.Fatalf("NewReader() = %s; want %s", err, psErr.OK)
+	}
+
+	want := &Packet{
+		Timestamp: ts,
+		LinkType:  LinkTypeEthernet,
+		Direction: DirectionOutbound,
+		Data:      data,
+	}
+	got, err := r.ReadPacket()
+	if err != psErr.OK {
+		t.Fatalf("ReadPacket() = %s; want %s", err, psErr.OK)
+	}
+	if d := cmp.Diff(got, want); d != "" {
+		t.Errorf("ReadPacket() differs: (-got +want)\n%s", d)
+	}
+}
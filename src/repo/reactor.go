@@ -0,0 +1,300 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/mw"
+	psSyscall "github.com/42milez/ProtocolStack/src/syscall"
+	"github.com/42milez/ProtocolStack/src/worker"
+	"sync"
+	"syscall"
+)
+
+const maxEpollEvents = 32
+
+// pollBudget is the maximum number of frames read from a device at a time. Frames which exceed it are read in the
+// next round so that a busy device doesn't starve the others.
+const pollBudget = 64
+
+// an 8-byte integer in host byte order which is added to the counter of the eventfd
+var evfdIncrement = []byte{1, 0, 0, 0, 0, 0, 0, 0}
+
+var reactor *reactor_
+
+// reactor_ waits for frames to arrive at all devices at once and reads every ready frame. Devices which have a file
+// descriptor are watched with epoll. The other devices notify the reactor via mw.NotifyRx() when they queue frames.
+// An eventfd wakes the reactor up when it is stopped or such a device notifies.
+type reactor_ struct {
+	epfd     int
+	evfd     int
+	fds      map[int32]mw.IDevice
+	devices  map[mw.IDevice]bool
+	devMtx   sync.Mutex
+	ready    map[mw.IDevice]bool
+	signaled bool
+	readyMtx sync.Mutex
+}
+
+func (p *reactor_) open() error {
+	var err error
+
+	if p.epfd, err = psSyscall.Syscall.EpollCreate1(0); err != nil {
+		p.epfd = -1
+		return psErr.CantCreateEpollInstance
+	}
+
+	if p.evfd, err = psSyscall.Syscall.Eventfd(0, 0); err != nil {
+		_ = psSyscall.Syscall.Close(p.epfd)
+		p.epfd = -1
+		p.evfd = -1
+		return psErr.CantOpenIOResource
+	}
+
+	var event syscall.EpollEvent
+	event.Events = syscall.EPOLLIN
+	event.Fd = int32(p.evfd)
+	if err = psSyscall.Syscall.EpollCtl(p.epfd, syscall.EPOLL_CTL_ADD, p.evfd, &event); err != nil {
+		_ = psSyscall.Syscall.Close(p.evfd)
+		_ = psSyscall.Syscall.Close(p.epfd)
+		p.epfd = -1
+		p.evfd = -1
+		return psErr.CantModifyIOResourceParameter
+	}
+
+	p.devMtx.Lock()
+	p.fds = make(map[int32]mw.IDevice)
+	p.devices = make(map[mw.IDevice]bool)
+	p.devMtx.Unlock()
+
+	p.readyMtx.Lock()
+	p.ready = make(map[mw.IDevice]bool)
+	p.signaled = false
+	p.readyMtx.Unlock()
+
+	mw.SetRxNotifier(p.notify)
+
+	return psErr.OK
+}
+
+func (p *reactor_) close() {
+	mw.SetRxNotifier(nil)
+
+	p.devMtx.Lock()
+	defer p.devMtx.Unlock()
+	p.readyMtx.Lock()
+	defer p.readyMtx.Unlock()
+
+	_ = psSyscall.Syscall.Close(p.evfd)
+	_ = psSyscall.Syscall.Close(p.epfd)
+	p.epfd = -1
+	p.evfd = -1
+	p.fds = nil
+	p.devices = nil
+}
+
+func (p *reactor_) isOpened() bool {
+	p.devMtx.Lock()
+	defer p.devMtx.Unlock()
+	return p.devices != nil
+}
+
+// add starts watching the device. The device is read once at first since it may already have frames.
+func (p *reactor_) add(dev mw.IDevice) error {
+	p.devMtx.Lock()
+	defer p.devMtx.Unlock()
+
+	if p.devices == nil || p.devices[dev] {
+		return psErr.Error
+	}
+
+	if fd := dev.Priv().FD; fd >= 0 {
+		var event syscall.EpollEvent
+		event.Events = syscall.EPOLLIN
+		event.Fd = int32(fd)
+		if err := psSyscall.Syscall.EpollCtl(p.epfd, syscall.EPOLL_CTL_ADD, fd, &event); err != nil {
+			return psErr.CantModifyIOResourceParameter
+		}
+		p.fds[int32(fd)] = dev
+	}
+	p.devices[dev] = true
+
+	p.notify(dev)
+
+	return psErr.OK
+}
+
+// remove stops watching the device. It waits for the device to be read if the reactor is reading it.
+func (p *reactor_) remove(dev mw.IDevice) error {
+	p.devMtx.Lock()
+	defer p.devMtx.Unlock()
+
+	if p.devices == nil || !p.devices[dev] {
+		return psErr.NotFound
+	}
+
+	if fd := dev.Priv().FD; fd >= 0 {
+		if err := psSyscall.Syscall.EpollCtl(p.epfd, syscall.EPOLL_CTL_DEL, fd, nil); err != nil {
+			return psErr.CantModifyIOResourceParameter
+		}
+		delete(p.fds, int32(fd))
+	}
+	delete(p.devices, dev)
+
+	p.readyMtx.Lock()
+	delete(p.ready, dev)
+	p.readyMtx.Unlock()
+
+	return psErr.OK
+}
+
+// notify marks the device as ready to be read and wakes the reactor up.
+func (p *reactor_) notify(dev mw.IDevice) {
+	p.readyMtx.Lock()
+	defer p.readyMtx.Unlock()
+
+	p.ready[dev] = true
+	if !p.signaled {
+		p.signaled = true
+		p.wake()
+	}
+}
+
+// interrupt wakes the reactor up so that it receives a signal.
+func (p *reactor_) interrupt() {
+	p.readyMtx.Lock()
+	defer p.readyMtx.Unlock()
+	p.wake()
+}
+
+// wake writes the eventfd. It must be called with readyMtx held.
+func (p *reactor_) wake() {
+	if p.evfd < 0 {
+		return
+	}
+	if _, err := psSyscall.Syscall.Write(p.evfd, evfdIncrement); err != nil {
+		psLog.E(fmt.Sprintf("can't wake reactor up: %s", err))
+	}
+}
+
+// wait blocks until any device gets ready. It doesn't block when some devices still have frames to be read.
+func (p *reactor_) wait() error {
+	p.readyMtx.Lock()
+	timeout := -1
+	if len(p.ready) != 0 {
+		timeout = 0
+	}
+	p.readyMtx.Unlock()
+
+	var events [maxEpollEvents]syscall.EpollEvent
+	nEvents, err := psSyscall.Syscall.EpollWait(p.epfd, events[:], timeout)
+	if err != nil {
+		if !errors.Is(err, syscall.EINTR) {
+			return psErr.SyscallError
+		}
+		return psErr.Interrupted
+	}
+
+	for i := 0; i < nEvents; i++ {
+		if events[i].Fd == int32(p.evfd) {
+			var buf [8]byte
+			if _, err := psSyscall.Syscall.Read(p.evfd, buf[:]); err != nil {
+				return psErr.SyscallError
+			}
+			p.readyMtx.Lock()
+			p.signaled = false
+			p.readyMtx.Unlock()
+			continue
+		}
+		p.devMtx.Lock()
+		dev, ok := p.fds[events[i].Fd]
+		p.devMtx.Unlock()
+		if ok {
+			p.readyMtx.Lock()
+			p.ready[dev] = true
+			p.readyMtx.Unlock()
+		}
+	}
+
+	return psErr.OK
+}
+
+// dispatch reads frames from the ready devices. A device stays ready while it has frames which exceed pollBudget. A
+// device which fails to be read is skipped until it gets ready again, so that it doesn't stop reading the others.
+func (p *reactor_) dispatch() {
+	p.readyMtx.Lock()
+	ready := p.ready
+	p.ready = make(map[mw.IDevice]bool)
+	p.readyMtx.Unlock()
+
+	p.devMtx.Lock()
+	defer p.devMtx.Unlock()
+
+	for dev := range ready {
+		if !p.devices[dev] || !dev.IsUp() {
+			continue
+		}
+		drained := false
+		for i := 0; i < pollBudget; i++ {
+			err := dev.Poll()
+			if err == psErr.NoDataToRead {
+				drained = true
+				break
+			}
+			if err == psErr.Interrupted {
+				break
+			}
+			if err != psErr.OK {
+				psLog.E(fmt.Sprintf("can't read frame: %s", err),
+					fmt.Sprintf("type: %s", dev.Type()),
+					fmt.Sprintf("name: %s (%s)", dev.Name(), dev.Priv().Name))
+				drained = true
+				break
+			}
+		}
+		if !drained {
+			p.readyMtx.Lock()
+			p.ready[dev] = true
+			p.readyMtx.Unlock()
+		}
+	}
+}
+
+func runReactor(wg *sync.WaitGroup) {
+	defer func() {
+		reactor.close()
+		psLog.D("device reactor stopped")
+		wg.Done()
+	}()
+
+	monCh <- &worker.Message{
+		ID:      reactorId,
+		Current: worker.Running,
+	}
+
+	for {
+		select {
+		case msg := <-sigCh:
+			if msg.Desired == worker.Stopped {
+				monCh <- &worker.Message{
+					ID:      reactorId,
+					Current: worker.Stopped,
+				}
+				return
+			}
+		default:
+			if err := reactor.wait(); err != psErr.OK {
+				if err == psErr.Interrupted {
+					continue
+				}
+				monCh <- &worker.Message{
+					ID:      reactorId,
+					Current: worker.Error,
+				}
+				return
+			}
+			reactor.dispatch()
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package repo
+
+import (
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/golang/mock/gomock"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const reactorTestTimeout = time.Second
+
+// genReactorTestDevice returns a device which reports each frame read to pollCh. The device has frames as many as
+// the values sent to frameCh, and Poll returns the value of each frame.
+func genReactorTestDevice(ctrl *gomock.Controller, name string, fd int) (m *mw.MockIDevice, frameCh chan error, pollCh chan bool) {
+	frameCh = make(chan error, 10)
+	pollCh = make(chan bool, 10)
+
+	var up bool
+	var mtx sync.Mutex
+
+	m = mw.NewMockIDevice(ctrl)
+	m.EXPECT().Open().Return(psErr.OK)
+	m.EXPECT().Up().Do(func() {
+		mtx.Lock()
+		defer mtx.Unlock()
+		up = true
+	}).AnyTimes()
+	m.EXPECT().Down().Do(func() {
+		mtx.Lock()
+		defer mtx.Unlock()
+		up = false
+	}).AnyTimes()
+	m.EXPECT().IsUp().DoAndReturn(func() bool {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return up
+	}).AnyTimes()
+	m.EXPECT().Poll().DoAndReturn(func() error {
+		select {
+		case err := <-frameCh:
+			pollCh <- true
+			return err
+		default:
+			return psErr.NoDataToRead
+		}
+	}).AnyTimes()
+	m.EXPECT().Equal(gomock.Any()).DoAndReturn(func(dev mw.IDevice) bool {
+		return dev.Name() == name
+	}).AnyTimes()
+	m.EXPECT().Type().Return(mw.EthernetDevice).AnyTimes()
+	m.EXPECT().Name().Return(name).AnyTimes()
+	m.EXPECT().Priv().Return(mw.Privilege{Name: name, FD: fd}).AnyTimes()
+	m.EXPECT().Addr().Return(mw.EthAddr{}).AnyTimes()
+
+	return
+}
+
+func startReactorTest(t *testing.T) (stop func()) {
+	var wg sync.WaitGroup
+	if err := Start(&wg); err != psErr.OK {
+		t.Fatalf("Start() = %s; want %s", err, psErr.OK)
+	}
+	<-monCh
+	stop = func() {
+		Stop()
+		<-monCh
+		wg.Wait()
+	}
+	return
+}
+
+func waitForPoll(t *testing.T, pollCh chan bool, n int) {
+	for i := 0; i < n; i++ {
+		select {
+		case <-pollCh:
+		case <-time.After(reactorTestTimeout):
+			t.Fatalf("frame was not read (%d/%d)", i, n)
+		}
+	}
+}
+
+// Success when frames queued in a device which doesn't have a file descriptor are read.
+func TestReactor_1(t *testing.T) {
+	ctrl, teardown := setupRepositoryTest(t)
+	defer teardown()
+
+	m, frameCh, pollCh := genReactorTestDevice(ctrl, "net0", -1)
+	_ = DeviceRepo.Register(m)
+
+	stop := startReactorTest(t)
+	defer stop()
+
+	// all the frames are read per notification
+	for i := 0; i < 3; i++ {
+		frameCh <- psErr.OK
+	}
+	mw.NotifyRx(m)
+	waitForPoll(t, pollCh, 3)
+}
+
+// Success when frames arrive at a device which has a file descriptor.
+func TestReactor_2(t *testing.T) {
+	ctrl, teardown := setupRepositoryTest(t)
+	defer teardown()
+
+	var fds [2]int
+	if err := syscall.Pipe(fds[:]); err != nil {
+		t.Fatalf("syscall.Pipe() failed: %s", err)
+	}
+	defer func() {
+		_ = syscall.Close(fds[0])
+		_ = syscall.Close(fds[1])
+	}()
+
+	m, frameCh, pollCh := genReactorTestDevice(ctrl, "net0", fds[0])
+	_ = DeviceRepo.Register(m)
+
+	stop := startReactorTest(t)
+	defer stop()
+
+	frameCh <- psErr.OK
+	if _, err := syscall.Write(fds[1], []byte{0x01}); err != nil {
+		t.Fatalf("syscall.Write() failed: %s", err)
+	}
+	waitForPoll(t, pollCh, 1)
+
+	// consume the data since the pipe stays readable until then
+	var buf [1]byte
+	_, _ = syscall.Read(fds[0], buf[:])
+}
+
+// Success when devices are registered and unregistered while the reactor is running.
+func TestReactor_3(t *testing.T) {
+	ctrl, teardown := setupRepositoryTest(t)
+	defer teardown()
+
+	stop := startReactorTest(t)
+	defer stop()
+
+	m, frameCh, pollCh := genReactorTestDevice(ctrl, "net0", -1)
+	if err := DeviceRepo.Register(m); err != psErr.OK {
+		t.Fatalf("DeviceRepo.Register() = %s; want %s", err, psErr.OK)
+	}
+	if !m.IsUp() {
+		t.Fatalf("IsUp() = false; want true")
+	}
+
+	frameCh <- psErr.OK
+	mw.NotifyRx(m)
+	waitForPoll(t, pollCh, 1)
+
+	m.EXPECT().Close().Return(psErr.OK)
+	if err := DeviceRepo.Unregister(m); err != psErr.OK {
+		t.Fatalf("DeviceRepo.Unregister() = %s; want %s", err, psErr.OK)
+	}
+
+	// the device is no longer read
+	frameCh <- psErr.OK
+	mw.NotifyRx(m)
+	select {
+	case <-pollCh:
+		t.Errorf("frame was read from unregistered device")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// Success when the device which fails to be read doesn't stop the reactor reading the others.
+func TestReactor_4(t *testing.T) {
+	ctrl, teardown := setupRepositoryTest(t)
+	defer teardown()
+
+	m1, frameCh1, pollCh1 := genReactorTestDevice(ctrl, "net0", -1)
+	m2, frameCh2, pollCh2 := genReactorTestDevice(ctrl, "net1", -1)
+	_ = DeviceRepo.Register(m1)
+	_ = DeviceRepo.Register(m2)
+
+	stop := startReactorTest(t)
+	defer stop()
+
+	frameCh1 <- psErr.Error
+	frameCh2 <- psErr.OK
+	mw.NotifyRx(m1)
+	mw.NotifyRx(m2)
+	waitForPoll(t, pollCh1, 1)
+	waitForPoll(t, pollCh2, 1)
+
+	// the device is read again when it gets ready
+	frameCh1 <- psErr.OK
+	mw.NotifyRx(m1)
+	waitForPoll(t, pollCh1, 1)
+
+	select {
+	case msg := <-monCh:
+		t.Errorf("reactor reported state %d", msg.Current)
+	default:
+	}
+}
@@ -16,7 +16,7 @@ const xChBufSize = 5
 
 var monCh chan *worker.Message
 var sigCh chan *worker.Message
-var watcherId uint32
+var reactorId uint32
 
 var DeviceRepo IDeviceRepo
 var IfaceRepo IIfaceRepo
@@ -24,6 +24,82 @@ var RouteRepo IRouteRepo
 
 type Handler func(data []byte, dev mw.IDevice) error
 
+// IfaceHook is called when the address of an interface becomes invalid, i.e. the interface is unregistered or its
+// address is changed. The hook receives a copy of the interface as it was before the change, so that the states which
+// depend on the old address (e.g. ARP entries, TCP connections) can be cleaned up.
+type IfaceHook func(old mw.Iface)
+
+var ifaceHooks []IfaceHook
+var ifaceHookMtx sync.Mutex
+
+// AddIfaceHook adds the hook which is called when the address of an interface becomes invalid.
+func AddIfaceHook(hook IfaceHook) {
+	ifaceHookMtx.Lock()
+	defer ifaceHookMtx.Unlock()
+	ifaceHooks = append(ifaceHooks, hook)
+}
+
+func notifyIfaceHooks(old mw.Iface) {
+	ifaceHookMtx.Lock()
+	hooks := make([]IfaceHook, len(ifaceHooks))
+	copy(hooks, ifaceHooks)
+	ifaceHookMtx.Unlock()
+
+	for _, hook := range hooks {
+		hook(old)
+	}
+}
+
+// AddrHook is called when an address is assigned to an interface, i.e. the interface is registered or its address is
+// changed, so that the address can be claimed on the link (e.g. probed and announced with ARP).
+type AddrHook func(iface *mw.Iface)
+
+var addrHooks []AddrHook
+var addrHookMtx sync.Mutex
+
+// AddAddrHook adds the hook which is called when an address is assigned to an interface.
+func AddAddrHook(hook AddrHook) {
+	addrHookMtx.Lock()
+	defer addrHookMtx.Unlock()
+	addrHooks = append(addrHooks, hook)
+}
+
+func notifyAddrHooks(iface *mw.Iface) {
+	addrHookMtx.Lock()
+	hooks := make([]AddrHook, len(addrHooks))
+	copy(hooks, addrHooks)
+	addrHookMtx.Unlock()
+
+	for _, hook := range hooks {
+		hook(iface)
+	}
+}
+
+// DeviceHook is called when a device is brought up or down. The hook is called with the lock of DeviceRepo held, so
+// it must not call DeviceRepo.
+type DeviceHook func(dev mw.IDevice, up bool)
+
+var deviceHooks []DeviceHook
+var deviceHookMtx sync.Mutex
+
+// AddDeviceHook adds the hook which is called when a device is brought up or down.
+func AddDeviceHook(hook DeviceHook) {
+	deviceHookMtx.Lock()
+	defer deviceHookMtx.Unlock()
+	deviceHooks = append(deviceHooks, hook)
+}
+
+func notifyDeviceHooks(dev mw.IDevice, up bool) {
+	deviceHookMtx.Lock()
+	hooks := make([]DeviceHook, len(deviceHooks))
+	copy(hooks, deviceHooks)
+	deviceHookMtx.Unlock()
+
+	for _, hook := range hooks {
+		hook(dev, up)
+	}
+}
+
 type Route struct {
 	Network mw.IP
 	Netmask mw.IP
@@ -34,9 +110,11 @@ type Route struct {
 type IDeviceRepo interface {
 	Init()
 	NextNumber() int
-	Poll() error
 	Register(dev mw.IDevice) error
+	Unregister(dev mw.IDevice) error
 	Up() error
+	UpDevice(dev mw.IDevice) error
+	DownDevice(dev mw.IDevice) error
 }
 
 type deviceRepo struct {
@@ -56,25 +134,8 @@ func (p *deviceRepo) NextNumber() int {
 	return len(p.devices)
 }
 
-func (p *deviceRepo) Poll() error {
-	defer p.mtx.Unlock()
-	p.mtx.Lock()
-
-	for _, dev := range p.devices {
-		if !dev.IsUp() {
-			continue
-		}
-		if err := dev.Poll(); err != psErr.OK {
-			if err == psErr.Interrupted {
-				return psErr.OK
-			}
-			return psErr.Error
-		}
-	}
-
-	return psErr.OK
-}
-
+// Register registers the device. The device is opened and watched by the reactor immediately when the reactor is
+// running.
 func (p *deviceRepo) Register(dev mw.IDevice) error {
 	defer p.mtx.Unlock()
 	p.mtx.Lock()
@@ -87,6 +148,12 @@ func (p *deviceRepo) Register(dev mw.IDevice) error {
 			return psErr.Error
 		}
 	}
+
+	if reactor.isOpened() {
+		if err := p.open(dev); err != psErr.OK {
+			return psErr.Error
+		}
+	}
 	p.devices = append(p.devices, dev)
 
 	psLog.D("device was registered",
@@ -97,6 +164,33 @@ func (p *deviceRepo) Register(dev mw.IDevice) error {
 	return psErr.OK
 }
 
+// Unregister stops watching the device and closes it. The interfaces assigned to the device are unregistered as well.
+func (p *deviceRepo) Unregister(dev mw.IDevice) error {
+	defer p.mtx.Unlock()
+	p.mtx.Lock()
+
+	for i, d := range p.devices {
+		if !d.Equal(dev) {
+			continue
+		}
+		if d.IsUp() {
+			if err := p.close(d); err != psErr.OK {
+				return psErr.Error
+			}
+		}
+		p.devices = append(p.devices[:i], p.devices[i+1:]...)
+		if iface := IfaceRepo.Lookup(d, mw.V4AddrFamily); iface != nil {
+			_ = IfaceRepo.Unregister(iface)
+		}
+		psLog.D("device was unregistered",
+			fmt.Sprintf("type: %s", d.Type()),
+			fmt.Sprintf("name: %s (%s)", d.Name(), d.Priv().Name))
+		return psErr.OK
+	}
+
+	return psErr.NotFound
+}
+
 func (p *deviceRepo) Up() error {
 	defer p.mtx.Unlock()
 	p.mtx.Lock()
@@ -108,18 +202,105 @@ func (p *deviceRepo) Up() error {
 				fmt.Sprintf("name: %s (%s)", dev.Name(), dev.Priv().Name))
 			return psErr.Error
 		}
-		if err := dev.Open(); err != psErr.OK {
-			psLog.E(fmt.Sprintf("can't open device: %s", err),
+		if err := p.open(dev); err != psErr.OK {
+			return psErr.Error
+		}
+	}
+
+	return psErr.OK
+}
+
+// UpDevice opens the registered device and gets it up while the stack is running.
+func (p *deviceRepo) UpDevice(dev mw.IDevice) error {
+	defer p.mtx.Unlock()
+	p.mtx.Lock()
+
+	d := p.lookup(dev)
+	if d == nil {
+		return psErr.NotFound
+	}
+	if d.IsUp() {
+		psLog.W("Device is already up",
+			fmt.Sprintf("type: %s", d.Type()),
+			fmt.Sprintf("name: %s (%s)", d.Name(), d.Priv().Name))
+		return psErr.Error
+	}
+
+	return p.open(d)
+}
+
+// DownDevice stops watching the registered device and closes it. The device keeps its interfaces, so that it can be
+// brought up again with UpDevice().
+func (p *deviceRepo) DownDevice(dev mw.IDevice) error {
+	defer p.mtx.Unlock()
+	p.mtx.Lock()
+
+	d := p.lookup(dev)
+	if d == nil {
+		return psErr.NotFound
+	}
+	if !d.IsUp() {
+		psLog.W("Device is already down",
+			fmt.Sprintf("type: %s", d.Type()),
+			fmt.Sprintf("name: %s (%s)", d.Name(), d.Priv().Name))
+		return psErr.Error
+	}
+
+	return p.close(d)
+}
+
+// lookup returns the registered device which is equal to the given one. It must be called with the lock held.
+func (p *deviceRepo) lookup(dev mw.IDevice) mw.IDevice {
+	for _, d := range p.devices {
+		if d.Equal(dev) {
+			return d
+		}
+	}
+	return nil
+}
+
+// open opens the device and gets it up. The device is watched by the reactor when the reactor is running.
+func (p *deviceRepo) open(dev mw.IDevice) error {
+	if err := dev.Open(); err != psErr.OK {
+		psLog.E(fmt.Sprintf("can't open device: %s", err),
+			fmt.Sprintf("type: %s", dev.Type()),
+			fmt.Sprintf("name: %s (%s)", dev.Name(), dev.Priv().Name))
+		return psErr.Error
+	}
+	dev.Up()
+	if reactor.isOpened() {
+		if err := reactor.add(dev); err != psErr.OK {
+			psLog.E(fmt.Sprintf("can't watch device: %s", err),
 				fmt.Sprintf("type: %s", dev.Type()),
 				fmt.Sprintf("name: %s (%s)", dev.Name(), dev.Priv().Name))
+			dev.Down()
+			_ = dev.Close()
 			return psErr.Error
 		}
-		dev.Up()
-		psLog.D("device was opened",
+	}
+	psLog.D("device was opened",
+		fmt.Sprintf("type: %s", dev.Type()),
+		fmt.Sprintf("name: %s (%s)", dev.Name(), dev.Priv().Name))
+	notifyDeviceHooks(dev, true)
+	return psErr.OK
+}
+
+// close stops watching the device, closes it and gets it down.
+func (p *deviceRepo) close(dev mw.IDevice) error {
+	if err := reactor.remove(dev); err != psErr.OK && err != psErr.NotFound {
+		return psErr.Error
+	}
+	if err := dev.Close(); err != psErr.OK {
+		psLog.E(fmt.Sprintf("can't close device: %s", err),
 			fmt.Sprintf("type: %s", dev.Type()),
 			fmt.Sprintf("name: %s (%s)", dev.Name(), dev.Priv().Name))
+		return psErr.Error
 	}
-
+	dev.Down()
+	notifyDeviceHooks(dev, false)
+	psLog.D("device was closed",
+		fmt.Sprintf("type: %s", dev.Type()),
+		fmt.Sprintf("name: %s (%s)", dev.Name(), dev.Priv().Name))
 	return psErr.OK
 }
 
@@ -128,6 +309,8 @@ type IIfaceRepo interface {
 	Get(unicast mw.IP) *mw.Iface
 	Lookup(dev mw.IDevice, family mw.AddrFamily) *mw.Iface
 	Register(iface *mw.Iface, dev mw.IDevice) error
+	Unregister(iface *mw.Iface) error
+	SetAddr(iface *mw.Iface, unicast mw.IP, netmask mw.IP, broadcast mw.IP) (*mw.Iface, error)
 }
 
 type ifaceRepo struct {
@@ -167,12 +350,13 @@ func (p *ifaceRepo) Lookup(dev mw.IDevice, family mw.AddrFamily) *mw.Iface {
 	return nil
 }
 
+// Register attaches the interface to the device, and the hooks are notified so that the address is claimed.
 func (p *ifaceRepo) Register(iface *mw.Iface, dev mw.IDevice) error {
-	defer p.mtx.Unlock()
 	p.mtx.Lock()
 
 	for _, i := range p.ifaces {
 		if i.Dev.Equal(dev) && i.Family == iface.Family {
+			p.mtx.Unlock()
 			psLog.W(fmt.Sprintf("Interface is already registered: %s", i.Family))
 			return psErr.Error
 		}
@@ -181,6 +365,10 @@ func (p *ifaceRepo) Register(iface *mw.Iface, dev mw.IDevice) error {
 	p.ifaces = append(p.ifaces, iface)
 	iface.Dev = dev
 
+	p.mtx.Unlock()
+
+	notifyAddrHooks(iface)
+
 	psLog.D("interface was attached",
 		fmt.Sprintf("ip:     %s", iface.Unicast),
 		fmt.Sprintf("device: %s (%s)", dev.Name(), dev.Priv().Name))
@@ -188,11 +376,95 @@ func (p *ifaceRepo) Register(iface *mw.Iface, dev mw.IDevice) error {
 	return psErr.OK
 }
 
+// Unregister detaches the interface from the device. The routes through the interface are removed, and the hooks are
+// notified so that the states depending on the address are cleaned up.
+func (p *ifaceRepo) Unregister(iface *mw.Iface) error {
+	p.mtx.Lock()
+
+	idx := p.index(iface)
+	if idx < 0 {
+		p.mtx.Unlock()
+		return psErr.NotFound
+	}
+	p.ifaces = append(p.ifaces[:idx], p.ifaces[idx+1:]...)
+	old := *iface
+
+	p.mtx.Unlock()
+
+	RouteRepo.Unregister(iface)
+	notifyIfaceHooks(old)
+
+	psLog.D("interface was detached",
+		fmt.Sprintf("ip:     %s", old.Unicast),
+		fmt.Sprintf("device: %s (%s)", old.Dev.Name(), old.Dev.Priv().Name))
+
+	return psErr.OK
+}
+
+// SetAddr changes the address of the interface. The interface is replaced with a new one which has the new address,
+// since the interface is read without the lock, and the new one is returned. The old one is no longer registered, so
+// it is rejected by net.Transmit(). The routes through the interface follow the new one, and the hooks are notified
+// so that the states depending on the old address are cleaned up and the new address is claimed.
+func (p *ifaceRepo) SetAddr(iface *mw.Iface, unicast mw.IP, netmask mw.IP, broadcast mw.IP) (*mw.Iface, error) {
+	if unicast.Mask(netmask) == nil {
+		psLog.E(fmt.Sprintf("invalid address: %s", unicast))
+		return nil, psErr.Error
+	}
+
+	p.mtx.Lock()
+
+	idx := p.index(iface)
+	if idx < 0 {
+		p.mtx.Unlock()
+		return nil, psErr.NotFound
+	}
+	for _, v := range p.ifaces {
+		if v != iface && v.Unicast.Equal(unicast) {
+			p.mtx.Unlock()
+			psLog.W(fmt.Sprintf("Address is already assigned: %s", unicast))
+			return nil, psErr.Exist
+		}
+	}
+	renumbered := &mw.Iface{
+		Family:    iface.Family,
+		Unicast:   unicast,
+		Netmask:   netmask,
+		Broadcast: broadcast,
+		Dev:       iface.Dev,
+	}
+	p.ifaces[idx] = renumbered
+
+	p.mtx.Unlock()
+
+	RouteRepo.Renumber(renumbered, iface)
+	notifyIfaceHooks(*iface)
+	notifyAddrHooks(renumbered)
+
+	psLog.D("interface address was changed",
+		fmt.Sprintf("old:    %s", iface.Unicast),
+		fmt.Sprintf("new:    %s", renumbered.Unicast),
+		fmt.Sprintf("device: %s (%s)", iface.Dev.Name(), iface.Dev.Priv().Name))
+
+	return renumbered, psErr.OK
+}
+
+// index returns the position of the interface. It must be called with the lock held.
+func (p *ifaceRepo) index(iface *mw.Iface) int {
+	for i, v := range p.ifaces {
+		if v == iface {
+			return i
+		}
+	}
+	return -1
+}
+
 type IRouteRepo interface {
 	Init()
 	Get(ip mw.IP) *Route
 	Register(network mw.IP, nextHop mw.IP, iface *mw.Iface)
 	RegisterDefaultGateway(iface *mw.Iface, nextHop mw.IP)
+	Unregister(iface *mw.Iface)
+	Renumber(iface *mw.Iface, old *mw.Iface)
 }
 
 type routeRepo struct {
@@ -265,13 +537,74 @@ func (p *routeRepo) RegisterDefaultGateway(iface *mw.Iface, nextHop mw.IP) {
 		fmt.Sprintf("device:   %s (%s)", iface.Dev.Name(), iface.Dev.Priv().Name))
 }
 
+// Unregister removes the routes through the interface.
+func (p *routeRepo) Unregister(iface *mw.Iface) {
+	defer p.mtx.Unlock()
+	p.mtx.Lock()
+
+	routes := p.routes[:0]
+	for _, route := range p.routes {
+		if route.Iface == iface {
+			psLog.D("route was unregistered",
+				fmt.Sprintf("network:  %s", route.Network),
+				fmt.Sprintf("netmask:  %s", route.Netmask),
+				fmt.Sprintf("next hop: %s", route.NextHop))
+			continue
+		}
+		routes = append(routes, route)
+	}
+	p.routes = routes
+}
+
+// Renumber moves the routes through the old interface to the interface which replaces it. The route to the network of
+// the old address is moved to the new network, and the routes of which next hop isn't reachable from the new network
+// are removed. The routes are replaced rather than modified, since they are read without the lock.
+func (p *routeRepo) Renumber(iface *mw.Iface, old *mw.Iface) {
+	defer p.mtx.Unlock()
+	p.mtx.Lock()
+
+	oldNetwork := old.Unicast.Mask(old.Netmask)
+	newNetwork := iface.Unicast.Mask(iface.Netmask)
+
+	routes := p.routes[:0]
+	for _, route := range p.routes {
+		if route.Iface != old {
+			routes = append(routes, route)
+			continue
+		}
+		renumbered := &Route{
+			Network: route.Network,
+			Netmask: route.Netmask,
+			NextHop: route.NextHop,
+			Iface:   iface,
+		}
+		if route.Network.Equal(oldNetwork) && route.Netmask.Equal(old.Netmask) {
+			renumbered.Network = newNetwork
+			renumbered.Netmask = iface.Netmask
+		} else if !route.NextHop.Equal(mw.V4Any) && !route.NextHop.Mask(iface.Netmask).Equal(newNetwork) {
+			psLog.D("route was unregistered (next hop is unreachable)",
+				fmt.Sprintf("network:  %s", route.Network),
+				fmt.Sprintf("netmask:  %s", route.Netmask),
+				fmt.Sprintf("next hop: %s", route.NextHop))
+			continue
+		}
+		routes = append(routes, renumbered)
+	}
+	p.routes = routes
+}
+
 func Start(wg *sync.WaitGroup) error {
+	if err := reactor.open(); err != psErr.OK {
+		return psErr.Error
+	}
+
 	if err := DeviceRepo.Up(); err != psErr.OK {
+		reactor.close()
 		return psErr.Error
 	}
 
 	wg.Add(1)
-	go watcher(wg)
+	go runReactor(wg)
 
 	return psErr.OK
 }
@@ -281,44 +614,18 @@ func Stop() {
 		Desired: worker.Stopped,
 	}
 	sigCh <- msg
-}
-
-func watcher(wg *sync.WaitGroup) {
-	defer func() {
-		psLog.D("repo watcher stopped")
-		wg.Done()
-	}()
-
-	monCh <- &worker.Message{
-		ID:      watcherId,
-		Current: worker.Running,
-	}
-
-	for {
-		select {
-		case msg := <-sigCh:
-			if msg.Desired == worker.Stopped {
-				monCh <- &worker.Message{
-					ID:      watcherId,
-					Current: worker.Stopped,
-				}
-				return
-			}
-		default:
-			if err := DeviceRepo.Poll(); err != psErr.OK {
-				monCh <- &worker.Message{
-					Current: worker.Error,
-				}
-				return
-			}
-		}
-	}
+	reactor.interrupt()
 }
 
 func init() {
 	monCh = make(chan *worker.Message, xChBufSize)
 	sigCh = make(chan *worker.Message, xChBufSize)
-	watcherId = monitor.Register("Repository Watcher", monCh, sigCh)
+	reactorId = monitor.Register("Device Reactor", monCh, sigCh)
+
+	reactor = &reactor_{
+		epfd: -1,
+		evfd: -1,
+	}
 
 	DeviceRepo = &deviceRepo{}
 	IfaceRepo = &ifaceRepo{}
@@ -34,6 +34,20 @@ func (m *MockIDeviceRepo) EXPECT() *MockIDeviceRepoMockRecorder {
 	return m.recorder
 }
 
+// DownDevice mocks base method.
+func (m *MockIDeviceRepo) DownDevice(dev mw.IDevice) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DownDevice", dev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DownDevice indicates an expected call of DownDevice.
+func (mr *MockIDeviceRepoMockRecorder) DownDevice(dev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DownDevice", reflect.TypeOf((*MockIDeviceRepo)(nil).DownDevice), dev)
+}
+
 // Init mocks base method.
 func (m *MockIDeviceRepo) Init() {
 	m.ctrl.T.Helper()
@@ -60,32 +74,32 @@ func (mr *MockIDeviceRepoMockRecorder) NextNumber() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NextNumber", reflect.TypeOf((*MockIDeviceRepo)(nil).NextNumber))
 }
 
-// Poll mocks base method.
-func (m *MockIDeviceRepo) Poll() error {
+// Register mocks base method.
+func (m *MockIDeviceRepo) Register(dev mw.IDevice) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Poll")
+	ret := m.ctrl.Call(m, "Register", dev)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// Poll indicates an expected call of Poll.
-func (mr *MockIDeviceRepoMockRecorder) Poll() *gomock.Call {
+// Register indicates an expected call of Register.
+func (mr *MockIDeviceRepoMockRecorder) Register(dev interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Poll", reflect.TypeOf((*MockIDeviceRepo)(nil).Poll))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockIDeviceRepo)(nil).Register), dev)
 }
 
-// Register mocks base method.
-func (m *MockIDeviceRepo) Register(dev mw.IDevice) error {
+// Unregister mocks base method.
+func (m *MockIDeviceRepo) Unregister(dev mw.IDevice) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Register", dev)
+	ret := m.ctrl.Call(m, "Unregister", dev)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// Register indicates an expected call of Register.
-func (mr *MockIDeviceRepoMockRecorder) Register(dev interface{}) *gomock.Call {
+// Unregister indicates an expected call of Unregister.
+func (mr *MockIDeviceRepoMockRecorder) Unregister(dev interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockIDeviceRepo)(nil).Register), dev)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unregister", reflect.TypeOf((*MockIDeviceRepo)(nil).Unregister), dev)
 }
 
 // Up mocks base method.
@@ -102,6 +116,20 @@ func (mr *MockIDeviceRepoMockRecorder) Up() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Up", reflect.TypeOf((*MockIDeviceRepo)(nil).Up))
 }
 
+// UpDevice mocks base method.
+func (m *MockIDeviceRepo) UpDevice(dev mw.IDevice) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpDevice", dev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpDevice indicates an expected call of UpDevice.
+func (mr *MockIDeviceRepoMockRecorder) UpDevice(dev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpDevice", reflect.TypeOf((*MockIDeviceRepo)(nil).UpDevice), dev)
+}
+
 // MockIIfaceRepo is a mock of IIfaceRepo interface.
 type MockIIfaceRepo struct {
 	ctrl     *gomock.Controller
@@ -179,6 +207,35 @@ func (mr *MockIIfaceRepoMockRecorder) Register(iface, dev interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockIIfaceRepo)(nil).Register), iface, dev)
 }
 
+// SetAddr mocks base method.
+func (m *MockIIfaceRepo) SetAddr(iface *mw.Iface, unicast, netmask, broadcast mw.IP) (*mw.Iface, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAddr", iface, unicast, netmask, broadcast)
+	ret0, _ := ret[0].(*mw.Iface)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetAddr indicates an expected call of SetAddr.
+func (mr *MockIIfaceRepoMockRecorder) SetAddr(iface, unicast, netmask, broadcast interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAddr", reflect.TypeOf((*MockIIfaceRepo)(nil).SetAddr), iface, unicast, netmask, broadcast)
+}
+
+// Unregister mocks base method.
+func (m *MockIIfaceRepo) Unregister(iface *mw.Iface) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unregister", iface)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unregister indicates an expected call of Unregister.
+func (mr *MockIIfaceRepoMockRecorder) Unregister(iface interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unregister", reflect.TypeOf((*MockIIfaceRepo)(nil).Unregister), iface)
+}
+
 // MockIRouteRepo is a mock of IRouteRepo interface.
 type MockIRouteRepo struct {
 	ctrl     *gomock.Controller
@@ -251,3 +308,27 @@ func (mr *MockIRouteRepoMockRecorder) RegisterDefaultGateway(iface, nextHop inte
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterDefaultGateway", reflect.TypeOf((*MockIRouteRepo)(nil).RegisterDefaultGateway), iface, nextHop)
 }
+
+// Renumber mocks base method.
+func (m *MockIRouteRepo) Renumber(iface, old *mw.Iface) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Renumber", iface, old)
+}
+
+// Renumber indicates an expected call of Renumber.
+func (mr *MockIRouteRepoMockRecorder) Renumber(iface, old interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Renumber", reflect.TypeOf((*MockIRouteRepo)(nil).Renumber), iface, old)
+}
+
+// Unregister mocks base method.
+func (m *MockIRouteRepo) Unregister(iface *mw.Iface) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Unregister", iface)
+}
+
+// Unregister indicates an expected call of Unregister.
+func (mr *MockIRouteRepoMockRecorder) Unregister(iface interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unregister", reflect.TypeOf((*MockIRouteRepo)(nil).Unregister), iface)
+}
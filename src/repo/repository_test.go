@@ -7,6 +7,7 @@ import (
 	"github.com/42milez/ProtocolStack/src/net/eth"
 	"github.com/42milez/ProtocolStack/src/worker"
 	"github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
 	"sync"
 	"testing"
 )
@@ -19,112 +20,68 @@ func TestDeviceRepo_NextNumber(t *testing.T) {
 	}
 }
 
-func TestDeviceRepo_Poll_1(t *testing.T) {
-	ctrl, teardown := setupRepositoryTest(t)
+func TestDeviceRepo_Register_1(t *testing.T) {
+	_, teardown := setupRepositoryTest(t)
 	defer teardown()
 
-	m := mw.NewMockIDevice(ctrl)
-	m.EXPECT().IsUp().Return(true)
-	m.EXPECT().Poll().Return(psErr.OK)
-	m.EXPECT().Type().Return(mw.EthernetDevice)
-	m.EXPECT().Name().Return("net0")
-	m.EXPECT().Priv().Return(mw.Privilege{Name: "tap0"})
-	m.EXPECT().Addr().Return(mw.EthAddr{})
-	_ = DeviceRepo.Register(m)
+	dev := &eth.TapDevice{}
 
-	want := psErr.OK
-	got := DeviceRepo.Poll()
-	if got != want {
-		t.Errorf("DeviceRepo.Poll() = %s; want %s", got, want)
+	got := DeviceRepo.Register(dev)
+	if got != psErr.OK {
+		t.Errorf("DeviceRepo.Register() = %s; want %s", got, psErr.OK)
 	}
 }
 
-// return OK when device is down
-func TestDeviceRepo_Poll_2(t *testing.T) {
-	ctrl, teardown := setupRepositoryTest(t)
+// Fail when it's trying to register same device.
+func TestDeviceRepo_Register_2(t *testing.T) {
+	_, teardown := setupRepositoryTest(t)
 	defer teardown()
 
-	m := mw.NewMockIDevice(ctrl)
-	m.EXPECT().IsUp().Return(false)
-	m.EXPECT().Type().Return(mw.EthernetDevice)
-	m.EXPECT().Name().Return("net0")
-	m.EXPECT().Priv().Return(mw.Privilege{Name: "tap0"})
-	m.EXPECT().Addr().Return(mw.EthAddr{})
-	_ = DeviceRepo.Register(m)
+	dev1 := &eth.TapDevice{Device: mw.Device{Name_: "net0"}}
+	dev2 := &eth.TapDevice{Device: mw.Device{Name_: "net0"}}
 
-	want := psErr.OK
-	got := DeviceRepo.Poll()
-	if got != want {
-		t.Errorf("DeviceRepo.Poll() = %s; want %s", got, want)
+	_ = DeviceRepo.Register(dev1)
+	got := DeviceRepo.Register(dev2)
+	if got != psErr.Error {
+		t.Errorf("DeviceRepo.Register() = %s; want %s", got, psErr.Error)
 	}
 }
 
-// DeviceRepo.Poll() returns OK when IDevice.Poll() returns Interrupted
-func TestDeviceRepo_Poll_3(t *testing.T) {
+func TestDeviceRepo_Unregister_1(t *testing.T) {
 	ctrl, teardown := setupRepositoryTest(t)
 	defer teardown()
 
 	m := mw.NewMockIDevice(ctrl)
 	m.EXPECT().IsUp().Return(true)
-	m.EXPECT().Poll().Return(psErr.Interrupted)
-	m.EXPECT().Type().Return(mw.EthernetDevice)
-	m.EXPECT().Name().Return("net0")
-	m.EXPECT().Priv().Return(mw.Privilege{Name: "tap0"})
+	m.EXPECT().Close().Return(psErr.OK)
+	m.EXPECT().Down()
+	m.EXPECT().Equal(gomock.Any()).Return(true)
+	m.EXPECT().Type().Return(mw.EthernetDevice).AnyTimes()
+	m.EXPECT().Name().Return("net0").AnyTimes()
+	m.EXPECT().Priv().Return(mw.Privilege{Name: "tap0"}).AnyTimes()
 	m.EXPECT().Addr().Return(mw.EthAddr{})
-	_ = DeviceRepo.Register(m)
-
-	want := psErr.OK
-	got := DeviceRepo.Poll()
-	if got != want {
-		t.Errorf("DeviceRepo.Poll() = %s; want %s", got, want)
-	}
-}
-
-// DeviceRepo.Poll() returns Error when IDevice.Poll() returns Error
-func TestDeviceRepo_Poll_4(t *testing.T) {
-	ctrl, teardown := setupRepositoryTest(t)
-	defer teardown()
 
-	m := mw.NewMockIDevice(ctrl)
-	m.EXPECT().IsUp().Return(true)
-	m.EXPECT().Poll().Return(psErr.Error)
-	m.EXPECT().Type().Return(mw.EthernetDevice)
-	m.EXPECT().Name().Return("net0")
-	m.EXPECT().Priv().Return(mw.Privilege{Name: "tap0"})
-	m.EXPECT().Addr().Return(mw.EthAddr{})
 	_ = DeviceRepo.Register(m)
 
-	want := psErr.Error
-	got := DeviceRepo.Poll()
-	if got != want {
-		t.Errorf("DeviceRepo.Poll() = %s; want %s", got, want)
-	}
-}
-
-func TestDeviceRepo_Register_1(t *testing.T) {
-	_, teardown := setupRepositoryTest(t)
-	defer teardown()
-
-	dev := &eth.TapDevice{}
-
-	got := DeviceRepo.Register(dev)
+	got := DeviceRepo.Unregister(m)
 	if got != psErr.OK {
-		t.Errorf("DeviceRepo.Register() = %s; want %s", got, psErr.OK)
+		t.Errorf("DeviceRepo.Unregister() = %s; want %s", got, psErr.OK)
+	}
+	if got := DeviceRepo.NextNumber(); got != 0 {
+		t.Errorf("DeviceRepo.NextNumber() = %d; want %d", got, 0)
 	}
 }
 
-// Fail when it's trying to register same device.
-func TestDeviceRepo_Register_2(t *testing.T) {
+// Fail when the device is not registered.
+func TestDeviceRepo_Unregister_2(t *testing.T) {
 	_, teardown := setupRepositoryTest(t)
 	defer teardown()
 
-	dev1 := &eth.TapDevice{Device: mw.Device{Name_: "net0"}}
-	dev2 := &eth.TapDevice{Device: mw.Device{Name_: "net0"}}
+	dev := &eth.TapDevice{Device: mw.Device{Name_: "net0"}}
 
-	_ = DeviceRepo.Register(dev1)
-	got := DeviceRepo.Register(dev2)
-	if got != psErr.Error {
-		t.Errorf("DeviceRepo.Register() = %s; want %s", got, psErr.Error)
+	got := DeviceRepo.Unregister(dev)
+	if got != psErr.NotFound {
+		t.Errorf("DeviceRepo.Unregister() = %s; want %s", got, psErr.NotFound)
 	}
 }
 
@@ -190,6 +147,134 @@ func TestUp_3(t *testing.T) {
 	}
 }
 
+func TestDeviceRepo_UpDevice_1(t *testing.T) {
+	ctrl, teardown := setupRepositoryTest(t)
+	defer teardown()
+
+	m := mw.NewMockIDevice(ctrl)
+	m.EXPECT().Equal(gomock.Any()).Return(true)
+	m.EXPECT().IsUp().Return(false)
+	m.EXPECT().Open().Return(psErr.OK)
+	m.EXPECT().Up()
+	m.EXPECT().Addr().Return(mw.EthAddr{})
+	m.EXPECT().Type().Return(mw.EthernetDevice).AnyTimes()
+	m.EXPECT().Name().Return("net0").AnyTimes()
+	m.EXPECT().Priv().Return(mw.Privilege{Name: "tap0"}).AnyTimes()
+
+	_ = DeviceRepo.Register(m)
+
+	got := DeviceRepo.UpDevice(m)
+	if got != psErr.OK {
+		t.Errorf("DeviceRepo.UpDevice() = %s; want %s", got, psErr.OK)
+	}
+}
+
+// Fail when the device is already up.
+func TestDeviceRepo_UpDevice_2(t *testing.T) {
+	ctrl, teardown := setupRepositoryTest(t)
+	defer teardown()
+
+	m := mw.NewMockIDevice(ctrl)
+	m.EXPECT().Equal(gomock.Any()).Return(true)
+	m.EXPECT().IsUp().Return(true)
+	m.EXPECT().Addr().Return(mw.EthAddr{})
+	m.EXPECT().Type().Return(mw.EthernetDevice).AnyTimes()
+	m.EXPECT().Name().Return("net0").AnyTimes()
+	m.EXPECT().Priv().Return(mw.Privilege{Name: "tap0"}).AnyTimes()
+
+	_ = DeviceRepo.Register(m)
+
+	got := DeviceRepo.UpDevice(m)
+	if got != psErr.Error {
+		t.Errorf("DeviceRepo.UpDevice() = %s; want %s", got, psErr.Error)
+	}
+}
+
+// Fail when the device is not registered.
+func TestDeviceRepo_UpDevice_3(t *testing.T) {
+	_, teardown := setupRepositoryTest(t)
+	defer teardown()
+
+	dev := &eth.TapDevice{Device: mw.Device{Name_: "net0"}}
+
+	got := DeviceRepo.UpDevice(dev)
+	if got != psErr.NotFound {
+		t.Errorf("DeviceRepo.UpDevice() = %s; want %s", got, psErr.NotFound)
+	}
+}
+
+func TestDeviceRepo_DownDevice_1(t *testing.T) {
+	ctrl, teardown := setupRepositoryTest(t)
+	defer teardown()
+
+	m := mw.NewMockIDevice(ctrl)
+	m.EXPECT().Equal(gomock.Any()).Return(true)
+	m.EXPECT().IsUp().Return(true)
+	m.EXPECT().Close().Return(psErr.OK)
+	m.EXPECT().Down()
+	m.EXPECT().Addr().Return(mw.EthAddr{})
+	m.EXPECT().Type().Return(mw.EthernetDevice).AnyTimes()
+	m.EXPECT().Name().Return("net0").AnyTimes()
+	m.EXPECT().Priv().Return(mw.Privilege{Name: "tap0"}).AnyTimes()
+
+	_ = DeviceRepo.Register(m)
+
+	got := DeviceRepo.DownDevice(m)
+	if got != psErr.OK {
+		t.Errorf("DeviceRepo.DownDevice() = %s; want %s", got, psErr.OK)
+	}
+	if got := DeviceRepo.NextNumber(); got != 1 {
+		t.Errorf("DeviceRepo.NextNumber() = %d; want %d", got, 1)
+	}
+}
+
+// Fail when the device is already down.
+func TestDeviceRepo_DownDevice_2(t *testing.T) {
+	ctrl, teardown := setupRepositoryTest(t)
+	defer teardown()
+
+	m := mw.NewMockIDevice(ctrl)
+	m.EXPECT().Equal(gomock.Any()).Return(true)
+	m.EXPECT().IsUp().Return(false)
+	m.EXPECT().Addr().Return(mw.EthAddr{})
+	m.EXPECT().Type().Return(mw.EthernetDevice).AnyTimes()
+	m.EXPECT().Name().Return("net0").AnyTimes()
+	m.EXPECT().Priv().Return(mw.Privilege{Name: "tap0"}).AnyTimes()
+
+	_ = DeviceRepo.Register(m)
+
+	got := DeviceRepo.DownDevice(m)
+	if got != psErr.Error {
+		t.Errorf("DeviceRepo.DownDevice() = %s; want %s", got, psErr.Error)
+	}
+}
+
+// Success when the interface of the device is unregistered with the device.
+func TestDeviceRepo_Unregister_3(t *testing.T) {
+	_, teardown := setupRepositoryTest(t)
+	defer teardown()
+
+	dev := &eth.TapDevice{Device: mw.Device{Name_: "net0", Priv_: mw.Privilege{FD: -1, Name: "tap0"}}}
+	iface := &mw.Iface{
+		Family:  mw.V4AddrFamily,
+		Unicast: mw.IP{192, 0, 2, 1},
+		Netmask: mw.IP{255, 255, 255, 0},
+	}
+	_ = DeviceRepo.Register(dev)
+	_ = IfaceRepo.Register(iface, dev)
+	RouteRepo.Register(mw.IP{192, 0, 2, 0}, mw.V4Any, iface)
+
+	if got := DeviceRepo.Unregister(dev); got != psErr.OK {
+		t.Errorf("DeviceRepo.Unregister() = %s; want %s", got, psErr.OK)
+	}
+	if IfaceRepo.Lookup(dev, mw.V4AddrFamily) != nil {
+		t.Errorf("IfaceRepo.Lookup() returns unregistered Iface")
+	}
+	if RouteRepo.Get(mw.IP{192, 0, 2, 2}) != nil {
+		t.Errorf("RouteRepo.Get() returns route through unregistered Iface")
+	}
+}
+
 func TestIfaceRepo_Get_1(t *testing.T) {
 	_, teardown := setupRepositoryTest(t)
 	defer teardown()
@@ -327,6 +412,141 @@ func TestIfaceRepo_Register_2(t *testing.T) {
 	}
 }
 
+func TestIfaceRepo_Unregister_1(t *testing.T) {
+	_, teardown := setupRepositoryTest(t)
+	defer teardown()
+
+	iface, _ := setupIface()
+	RouteRepo.Register(mw.IP{192, 0, 2, 0}, mw.V4Any, iface)
+	RouteRepo.RegisterDefaultGateway(iface, mw.IP{192, 0, 2, 254})
+
+	var notified []mw.IP
+	AddIfaceHook(func(old mw.Iface) {
+		notified = append(notified, old.Unicast)
+	})
+
+	got := IfaceRepo.Unregister(iface)
+	if got != psErr.OK {
+		t.Errorf("IfaceRepo.Unregister() = %s; want %s", got, psErr.OK)
+	}
+	if IfaceRepo.Get(iface.Unicast) != nil {
+		t.Errorf("IfaceRepo.Get() returns unregistered Iface")
+	}
+	if RouteRepo.Get(mw.IP{192, 0, 2, 2}) != nil || RouteRepo.Get(mw.IP{198, 51, 100, 1}) != nil {
+		t.Errorf("RouteRepo.Get() returns route through unregistered Iface")
+	}
+	if d := cmp.Diff(notified, []mw.IP{{192, 0, 2, 1}}); d != "" {
+		t.Errorf("notified addresses differs: (-got +want)\n%s", d)
+	}
+}
+
+// Fail when the interface is not registered.
+func TestIfaceRepo_Unregister_2(t *testing.T) {
+	_, teardown := setupRepositoryTest(t)
+	defer teardown()
+
+	iface := &mw.Iface{Family: mw.V4AddrFamily, Unicast: mw.IP{192, 0, 2, 1}}
+
+	got := IfaceRepo.Unregister(iface)
+	if got != psErr.NotFound {
+		t.Errorf("IfaceRepo.Unregister() = %s; want %s", got, psErr.NotFound)
+	}
+}
+
+func TestIfaceRepo_SetAddr_1(t *testing.T) {
+	_, teardown := setupRepositoryTest(t)
+	defer teardown()
+
+	iface, _ := setupIface()
+	RouteRepo.Register(mw.IP{192, 0, 2, 0}, mw.V4Any, iface)
+	RouteRepo.RegisterDefaultGateway(iface, mw.IP{192, 0, 2, 254})
+
+	var notified []mw.IP
+	AddIfaceHook(func(old mw.Iface) {
+		notified = append(notified, old.Unicast)
+	})
+
+	var claimed []mw.IP
+	AddAddrHook(func(iface *mw.Iface) {
+		claimed = append(claimed, iface.Unicast)
+	})
+
+	renumbered, got := IfaceRepo.SetAddr(iface, mw.IP{198, 51, 100, 1}, mw.IP{255, 255, 255, 0},
+		mw.IP{198, 51, 100, 255})
+	if got != psErr.OK {
+		t.Errorf("IfaceRepo.SetAddr() = %s; want %s", got, psErr.OK)
+	}
+	if renumbered == nil || renumbered == iface || renumbered.Dev != iface.Dev {
+		t.Fatalf("IfaceRepo.SetAddr() returns invalid Iface")
+	}
+	if IfaceRepo.Get(mw.IP{198, 51, 100, 1}) != renumbered {
+		t.Errorf("IfaceRepo.Get() returns invalid Iface")
+	}
+	if IfaceRepo.Get(mw.IP{192, 0, 2, 1}) != nil {
+		t.Errorf("IfaceRepo.Get() returns replaced Iface")
+	}
+	// the interface which was replaced isn't modified since it may be read without the lock
+	if !iface.Unicast.Equal(mw.IP{192, 0, 2, 1}) {
+		t.Errorf("replaced interface was modified: %s", iface.Unicast)
+	}
+	if IfaceRepo.Lookup(iface.Dev, mw.V4AddrFamily) != renumbered {
+		t.Errorf("IfaceRepo.Lookup() returns invalid Iface")
+	}
+	if d := cmp.Diff(notified, []mw.IP{{192, 0, 2, 1}}); d != "" {
+		t.Errorf("notified addresses differs: (-got +want)\n%s", d)
+	}
+	if d := cmp.Diff(claimed, []mw.IP{{198, 51, 100, 1}}); d != "" {
+		t.Errorf("claimed addresses differs: (-got +want)\n%s", d)
+	}
+
+	// the route to the network follows the new address
+	if route := RouteRepo.Get(mw.IP{198, 51, 100, 2}); route == nil || !route.Network.Equal(mw.IP{198, 51, 100, 0}) ||
+		route.Iface != renumbered {
+		t.Errorf("RouteRepo.Get() returns invalid route")
+	}
+	// the default gateway is no longer reachable
+	if RouteRepo.Get(mw.IP{203, 0, 113, 1}) != nil {
+		t.Errorf("RouteRepo.Get() returns route through unreachable gateway")
+	}
+}
+
+// Fail when the address is assigned to another interface.
+func TestIfaceRepo_SetAddr_2(t *testing.T) {
+	_, teardown := setupRepositoryTest(t)
+	defer teardown()
+
+	iface, _ := setupIface()
+	other := &mw.Iface{
+		Family:  mw.V4AddrFamily,
+		Unicast: mw.IP{198, 51, 100, 1},
+		Netmask: mw.IP{255, 255, 255, 0},
+	}
+	_ = IfaceRepo.Register(other, &eth.TapDevice{Device: mw.Device{Name_: "net1"}})
+
+	renumbered, got := IfaceRepo.SetAddr(iface, mw.IP{198, 51, 100, 1}, mw.IP{255, 255, 255, 0},
+		mw.IP{198, 51, 100, 255})
+	if renumbered != nil || got != psErr.Exist {
+		t.Errorf("IfaceRepo.SetAddr() = (%v, %s); want (%v, %s)", renumbered, got, nil, psErr.Exist)
+	}
+	if !iface.Unicast.Equal(mw.IP{192, 0, 2, 1}) {
+		t.Errorf("address was changed: %s", iface.Unicast)
+	}
+}
+
+// Fail when the interface is not registered.
+func TestIfaceRepo_SetAddr_3(t *testing.T) {
+	_, teardown := setupRepositoryTest(t)
+	defer teardown()
+
+	iface := &mw.Iface{Family: mw.V4AddrFamily, Unicast: mw.IP{192, 0, 2, 1}}
+
+	renumbered, got := IfaceRepo.SetAddr(iface, mw.IP{198, 51, 100, 1}, mw.IP{255, 255, 255, 0},
+		mw.IP{198, 51, 100, 255})
+	if renumbered != nil || got != psErr.NotFound {
+		t.Errorf("IfaceRepo.SetAddr() = (%v, %s); want (%v, %s)", renumbered, got, nil, psErr.NotFound)
+	}
+}
+
 func TestRouteRepo_Get_1(t *testing.T) {
 	_, teardown := setupRepositoryTest(t)
 	defer teardown()
@@ -399,6 +619,10 @@ func TestStart(t *testing.T) {
 	if monMsg.Current != worker.Running {
 		t.Errorf("Start() failed")
 	}
+
+	Stop()
+	<-monCh
+	wg.Wait()
 }
 
 func TestStop(t *testing.T) {
@@ -414,6 +638,7 @@ func TestStop(t *testing.T) {
 	if monMsg.Current != worker.Stopped {
 		t.Errorf("Stop() failed")
 	}
+	wg.Wait()
 }
 
 func setupRepositoryTest(t *testing.T) (ctrl *gomock.Controller, teardown func()) {
@@ -423,6 +648,17 @@ func setupRepositoryTest(t *testing.T) (ctrl *gomock.Controller, teardown func()
 		psLog.EnableOutput()
 		DeviceRepo.Init()
 		IfaceRepo.Init()
+		RouteRepo.Init()
+		// the hooks added by a test don't remain in the later tests
+		ifaceHookMtx.Lock()
+		ifaceHooks = nil
+		ifaceHookMtx.Unlock()
+		addrHookMtx.Lock()
+		addrHooks = nil
+		addrHookMtx.Unlock()
+		deviceHookMtx.Lock()
+		deviceHooks = nil
+		deviceHookMtx.Unlock()
 	}
 	teardown = func() {
 		ctrl.Finish()
@@ -430,3 +666,23 @@ func setupRepositoryTest(t *testing.T) (ctrl *gomock.Controller, teardown func()
 	}
 	return
 }
+
+func setupIface() (*mw.Iface, mw.IDevice) {
+	iface := &mw.Iface{
+		Family:    mw.V4AddrFamily,
+		Unicast:   mw.IP{192, 0, 2, 1},
+		Netmask:   mw.IP{255, 255, 255, 0},
+		Broadcast: mw.IP{192, 0, 2, 255},
+	}
+	dev := &eth.TapDevice{
+		Device: mw.Device{
+			Type_: mw.EthernetDevice,
+			MTU_:  mw.EthPayloadLenMax,
+			Flag_: mw.BroadcastFlag | mw.NeedArpFlag,
+			Addr_: mw.EthAddr{11, 12, 13, 14, 15, 16},
+			Priv_: mw.Privilege{FD: -1, Name: "tap0"},
+		},
+	}
+	_ = IfaceRepo.Register(iface, dev)
+	return iface, dev
+}
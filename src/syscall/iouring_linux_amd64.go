@@ -0,0 +1,87 @@
+package syscall
+
+// io_uring @ io_uring.h
+// https://github.com/torvalds/linux/blob/master/include/uapi/linux/io_uring.h
+
+const sysIoUringSetup = 425
+const sysIoUringEnter = 426
+
+// offsets of the regions which are mapped with mmap(2)
+const (
+	IoUringOffSqRing = 0
+	IoUringOffCqRing = 0x8000000
+	IoUringOffSqes   = 0x10000000
+)
+
+// IoUringEnterGetEvents makes io_uring_enter(2) wait for minComplete completions.
+const IoUringEnterGetEvents = 0x01
+
+// opcodes of submission queue entries
+const (
+	IoUringOpNop   = 0
+	IoUringOpRead  = 22
+	IoUringOpWrite = 23
+)
+
+// IoUringParams is struct io_uring_params, which io_uring_setup(2) fills with the layout of the rings.
+type IoUringParams struct {
+	SqEntries    uint32
+	CqEntries    uint32
+	Flags        uint32
+	SqThreadCPU  uint32
+	SqThreadIdle uint32
+	Features     uint32
+	WqFd         uint32
+	_            [3]uint32
+	SqOff        IoSqringOffsets
+	CqOff        IoCqringOffsets
+}
+
+type IoSqringOffsets struct {
+	Head        uint32
+	Tail        uint32
+	RingMask    uint32
+	RingEntries uint32
+	Flags       uint32
+	Dropped     uint32
+	Array       uint32
+	_           uint32
+	UserAddr    uint64
+}
+
+type IoCqringOffsets struct {
+	Head        uint32
+	Tail        uint32
+	RingMask    uint32
+	RingEntries uint32
+	Overflow    uint32
+	Cqes        uint32
+	Flags       uint32
+	_           uint32
+	UserAddr    uint64
+}
+
+// IoUringSqe is struct io_uring_sqe (64 bytes).
+type IoUringSqe struct {
+	Opcode      uint8
+	Flags       uint8
+	IoPrio      uint16
+	Fd          int32
+	Off         uint64
+	Addr        uint64
+	Len         uint32
+	RwFlags     uint32
+	UserData    uint64
+	BufIndex    uint16
+	Personality uint16
+	SpliceFdIn  int32
+	Addr3       uint64
+	_           uint64
+}
+
+// IoUringCqe is struct io_uring_cqe (16 bytes).
+type IoUringCqe struct {
+	UserData uint64
+	Res      int32
+	Flags    uint32
+}
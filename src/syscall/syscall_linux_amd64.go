@@ -10,19 +10,42 @@ import (
 var Syscall ISyscall
 
 type ISyscall interface {
+	Bind(fd int, sa syscall.Sockaddr) (err error)
 	Open(path string, mode int, perm uint32) (fd int, err error)
 	Close(fd int) (err error)
 	EpollCreate1(flag int) (fd int, err error)
 	EpollCtl(epfd int, op int, fd int, event *syscall.EpollEvent) (err error)
 	EpollWait(epfd int, events []syscall.EpollEvent, msec int) (n int, err error)
+	Eventfd(initval uint, flags int) (fd int, err error)
 	Ioctl(fd int, code int, data unsafe.Pointer) (err syscall.Errno)
+	IoctlInt(fd int, code int, value uint) (err syscall.Errno)
+	IoUringEnter(fd int, toSubmit uint32, minComplete uint32, flags uint32) (n int, err error)
+	IoUringSetup(entries uint32, params *IoUringParams) (fd int, err error)
+	Mmap(fd int, offset int64, length int, prot int, flags int) (data []byte, err error)
+	Munmap(b []byte) (err error)
 	Socket(domain, typ, proto int) (fd int, err error)
 	Read(fd int, p []byte) (n int, err error)
+	Recvfrom(fd int, p []byte, flags int) (n int, from syscall.Sockaddr, err error)
+	Sendto(fd int, p []byte, flags int, to syscall.Sockaddr) (err error)
+	SetsockoptPacketMreq(fd int, opt int, mreq *PacketMreq) (err error)
 	Write(fd int, p []byte) (n int, err error)
 }
 
+// PacketMreq is struct packet_mreq of if_packet.h, which the syscall package doesn't define.
+// https://github.com/torvalds/linux/blob/master/include/uapi/linux/if_packet.h
+type PacketMreq struct {
+	Ifindex int32
+	Type    uint16
+	Alen    uint16
+	Address [8]byte
+}
+
 type scImpl struct{}
 
+func (scImpl) Bind(fd int, sa syscall.Sockaddr) (err error) {
+	return syscall.Bind(fd, sa)
+}
+
 func (scImpl) Open(path string, mode int, perm uint32) (fd int, err error) {
 	return syscall.Open(path, mode, perm)
 }
@@ -43,6 +66,14 @@ func (scImpl) EpollWait(epfd int, events []syscall.EpollEvent, msec int) (n int,
 	return syscall.EpollWait(epfd, events, msec)
 }
 
+func (scImpl) Eventfd(initval uint, flags int) (fd int, err error) {
+	r, _, errno := syscall.Syscall(syscall.SYS_EVENTFD2, uintptr(initval), uintptr(flags), 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(r), nil
+}
+
 func (scImpl) Ioctl(fd int, code int, data unsafe.Pointer) (err syscall.Errno) {
 	// doc: second return value of syscall.Syscall needs to be documented #29842
 	// https://github.com/golang/go/issues/29842
@@ -50,6 +81,37 @@ func (scImpl) Ioctl(fd int, code int, data unsafe.Pointer) (err syscall.Errno) {
 	return
 }
 
+// IoctlInt issues an ioctl request which takes an integer argument by value (e.g. TUNSETOFFLOAD).
+func (scImpl) IoctlInt(fd int, code int, value uint) (err syscall.Errno) {
+	_, _, err = syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(code), uintptr(value))
+	return
+}
+
+func (scImpl) IoUringEnter(fd int, toSubmit uint32, minComplete uint32, flags uint32) (n int, err error) {
+	r, _, errno := syscall.Syscall6(sysIoUringEnter, uintptr(fd), uintptr(toSubmit), uintptr(minComplete),
+		uintptr(flags), 0, 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(r), nil
+}
+
+func (scImpl) IoUringSetup(entries uint32, params *IoUringParams) (fd int, err error) {
+	r, _, errno := syscall.Syscall(sysIoUringSetup, uintptr(entries), uintptr(unsafe.Pointer(params)), 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(r), nil
+}
+
+func (scImpl) Mmap(fd int, offset int64, length int, prot int, flags int) (data []byte, err error) {
+	return syscall.Mmap(fd, offset, length, prot, flags)
+}
+
+func (scImpl) Munmap(b []byte) (err error) {
+	return syscall.Munmap(b)
+}
+
 func (scImpl) Socket(domain, typ, proto int) (fd int, err error) {
 	return syscall.Socket(domain, typ, proto)
 }
@@ -58,6 +120,23 @@ func (scImpl) Read(fd int, p []byte) (n int, err error) {
 	return syscall.Read(fd, p)
 }
 
+func (scImpl) Recvfrom(fd int, p []byte, flags int) (n int, from syscall.Sockaddr, err error) {
+	return syscall.Recvfrom(fd, p, flags)
+}
+
+func (scImpl) Sendto(fd int, p []byte, flags int, to syscall.Sockaddr) (err error) {
+	return syscall.Sendto(fd, p, flags, to)
+}
+
+func (scImpl) SetsockoptPacketMreq(fd int, opt int, mreq *PacketMreq) (err error) {
+	_, _, errno := syscall.Syscall6(syscall.SYS_SETSOCKOPT, uintptr(fd), syscall.SOL_PACKET, uintptr(opt),
+		uintptr(unsafe.Pointer(mreq)), unsafe.Sizeof(*mreq), 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
 func (scImpl) Write(fd int, p []byte) (n int, err error) {
 	return syscall.Write(fd, p)
 }
@@ -35,6 +35,20 @@ func (m *MockISyscall) EXPECT() *MockISyscallMockRecorder {
 	return m.recorder
 }
 
+// Bind mocks base method.
+func (m *MockISyscall) Bind(fd int, sa syscall.Sockaddr) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Bind", fd, sa)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Bind indicates an expected call of Bind.
+func (mr *MockISyscallMockRecorder) Bind(fd, sa interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Bind", reflect.TypeOf((*MockISyscall)(nil).Bind), fd, sa)
+}
+
 // Close mocks base method.
 func (m *MockISyscall) Close(fd int) error {
 	m.ctrl.T.Helper()
@@ -93,6 +107,51 @@ func (mr *MockISyscallMockRecorder) EpollWait(epfd, events, msec interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EpollWait", reflect.TypeOf((*MockISyscall)(nil).EpollWait), epfd, events, msec)
 }
 
+// Eventfd mocks base method.
+func (m *MockISyscall) Eventfd(initval uint, flags int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Eventfd", initval, flags)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Eventfd indicates an expected call of Eventfd.
+func (mr *MockISyscallMockRecorder) Eventfd(initval, flags interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Eventfd", reflect.TypeOf((*MockISyscall)(nil).Eventfd), initval, flags)
+}
+
+// IoUringEnter mocks base method.
+func (m *MockISyscall) IoUringEnter(fd int, toSubmit, minComplete, flags uint32) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IoUringEnter", fd, toSubmit, minComplete, flags)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IoUringEnter indicates an expected call of IoUringEnter.
+func (mr *MockISyscallMockRecorder) IoUringEnter(fd, toSubmit, minComplete, flags interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IoUringEnter", reflect.TypeOf((*MockISyscall)(nil).IoUringEnter), fd, toSubmit, minComplete, flags)
+}
+
+// IoUringSetup mocks base method.
+func (m *MockISyscall) IoUringSetup(entries uint32, params *IoUringParams) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IoUringSetup", entries, params)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IoUringSetup indicates an expected call of IoUringSetup.
+func (mr *MockISyscallMockRecorder) IoUringSetup(entries, params interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IoUringSetup", reflect.TypeOf((*MockISyscall)(nil).IoUringSetup), entries, params)
+}
+
 // Ioctl mocks base method.
 func (m *MockISyscall) Ioctl(fd, code int, data unsafe.Pointer) syscall.Errno {
 	m.ctrl.T.Helper()
@@ -107,6 +166,49 @@ func (mr *MockISyscallMockRecorder) Ioctl(fd, code, data interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ioctl", reflect.TypeOf((*MockISyscall)(nil).Ioctl), fd, code, data)
 }
 
+// IoctlInt mocks base method.
+func (m *MockISyscall) IoctlInt(fd, code int, value uint) syscall.Errno {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IoctlInt", fd, code, value)
+	ret0, _ := ret[0].(syscall.Errno)
+	return ret0
+}
+
+// IoctlInt indicates an expected call of IoctlInt.
+func (mr *MockISyscallMockRecorder) IoctlInt(fd, code, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IoctlInt", reflect.TypeOf((*MockISyscall)(nil).IoctlInt), fd, code, value)
+}
+
+// Mmap mocks base method.
+func (m *MockISyscall) Mmap(fd int, offset int64, length, prot, flags int) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Mmap", fd, offset, length, prot, flags)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Mmap indicates an expected call of Mmap.
+func (mr *MockISyscallMockRecorder) Mmap(fd, offset, length, prot, flags interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Mmap", reflect.TypeOf((*MockISyscall)(nil).Mmap), fd, offset, length, prot, flags)
+}
+
+// Munmap mocks base method.
+func (m *MockISyscall) Munmap(b []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Munmap", b)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Munmap indicates an expected call of Munmap.
+func (mr *MockISyscallMockRecorder) Munmap(b interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Munmap", reflect.TypeOf((*MockISyscall)(nil).Munmap), b)
+}
+
 // Open mocks base method.
 func (m *MockISyscall) Open(path string, mode int, perm uint32) (int, error) {
 	m.ctrl.T.Helper()
@@ -137,6 +239,50 @@ func (mr *MockISyscallMockRecorder) Read(fd, p interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Read", reflect.TypeOf((*MockISyscall)(nil).Read), fd, p)
 }
 
+// Recvfrom mocks base method.
+func (m *MockISyscall) Recvfrom(fd int, p []byte, flags int) (int, syscall.Sockaddr, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Recvfrom", fd, p, flags)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(syscall.Sockaddr)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Recvfrom indicates an expected call of Recvfrom.
+func (mr *MockISyscallMockRecorder) Recvfrom(fd, p, flags interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Recvfrom", reflect.TypeOf((*MockISyscall)(nil).Recvfrom), fd, p, flags)
+}
+
+// Sendto mocks base method.
+func (m *MockISyscall) Sendto(fd int, p []byte, flags int, to syscall.Sockaddr) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Sendto", fd, p, flags, to)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Sendto indicates an expected call of Sendto.
+func (mr *MockISyscallMockRecorder) Sendto(fd, p, flags, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sendto", reflect.TypeOf((*MockISyscall)(nil).Sendto), fd, p, flags, to)
+}
+
+// SetsockoptPacketMreq mocks base method.
+func (m *MockISyscall) SetsockoptPacketMreq(fd, opt int, mreq *PacketMreq) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetsockoptPacketMreq", fd, opt, mreq)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetsockoptPacketMreq indicates an expected call of SetsockoptPacketMreq.
+func (mr *MockISyscallMockRecorder) SetsockoptPacketMreq(fd, opt, mreq interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetsockoptPacketMreq", reflect.TypeOf((*MockISyscall)(nil).SetsockoptPacketMreq), fd, opt, mreq)
+}
+
 // Socket mocks base method.
 func (m *MockISyscall) Socket(domain, typ, proto int) (int, error) {
 	m.ctrl.T.Helper()
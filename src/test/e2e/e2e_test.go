@@ -0,0 +1,219 @@
+package e2e
+
+import (
+	psErr "github.com/42milez/ProtocolStack/src/error"
+	psLog "github.com/42milez/ProtocolStack/src/log"
+	"github.com/42milez/ProtocolStack/src/mw"
+	"github.com/42milez/ProtocolStack/src/net"
+	"github.com/42milez/ProtocolStack/src/net/arp"
+	"github.com/42milez/ProtocolStack/src/net/eth"
+	"github.com/42milez/ProtocolStack/src/net/icmp"
+	"github.com/42milez/ProtocolStack/src/net/ip"
+	"github.com/42milez/ProtocolStack/src/net/tcp"
+	"github.com/42milez/ProtocolStack/src/repo"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+const timeout = 3 * time.Second
+
+// claimTimeout is longer than the time which the addresses take to be probed and announced (RFC 5227).
+const claimTimeout = 15 * time.Second
+
+// Two interfaces of the stack, host A (192.0.2.1, net0) and host B (192.0.2.2, net1), are connected with a veth pair.
+var hostA = endpoint{
+	ethAddr: mw.EthAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01},
+	ip:      mw.ParseIP("192.0.2.1"),
+}
+var hostB = endpoint{
+	ethAddr: mw.EthAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x02},
+	ip:      mw.ParseIP("192.0.2.2"),
+}
+
+type endpoint struct {
+	ethAddr mw.EthAddr
+	ip      mw.IP
+	iface   *mw.Iface
+}
+
+// The hosts are also connected with VLAN 10 over the same veth pair, host A (198.51.100.1, net0.10) and host B
+// (198.51.100.2, net1.10).
+var vlanHostA = endpoint{
+	ethAddr: hostA.ethAddr,
+	ip:      mw.ParseIP("198.51.100.1"),
+}
+var vlanHostB = endpoint{
+	ethAddr: hostB.ethAddr,
+	ip:      mw.ParseIP("198.51.100.2"),
+}
+
+func TestMain(m *testing.M) {
+	psLog.DisableOutput()
+
+	devA, devB := eth.GenVethPair("net0", "net1", hostA.ethAddr, hostB.ethAddr)
+	hostA.iface = net.GenIface(hostA.ip.String(), "255.255.255.0", "192.0.2.255")
+	hostB.iface = net.GenIface(hostB.ip.String(), "255.255.255.0", "192.0.2.255")
+	vlanA := eth.GenVlanDevice("net0.10", devA, 10)
+	vlanB := eth.GenVlanDevice("net1.10", devB, 10)
+	vlanHostA.iface = net.GenIface(vlanHostA.ip.String(), "255.255.255.0", "198.51.100.255")
+	vlanHostB.iface = net.GenIface(vlanHostB.ip.String(), "255.255.255.0", "198.51.100.255")
+	for _, v := range []struct {
+		dev   mw.IDevice
+		iface *mw.Iface
+	}{{devA, hostA.iface}, {devB, hostB.iface}, {vlanA, vlanHostA.iface}, {vlanB, vlanHostB.iface}} {
+		if err := repo.DeviceRepo.Register(v.dev); err != psErr.OK {
+			os.Exit(1)
+		}
+		if err := repo.IfaceRepo.Register(v.iface, v.dev); err != psErr.OK {
+			os.Exit(1)
+		}
+	}
+	// packets which don't specify a source address are sent from host A
+	repo.RouteRepo.Register(mw.ParseIP("192.0.2.0"), mw.V4Any, hostA.iface)
+	repo.RouteRepo.Register(mw.ParseIP("198.51.100.0"), mw.V4Any, vlanHostA.iface)
+
+	var wg sync.WaitGroup
+	for _, start := range []func(wg *sync.WaitGroup) error{arp.Start, eth.Start, icmp.Start, ip.Start, repo.Start, tcp.Start} {
+		if err := start(&wg); err != psErr.OK {
+			os.Exit(1)
+		}
+	}
+
+	if !waitClaimed(hostA.iface, hostB.iface, vlanHostA.iface, vlanHostB.iface) {
+		os.Exit(1)
+	}
+
+	code := m.Run()
+
+	arp.Stop()
+	eth.Stop()
+	icmp.Stop()
+	ip.Stop()
+	repo.Stop()
+	tcp.Stop()
+	wg.Wait()
+
+	os.Exit(code)
+}
+
+func TestArpResolution(t *testing.T) {
+	var zero = time.Now()
+	for {
+		addr, status := arp.Resolver.Resolve(hostA.iface, hostB.ip)
+		if status == arp.Complete && addr == hostB.ethAddr {
+			break
+		}
+		if time.Since(zero) > timeout {
+			t.Fatalf("Resolve() = (%s, %d); want (%s, %d)", addr, status, hostB.ethAddr, arp.Complete)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestIcmpEcho(t *testing.T) {
+	echo(t, hostA, hostB, 1)
+}
+
+func TestVlanIcmpEcho(t *testing.T) {
+	echo(t, vlanHostA, vlanHostB, 2)
+}
+
+// The device of host B is brought down and up again while the stack is running.
+func TestDeviceDownUp(t *testing.T) {
+	dev := hostB.iface.Dev
+	if err := repo.DeviceRepo.DownDevice(dev); err != psErr.OK {
+		t.Fatalf("DeviceRepo.DownDevice() = %s; want %s", err, psErr.OK)
+	}
+	if err := repo.DeviceRepo.UpDevice(dev); err != psErr.OK {
+		t.Fatalf("DeviceRepo.UpDevice() = %s; want %s", err, psErr.OK)
+	}
+	// the address is probed again when the device comes back up
+	if !waitClaimed(hostB.iface) {
+		t.Fatalf("address of host B wasn't claimed")
+	}
+	echo(t, hostA, hostB, 3)
+}
+
+func TestTcpHandshake(t *testing.T) {
+	server, err := tcp.Open()
+	if err != psErr.OK {
+		t.Fatalf("tcp.Open() = %s; want %s", err, psErr.OK)
+	}
+	local := tcp.EndPoint{Addr: hostB.ip.ToV4(), Port: 12345}
+	if err := tcp.Bind(server, local); err != psErr.OK {
+		t.Fatalf("tcp.Bind() = %s; want %s", err, psErr.OK)
+	}
+	if err := tcp.Listen(server, 1); err != psErr.OK {
+		t.Fatalf("tcp.Listen() = %s; want %s", err, psErr.OK)
+	}
+
+	accepted := make(chan tcp.EndPoint, 1)
+	go func() {
+		_, foreign, _ := tcp.Accept(server)
+		accepted <- foreign
+	}()
+
+	client, err := tcp.Open()
+	if err != psErr.OK {
+		t.Fatalf("tcp.Open() = %s; want %s", err, psErr.OK)
+	}
+	if err := tcp.Connect(client, local); err != psErr.OK {
+		t.Fatalf("tcp.Connect() = %s; want %s", err, psErr.OK)
+	}
+
+	select {
+	case foreign := <-accepted:
+		if foreign.Addr != hostA.ip.ToV4() {
+			t.Errorf("foreign address = %s; want %s", foreign.Addr, hostA.ip)
+		}
+	case <-time.After(timeout):
+		t.Fatal("connection was not accepted")
+	}
+}
+
+// echo sends an echo request from src to dst and waits for the reply.
+func echo(t *testing.T, src endpoint, dst endpoint, id uint16) {
+	seq := uint16(1)
+	send := func(data []byte) {
+		mw.IcmpTxCh <- &mw.IcmpTxMessage{
+			Type:    icmp.Echo,
+			Content: uint32(id)<<16 | uint32(seq),
+			Data:    data,
+			Src:     src.ip,
+			Dst:     dst.ip,
+		}
+	}
+	send([]byte{0x01, 0x02, 0x03, 0x04})
+
+	for {
+		select {
+		case reply := <-icmp.ReplyQueue:
+			if reply.ID != id || reply.Seq != seq {
+				t.Errorf("reply = (id: %d, seq: %d); want (id: %d, seq: %d)", reply.ID, reply.Seq, id, seq)
+			}
+			return
+		case letter := <-mw.IcmpDeadLetterQueue:
+			// the request is sent again after the address is resolved
+			time.Sleep(10 * time.Millisecond)
+			send(letter.Packet[icmp.HdrLen:])
+		case <-time.After(timeout):
+			t.Fatal("echo reply was not received")
+		}
+	}
+}
+
+// waitClaimed waits until the addresses of the interfaces are no longer tentative.
+func waitClaimed(ifaces ...*mw.Iface) bool {
+	var zero = time.Now()
+	for _, v := range ifaces {
+		for arp.Tentative(v) {
+			if time.Since(zero) > claimTimeout {
+				return false
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	return true
+}
@@ -8,6 +8,8 @@ var Time ITime
 
 type ITime interface {
 	Now() time.Time
+	// After returns the channel which receives the time when the duration elapses, and the function which stops it.
+	After(d time.Duration) (<-chan time.Time, func())
 }
 
 type timeProvider struct{}
@@ -16,6 +18,11 @@ func (timeProvider) Now() time.Time {
 	return time.Now()
 }
 
+func (timeProvider) After(d time.Duration) (<-chan time.Time, func()) {
+	timer := time.NewTimer(d)
+	return timer.C, func() { timer.Stop() }
+}
+
 func init() {
 	Time = &timeProvider{}
 }
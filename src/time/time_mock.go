@@ -34,6 +34,21 @@ func (m *MockITime) EXPECT() *MockITimeMockRecorder {
 	return m.recorder
 }
 
+// After mocks base method.
+func (m *MockITime) After(d time.Duration) (<-chan time.Time, func()) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "After", d)
+	ret0, _ := ret[0].(<-chan time.Time)
+	ret1, _ := ret[1].(func())
+	return ret0, ret1
+}
+
+// After indicates an expected call of After.
+func (mr *MockITimeMockRecorder) After(d interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "After", reflect.TypeOf((*MockITime)(nil).After), d)
+}
+
 // Now mocks base method.
 func (m *MockITime) Now() time.Time {
 	m.ctrl.T.Helper()